@@ -17,6 +17,9 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"strings"
+	"time"
+
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
@@ -26,29 +29,109 @@ import (
 
 // DevSpaceSpec defines the desired state of DevSpace
 type DevSpaceSpec struct {
-	// CPU is the CPU limit
-	// +kubebuilder:default:cpu="2"
+	// CPU is the CPU limit, it is 2 if neither the DevSpace nor its template gives it
 	CPU string `json:"cpu,omitempty"`
-	// Memory is the memory limit
-	// +kubebuilder:default:memory="4Gi"
+	// Memory is the memory limit, it is 4Gi if neither the DevSpace nor its template gives it
 	Memory string `json:"memory,omitempty"`
-	// Storage is the storage size
-	// +kubebuilder:default:storage="50Gi"
-	Storage                string `json:"storage,omitempty"`
-	Image                  string `json:"image,omitempty"`
-	ReadOnlyRootFilesystem bool   `json:"readOnlyRootFilesystem,omitempty"`
+	// Resources overrides the CPU and Memory, the requests are derived from the limits
+	// with the overcommit ratio of the config if they are not given
+	// +optional
+	Resources *v1.ResourceRequirements `json:"resources,omitempty"`
+	// Storage is the storage size, it is 50Gi if neither the DevSpace nor its template gives it
+	Storage string `json:"storage,omitempty"`
+	Image   string `json:"image,omitempty"`
+	// ReadOnlyRootFilesystem is a pointer like the other switches, so the DevSpace can turn off the one of its template
+	// +optional
+	ReadOnlyRootFilesystem *bool `json:"readOnlyRootFilesystem,omitempty"`
+	// SecurityProfile is what the containers are allowed to do, it defaults to the one of the config.
+	// The stricter one is taken if the config requires a stricter profile than this one.
+	// +optional
+	SecurityProfile SecurityProfile `json:"securityProfile,omitempty"`
 	// Replicas is the number of replicas
 	// +kubebuilder:default:replicas=1
 	Replicas *int32 `json:"replicas,omitempty"`
 	// Host is the hostname
-	Host        string            `json:"host,omitempty"`
-	Repository  *GitRepository    `json:"repository,omitempty"`
-	Auth        DevSpaceAuth      `json:"auth,omitempty"`
-	Environment map[string]string `json:"env,omitempty"`
-	HostAliases []v1.HostAlias    `json:"hostAliases,omitempty"`
-	Windows     []Window          `json:"windows,omitempty"`
-	InitScript  string            `json:"initScript,omitempty"`
-	Services    Services          `json:"services,omitempty"`
+	Host string `json:"host,omitempty"`
+	// Repository is cloned into the workspace, prefer Repositories.
+	// It is cloned before the ones in Repositories.
+	Repository *GitRepository `json:"repository,omitempty"`
+	// Repositories are cloned into the workspace by the init container,
+	// the ones from the template are merged by the URL
+	// +optional
+	// +patchMergeKey=url
+	// +patchStrategy=merge
+	// +listType=map
+	// +listMapKey=url
+	Repositories []GitRepository   `json:"repositories,omitempty" patchStrategy:"merge" patchMergeKey:"url"`
+	Auth         DevSpaceAuth      `json:"auth,omitempty"`
+	Environment  map[string]string `json:"env,omitempty"`
+	HostAliases  []v1.HostAlias    `json:"hostAliases,omitempty"`
+	Windows      []Window          `json:"windows,omitempty"`
+	InitScript   string            `json:"initScript,omitempty"`
+	Services     Services          `json:"services,omitempty"`
+	// IdleTimeout overrides the global idle timeout, the DevSpace will be suspended
+	// after being idle for this long. Zero disables the idle detection.
+	IdleTimeout *metav1.Duration `json:"idleTimeout,omitempty"`
+	// ScheduleOverride keeps the DevSpace alive, or turns it off, regardless of
+	// the alive windows until the given time. It is ignored once expired.
+	// +optional
+	ScheduleOverride *ScheduleOverride `json:"scheduleOverride,omitempty"`
+	// RestoreFrom is the name of a DevSpaceSnapshot in the same namespace to populate the storage from.
+	// Changing it on an existing DevSpace rolls the storage back, all the changes after the snapshot are lost.
+	// +optional
+	RestoreFrom string `json:"restoreFrom,omitempty"`
+	// TemplateRef points to the template which provides the default values of this spec
+	// +optional
+	TemplateRef *TemplateReference `json:"templateRef,omitempty"`
+	// Scheduling is where the pods of the DevSpace run, the missing fields come from
+	// the language of the image in the config, and then the global ones of the config
+	Scheduling `json:",inline"`
+	// NetworkPolicy overrides the network policy of the config
+	// +optional
+	NetworkPolicy *NetworkPolicy `json:"networkPolicy,omitempty"`
+}
+
+// NetworkPolicy isolates the pods of a DevSpace, only the ingress controllers and the kde apiserver can reach them
+type NetworkPolicy struct {
+	// Disabled renders no NetworkPolicy, the DevSpace is reachable from everywhere
+	// +optional
+	Disabled bool `json:"disabled,omitempty"`
+	// IngressNamespaces are the namespaces of the ingress controllers, they are the usual ones of
+	// the ingress provider and the Gateway namespace if neither the DevSpace nor the config gives them
+	// +optional
+	IngressNamespaces []string `json:"ingressNamespaces,omitempty"`
+	// Egress is where the DevSpace can reach besides the DNS and the kde apiserver,
+	// the egress is not restricted if it is nil
+	// +optional
+	Egress *NetworkEgress `json:"egress,omitempty"`
+}
+
+// NetworkEgress is the allowlist of the egress traffic
+type NetworkEgress struct {
+	// Internet allows the IPv4 addresses out of the private networks
+	// +optional
+	Internet bool `json:"internet,omitempty"`
+	// Namespaces are the names of the namespaces which can be reached
+	// +optional
+	Namespaces []string `json:"namespaces,omitempty"`
+	// CIDRs are the IP blocks which can be reached, e.g. 10.0.1.0/24
+	// +optional
+	CIDRs []string `json:"cidrs,omitempty"`
+}
+
+// Scheduling constrains the nodes which the pods run on, the fields are the same as the ones of a pod
+type Scheduling struct {
+	// +optional
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+	// +optional
+	Tolerations []v1.Toleration `json:"tolerations,omitempty"`
+	// Affinity of the pods, the soft anti-affinity between the replicas is kept if it has no pod anti-affinity
+	// +optional
+	Affinity *v1.Affinity `json:"affinity,omitempty"`
+	// +optional
+	PriorityClassName string `json:"priorityClassName,omitempty"`
+	// +optional
+	TopologySpreadConstraints []v1.TopologySpreadConstraint `json:"topologySpreadConstraints,omitempty"`
 }
 
 type Services struct {
@@ -59,10 +142,84 @@ type Services struct {
 	TDEngine *TDEngine `json:"tdEngine,omitempty"`
 	RabbitMQ *RabbitMQ `json:"rabbitMQ,omitempty"`
 	Redis    *Redis    `json:"redis,omitempty"`
+
+	// the services of the built-in catalog
+	MongoDB       *CatalogService `json:"mongodb,omitempty"`
+	Kafka         *CatalogService `json:"kafka,omitempty"`
+	Elasticsearch *CatalogService `json:"elasticsearch,omitempty"`
+	MinIO         *CatalogService `json:"minio,omitempty"`
+	Etcd          *CatalogService `json:"etcd,omitempty"`
+	Nginx         *CatalogService `json:"nginx,omitempty"`
+
+	// Custom are the user-defined services, they run as sidecars of the DevSpace
+	// +optional
+	Custom []CustomService `json:"custom,omitempty"`
+
+	// WaitForReady starts the services before the init container, so the init script runs once they are ready.
+	// The services run as the sidecar containers of Kubernetes, it requires Kubernetes 1.29 or later.
+	// +optional
+	WaitForReady *bool `json:"waitForReady,omitempty"`
+}
+
+// CatalogService enables a service of the built-in catalog
+type CatalogService struct {
+	Enabled *bool `json:"enabled,omitempty"`
+	// Image overrides the default image of the catalog
+	Image string `json:"image,omitempty"`
+	// Env is added to the default environment variables of the catalog, the one with the same name overrides the default one
+	// +optional
+	Env []v1.EnvVar `json:"env,omitempty"`
+	// Resources of the service container, the requests are derived from the limits
+	// with the overcommit ratio of the config if they are not given
+	// +optional
+	Resources *v1.ResourceRequirements `json:"resources,omitempty"`
+}
+
+// CustomService is a user-defined service which runs as a sidecar of the DevSpace
+type CustomService struct {
+	// Name is the name of the container, it must be unique among the services
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	// +kubebuilder:validation:MaxLength=63
+	Name  string `json:"name"`
+	Image string `json:"image"`
+	// +optional
+	Command []string `json:"command,omitempty"`
+	// +optional
+	Args []string `json:"args,omitempty"`
+	// +optional
+	Env []v1.EnvVar `json:"env,omitempty"`
+	// Ports are the ports which the service listens on, they are reachable via localhost in the DevSpace
+	// +optional
+	Ports []ServicePort `json:"ports,omitempty"`
+	// Persistence keeps the data of the service in the storage of the DevSpace
+	// +optional
+	Persistence *ServicePersistence `json:"persistence,omitempty"`
+	// +optional
+	ReadinessProbe *v1.Probe `json:"readinessProbe,omitempty"`
+	// Resources of the service container, the requests are derived from the limits
+	// with the overcommit ratio of the config if they are not given
+	// +optional
+	Resources *v1.ResourceRequirements `json:"resources,omitempty"`
+}
+
+type ServicePort struct {
+	// +optional
+	Name string `json:"name,omitempty"`
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	Port int32 `json:"port"`
+}
+
+// ServicePersistence mounts a sub path of the DevSpace storage into the service
+type ServicePersistence struct {
+	MountPath string `json:"mountPath"`
+	// SubPath is the path in the storage, it is services/<name> by default
+	// +optional
+	SubPath string `json:"subPath,omitempty"`
 }
 
 type Docker struct {
-	Enabled            bool     `json:"enabled,omitempty"`
+	Enabled            *bool    `json:"enabled,omitempty"`
 	Image              string   `json:"image,omitempty"`
 	InsecureRegistries []string `json:"insecureRegistries,omitempty"`
 	RegistryMirrors    []string `json:"registryMirrors,omitempty"`
@@ -70,54 +227,233 @@ type Docker struct {
 	MaxConcurrentUploads int `json:"maxConcurrentUploads,omitempty"`
 	// +kubebuilder:default:maxConcurrentDownloads=2
 	MaxConcurrentDownloads int `json:"maxConcurrentDownloads,omitempty"`
+	// Resources of the service container, the requests are derived from the limits
+	// with the overcommit ratio of the config if they are not given
+	// +optional
+	Resources *v1.ResourceRequirements `json:"resources,omitempty"`
 }
 
 type MySQL struct {
-	Enabled  bool   `json:"enabled,omitempty"`
+	Enabled  *bool  `json:"enabled,omitempty"`
 	Username string `json:"username,omitempty"`
+	// Password is stored in plaintext, prefer PasswordSecretRef.
+	// The controller moves it into the credentials Secret of the DevSpace.
 	Password string `json:"password,omitempty"`
-	Database string `json:"database,omitempty"`
-	Image    string `json:"image,omitempty"`
+	// PasswordSecretRef refers to the password in a Secret of the same namespace,
+	// a random one is generated into the credentials Secret of the DevSpace if neither it nor Password is given
+	// +optional
+	PasswordSecretRef *v1.SecretKeySelector `json:"passwordSecretRef,omitempty"`
+	Database          string                `json:"database,omitempty"`
+	Image             string                `json:"image,omitempty"`
+	// Resources of the service container, the requests are derived from the limits
+	// with the overcommit ratio of the config if they are not given
+	// +optional
+	Resources *v1.ResourceRequirements `json:"resources,omitempty"`
 }
 
 type MySQLUI struct {
-	Enabled bool   `json:"enabled,omitempty"`
+	Enabled *bool  `json:"enabled,omitempty"`
 	Image   string `json:"image,omitempty"`
+	// Resources of the service container, the requests are derived from the limits
+	// with the overcommit ratio of the config if they are not given
+	// +optional
+	Resources *v1.ResourceRequirements `json:"resources,omitempty"`
 }
 
 type Postgres struct {
-	Enabled  bool   `json:"enabled,omitempty"`
+	Enabled  *bool  `json:"enabled,omitempty"`
 	Username string `json:"username,omitempty"`
+	// Password is stored in plaintext, prefer PasswordSecretRef.
+	// The controller moves it into the credentials Secret of the DevSpace.
 	Password string `json:"password,omitempty"`
-	Database string `json:"database,omitempty"`
-	Image    string `json:"image,omitempty"`
+	// PasswordSecretRef refers to the password in a Secret of the same namespace,
+	// a random one is generated into the credentials Secret of the DevSpace if neither it nor Password is given
+	// +optional
+	PasswordSecretRef *v1.SecretKeySelector `json:"passwordSecretRef,omitempty"`
+	Database          string                `json:"database,omitempty"`
+	Image             string                `json:"image,omitempty"`
+	// Resources of the service container, the requests are derived from the limits
+	// with the overcommit ratio of the config if they are not given
+	// +optional
+	Resources *v1.ResourceRequirements `json:"resources,omitempty"`
 }
 
 type RabbitMQ struct {
-	Enabled  bool   `json:"enabled,omitempty"`
+	Enabled  *bool  `json:"enabled,omitempty"`
 	Username string `json:"username,omitempty"`
+	// Password is stored in plaintext, prefer PasswordSecretRef.
+	// The controller moves it into the credentials Secret of the DevSpace.
 	Password string `json:"password,omitempty"`
-	Image    string `json:"image,omitempty"`
+	// PasswordSecretRef refers to the password in a Secret of the same namespace,
+	// a random one is generated into the credentials Secret of the DevSpace if neither it nor Password is given
+	// +optional
+	PasswordSecretRef *v1.SecretKeySelector `json:"passwordSecretRef,omitempty"`
+	Image             string                `json:"image,omitempty"`
+	// Resources of the service container, the requests are derived from the limits
+	// with the overcommit ratio of the config if they are not given
+	// +optional
+	Resources *v1.ResourceRequirements `json:"resources,omitempty"`
 }
 
 type TDEngine struct {
-	Enabled bool   `json:"enabled,omitempty"`
+	Enabled *bool  `json:"enabled,omitempty"`
 	Image   string `json:"image,omitempty"`
+	// Resources of the service container, the requests are derived from the limits
+	// with the overcommit ratio of the config if they are not given
+	// +optional
+	Resources *v1.ResourceRequirements `json:"resources,omitempty"`
 }
 
 type Redis struct {
-	Enabled bool   `json:"enabled,omitempty"`
+	Enabled *bool  `json:"enabled,omitempty"`
 	Image   string `json:"image,omitempty"`
+	// Resources of the service container, the requests are derived from the limits
+	// with the overcommit ratio of the config if they are not given
+	// +optional
+	Resources *v1.ResourceRequirements `json:"resources,omitempty"`
 }
 
+// The switches are pointers, so a DevSpace can turn off the ones which its template turns on.
+// The following methods tell whether they are on, the services can be nil.
+
+// IsReadOnlyRootFilesystem returns true if the root filesystems of the containers are read-only
+func (s *DevSpaceSpec) IsReadOnlyRootFilesystem() bool { return isOn(s.ReadOnlyRootFilesystem) }
+
+// ShouldWaitForReady returns true if the services start before the init container
+func (s *Services) ShouldWaitForReady() bool { return isOn(s.WaitForReady) }
+
+// IsEnabled returns true if the service is given and enabled
+func (s *CatalogService) IsEnabled() bool { return s != nil && isOn(s.Enabled) }
+
+// IsEnabled returns true if the service is given and enabled
+func (s *Docker) IsEnabled() bool { return s != nil && isOn(s.Enabled) }
+
+// IsEnabled returns true if the service is given and enabled
+func (s *MySQL) IsEnabled() bool { return s != nil && isOn(s.Enabled) }
+
+// IsEnabled returns true if the service is given and enabled
+func (s *MySQLUI) IsEnabled() bool { return s != nil && isOn(s.Enabled) }
+
+// IsEnabled returns true if the service is given and enabled
+func (s *Postgres) IsEnabled() bool { return s != nil && isOn(s.Enabled) }
+
+// IsEnabled returns true if the service is given and enabled
+func (s *RabbitMQ) IsEnabled() bool { return s != nil && isOn(s.Enabled) }
+
+// IsEnabled returns true if the service is given and enabled
+func (s *TDEngine) IsEnabled() bool { return s != nil && isOn(s.Enabled) }
+
+// IsEnabled returns true if the service is given and enabled
+func (s *Redis) IsEnabled() bool { return s != nil && isOn(s.Enabled) }
+
+func isOn(value *bool) bool {
+	return value != nil && *value
+}
+
+// Window is a time range in which the DevSpace is alive.
+// The window crosses midnight if To is not after From, e.g. 22:00 - 06:00.
 type Window struct {
+	// From is the start time in the format of 15:04 or 15:04:05
 	From string `json:"from"`
-	To   string `json:"to"`
+	// To is the end time in the format of 15:04 or 15:04:05
+	To string `json:"to"`
+	// Days are the days of week on which the window starts, empty means every day
+	// +optional
+	Days []Weekday `json:"days,omitempty"`
+	// TimeZone is an IANA time zone name (e.g. Europe/Berlin),
+	// the local time zone of the controller is used if it is empty
+	// +optional
+	TimeZone string `json:"timeZone,omitempty"`
 }
 
+// ScheduleOverride temporarily overrides the alive windows and the idle detection
+type ScheduleOverride struct {
+	Mode ScheduleOverrideMode `json:"mode"`
+	// Until is the time when the override expires
+	Until metav1.Time `json:"until"`
+}
+
+// ScheduleOverrideMode is the mode of a schedule override
+// +kubebuilder:validation:Enum=KeepAlive;Off
+type ScheduleOverrideMode string
+
+const (
+	// ScheduleOverrideKeepAlive keeps the DevSpace alive even if it is out of the windows or idle
+	ScheduleOverrideKeepAlive ScheduleOverrideMode = "KeepAlive"
+	// ScheduleOverrideOff turns the DevSpace off even if it is in the windows
+	ScheduleOverrideOff ScheduleOverrideMode = "Off"
+)
+
+// IsActive returns true if the override is not expired at the given time
+func (o *ScheduleOverride) IsActive(now time.Time) bool {
+	return o != nil && now.Before(o.Until.Time)
+}
+
+// SecurityProfile is what the containers of a DevSpace are allowed to do,
+// they follow the Pod Security Standards of the same names
+// +kubebuilder:validation:Enum=privileged;baseline;restricted
+type SecurityProfile string
+
+const (
+	// SecurityProfilePrivileged runs the IDE and the init container as root in the privileged mode,
+	// it is taken when the profile is empty
+	SecurityProfilePrivileged SecurityProfile = "privileged"
+	// SecurityProfileBaseline runs no privileged containers, Docker is not supported
+	SecurityProfileBaseline SecurityProfile = "baseline"
+	// SecurityProfileRestricted runs all the containers as a non-root user with the RuntimeDefault seccomp profile,
+	// Docker is not supported
+	SecurityProfileRestricted SecurityProfile = "restricted"
+)
+
+// level returns how strict the profile is, an unknown profile is as strict as the privileged one
+func (p SecurityProfile) level() int {
+	switch p {
+	case SecurityProfileBaseline:
+		return 1
+	case SecurityProfileRestricted:
+		return 2
+	default:
+		return 0
+	}
+}
+
+// IsStricterThan returns true if the profile allows less than the given one
+func (p SecurityProfile) IsStricterThan(another SecurityProfile) bool {
+	return p.level() > another.level()
+}
+
+// Stricter returns the stricter one of the two profiles, the privileged one is returned if both are empty
+func (p SecurityProfile) Stricter(another SecurityProfile) SecurityProfile {
+	if another.IsStricterThan(p) {
+		p = another
+	}
+	if p == "" {
+		p = SecurityProfilePrivileged
+	}
+	return p
+}
+
+// Weekday is the short name of a day of week
+// +kubebuilder:validation:Enum=Mon;Tue;Wed;Thu;Fri;Sat;Sun
+type Weekday string
+
 type DevSpaceAuth struct {
-	BasicAuth     *BasicAuth `json:"basicAuth,omitempty"`
-	SSHPrivateKey string     `json:"sshPrivateKey,omitempty"`
+	BasicAuth *BasicAuth `json:"basicAuth,omitempty"`
+	// SSHPrivateKey is used by git over SSH, it is stored in plaintext, prefer SSHPrivateKeySecretRef.
+	// The controller moves it into the credentials Secret of the DevSpace.
+	SSHPrivateKey string `json:"sshPrivateKey,omitempty"`
+	// SSHPrivateKeySecretRef refers to the private key in a Secret of the same namespace,
+	// it is used by git over SSH in both the init container and the IDE
+	// +optional
+	SSHPrivateKeySecretRef *v1.SecretKeySelector `json:"sshPrivateKeySecretRef,omitempty"`
+	// KnownHosts are the lines of known_hosts in addition to the ones from the global config.
+	// The host keys are checked strictly if there is any, otherwise a new host is trusted on the first connection.
+	// +optional
+	KnownHosts []string `json:"knownHosts,omitempty"`
+	// Collaborators are the users who may open the DevSpace besides the owner when the forward auth is enabled,
+	// a user is given by the username or the email of the OAuth provider of the kde apiserver
+	// +optional
+	Collaborators []string `json:"collaborators,omitempty"`
 }
 
 type BasicAuth struct {
@@ -127,14 +463,59 @@ type BasicAuth struct {
 
 type GitRepository struct {
 	URL string `json:"url"`
-	// Branch is the branch
-	// +kubebuilder:default:branch="master"
+	// Branch is the branch to check out, it is the default branch of the remote repository if not given
+	// +optional
 	Branch string `json:"branch,omitempty"`
-	// Username is the username
-	Username string `json:"username"`
-	// Password is the password
+	// Tag is the tag to check out, it cannot be given along with the branch
+	// +optional
+	Tag string `json:"tag,omitempty"`
+	// Commit is checked out after cloning, the full history is fetched in this case so it cannot be given along with the depth
+	// +optional
+	Commit string `json:"commit,omitempty"`
+	// Directory is the target path relative to the workspace, it is the name of the repository by default
+	// +optional
+	Directory string `json:"directory,omitempty"`
+	// Depth is the number of commits to fetch, zero means the full history. It is 1 by default unless the commit is given
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	Depth *int32 `json:"depth,omitempty"`
+	// Submodules tells if the submodules should be cloned recursively
+	// +optional
+	Submodules bool `json:"submodules,omitempty"`
+	// Username is the username, it is set as the git user of the repository as well
+	// +optional
+	Username string `json:"username,omitempty"`
+	// Password is the password or the token of the git server, it is stored in plaintext, prefer PasswordSecretRef.
+	// The controller moves it into the credentials Secret of the DevSpace.
 	Password string `json:"password,omitempty"`
-	Email    string `json:"email,omitempty"`
+	// PasswordSecretRef refers to the password or the token in a Secret of the same namespace
+	// +optional
+	PasswordSecretRef *v1.SecretKeySelector `json:"passwordSecretRef,omitempty"`
+	Email             string                `json:"email,omitempty"`
+}
+
+// TargetDirectory returns the directory to clone into, relative to the workspace
+func (r GitRepository) TargetDirectory() string {
+	if r.Directory != "" {
+		return strings.Trim(r.Directory, "/")
+	}
+	name := strings.TrimSuffix(strings.TrimRight(r.URL, "/"), ".git")
+	if index := strings.LastIndexAny(name, "/:"); index >= 0 {
+		name = name[index+1:]
+	}
+	return name
+}
+
+// CloneDepth returns the depth of cloning, zero means the full history
+func (r GitRepository) CloneDepth() int32 {
+	switch {
+	case r.Depth != nil:
+		return *r.Depth
+	case r.Commit != "":
+		return 0
+	default:
+		return 1
+	}
 }
 
 // DevSpaceStatus defines the observed state of DevSpace
@@ -144,13 +525,122 @@ type DevSpaceStatus struct {
 	DeployStatus string                    `json:"deployStatus,omitempty"`
 	Pods         []v1.LocalObjectReference `json:"pods,omitempty"`
 	Phase        DevSpacePhase             `json:"phase,omitempty"`
+	// ObservedGeneration is the most recent generation observed by the controller
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// LastActivityTime is the last time the IDE or the ingress reported any activity
+	LastActivityTime *metav1.Time `json:"lastActivityTime,omitempty"`
+	// SuspendReason tells why the DevSpace is off, it is empty when the DevSpace is on
+	SuspendReason DevSpaceSuspendReason `json:"suspendReason,omitempty"`
+	// ScheduleOverride is the schedule override in effect, it is empty once expired
+	ScheduleOverride *ScheduleOverride `json:"scheduleOverride,omitempty"`
+	// Restore is the snapshot which the storage was restored from
+	Restore *DevSpaceRestoreStatus `json:"restore,omitempty"`
+	// Template is the template which the DevSpace is rendered with
+	Template *AppliedTemplate `json:"template,omitempty"`
+	// Services are the status of the enabled services
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	Services []ServiceStatus `json:"services,omitempty"`
+	// Inventory is the child resources applied by the controller,
+	// the ones which are not rendered anymore are deleted
+	// +optional
+	Inventory []InventoryEntry `json:"inventory,omitempty"`
+	// Conditions tell why a DevSpace is (not) usable
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// ServiceStatus tells whether a service of the DevSpace is ready and how to connect to it
+type ServiceStatus struct {
+	// Name is the name of the service container
+	Name string `json:"name"`
+	// Ready is true once the readiness probe of the service passes
+	Ready bool `json:"ready"`
+	// RestartCount is the restart count of the service container
+	RestartCount int32 `json:"restartCount"`
+	// Endpoints are the host:port addresses of the service which are reachable from the IDE container
+	// +optional
+	Endpoints []string `json:"endpoints,omitempty"`
+	// CredentialsSecret is the name of the Secret which holds the credentials of the service
+	// +optional
+	CredentialsSecret string `json:"credentialsSecret,omitempty"`
+}
+
+// InventoryEntry is a child resource in the namespace of the DevSpace
+type InventoryEntry struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+}
+
+// DevSpaceRestoreStatus records the snapshot which the storage was populated from
+type DevSpaceRestoreStatus struct {
+	// Snapshot is the name of the DevSpaceSnapshot
+	Snapshot string `json:"snapshot"`
+	// VolumeSnapshotName is the name of the VolumeSnapshot used as the data source of the storage
+	VolumeSnapshotName string `json:"volumeSnapshotName"`
+	// RestoreTime is the time when the storage was restored
+	RestoreTime metav1.Time `json:"restoreTime"`
 }
 
+// DevSpacePhase is the lifecycle phase of a DevSpace
+// +kubebuilder:validation:Enum=Pending;Provisioning;Starting;Running;Stopping;Stopped;Failed;Deleting
 type DevSpacePhase string
 
 const (
-	DevSpacePhaseReady DevSpacePhase = "Ready"
-	DevSpacePhaseOff   DevSpacePhase = "Off"
+	// DevSpacePhasePending means nothing has been deployed yet
+	DevSpacePhasePending DevSpacePhase = "Pending"
+	// DevSpacePhaseProvisioning means the storage or the workload is being created
+	DevSpacePhaseProvisioning DevSpacePhase = "Provisioning"
+	// DevSpacePhaseStarting means the pods are created but not available yet
+	DevSpacePhaseStarting DevSpacePhase = "Starting"
+	// DevSpacePhaseRunning means the IDE is available
+	DevSpacePhaseRunning DevSpacePhase = "Running"
+	// DevSpacePhaseStopping means the DevSpace should be off, but some pods are still alive
+	DevSpacePhaseStopping DevSpacePhase = "Stopping"
+	// DevSpacePhaseStopped means the DevSpace is off and all the pods are gone
+	DevSpacePhaseStopped DevSpacePhase = "Stopped"
+	// DevSpacePhaseFailed means the DevSpace cannot start without manual intervention
+	DevSpacePhaseFailed DevSpacePhase = "Failed"
+	// DevSpacePhaseDeleting means the DevSpace is being deleted
+	DevSpacePhaseDeleting DevSpacePhase = "Deleting"
+)
+
+// IsOff returns true if the DevSpace should not have any running pods
+func (p DevSpacePhase) IsOff() bool {
+	return p == DevSpacePhaseStopping || p == DevSpacePhaseStopped
+}
+
+// DevSpaceSuspendReason is the reason why a DevSpace is off
+type DevSpaceSuspendReason string
+
+const (
+	// DevSpaceSuspendReasonManual means the replicas is set to zero
+	DevSpaceSuspendReasonManual DevSpaceSuspendReason = "Manual"
+	// DevSpaceSuspendReasonOutOfWindow means the current time is not in any alive windows
+	DevSpaceSuspendReasonOutOfWindow DevSpaceSuspendReason = "OutOfWindow"
+	// DevSpaceSuspendReasonIdle means there is no activity within the idle timeout
+	DevSpaceSuspendReasonIdle DevSpaceSuspendReason = "Idle"
+	// DevSpaceSuspendReasonScheduleOverride means the DevSpace is turned off early by a schedule override
+	DevSpaceSuspendReasonScheduleOverride DevSpaceSuspendReason = "ScheduleOverride"
+	// DevSpaceSuspendReasonRestoring means the storage is being rolled back to a snapshot
+	DevSpaceSuspendReasonRestoring DevSpaceSuspendReason = "Restoring"
+)
+
+const (
+	// DevSpaceConditionStorageBound tells if the PVC is bound
+	DevSpaceConditionStorageBound = "StorageBound"
+	// DevSpaceConditionWorkloadAvailable tells if the Deployment has available replicas
+	DevSpaceConditionWorkloadAvailable = "WorkloadAvailable"
+	// DevSpaceConditionIngressReady tells if the Ingress got an address from the ingress controller
+	DevSpaceConditionIngressReady = "IngressReady"
+	// DevSpaceConditionServicesReady tells if all the built-in services (MySQL, Redis, etc.) are ready
+	DevSpaceConditionServicesReady = "ServicesReady"
+	// DevSpaceConditionRepositoryCloned tells if all the git repositories were cloned by the init container
+	DevSpaceConditionRepositoryCloned = "RepositoryCloned"
 )
 
 type ExposeLink struct {
@@ -166,6 +656,7 @@ type ExposeLink struct {
 // +kubebuilder:printcolumn:name="Replicas",type=integer,JSONPath=`.spec.replicas`
 // +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
 // +kubebuilder:subresource:status
+// +kubebuilder:storageversion
 
 // DevSpace is the Schema for the devspaces API
 type DevSpace struct {
@@ -192,13 +683,58 @@ const (
 	AnnoKeyStorageClassName = "linuxsuren.github.io/storage-class-name"
 	AnnoKeyVolumeAccessMode = "linuxsuren.github.io/volume-access-mode"
 	AnnoKeyVolumeMode       = "linuxsuren.github.io/volume-mode"
+	AnnoKeyStorageTemporary = "storageTemporary"
 	AnnoKeyIngressMode      = "linuxsuren.github.io/ingress-mode"
 	AnnoKeyBasicAuth        = "linuxsuren.github.io/basic-auth"
 	AnnoKeyMaintainMode     = "linuxsuren.github.io/maintain-mode"
 	AnnoKeyServiceName      = "linuxsuren.github.io/service-name"
 	AnnoKeyServiceNamespace = "linuxsuren.github.io/service-namespace"
+	// AnnoKeyIngressProvider is the ingress provider which routes the requests to the DevSpace, it comes from the config
+	AnnoKeyIngressProvider = "linuxsuren.github.io/ingress-provider"
+	// AnnoKeyGatewayName and AnnoKeyGatewayNamespace are the parent Gateway of the HTTPRoutes of the gateway provider
+	AnnoKeyGatewayName      = "linuxsuren.github.io/gateway-name"
+	AnnoKeyGatewayNamespace = "linuxsuren.github.io/gateway-namespace"
+	// AnnoKeyTLSSecret is the copy of the wildcard certificate Secret which the ingresses of the DevSpace serve
+	AnnoKeyTLSSecret = "linuxsuren.github.io/tls-secret"
+	// AnnoKeyTLSIssuer and AnnoKeyTLSClusterIssuer are the cert-manager issuers of the certificates of the DevSpace
+	AnnoKeyTLSIssuer        = "linuxsuren.github.io/tls-issuer"
+	AnnoKeyTLSClusterIssuer = "linuxsuren.github.io/tls-cluster-issuer"
+	// AnnoKeyTLSDefaultCertificate tells the ingresses to have the TLS hosts without a Secret,
+	// the ingress controller or the Gateway serves its own certificate
+	AnnoKeyTLSDefaultCertificate = "linuxsuren.github.io/tls-default-certificate"
+	// AnnoKeyAuthSignIn is the sign-in page of the forward auth, the ingresses ask the kde apiserver
+	// who may open the DevSpace if it is set
+	AnnoKeyAuthSignIn = "linuxsuren.github.io/auth-signin"
+	// AnnoKeyActivityToken tells the ingress template the activity token, it is not persisted
+	AnnoKeyActivityToken = "linuxsuren.github.io/activity-token"
+	// AnnoKeyOwner is the user who created the DevSpace through the kde apiserver
+	AnnoKeyOwner = "linuxsuren.github.io/owner"
+	// AnnoKeyCloneFrom is the source DevSpace in the format of namespace/name,
+	// the storage is provisioned as a clone of the source storage
+	AnnoKeyCloneFrom = "linuxsuren.github.io/clone-from"
 )
 
+// the keys of the credentials Secret of a DevSpace
+const (
+	CredentialKeyMySQL    = "mysql-password"
+	CredentialKeyPostgres = "postgres-password"
+	CredentialKeyRabbitMQ = "rabbitmq-password"
+	CredentialKeyGit      = "git-password"
+	// CredentialKeySSHPrivateKey is the same key as the Secret of type kubernetes.io/ssh-auth
+	CredentialKeySSHPrivateKey = "ssh-privatekey"
+	// CredentialKeyActivityToken authenticates the mirrored ingress traffic, it allows recording the activity
+	// and waking up the DevSpace only, it grants no access to the IDE. It is in the Ingresses, Middlewares and
+	// HTTPRoutes of the DevSpace as well, so whoever may read them in the namespace could keep the DevSpace awake.
+	// Deleting it from the credentials Secret rotates it.
+	CredentialKeyActivityToken = "activity-token"
+)
+
+// CredentialsSecretName returns the name of the Secret which holds the credentials of the given DevSpace,
+// it is owned by the DevSpace
+func CredentialsSecretName(devSpace string) string {
+	return devSpace + "-credentials"
+}
+
 func init() {
 	SchemeBuilder.Register(&DevSpace{}, &DevSpaceList{})
 }
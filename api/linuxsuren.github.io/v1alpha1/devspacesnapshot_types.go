@@ -0,0 +1,77 @@
+/*
+Copyright 2024 kde authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DevSpaceSnapshotSpec defines the desired state of DevSpaceSnapshot
+type DevSpaceSnapshotSpec struct {
+	// DevSpace is the name of the DevSpace in the same namespace to take the snapshot from
+	// +kubebuilder:validation:XValidation:rule="self == oldSelf",message="devSpace is immutable"
+	DevSpace string `json:"devSpace"`
+	// VolumeSnapshotClassName is the class of the VolumeSnapshot, the default class is used if it is empty
+	// +optional
+	VolumeSnapshotClassName string `json:"volumeSnapshotClassName,omitempty"`
+}
+
+// DevSpaceSnapshotStatus defines the observed state of DevSpaceSnapshot
+type DevSpaceSnapshotStatus struct {
+	// VolumeSnapshotName is the name of the CSI VolumeSnapshot of the DevSpace storage
+	VolumeSnapshotName string `json:"volumeSnapshotName,omitempty"`
+	// ReadyToUse tells if the snapshot can be used to restore a DevSpace
+	ReadyToUse bool `json:"readyToUse,omitempty"`
+	// RestoreSize is the minimum size of the storage to restore the snapshot
+	RestoreSize *resource.Quantity `json:"restoreSize,omitempty"`
+	// SourceGeneration is the generation of the DevSpace when the snapshot was taken
+	SourceGeneration int64 `json:"sourceGeneration,omitempty"`
+	// CreationTime is the time when the snapshot was taken by the storage system
+	CreationTime *metav1.Time `json:"creationTime,omitempty"`
+	// Error is the last error of taking the snapshot
+	Error string `json:"error,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="DevSpace",type=string,JSONPath=`.spec.devSpace`
+// +kubebuilder:printcolumn:name="Ready",type=boolean,JSONPath=`.status.readyToUse`
+// +kubebuilder:printcolumn:name="Size",type=string,JSONPath=`.status.restoreSize`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// DevSpaceSnapshot is the Schema for the devspacesnapshots API
+type DevSpaceSnapshot struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   DevSpaceSnapshotSpec   `json:"spec,omitempty"`
+	Status DevSpaceSnapshotStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// DevSpaceSnapshotList contains a list of DevSpaceSnapshot
+type DevSpaceSnapshotList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []DevSpaceSnapshot `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&DevSpaceSnapshot{}, &DevSpaceSnapshotList{})
+}
@@ -0,0 +1,129 @@
+/*
+Copyright 2024 kde authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DevSpaceTemplateSpec defines the desired state of DevSpaceTemplate
+type DevSpaceTemplateSpec struct {
+	// Description tells what the template is for, e.g. Java backend with MySQL and RabbitMQ
+	Description string `json:"description,omitempty"`
+	// UpdatePolicy decides when the changes of the template are applied to the DevSpaces
+	// +kubebuilder:default=Auto
+	UpdatePolicy TemplateUpdatePolicy `json:"updatePolicy,omitempty"`
+	// Template holds the default spec of the DevSpaces, the fields given by a DevSpace take precedence
+	Template DevSpaceSpec `json:"template,omitempty"`
+}
+
+// TemplateUpdatePolicy decides when the changes of a template are applied to the DevSpaces
+// +kubebuilder:validation:Enum=Auto;OnRestart;Never
+type TemplateUpdatePolicy string
+
+const (
+	// TemplateUpdatePolicyAuto applies the changes immediately
+	TemplateUpdatePolicyAuto TemplateUpdatePolicy = "Auto"
+	// TemplateUpdatePolicyOnRestart applies the changes next time the DevSpace starts
+	TemplateUpdatePolicyOnRestart TemplateUpdatePolicy = "OnRestart"
+	// TemplateUpdatePolicyNever keeps the template as it was when the DevSpace got it
+	TemplateUpdatePolicyNever TemplateUpdatePolicy = "Never"
+)
+
+// kinds of the DevSpace templates
+const (
+	KindDevSpaceTemplate        = "DevSpaceTemplate"
+	KindClusterDevSpaceTemplate = "ClusterDevSpaceTemplate"
+)
+
+// TemplateReference points to a DevSpaceTemplate in the same namespace, or a ClusterDevSpaceTemplate
+type TemplateReference struct {
+	// Kind is the kind of the template
+	// +kubebuilder:validation:Enum=DevSpaceTemplate;ClusterDevSpaceTemplate
+	// +kubebuilder:default=DevSpaceTemplate
+	Kind string `json:"kind,omitempty"`
+	// Name is the name of the template
+	Name string `json:"name"`
+	// UpdatePolicy overrides the update policy of the template
+	// +optional
+	UpdatePolicy TemplateUpdatePolicy `json:"updatePolicy,omitempty"`
+}
+
+// AppliedTemplate is the template which a DevSpace is rendered with,
+// its spec is kept in the ConfigMap named by AppliedTemplateConfigMapName
+type AppliedTemplate struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+	// Generation is the generation of the template when it was applied
+	Generation int64 `json:"generation"`
+}
+
+// AppliedTemplateKey is the key of the template spec in the ConfigMap of the applied template
+const AppliedTemplateKey = "spec"
+
+// AppliedTemplateConfigMapName returns the name of the ConfigMap which holds the spec of the template
+// applied to the given DevSpace, it is owned by the DevSpace
+func AppliedTemplateConfigMapName(devSpace string) string {
+	return devSpace + "-template"
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:printcolumn:name="Description",type=string,JSONPath=`.spec.description`
+// +kubebuilder:printcolumn:name="UpdatePolicy",type=string,JSONPath=`.spec.updatePolicy`
+
+// DevSpaceTemplate is the Schema for the devspacetemplates API
+type DevSpaceTemplate struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec DevSpaceTemplateSpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// DevSpaceTemplateList contains a list of DevSpaceTemplate
+type DevSpaceTemplateList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []DevSpaceTemplate `json:"items"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:printcolumn:name="Description",type=string,JSONPath=`.spec.description`
+// +kubebuilder:printcolumn:name="UpdatePolicy",type=string,JSONPath=`.spec.updatePolicy`
+
+// ClusterDevSpaceTemplate is the Schema for the clusterdevspacetemplates API, it is available in all namespaces
+type ClusterDevSpaceTemplate struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec DevSpaceTemplateSpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ClusterDevSpaceTemplateList contains a list of ClusterDevSpaceTemplate
+type ClusterDevSpaceTemplateList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ClusterDevSpaceTemplate `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&DevSpaceTemplate{}, &DevSpaceTemplateList{}, &ClusterDevSpaceTemplate{}, &ClusterDevSpaceTemplateList{})
+}
@@ -0,0 +1,185 @@
+/*
+Copyright 2024 kde authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/linuxsuren/kde/api/linuxsuren.github.io/v1alpha1"
+	v1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+)
+
+// AnnoKeyConvertedIngressMode keeps the v1alpha1 ingress mode which is not typed in this version, e.g. nginx.
+// It is restored when converting back unless the ingress mode was changed.
+const AnnoKeyConvertedIngressMode = "conversion.linuxsuren.github.io/ingress-mode"
+
+// ConvertTo converts this DevSpace to the hub version (v1alpha1).
+// The typed fields are stored as the annotations of v1alpha1.
+func (src *DevSpace) ConvertTo(dstRaw conversion.Hub) error {
+	dst := dstRaw.(*v1alpha1.DevSpace)
+	dst.ObjectMeta = *src.ObjectMeta.DeepCopy()
+	dst.Status = *src.Status.DeepCopy()
+
+	spec := src.Spec.DeepCopy()
+	dst.Spec = v1alpha1.DevSpaceSpec{
+		CPU:                    spec.CPU,
+		Memory:                 spec.Memory,
+		Resources:              spec.Resources,
+		Storage:                spec.Storage.Size,
+		Image:                  spec.Image,
+		ReadOnlyRootFilesystem: spec.ReadOnlyRootFilesystem,
+		SecurityProfile:        spec.SecurityProfile,
+		Replicas:               spec.Replicas,
+		Host:                   spec.Host,
+		Repository:             spec.Repository,
+		Repositories:           spec.Repositories,
+		Auth:                   spec.Auth,
+		Environment:            spec.Environment,
+		HostAliases:            spec.HostAliases,
+		Windows:                spec.Windows,
+		InitScript:             spec.InitScript,
+		Services:               spec.Services,
+		IdleTimeout:            spec.IdleTimeout,
+		ScheduleOverride:       spec.ScheduleOverride,
+		RestoreFrom:            spec.RestoreFrom,
+		TemplateRef:            spec.TemplateRef,
+		Scheduling:             spec.Scheduling,
+		NetworkPolicy:          spec.Networking.Policy,
+	}
+
+	var temporary string
+	if spec.Storage.Temporary {
+		temporary = "true"
+	}
+	ports := make([]string, 0, len(spec.Networking.ExposedPorts))
+	for _, port := range spec.Networking.ExposedPorts {
+		ports = append(ports, strconv.Itoa(int(port)))
+	}
+
+	ingressMode := string(spec.Ingress.Mode)
+	if converted, ok := dst.Annotations[AnnoKeyConvertedIngressMode]; ok {
+		if ingressModeOf(converted) == spec.Ingress.Mode {
+			ingressMode = converted
+		}
+		delete(dst.Annotations, AnnoKeyConvertedIngressMode)
+		if len(dst.Annotations) == 0 {
+			dst.Annotations = nil
+		}
+	}
+
+	annotations := map[string]string{
+		v1alpha1.AnnoKeyImagePullPolicy:  string(spec.ImagePullPolicy),
+		v1alpha1.AnnoKeyStorageClassName: spec.Storage.StorageClassName,
+		v1alpha1.AnnoKeyVolumeAccessMode: string(spec.Storage.AccessMode),
+		v1alpha1.AnnoKeyVolumeMode:       string(spec.Storage.VolumeMode),
+		v1alpha1.AnnoKeyStorageTemporary: temporary,
+		v1alpha1.AnnoKeyExposePorts:      strings.Join(ports, ","),
+		v1alpha1.AnnoKeyIngressMode:      ingressMode,
+	}
+	for key, value := range annotations {
+		if value == "" {
+			continue
+		}
+		if dst.Annotations == nil {
+			dst.Annotations = map[string]string{}
+		}
+		dst.Annotations[key] = value
+	}
+	return nil
+}
+
+// ConvertFrom converts from the hub version (v1alpha1) to this version.
+// The annotations which are typed fields in this version are moved out of the metadata.
+func (dst *DevSpace) ConvertFrom(srcRaw conversion.Hub) error {
+	src := srcRaw.(*v1alpha1.DevSpace)
+	dst.ObjectMeta = *src.ObjectMeta.DeepCopy()
+	dst.Status = *src.Status.DeepCopy()
+
+	spec := src.Spec.DeepCopy()
+	dst.Spec = DevSpaceSpec{
+		CPU:                    spec.CPU,
+		Memory:                 spec.Memory,
+		Resources:              spec.Resources,
+		Image:                  spec.Image,
+		ReadOnlyRootFilesystem: spec.ReadOnlyRootFilesystem,
+		SecurityProfile:        spec.SecurityProfile,
+		Replicas:               spec.Replicas,
+		Host:                   spec.Host,
+		Repository:             spec.Repository,
+		Repositories:           spec.Repositories,
+		Auth:                   spec.Auth,
+		Environment:            spec.Environment,
+		HostAliases:            spec.HostAliases,
+		Windows:                spec.Windows,
+		InitScript:             spec.InitScript,
+		Services:               spec.Services,
+		IdleTimeout:            spec.IdleTimeout,
+		ScheduleOverride:       spec.ScheduleOverride,
+		RestoreFrom:            spec.RestoreFrom,
+		TemplateRef:            spec.TemplateRef,
+		Scheduling:             spec.Scheduling,
+	}
+
+	annotations := dst.Annotations
+	dst.Spec.ImagePullPolicy = v1.PullPolicy(annotations[v1alpha1.AnnoKeyImagePullPolicy])
+	dst.Spec.Storage = Storage{
+		Size:             spec.Storage,
+		StorageClassName: annotations[v1alpha1.AnnoKeyStorageClassName],
+		AccessMode:       v1.PersistentVolumeAccessMode(annotations[v1alpha1.AnnoKeyVolumeAccessMode]),
+		VolumeMode:       v1.PersistentVolumeMode(annotations[v1alpha1.AnnoKeyVolumeMode]),
+		Temporary:        annotations[v1alpha1.AnnoKeyStorageTemporary] != "",
+	}
+	ingressMode := annotations[v1alpha1.AnnoKeyIngressMode]
+	dst.Spec.Ingress.Mode = ingressModeOf(ingressMode)
+	dst.Spec.Networking.Policy = spec.NetworkPolicy
+	for _, item := range strings.Split(annotations[v1alpha1.AnnoKeyExposePorts], ",") {
+		// the invalid ports were ignored by v1alpha1 as well
+		if port, err := strconv.ParseInt(item, 10, 32); err == nil {
+			dst.Spec.Networking.ExposedPorts = append(dst.Spec.Networking.ExposedPorts, int32(port))
+		}
+	}
+
+	for _, key := range []string{
+		v1alpha1.AnnoKeyImagePullPolicy, v1alpha1.AnnoKeyStorageClassName, v1alpha1.AnnoKeyVolumeAccessMode,
+		v1alpha1.AnnoKeyVolumeMode, v1alpha1.AnnoKeyStorageTemporary, v1alpha1.AnnoKeyExposePorts,
+		v1alpha1.AnnoKeyIngressMode, AnnoKeyConvertedIngressMode,
+	} {
+		delete(dst.Annotations, key)
+	}
+	if ingressMode != string(dst.Spec.Ingress.Mode) {
+		dst.Annotations[AnnoKeyConvertedIngressMode] = ingressMode
+	}
+	if len(dst.Annotations) == 0 {
+		dst.Annotations = nil
+	}
+	return nil
+}
+
+// ingressModeOf returns the typed ingress mode, any mode other than path routes by the host in v1alpha1,
+// e.g. the nginx and traefik ones which were given as the ingress mode before
+func ingressModeOf(mode string) IngressMode {
+	switch mode {
+	case "":
+		return ""
+	case string(IngressModePath):
+		return IngressModePath
+	default:
+		return IngressModeHost
+	}
+}
@@ -0,0 +1,151 @@
+/*
+Copyright 2024 kde authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"testing"
+
+	"github.com/linuxsuren/kde/api/linuxsuren.github.io/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestConvertTo(t *testing.T) {
+	replicas := int32(1)
+	src := &DevSpace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "demo",
+			Namespace:   "default",
+			Annotations: map[string]string{v1alpha1.AnnoKeyWebhookToken: "token"},
+		},
+		Spec: DevSpaceSpec{
+			CPU: "2",
+			Resources: &v1.ResourceRequirements{
+				Limits: v1.ResourceList{v1.ResourceEphemeralStorage: resource.MustParse("10Gi")},
+			},
+			Image:           "ide:latest",
+			ImagePullPolicy: v1.PullAlways,
+			Replicas:        &replicas,
+			Storage: Storage{
+				Size:             "10Gi",
+				StorageClassName: "local-path",
+				AccessMode:       v1.ReadWriteOnce,
+				VolumeMode:       v1.PersistentVolumeFilesystem,
+			},
+			Networking: Networking{
+				ExposedPorts: []int32{8080, 9090},
+				Policy:       &v1alpha1.NetworkPolicy{Egress: &v1alpha1.NetworkEgress{Internet: true}},
+			},
+			Ingress: Ingress{Mode: IngressModePath},
+			Windows: []v1alpha1.Window{{From: "08:00", To: "18:00"}},
+			Repositories: []v1alpha1.GitRepository{{
+				URL: "https://github.com/linuxsuren/kde", Tag: "v0.0.1",
+			}},
+			Scheduling:      v1alpha1.Scheduling{NodeSelector: map[string]string{"node.example.com/pool": "dev"}},
+			SecurityProfile: v1alpha1.SecurityProfileRestricted,
+		},
+		Status: v1alpha1.DevSpaceStatus{Phase: v1alpha1.DevSpacePhaseRunning},
+	}
+
+	dst := &v1alpha1.DevSpace{}
+	assert.NoError(t, src.ConvertTo(dst))
+	assert.Equal(t, "demo", dst.Name)
+	assert.Equal(t, "10Gi", dst.Spec.Storage)
+	assert.Equal(t, "2", dst.Spec.CPU)
+	assert.Equal(t, src.Spec.Resources, dst.Spec.Resources)
+	assert.Equal(t, src.Spec.Scheduling, dst.Spec.Scheduling)
+	assert.Equal(t, v1alpha1.SecurityProfileRestricted, dst.Spec.SecurityProfile)
+	assert.Equal(t, src.Spec.Networking.Policy, dst.Spec.NetworkPolicy)
+	assert.Equal(t, &replicas, dst.Spec.Replicas)
+	assert.Equal(t, src.Spec.Windows, dst.Spec.Windows)
+	assert.Equal(t, src.Spec.Repositories, dst.Spec.Repositories)
+	assert.Equal(t, v1alpha1.DevSpacePhaseRunning, dst.Status.Phase)
+	assert.Equal(t, map[string]string{
+		v1alpha1.AnnoKeyWebhookToken:     "token",
+		v1alpha1.AnnoKeyImagePullPolicy:  "Always",
+		v1alpha1.AnnoKeyStorageClassName: "local-path",
+		v1alpha1.AnnoKeyVolumeAccessMode: "ReadWriteOnce",
+		v1alpha1.AnnoKeyVolumeMode:       "Filesystem",
+		v1alpha1.AnnoKeyExposePorts:      "8080,9090",
+		v1alpha1.AnnoKeyIngressMode:      "path",
+	}, dst.Annotations)
+	// the source is not changed
+	assert.Len(t, src.Annotations, 1)
+
+	t.Run("round trip", func(t *testing.T) {
+		back := &DevSpace{}
+		assert.NoError(t, back.ConvertFrom(dst))
+		assert.Equal(t, src, back)
+	})
+}
+
+func TestConvertFrom(t *testing.T) {
+	src := &v1alpha1.DevSpace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "demo",
+			Annotations: map[string]string{
+				v1alpha1.AnnoKeyExposePorts:      "8080,abc,,9090",
+				v1alpha1.AnnoKeyStorageTemporary: "true",
+				v1alpha1.AnnoKeyIngressMode:      "nginx",
+			},
+		},
+		Spec: v1alpha1.DevSpaceSpec{
+			Storage: "50Gi",
+			Memory:  "4Gi",
+		},
+	}
+
+	dst := &DevSpace{}
+	assert.NoError(t, dst.ConvertFrom(src))
+	// the legacy ingress mode is kept for the round trip
+	assert.Equal(t, map[string]string{AnnoKeyConvertedIngressMode: "nginx"}, dst.Annotations)
+	assert.Equal(t, "4Gi", dst.Spec.Memory)
+	assert.Equal(t, Storage{Size: "50Gi", Temporary: true}, dst.Spec.Storage)
+	assert.Equal(t, []int32{8080, 9090}, dst.Spec.Networking.ExposedPorts)
+	assert.Equal(t, IngressModeHost, dst.Spec.Ingress.Mode)
+	assert.Empty(t, dst.Spec.ImagePullPolicy)
+
+	t.Run("round trip", func(t *testing.T) {
+		back := &v1alpha1.DevSpace{}
+		assert.NoError(t, dst.ConvertTo(back))
+		assert.Equal(t, "8080,9090", back.Annotations[v1alpha1.AnnoKeyExposePorts])
+		assert.Equal(t, "true", back.Annotations[v1alpha1.AnnoKeyStorageTemporary])
+		assert.Equal(t, "nginx", back.Annotations[v1alpha1.AnnoKeyIngressMode])
+		assert.NotContains(t, back.Annotations, AnnoKeyConvertedIngressMode)
+		assert.Equal(t, src.Spec, back.Spec)
+	})
+
+	t.Run("ingress mode changed", func(t *testing.T) {
+		changed := dst.DeepCopy()
+		changed.Spec.Ingress.Mode = IngressModePath
+		back := &v1alpha1.DevSpace{}
+		assert.NoError(t, changed.ConvertTo(back))
+		assert.Equal(t, "path", back.Annotations[v1alpha1.AnnoKeyIngressMode])
+		assert.NotContains(t, back.Annotations, AnnoKeyConvertedIngressMode)
+	})
+
+	t.Run("typed ingress mode", func(t *testing.T) {
+		typed := src.DeepCopy()
+		typed.Annotations = map[string]string{v1alpha1.AnnoKeyIngressMode: "path"}
+		converted := &DevSpace{}
+		assert.NoError(t, converted.ConvertFrom(typed))
+		assert.Equal(t, IngressModePath, converted.Spec.Ingress.Mode)
+		assert.Nil(t, converted.Annotations)
+	})
+}
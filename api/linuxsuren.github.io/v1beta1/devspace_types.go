@@ -0,0 +1,166 @@
+/*
+Copyright 2024 kde authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"github.com/linuxsuren/kde/api/linuxsuren.github.io/v1alpha1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DevSpaceSpec defines the desired state of DevSpace.
+// The settings which were annotations in v1alpha1 are typed fields here,
+// the nested types which did not change are shared with v1alpha1.
+type DevSpaceSpec struct {
+	// CPU is the CPU limit, it is 2 if neither the DevSpace nor its template gives it
+	CPU string `json:"cpu,omitempty"`
+	// Memory is the memory limit, it is 4Gi if neither the DevSpace nor its template gives it
+	Memory string `json:"memory,omitempty"`
+	// Resources overrides the CPU and Memory, the requests are derived from the limits
+	// with the overcommit ratio of the config if they are not given
+	// +optional
+	Resources *v1.ResourceRequirements `json:"resources,omitempty"`
+	Image     string                   `json:"image,omitempty"`
+	// ImagePullPolicy applies to all the containers of the DevSpace
+	// +kubebuilder:validation:Enum=Always;IfNotPresent
+	// +optional
+	ImagePullPolicy v1.PullPolicy `json:"imagePullPolicy,omitempty"`
+	// +optional
+	ReadOnlyRootFilesystem *bool `json:"readOnlyRootFilesystem,omitempty"`
+	// SecurityProfile is what the containers are allowed to do, it defaults to the one of the config
+	// +optional
+	SecurityProfile v1alpha1.SecurityProfile `json:"securityProfile,omitempty"`
+	// Replicas is the number of replicas
+	// +kubebuilder:default:replicas=1
+	Replicas *int32 `json:"replicas,omitempty"`
+	// Host is the hostname
+	Host string `json:"host,omitempty"`
+	// Storage is the workspace volume
+	// +optional
+	Storage Storage `json:"storage,omitempty"`
+	// Networking is about the ports of the DevSpace
+	// +optional
+	Networking Networking `json:"networking,omitempty"`
+	// Ingress is about how the DevSpace is exposed
+	// +optional
+	Ingress Ingress `json:"ingress,omitempty"`
+	// Repository is cloned into the workspace, prefer Repositories
+	Repository *v1alpha1.GitRepository `json:"repository,omitempty"`
+	// Repositories are cloned into the workspace by the init container
+	// +optional
+	// +patchMergeKey=url
+	// +patchStrategy=merge
+	// +listType=map
+	// +listMapKey=url
+	Repositories []v1alpha1.GitRepository `json:"repositories,omitempty" patchStrategy:"merge" patchMergeKey:"url"`
+	Auth         v1alpha1.DevSpaceAuth    `json:"auth,omitempty"`
+	Environment  map[string]string        `json:"env,omitempty"`
+	HostAliases  []v1.HostAlias           `json:"hostAliases,omitempty"`
+	Windows      []v1alpha1.Window        `json:"windows,omitempty"`
+	InitScript   string                   `json:"initScript,omitempty"`
+	Services     v1alpha1.Services        `json:"services,omitempty"`
+	// IdleTimeout overrides the global idle timeout, the DevSpace will be suspended
+	// after being idle for this long. Zero disables the idle detection.
+	IdleTimeout *metav1.Duration `json:"idleTimeout,omitempty"`
+	// ScheduleOverride keeps the DevSpace alive, or turns it off, regardless of
+	// the alive windows until the given time. It is ignored once expired.
+	// +optional
+	ScheduleOverride *v1alpha1.ScheduleOverride `json:"scheduleOverride,omitempty"`
+	// RestoreFrom is the name of a DevSpaceSnapshot in the same namespace to populate the storage from.
+	// Changing it on an existing DevSpace rolls the storage back, all the changes after the snapshot are lost.
+	// +optional
+	RestoreFrom string `json:"restoreFrom,omitempty"`
+	// TemplateRef points to the template which provides the default values of this spec
+	// +optional
+	TemplateRef *v1alpha1.TemplateReference `json:"templateRef,omitempty"`
+	// Scheduling is where the pods of the DevSpace run, the missing fields come from
+	// the language of the image in the config, and then the global ones of the config
+	v1alpha1.Scheduling `json:",inline"`
+}
+
+// Storage describes the workspace volume
+type Storage struct {
+	// Size is the storage size, it is 50Gi if neither the DevSpace nor its template gives it
+	Size string `json:"size,omitempty"`
+	// StorageClassName is the class of the PersistentVolumeClaim
+	StorageClassName string `json:"storageClassName,omitempty"`
+	// +kubebuilder:validation:Enum=ReadWriteOnce;ReadWriteMany;ReadWriteOncePod
+	AccessMode v1.PersistentVolumeAccessMode `json:"accessMode,omitempty"`
+	// +kubebuilder:validation:Enum=Filesystem;Block
+	VolumeMode v1.PersistentVolumeMode `json:"volumeMode,omitempty"`
+	// Temporary uses an emptyDir instead of a PersistentVolumeClaim, the data is lost once the DevSpace is off
+	Temporary bool `json:"temporary,omitempty"`
+}
+
+// Networking describes the ports of the DevSpace
+type Networking struct {
+	// ExposedPorts are the ports to be exposed via the ingress, each one gets a sub-domain
+	// +listType=set
+	ExposedPorts []int32 `json:"exposedPorts,omitempty"`
+	// Policy overrides the network policy of the config
+	// +optional
+	Policy *v1alpha1.NetworkPolicy `json:"policy,omitempty"`
+}
+
+// Ingress describes how the DevSpace is exposed
+type Ingress struct {
+	// Mode is the way of routing the requests to the DevSpace
+	// +optional
+	Mode IngressMode `json:"mode,omitempty"`
+}
+
+// IngressMode is the way of routing the requests to the DevSpace, the ingress provider comes from the config
+// +kubebuilder:validation:Enum=host;path
+type IngressMode string
+
+const (
+	// IngressModeHost routes by the host, each DevSpace gets a sub-domain
+	IngressModeHost IngressMode = "host"
+	// IngressModePath routes by the path, it does not need a wildcard domain
+	IngressModePath IngressMode = "path"
+)
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:object:root=true
+// +kubebuilder:printcolumn:name="Link",type=string,JSONPath=`.status.link`
+// +kubebuilder:printcolumn:name="DeployStatus",type=string,JSONPath=`.status.deployStatus`
+// +kubebuilder:printcolumn:name="Replicas",type=integer,JSONPath=`.spec.replicas`
+// +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+// +kubebuilder:subresource:status
+
+// DevSpace is the Schema for the devspaces API
+type DevSpace struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   DevSpaceSpec            `json:"spec,omitempty"`
+	Status v1alpha1.DevSpaceStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// DevSpaceList contains a list of DevSpace
+type DevSpaceList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []DevSpace `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&DevSpace{}, &DevSpaceList{})
+}
@@ -36,7 +36,9 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
 	"github.com/linuxsuren/kde/api/linuxsuren.github.io/v1alpha1"
+	"github.com/linuxsuren/kde/api/linuxsuren.github.io/v1beta1"
 	"github.com/linuxsuren/kde/internal/controller"
+	webhookv1alpha1 "github.com/linuxsuren/kde/internal/webhook/v1alpha1"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -49,6 +51,7 @@ func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
 
 	utilruntime.Must(v1alpha1.AddToScheme(scheme))
+	utilruntime.Must(v1beta1.AddToScheme(scheme))
 	// +kubebuilder:scaffold:scheme
 }
 
@@ -60,6 +63,7 @@ func main() {
 	var enableHTTP2 bool
 	var tlsOpts []func(*tls.Config)
 	var systemNamespace string
+	var enableWebhooks bool
 	flag.StringVar(&metricsAddr, "metrics-bind-address", "0", "The address the metrics endpoint binds to. "+
 		"Use :8443 for HTTPS or :8080 for HTTP, or leave as 0 to disable the metrics service.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
@@ -71,6 +75,10 @@ func main() {
 	flag.BoolVar(&enableHTTP2, "enable-http2", false,
 		"If set, HTTP/2 will be enabled for the metrics and webhook servers")
 	flag.StringVar(&systemNamespace, "system-namespace", "kde-system", "The system namespace for installation")
+	flag.BoolVar(&enableWebhooks, "enable-webhooks", true,
+		"If set, the admission and conversion webhooks of DevSpace and User will be served. "+
+			"It requires the certificates of the webhook server, see config/default/kustomization.yaml. "+
+			"Use --enable-webhooks=false to run without them, the v1beta1 DevSpace must not be served in that case")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -149,17 +157,12 @@ func main() {
 	if err = (&controller.DevSpaceReconciler{
 		Client:          mgr.GetClient(),
 		Scheme:          mgr.GetScheme(),
+		Recorder:        mgr.GetEventRecorderFor("devspace-controller"),
 		SystemNamespace: systemNamespace,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "DevSpace")
 		os.Exit(1)
 	}
-	if err = (&controller.DevSpacePodPodReconciler{
-		Client: mgr.GetClient(),
-	}).SetupWithManager(mgr); err != nil {
-		setupLog.Error(err, "unable to create controller", "controller", "DevSpacePod")
-		os.Exit(1)
-	}
 	if err = controller.NewConfigReconciler(mgr).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Config")
 		os.Exit(1)
@@ -171,6 +174,24 @@ func main() {
 		setupLog.Error(err, "unable to create controller", "controller", "User")
 		os.Exit(1)
 	}
+	if err = (&controller.DevSpaceSnapshotReconciler{
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor("devspacesnapshot-controller"),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "DevSpaceSnapshot")
+		os.Exit(1)
+	}
+	if enableWebhooks {
+		if err = webhookv1alpha1.SetupDevSpaceWebhookWithManager(mgr, systemNamespace); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "DevSpace")
+			os.Exit(1)
+		}
+		if err = webhookv1alpha1.SetupUserWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "User")
+			os.Exit(1)
+		}
+	}
 	// +kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
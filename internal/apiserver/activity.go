@@ -0,0 +1,151 @@
+/*
+Copyright 2024 kde authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apiserver
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/linuxsuren/kde/api/linuxsuren.github.io/v1alpha1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// activityRecordInterval limits how often the last activity time is written
+const activityRecordInterval = time.Minute
+
+// activityTokenTTL is how long a verified activity token is trusted without reading the credentials Secret,
+// a rotated or deleted token is rejected once it expires
+const activityTokenTTL = 5 * time.Minute
+
+// verifiedToken is the cached activity token of a DevSpace
+type verifiedToken struct {
+	token   string
+	expires time.Time
+}
+
+// DevSpaceActivity records the activity of a DevSpace.
+// It is the mirror target of the DevSpace ingress, so every request to the IDE counts.
+func (s *Server) DevSpaceActivity(c *gin.Context) {
+	ns := c.Query("namespace")
+	name := c.Query("devspace")
+	token := c.Query("token")
+	if ns == "" || name == "" {
+		err := fmt.Errorf("the query parameter 'namespace' or 'devspace' is missing")
+		c.Error(err)
+		c.JSON(http.StatusBadRequest, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	if ok, err := s.isActivityToken(ctx, ns, name, token); err != nil {
+		c.Error(err)
+		c.JSON(http.StatusBadRequest, err)
+		return
+	} else if !ok {
+		c.JSON(http.StatusForbidden, fmt.Errorf("token is incorrect"))
+		return
+	}
+
+	// avoid querying the DevSpace for every single request
+	if s.activityRecentlyRecorded(ns, name, time.Now()) {
+		c.Status(http.StatusNoContent)
+		return
+	}
+
+	devspace, err := s.KClient.LinuxsurenV1alpha1().DevSpaces(ns).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusBadRequest, err)
+		return
+	}
+
+	if err = s.recordActivity(ctx, devspace); err != nil {
+		c.Error(err)
+		c.JSON(http.StatusBadRequest, err)
+	} else {
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// isActivityToken compares the given token with the one in the credentials Secret of the DevSpace,
+// the verified token is cached for activityTokenTTL since the mirrored requests are as many as the IDE requests.
+// A token which is not the cached one is always checked against the Secret, e.g. the rotated one.
+func (s *Server) isActivityToken(ctx context.Context, namespace, name, token string) (ok bool, err error) {
+	if token == "" {
+		return
+	}
+	key := namespace + "/" + name
+	now := time.Now()
+	if cached, found := s.activityTokens.Load(key); found {
+		verified := cached.(verifiedToken)
+		if now.Before(verified.expires) && isSameToken(verified.token, token) {
+			ok = true
+			return
+		}
+	}
+
+	var secret *v1.Secret
+	if secret, err = s.Client.CoreV1().Secrets(namespace).Get(ctx, v1alpha1.CredentialsSecretName(name), metav1.GetOptions{}); err != nil {
+		s.activityTokens.Delete(key)
+		// not telling whether the DevSpace exists
+		err = client.IgnoreNotFound(err)
+		return
+	}
+	expected := string(secret.Data[v1alpha1.CredentialKeyActivityToken])
+	if ok = isSameToken(expected, token); ok {
+		s.activityTokens.Store(key, verifiedToken{token: token, expires: now.Add(activityTokenTTL)})
+	} else if cached, found := s.activityTokens.Load(key); found && !isSameToken(expected, cached.(verifiedToken).token) {
+		// it was rotated
+		s.activityTokens.Delete(key)
+	}
+	return
+}
+
+func isSameToken(expected, token string) bool {
+	return expected != "" && subtle.ConstantTimeCompare([]byte(expected), []byte(token)) == 1
+}
+
+func (s *Server) activityRecentlyRecorded(namespace, name string, now time.Time) bool {
+	last, ok := s.activities.Load(namespace + "/" + name)
+	return ok && now.Sub(last.(time.Time)) < activityRecordInterval
+}
+
+// recordActivity writes the last activity time into the status of the DevSpace
+func (s *Server) recordActivity(ctx context.Context, devspace *v1alpha1.DevSpace) (err error) {
+	now := time.Now()
+	if s.activityRecentlyRecorded(devspace.Namespace, devspace.Name, now) {
+		return
+	}
+
+	last := devspace.Status.LastActivityTime
+	if last == nil || now.Sub(last.Time) >= activityRecordInterval {
+		devspace = devspace.DeepCopy()
+		devspace.Status.LastActivityTime = &metav1.Time{Time: now}
+		_, err = s.KClient.LinuxsurenV1alpha1().DevSpaces(devspace.Namespace).UpdateStatus(ctx, devspace, metav1.UpdateOptions{})
+	}
+
+	if err == nil {
+		s.activities.Store(devspace.Namespace+"/"+devspace.Name, now)
+	}
+	return
+}
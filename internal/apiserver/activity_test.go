@@ -0,0 +1,111 @@
+/*
+Copyright 2024 kde authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apiserver_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/linuxsuren/kde/internal/apiserver"
+	"github.com/linuxsuren/kde/pkg/client/clientset/versioned/fake"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func createActivityTokenSecret() *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "fake-credentials", Namespace: "default"},
+		Data:       map[string][]byte{"activity-token": []byte("activity")},
+	}
+}
+
+func TestDevSpaceActivity(t *testing.T) {
+	t.Run("ns or name is empty", func(t *testing.T) {
+		engine := gin.New()
+		server := apiserver.Server{}
+		engine.Any("/activity", server.DevSpaceActivity)
+		w := httptest.NewRecorder()
+
+		req, _ := http.NewRequest(http.MethodGet, "/activity", nil)
+		engine.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	})
+
+	t.Run("not found devspace", func(t *testing.T) {
+		engine := gin.New()
+		server := apiserver.Server{
+			Client:  k8sfake.NewSimpleClientset(),
+			KClient: fake.NewSimpleClientset(),
+		}
+		engine.Any("/activity", server.DevSpaceActivity)
+		w := httptest.NewRecorder()
+
+		req, _ := http.NewRequest(http.MethodGet, "/activity?namespace=default&devspace=fake&token=activity", nil)
+		engine.ServeHTTP(w, req)
+		// the same as an incorrect token, it does not tell whether the DevSpace exists
+		assert.Equal(t, http.StatusForbidden, w.Result().StatusCode)
+	})
+
+	t.Run("token is incorrect", func(t *testing.T) {
+		engine := gin.New()
+		server := apiserver.Server{
+			Client:  k8sfake.NewSimpleClientset(createActivityTokenSecret()),
+			KClient: fake.NewSimpleClientset(createDefaultDevSpace()),
+		}
+		engine.Any("/activity", server.DevSpaceActivity)
+
+		// the webhook token is not accepted
+		for _, token := range []string{"wrong", "token", ""} {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/activity?namespace=default&devspace=fake&token="+token, nil)
+			engine.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusForbidden, w.Result().StatusCode, token)
+		}
+	})
+
+	t.Run("normal", func(t *testing.T) {
+		engine := gin.New()
+		kClient := fake.NewSimpleClientset(createDefaultDevSpace())
+		server := apiserver.Server{
+			Client:  k8sfake.NewSimpleClientset(createActivityTokenSecret()),
+			KClient: kClient,
+		}
+		engine.Any("/activity", server.DevSpaceActivity)
+
+		for i := 0; i < 2; i++ {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/activity?namespace=default&devspace=fake&token=activity", nil)
+			engine.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusNoContent, w.Result().StatusCode)
+		}
+
+		devSpace, err := kClient.LinuxsurenV1alpha1().DevSpaces("default").Get(context.Background(), "fake", metav1.GetOptions{})
+		assert.NoError(t, err)
+		assert.NotNil(t, devSpace.Status.LastActivityTime)
+
+		// the token is checked even if the activity was recorded recently
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/activity?namespace=default&devspace=fake&token=wrong", nil)
+		engine.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusForbidden, w.Result().StatusCode)
+	})
+}
@@ -0,0 +1,61 @@
+/*
+Copyright 2024 kde authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apiserver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestIsActivityToken(t *testing.T) {
+	ctx := context.Background()
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "demo-credentials", Namespace: "default"},
+		Data:       map[string][]byte{"activity-token": []byte("activity")},
+	}
+	client := fake.NewSimpleClientset(secret)
+	s := &Server{Client: client}
+	isActivityToken := func(token string) bool {
+		ok, err := s.isActivityToken(ctx, "default", "demo", token)
+		assert.NoError(t, err)
+		return ok
+	}
+
+	assert.True(t, isActivityToken("activity"))
+	assert.False(t, isActivityToken("wrong"))
+
+	// rotated
+	secret.Data["activity-token"] = []byte("rotated")
+	_, err := client.CoreV1().Secrets("default").Update(ctx, secret, metav1.UpdateOptions{})
+	assert.NoError(t, err)
+	assert.True(t, isActivityToken("rotated"))
+	assert.False(t, isActivityToken("activity"))
+
+	// the cached one expires
+	assert.NoError(t, client.CoreV1().Secrets("default").Delete(ctx, "demo-credentials", metav1.DeleteOptions{}))
+	assert.True(t, isActivityToken("rotated"))
+	s.activityTokens.Store("default/demo", verifiedToken{token: "rotated", expires: time.Now().Add(-time.Second)})
+	assert.False(t, isActivityToken("rotated"))
+	_, found := s.activityTokens.Load("default/demo")
+	assert.False(t, found)
+}
@@ -0,0 +1,281 @@
+/*
+Copyright 2024 kde authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apiserver
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/linuxsuren/kde/api/linuxsuren.github.io/v1alpha1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+type cloneRequest struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+}
+
+// annotations which belong to the source DevSpace only
+var notClonedAnnotations = []string{
+	v1alpha1.AnnoKeyWebhookToken,
+	v1alpha1.AnnoKeyActivityToken,
+	v1alpha1.AnnoKeyBasicAuth,
+	v1alpha1.AnnoKeyServiceName,
+	v1alpha1.AnnoKeyServiceNamespace,
+	v1alpha1.AnnoKeyIngressProvider,
+	v1alpha1.AnnoKeyGatewayName,
+	v1alpha1.AnnoKeyGatewayNamespace,
+	v1alpha1.AnnoKeyTLSSecret,
+	v1alpha1.AnnoKeyTLSIssuer,
+	v1alpha1.AnnoKeyTLSClusterIssuer,
+	v1alpha1.AnnoKeyTLSDefaultCertificate,
+	v1alpha1.AnnoKeyAuthSignIn,
+	v1alpha1.AnnoKeyOwner,
+	v1alpha1.AnnoKeyCloneFrom,
+	v1.LastAppliedConfigAnnotation,
+}
+
+// CloneDevSpace creates a new DevSpace with the same spec of the given one,
+// its storage is provisioned as a CSI clone of the source storage.
+func (s *Server) CloneDevSpace(c *gin.Context) {
+	ctx := c.Request.Context()
+	name := c.Params.ByName("devspace")
+	namespace := getNamespaceFromQuery(c)
+
+	req := cloneRequest{}
+	if err := c.BindJSON(&req); err != nil {
+		c.Error(err)
+		return
+	}
+	if req.Name == "" {
+		err := fmt.Errorf("the name of the new DevSpace is required")
+		c.Error(err)
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.Namespace == "" {
+		req.Namespace = namespace
+	}
+
+	source, err := s.KClient.LinuxsurenV1alpha1().DevSpaces(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusBadRequest, err)
+		return
+	}
+
+	var target *v1alpha1.DevSpace
+	if target, err = cloneDevSpace(source, req.Name, req.Namespace); err != nil {
+		c.Error(err)
+		c.JSON(http.StatusBadRequest, err)
+		return
+	}
+	// the one who clones it owns the new DevSpace
+	setOwner(c, target)
+
+	// the credentials Secret goes first, otherwise the controller generates
+	// the passwords which do not match the cloned data
+	var secret *v1.Secret
+	if secret, err = s.cloneCredentials(ctx, source, target); err != nil {
+		c.Error(err)
+		c.JSON(http.StatusBadRequest, err)
+		return
+	}
+	if target, err = s.KClient.LinuxsurenV1alpha1().DevSpaces(req.Namespace).Create(ctx, target, metav1.CreateOptions{}); err != nil {
+		if secret != nil {
+			// nothing owns it yet
+			_ = s.Client.CoreV1().Secrets(secret.Namespace).Delete(ctx, secret.Name, metav1.DeleteOptions{})
+		}
+		c.Error(err)
+		c.JSON(http.StatusBadRequest, err)
+		return
+	}
+	if secret != nil {
+		if err = s.ownCredentials(ctx, secret, target); err != nil {
+			_ = s.KClient.LinuxsurenV1alpha1().DevSpaces(target.Namespace).Delete(ctx, target.Name, metav1.DeleteOptions{})
+			_ = s.Client.CoreV1().Secrets(secret.Namespace).Delete(ctx, secret.Name, metav1.DeleteOptions{})
+			c.Error(err)
+			c.JSON(http.StatusBadRequest, err)
+			return
+		}
+	}
+	c.JSON(http.StatusOK, hideCredentials(target))
+}
+
+// cloneCredentials copies the passwords of the services into the credentials Secret of the target,
+// because the cloned data was initialized with them. The git password and the activity token are not copied.
+// The Secrets referred by the services are in the source namespace only, so the passwords are resolved
+// into the credentials Secret as well when cloning into another namespace.
+// The Secret is created without any owner since the target does not exist yet, it is nil if there is no password.
+func (s *Server) cloneCredentials(ctx context.Context, source, target *v1alpha1.DevSpace) (created *v1.Secret, err error) {
+	var secret *v1.Secret
+	if secret, err = s.Client.CoreV1().Secrets(source.Namespace).Get(ctx,
+		v1alpha1.CredentialsSecretName(source.Name), metav1.GetOptions{}); err != nil {
+		if err = client.IgnoreNotFound(err); err != nil {
+			return
+		}
+		secret = &v1.Secret{Type: v1.SecretTypeOpaque}
+	}
+
+	data := maps.Clone(secret.Data)
+	maps.DeleteFunc(data, func(key string, _ []byte) bool {
+		return strings.HasPrefix(key, v1alpha1.CredentialKeyGit) || key == v1alpha1.CredentialKeySSHPrivateKey ||
+			key == v1alpha1.CredentialKeyActivityToken
+	})
+	if target.Namespace != source.Namespace {
+		if data == nil {
+			data = map[string][]byte{}
+		}
+		for key, ref := range servicePasswordRefsOf(&target.Spec) {
+			if *ref == nil {
+				continue
+			}
+			var value []byte
+			if value, err = s.secretValueOf(ctx, source.Namespace, *ref); err != nil {
+				return
+			}
+			data[key] = value
+			// the controller refers to the credentials Secret instead
+			*ref = nil
+		}
+	}
+	if len(data) == 0 {
+		return
+	}
+
+	created, err = s.Client.CoreV1().Secrets(target.Namespace).Create(ctx, &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      v1alpha1.CredentialsSecretName(target.Name),
+			Namespace: target.Namespace,
+			Labels:    secret.Labels,
+		},
+		Type: secret.Type,
+		Data: data,
+	}, metav1.CreateOptions{})
+	return
+}
+
+// servicePasswordRefsOf returns the password references of the services by their keys in the credentials Secret
+func servicePasswordRefsOf(spec *v1alpha1.DevSpaceSpec) (refs map[string]**v1.SecretKeySelector) {
+	refs = map[string]**v1.SecretKeySelector{}
+	if mysql := spec.Services.MySQL; mysql != nil {
+		refs[v1alpha1.CredentialKeyMySQL] = &mysql.PasswordSecretRef
+	}
+	if postgres := spec.Services.Postgres; postgres != nil {
+		refs[v1alpha1.CredentialKeyPostgres] = &postgres.PasswordSecretRef
+	}
+	if rabbitMQ := spec.Services.RabbitMQ; rabbitMQ != nil {
+		refs[v1alpha1.CredentialKeyRabbitMQ] = &rabbitMQ.PasswordSecretRef
+	}
+	return
+}
+
+// secretValueOf returns the value which is referred by the given selector
+func (s *Server) secretValueOf(ctx context.Context, namespace string, ref *v1.SecretKeySelector) (value []byte, err error) {
+	var secret *v1.Secret
+	if secret, err = s.Client.CoreV1().Secrets(namespace).Get(ctx, ref.Name, metav1.GetOptions{}); err != nil {
+		return
+	}
+	var ok bool
+	if value, ok = secret.Data[ref.Key]; !ok {
+		err = fmt.Errorf("key %q is not found in Secret %s/%s", ref.Key, namespace, ref.Name)
+	}
+	return
+}
+
+// ownCredentials lets the credentials Secret be collected along with the DevSpace
+func (s *Server) ownCredentials(ctx context.Context, secret *v1.Secret, devSpace *v1alpha1.DevSpace) (err error) {
+	var patch []byte
+	if patch, err = json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"ownerReferences": []metav1.OwnerReference{
+				*metav1.NewControllerRef(devSpace, v1alpha1.GroupVersion.WithKind("DevSpace")),
+			},
+		},
+	}); err == nil {
+		_, err = s.Client.CoreV1().Secrets(secret.Namespace).Patch(ctx, secret.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+	}
+	return
+}
+
+// cloneDevSpace copies the spec without any credentials, the auth including the SSH private key is not copied.
+// The database passwords of the services are kept, because they are stored in the cloned data as well.
+// The passwords in the credentials Secret are copied by cloneCredentials.
+func cloneDevSpace(source *v1alpha1.DevSpace, name, namespace string) (target *v1alpha1.DevSpace, err error) {
+	target = &v1alpha1.DevSpace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   namespace,
+			Labels:      map[string]string{},
+			Annotations: map[string]string{},
+		},
+		Spec: *source.Spec.DeepCopy(),
+	}
+	for k, v := range source.Labels {
+		target.Labels[k] = v
+	}
+	for k, v := range source.Annotations {
+		target.Annotations[k] = v
+	}
+	for _, key := range notClonedAnnotations {
+		delete(target.Annotations, key)
+	}
+
+	if source.Annotations[v1alpha1.AnnoKeyWebhookToken] != "" {
+		// give the new DevSpace its own token
+		var token string
+		if token, err = randomToken(); err != nil {
+			return
+		}
+		target.Annotations[v1alpha1.AnnoKeyWebhookToken] = token
+	}
+	if source.Annotations[v1alpha1.AnnoKeyStorageTemporary] == "" {
+		target.Annotations[v1alpha1.AnnoKeyCloneFrom] = fmt.Sprintf("%s/%s", source.Namespace, source.Name)
+	}
+
+	target.Spec.Auth = v1alpha1.DevSpaceAuth{}
+	if target.Spec.Repository != nil {
+		target.Spec.Repository.Password = ""
+		target.Spec.Repository.PasswordSecretRef = nil
+	}
+	for i := range target.Spec.Repositories {
+		target.Spec.Repositories[i].Password = ""
+		target.Spec.Repositories[i].PasswordSecretRef = nil
+	}
+	// the data comes from the source storage instead of any snapshots
+	target.Spec.RestoreFrom = ""
+	target.Spec.ScheduleOverride = nil
+	return
+}
+
+func randomToken() (token string, err error) {
+	data := make([]byte, 16)
+	if _, err = rand.Read(data); err == nil {
+		token = hex.EncodeToString(data)
+	}
+	return
+}
@@ -0,0 +1,267 @@
+/*
+Copyright 2024 kde authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apiserver_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/linuxsuren/kde/api/linuxsuren.github.io/v1alpha1"
+	"github.com/linuxsuren/kde/internal/apiserver"
+	"github.com/linuxsuren/kde/pkg/client/clientset/versioned/fake"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestCloneDevSpace(t *testing.T) {
+	request := func(server *apiserver.Server, body map[string]string) *httptest.ResponseRecorder {
+		engine := gin.New()
+		engine.POST("/devspace/:devspace/clone", server.CloneDevSpace)
+		data, _ := json.Marshal(body)
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/devspace/fake/clone?namespace=default", bytes.NewBuffer(data))
+		engine.ServeHTTP(w, req)
+		return w
+	}
+
+	source := createDefaultDevSpace()
+	source.UID = "uid"
+	source.Labels = map[string]string{"team": "dev"}
+	source.Annotations[v1alpha1.AnnoKeyBasicAuth] = "hash"
+	source.Annotations[v1alpha1.AnnoKeyExposePorts] = "8080"
+	source.OwnerReferences = []metav1.OwnerReference{{Name: "owner", UID: "owner"}}
+	source.Spec.CPU = "2"
+	source.Spec.Auth = v1alpha1.DevSpaceAuth{
+		BasicAuth:     &v1alpha1.BasicAuth{Username: "admin", Password: "admin"},
+		SSHPrivateKey: "private key",
+	}
+	source.Spec.Repository = &v1alpha1.GitRepository{
+		URL:      "https://github.com/linuxsuren/kde",
+		Username: "linuxsuren",
+		Password: "password",
+	}
+	source.Spec.Repositories = []v1alpha1.GitRepository{{
+		URL:      "https://github.com/linuxsuren/api-testing",
+		Password: "password",
+	}}
+
+	credentials := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "fake-credentials", Namespace: "default"},
+		Data: map[string][]byte{
+			v1alpha1.CredentialKeyMySQL:         []byte("mysql"),
+			v1alpha1.CredentialKeyGit:           []byte("token"),
+			"git-password-kde":                  []byte("token"),
+			v1alpha1.CredentialKeySSHPrivateKey: []byte("key"),
+			v1alpha1.CredentialKeyActivityToken: []byte("activity"),
+		},
+	}
+
+	t.Run("clone into another namespace", func(t *testing.T) {
+		server := &apiserver.Server{
+			KClient: fake.NewSimpleClientset(source.DeepCopy()),
+			Client:  k8sfake.NewSimpleClientset(credentials.DeepCopy()),
+		}
+		w := request(server, map[string]string{"name": "copy", "namespace": "another"})
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		target, err := server.KClient.LinuxsurenV1alpha1().DevSpaces("another").Get(context.Background(), "copy", metav1.GetOptions{})
+		assert.NoError(t, err)
+		assert.Equal(t, "2", target.Spec.CPU)
+		assert.Equal(t, "dev", target.Labels["team"])
+		assert.Empty(t, target.UID)
+		assert.Empty(t, target.OwnerReferences)
+		assert.Empty(t, target.Status.Link)
+		assert.Equal(t, "default/fake", target.Annotations[v1alpha1.AnnoKeyCloneFrom])
+		assert.Equal(t, "8080", target.Annotations[v1alpha1.AnnoKeyExposePorts])
+
+		// credentials are not copied
+		assert.Nil(t, target.Spec.Auth.BasicAuth)
+		assert.Empty(t, target.Spec.Auth.SSHPrivateKey)
+		assert.Empty(t, target.Spec.Repository.Password)
+		assert.Empty(t, target.Spec.Repositories[0].Password)
+		assert.Equal(t, "linuxsuren", target.Spec.Repository.Username)
+		assert.Empty(t, target.Annotations[v1alpha1.AnnoKeyBasicAuth])
+		assert.NotEmpty(t, target.Annotations[v1alpha1.AnnoKeyWebhookToken])
+		assert.NotEqual(t, "token", target.Annotations[v1alpha1.AnnoKeyWebhookToken])
+
+		// the passwords of the services are kept along with the cloned data
+		secret, err := server.Client.CoreV1().Secrets("another").Get(context.Background(), "copy-credentials", metav1.GetOptions{})
+		assert.NoError(t, err)
+		assert.Equal(t, "mysql", string(secret.Data[v1alpha1.CredentialKeyMySQL]))
+		assert.NotContains(t, secret.Data, v1alpha1.CredentialKeyGit)
+		assert.NotContains(t, secret.Data, v1alpha1.CredentialKeySSHPrivateKey)
+		assert.NotContains(t, secret.Data, "git-password-kde")
+		assert.NotContains(t, secret.Data, v1alpha1.CredentialKeyActivityToken)
+		assert.Equal(t, "copy", secret.OwnerReferences[0].Name)
+		assert.NotContains(t, w.Body.String(), "password\":")
+
+		// the source is untouched
+		source, err := server.KClient.LinuxsurenV1alpha1().DevSpaces("default").Get(context.Background(), "fake", metav1.GetOptions{})
+		assert.NoError(t, err)
+		assert.Equal(t, "password", source.Spec.Repository.Password)
+	})
+
+	t.Run("clone the referred passwords into another namespace", func(t *testing.T) {
+		withRefs := source.DeepCopy()
+		withRefs.Spec.Auth.SSHPrivateKeySecretRef = &v1.SecretKeySelector{
+			LocalObjectReference: v1.LocalObjectReference{Name: "passwords"}, Key: "ssh",
+		}
+		withRefs.Spec.Services.MySQL = &v1alpha1.MySQL{PasswordSecretRef: &v1.SecretKeySelector{
+			LocalObjectReference: v1.LocalObjectReference{Name: "passwords"}, Key: "mysql",
+		}}
+		withRefs.Spec.Services.Postgres = &v1alpha1.Postgres{PasswordSecretRef: &v1.SecretKeySelector{
+			LocalObjectReference: v1.LocalObjectReference{Name: "passwords"}, Key: "postgres",
+		}}
+		passwords := &v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "passwords", Namespace: "default"},
+			Data: map[string][]byte{
+				"mysql":    []byte("my-mysql"),
+				"postgres": []byte("my-postgres"),
+				"ssh":      []byte("my-key"),
+			},
+		}
+		server := &apiserver.Server{
+			KClient: fake.NewSimpleClientset(withRefs),
+			Client:  k8sfake.NewSimpleClientset(passwords),
+		}
+		w := request(server, map[string]string{"name": "copy", "namespace": "another"})
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		target, err := server.KClient.LinuxsurenV1alpha1().DevSpaces("another").Get(context.Background(), "copy", metav1.GetOptions{})
+		assert.NoError(t, err)
+		assert.Nil(t, target.Spec.Services.MySQL.PasswordSecretRef)
+		assert.Nil(t, target.Spec.Services.Postgres.PasswordSecretRef)
+		assert.Nil(t, target.Spec.Auth.SSHPrivateKeySecretRef)
+
+		secret, err := server.Client.CoreV1().Secrets("another").Get(context.Background(), "copy-credentials", metav1.GetOptions{})
+		assert.NoError(t, err)
+		assert.Equal(t, map[string][]byte{
+			v1alpha1.CredentialKeyMySQL:    []byte("my-mysql"),
+			v1alpha1.CredentialKeyPostgres: []byte("my-postgres"),
+		}, secret.Data)
+
+		// the referred key is missing
+		delete(passwords.Data, "postgres")
+		server = &apiserver.Server{
+			KClient: fake.NewSimpleClientset(withRefs),
+			Client:  k8sfake.NewSimpleClientset(passwords),
+		}
+		w = request(server, map[string]string{"name": "copy", "namespace": "another"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		_, err = server.KClient.LinuxsurenV1alpha1().DevSpaces("another").Get(context.Background(), "copy", metav1.GetOptions{})
+		assert.True(t, apierrors.IsNotFound(err), err)
+
+		// the references stay valid in the same namespace
+		server = &apiserver.Server{
+			KClient: fake.NewSimpleClientset(withRefs),
+			Client:  k8sfake.NewSimpleClientset(passwords),
+		}
+		w = request(server, map[string]string{"name": "copy"})
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		target, err = server.KClient.LinuxsurenV1alpha1().DevSpaces("default").Get(context.Background(), "copy", metav1.GetOptions{})
+		assert.NoError(t, err)
+		assert.Equal(t, "passwords", target.Spec.Services.MySQL.PasswordSecretRef.Name)
+	})
+
+	t.Run("clone into the same namespace", func(t *testing.T) {
+		server := &apiserver.Server{KClient: fake.NewSimpleClientset(source.DeepCopy()), Client: k8sfake.NewSimpleClientset()}
+		w := request(server, map[string]string{"name": "copy"})
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		_, err := server.KClient.LinuxsurenV1alpha1().DevSpaces("default").Get(context.Background(), "copy", metav1.GetOptions{})
+		assert.NoError(t, err)
+	})
+
+	t.Run("without name", func(t *testing.T) {
+		server := &apiserver.Server{KClient: fake.NewSimpleClientset(source.DeepCopy())}
+		w := request(server, map[string]string{})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("source not found", func(t *testing.T) {
+		server := &apiserver.Server{KClient: fake.NewSimpleClientset()}
+		w := request(server, map[string]string{"name": "copy"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("name conflict", func(t *testing.T) {
+		server := &apiserver.Server{
+			KClient: fake.NewSimpleClientset(source.DeepCopy()),
+			Client:  k8sfake.NewSimpleClientset(credentials.DeepCopy()),
+		}
+		w := request(server, map[string]string{"name": "fake"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		// the credentials Secret of the existing one is kept
+		secret, err := server.Client.CoreV1().Secrets("default").Get(context.Background(), "fake-credentials", metav1.GetOptions{})
+		assert.NoError(t, err)
+		assert.Equal(t, "key", string(secret.Data[v1alpha1.CredentialKeySSHPrivateKey]))
+	})
+
+	t.Run("the credentials Secret goes first", func(t *testing.T) {
+		kClient := fake.NewSimpleClientset(source.DeepCopy())
+		server := &apiserver.Server{KClient: kClient, Client: k8sfake.NewSimpleClientset(credentials.DeepCopy())}
+		kClient.PrependReactor("create", "devspaces", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			secret, err := server.Client.CoreV1().Secrets("default").Get(context.Background(), "copy-credentials", metav1.GetOptions{})
+			assert.NoError(t, err)
+			assert.Equal(t, "mysql", string(secret.Data[v1alpha1.CredentialKeyMySQL]))
+			return false, nil, nil
+		})
+		w := request(server, map[string]string{"name": "copy"})
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	})
+
+	t.Run("failed to create the DevSpace", func(t *testing.T) {
+		another := createDefaultDevSpace()
+		another.Name = "another"
+		server := &apiserver.Server{
+			KClient: fake.NewSimpleClientset(source.DeepCopy(), another),
+			Client:  k8sfake.NewSimpleClientset(credentials.DeepCopy()),
+		}
+		w := request(server, map[string]string{"name": "another"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		_, err := server.Client.CoreV1().Secrets("default").Get(context.Background(), "another-credentials", metav1.GetOptions{})
+		assert.True(t, apierrors.IsNotFound(err), err)
+	})
+
+	t.Run("failed to own the credentials Secret", func(t *testing.T) {
+		client := k8sfake.NewSimpleClientset(credentials.DeepCopy())
+		client.PrependReactor("patch", "secrets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			return true, nil, errors.New("fake")
+		})
+		server := &apiserver.Server{KClient: fake.NewSimpleClientset(source.DeepCopy()), Client: client}
+		w := request(server, map[string]string{"name": "copy"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		_, err := server.KClient.LinuxsurenV1alpha1().DevSpaces("default").Get(context.Background(), "copy", metav1.GetOptions{})
+		assert.True(t, apierrors.IsNotFound(err), err)
+		_, err = client.CoreV1().Secrets("default").Get(context.Background(), "copy-credentials", metav1.GetOptions{})
+		assert.True(t, apierrors.IsNotFound(err), err)
+	})
+}
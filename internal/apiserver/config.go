@@ -20,7 +20,6 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
-	"github.com/linuxsuren/kde/api/linuxsuren.github.io/v1alpha1"
 	"github.com/linuxsuren/kde/pkg/core"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
@@ -74,27 +73,3 @@ func (s *Server) UpdateConfig(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"message": "success"})
 	}
 }
-
-func setDefaultConfig(devspace *v1alpha1.DevSpace, config *core.Config) {
-	if devspace.Annotations == nil {
-		devspace.Annotations = make(map[string]string)
-	}
-	if _, ok := devspace.Annotations[v1alpha1.AnnoKeyImagePullPolicy]; !ok {
-		devspace.Annotations[v1alpha1.AnnoKeyImagePullPolicy] = config.ImagePullPolicy
-	}
-	if _, ok := devspace.Annotations[v1alpha1.AnnoKeyStorageClassName]; !ok {
-		devspace.Annotations[v1alpha1.AnnoKeyStorageClassName] = config.StorageClassName
-	}
-	if _, ok := devspace.Annotations[v1alpha1.AnnoKeyVolumeAccessMode]; !ok {
-		devspace.Annotations[v1alpha1.AnnoKeyVolumeAccessMode] = config.VolumeAccessMode
-	}
-	if _, ok := devspace.Annotations[v1alpha1.AnnoKeyVolumeMode]; !ok {
-		devspace.Annotations[v1alpha1.AnnoKeyVolumeMode] = config.VolumeMode
-	}
-	if _, ok := devspace.Annotations[v1alpha1.AnnoKeyIngressMode]; !ok {
-		devspace.Annotations[v1alpha1.AnnoKeyIngressMode] = config.IngressMode
-	}
-	if devspace.Spec.Host == "" {
-		devspace.Spec.Host = config.Host
-	}
-}
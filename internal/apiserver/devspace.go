@@ -23,6 +23,8 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/linuxsuren/kde/api/linuxsuren.github.io/v1alpha1"
@@ -42,6 +44,11 @@ type Server struct {
 	ExtClient       apiextensionsclientset.Interface
 	MetricClient    metricv1beta1.Interface
 	SystemNamespace string
+
+	// activities holds the last time of recording the activity of each DevSpace
+	activities sync.Map
+	// activityTokens holds the verified activity token of each DevSpace, see isActivityToken
+	activityTokens sync.Map
 }
 
 func (s *Server) CreateDevSpace(c *gin.Context) {
@@ -60,14 +67,15 @@ func (s *Server) CreateDevSpace(c *gin.Context) {
 		if err != nil {
 			c.Error(err)
 		}
-		setDefaultConfig(devSpace, config)
+		core.SetDefaultDevSpace(devSpace, config)
+		setOwner(c, devSpace)
 
 		result, err := s.KClient.LinuxsurenV1alpha1().DevSpaces(namespace).Create(ctx, devSpace, metav1.CreateOptions{})
 		if err != nil {
 			c.Error(err)
 			c.JSON(http.StatusBadRequest, err)
 		} else {
-			c.JSON(http.StatusOK, result)
+			c.JSON(http.StatusOK, hideCredentials(result))
 		}
 	}
 
@@ -83,6 +91,9 @@ func (s *Server) ListDevSpace(c *gin.Context) {
 		c.Error(err)
 		c.JSON(http.StatusBadRequest, err)
 	} else {
+		for i := range result.Items {
+			hideCredentials(&result.Items[i])
+		}
 		c.JSON(http.StatusOK, result)
 	}
 }
@@ -112,7 +123,7 @@ func (s *Server) UpdateDevSpace(c *gin.Context) {
 			c.Error(err)
 			c.JSON(http.StatusBadRequest, err)
 		} else {
-			c.JSON(http.StatusOK, result)
+			c.JSON(http.StatusOK, hideCredentials(result))
 		}
 	}
 }
@@ -167,7 +178,11 @@ func (s *Server) updateReplicas(ctx context.Context, namespace, name string, rep
 	}
 
 	devSpace.Spec.Replicas = &replicas
-	_, err = s.KClient.LinuxsurenV1alpha1().DevSpaces(namespace).Update(ctx, devSpace, metav1.UpdateOptions{})
+	if devSpace, err = s.KClient.LinuxsurenV1alpha1().DevSpaces(namespace).Update(ctx, devSpace, metav1.UpdateOptions{}); err == nil && replicas > 0 {
+		// turning on a DevSpace counts as an activity, otherwise an idle one will be suspended again
+		devSpace.Status.LastActivityTime = &metav1.Time{Time: time.Now()}
+		_, err = s.KClient.LinuxsurenV1alpha1().DevSpaces(namespace).UpdateStatus(ctx, devSpace, metav1.UpdateOptions{})
+	}
 	return
 }
 
@@ -179,8 +194,34 @@ func (s *Server) GetDevSpace(c *gin.Context) {
 		c.Error(err)
 		c.JSON(http.StatusBadRequest, err)
 	} else {
-		c.JSON(http.StatusOK, result)
+		c.JSON(http.StatusOK, hideCredentials(result))
+	}
+}
+
+// hideCredentials removes the plaintext passwords which are not moved into the Secrets yet,
+// the passwords never leave the cluster through the apiserver
+func hideCredentials(devSpace *v1alpha1.DevSpace) *v1alpha1.DevSpace {
+	spec := &devSpace.Spec
+	spec.Auth.SSHPrivateKey = ""
+	if spec.Auth.BasicAuth != nil {
+		spec.Auth.BasicAuth.Password = ""
+	}
+	if spec.Repository != nil {
+		spec.Repository.Password = ""
+	}
+	for i := range spec.Repositories {
+		spec.Repositories[i].Password = ""
+	}
+	if spec.Services.MySQL != nil {
+		spec.Services.MySQL.Password = ""
+	}
+	if spec.Services.Postgres != nil {
+		spec.Services.Postgres.Password = ""
+	}
+	if spec.Services.RabbitMQ != nil {
+		spec.Services.RabbitMQ.Password = ""
 	}
+	return devSpace
 }
 
 func (s *Server) GetDevSpaceLanguages(c *gin.Context) {
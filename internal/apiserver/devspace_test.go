@@ -0,0 +1,84 @@
+/*
+Copyright 2024 kde authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apiserver_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/linuxsuren/kde/api/linuxsuren.github.io/v1alpha1"
+	"github.com/linuxsuren/kde/internal/apiserver"
+	"github.com/linuxsuren/kde/pkg/client/clientset/versioned/fake"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/utils/ptr"
+)
+
+func TestDevSpaceCredentialsAreHidden(t *testing.T) {
+	devSpace := createDefaultDevSpace()
+	devSpace.Spec.Auth.BasicAuth = &v1alpha1.BasicAuth{Username: "admin", Password: "admin"}
+	devSpace.Spec.Auth.SSHPrivateKey = "private key"
+	devSpace.Spec.Repository = &v1alpha1.GitRepository{URL: "https://github.com/linuxsuren/kde", Password: "token"}
+	devSpace.Spec.Repositories = []v1alpha1.GitRepository{{URL: "https://github.com/linuxsuren/api-testing", Password: "token"}}
+	devSpace.Spec.Services = v1alpha1.Services{
+		MySQL:    &v1alpha1.MySQL{Enabled: ptr.To(true), Password: "mysql"},
+		Postgres: &v1alpha1.Postgres{Enabled: ptr.To(true), Password: "postgres"},
+		RabbitMQ: &v1alpha1.RabbitMQ{Enabled: ptr.To(true), Password: "rabbitmq"},
+	}
+	server := &apiserver.Server{KClient: fake.NewSimpleClientset(devSpace)}
+
+	engine := gin.New()
+	engine.GET("/devspace", server.ListDevSpace)
+	engine.GET("/devspace/:devspace", server.GetDevSpace)
+
+	verify := func(t *testing.T, devSpace v1alpha1.DevSpace) {
+		assert.Equal(t, "admin", devSpace.Spec.Auth.BasicAuth.Username)
+		assert.Empty(t, devSpace.Spec.Auth.BasicAuth.Password)
+		assert.Empty(t, devSpace.Spec.Auth.SSHPrivateKey)
+		assert.Empty(t, devSpace.Spec.Repository.Password)
+		assert.Empty(t, devSpace.Spec.Repositories[0].Password)
+		assert.Empty(t, devSpace.Spec.Services.MySQL.Password)
+		assert.Empty(t, devSpace.Spec.Services.Postgres.Password)
+		assert.Empty(t, devSpace.Spec.Services.RabbitMQ.Password)
+	}
+
+	t.Run("get", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/devspace/fake?namespace=default", nil)
+		engine.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		result := v1alpha1.DevSpace{}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+		verify(t, result)
+	})
+
+	t.Run("list", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/devspace?namespace=default", nil)
+		engine.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		result := v1alpha1.DevSpaceList{}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+		if assert.Len(t, result.Items, 1) {
+			verify(t, result.Items[0])
+		}
+	})
+}
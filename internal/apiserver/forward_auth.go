@@ -0,0 +1,391 @@
+/*
+Copyright 2024 kde authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apiserver
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/linuxsuren/kde/api/linuxsuren.github.io/v1alpha1"
+	"github.com/linuxsuren/oauth-hub"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// DevSpaceSessionCookie is the prefix of the cookies which hold the sessions of the DevSpaces,
+	// see DevSpaceSessionCookieOf
+	DevSpaceSessionCookie = "kde-devspace-session"
+	// SignInSessionCookie holds the session of the kde host, the DevSpace sessions are handed over from it
+	SignInSessionCookie = "kde-session"
+	// signInRedirectCookie remembers the sign-in page during the OAuth login
+	signInRedirectCookie = "kde-signin-rd"
+	// TicketParam is the query parameter which hands a session over from the kde host to the DevSpace host
+	TicketParam = "kde-ticket"
+
+	signInPath        = "/auth/devspace/signin"
+	sessionTTL        = 12 * time.Hour
+	ticketTTL         = time.Minute
+	authCheckInterval = time.Minute
+)
+
+// authSession is a logged-in user, the DevSpace is empty for the sessions of the kde host
+type authSession struct {
+	user     *oauth.UserInfo
+	devSpace string
+	expires  time.Time
+	// checkedAt is the last time of checking if the user may open the DevSpace
+	checkedAt time.Time
+}
+
+// authSessions are kept in memory like the tokens of the OAuth, the users log in again after a restart
+type authSessions struct {
+	lock  sync.Mutex
+	items map[string]*authSession
+}
+
+func (s *authSessions) create(user *oauth.UserInfo, devSpace string, ttl time.Duration) (id string, err error) {
+	if id, err = randomToken(); err != nil {
+		return
+	}
+	now := time.Now()
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for key, session := range s.items {
+		if now.After(session.expires) {
+			delete(s.items, key)
+		}
+	}
+	s.items[id] = &authSession{user: user, devSpace: devSpace, expires: now.Add(ttl), checkedAt: now}
+	return
+}
+
+// get returns the session of the given id, a ticket is taken only once
+func (s *authSessions) get(id string, take bool) (session *authSession) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if session = s.items[id]; session == nil {
+		return
+	}
+	if take || time.Now().After(session.expires) {
+		delete(s.items, id)
+	}
+	if time.Now().After(session.expires) {
+		session = nil
+	}
+	return
+}
+
+// due returns true if it is time to check if the user of the session may still open the DevSpace
+func (s *authSessions) due(session *authSession) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if time.Since(session.checkedAt) <= authCheckInterval {
+		return false
+	}
+	session.checkedAt = time.Now()
+	return true
+}
+
+// revoke expires the session, e.g. the user is not a collaborator anymore
+func (s *authSessions) revoke(session *authSession) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	session.expires = time.Time{}
+}
+
+var (
+	sessions = &authSessions{items: map[string]*authSession{}}
+	tickets  = &authSessions{items: map[string]*authSession{}}
+)
+
+// UsernameOf returns the name of the user which is recorded as the owner of the DevSpaces
+func UsernameOf(user *oauth.UserInfo) string {
+	for _, name := range []string{user.PreferredUsername, user.Email, user.Name} {
+		if name != "" {
+			return name
+		}
+	}
+	return ""
+}
+
+// isAllowed returns true if the user is the owner or a collaborator of the DevSpace
+func isAllowed(devSpace *v1alpha1.DevSpace, user *oauth.UserInfo) bool {
+	allowed := append([]string{devSpace.Annotations[v1alpha1.AnnoKeyOwner]}, devSpace.Spec.Auth.Collaborators...)
+	for _, name := range []string{user.PreferredUsername, user.Email, user.Name} {
+		if name == "" {
+			continue
+		}
+		for _, item := range allowed {
+			if strings.EqualFold(item, name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// originalURL returns the URL which is requested through the ingress,
+// ingress-nginx gives the X-Original-URL, and Traefik gives the X-Forwarded ones
+func originalURL(req *http.Request) (*url.URL, error) {
+	if raw := req.Header.Get("X-Original-URL"); raw != "" {
+		return url.Parse(raw)
+	}
+	proto := req.Header.Get("X-Forwarded-Proto")
+	if proto == "" {
+		proto = "http"
+	}
+	return url.Parse(fmt.Sprintf("%s://%s%s", proto, req.Header.Get("X-Forwarded-Host"), req.Header.Get("X-Forwarded-Uri")))
+}
+
+// isHostOf returns true if the given host is the one of the DevSpace, or one of its exposed ports
+func isHostOf(devSpace *v1alpha1.DevSpace, host string) bool {
+	devSpaceHost := devSpaceHostOf(devSpace)
+	return devSpaceHost != "" && (host == devSpaceHost || strings.HasSuffix(host, "."+devSpaceHost))
+}
+
+// DevSpaceSessionCookieOf returns the name of the session cookie of the given DevSpace. The DevSpaces share
+// one host in the path ingress mode, so the name tells them apart. The namespace has no underscore.
+func DevSpaceSessionCookieOf(namespace, name string) string {
+	return DevSpaceSessionCookie + "_" + namespace + "_" + name
+}
+
+// sessionOf returns the session from the cookie of the given DevSpace, it is nil if the cookie is absent or invalid
+func sessionOf(c *gin.Context, namespace, name string) (session *authSession) {
+	if id, err := c.Cookie(DevSpaceSessionCookieOf(namespace, name)); err == nil {
+		if session = sessions.get(id, false); session != nil && session.devSpace != namespace+"/"+name {
+			session = nil
+		}
+	}
+	return
+}
+
+func devSpaceHostOf(devSpace *v1alpha1.DevSpace) string {
+	link := devSpace.Status.Link
+	if _, host, ok := strings.Cut(link, "://"); ok {
+		return host
+	}
+	return link
+}
+
+func isSecure(req *http.Request) bool {
+	return req.TLS != nil || req.Header.Get("X-Forwarded-Proto") == "https"
+}
+
+// DevSpaceForwardAuth tells the ingress whether the request to a DevSpace is allowed. It is allowed if the session
+// cookie belongs to the owner or a collaborator, the session is created from the ticket of the sign-in page.
+// The unauthenticated requests are redirected to the sign-in page if it is given, since Traefik returns the
+// response as it is, otherwise ingress-nginx redirects the 401 to its auth-signin.
+func (s *Server) DevSpaceForwardAuth(c *gin.Context) {
+	ctx := c.Request.Context()
+	ns := c.Query("namespace")
+	name := c.Query("devspace")
+	signIn := c.Query("signin")
+	key := ns + "/" + name
+
+	original, err := originalURL(c.Request)
+	if err != nil {
+		c.Error(err)
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	var devSpace *v1alpha1.DevSpace
+	getDevSpace := func() bool {
+		if devSpace == nil {
+			if devSpace, err = s.KClient.LinuxsurenV1alpha1().DevSpaces(ns).Get(ctx, name, metav1.GetOptions{}); err != nil {
+				c.Error(err)
+				c.AbortWithStatus(http.StatusNotFound)
+				return false
+			}
+		}
+		return true
+	}
+
+	var session *authSession
+	handedOver := false
+	if ticket := original.Query().Get(TicketParam); ticket != "" {
+		if session = tickets.get(ticket, true); session != nil && session.devSpace == key {
+			if !getDevSpace() {
+				return
+			}
+			var id string
+			if id, err = sessions.create(session.user, key, sessionTTL); err != nil {
+				c.Error(err)
+				c.AbortWithStatus(http.StatusInternalServerError)
+				return
+			}
+			// the cookie is sent to the exposed ports as well
+			domain := ""
+			if isHostOf(devSpace, original.Hostname()) {
+				domain = devSpaceHostOf(devSpace)
+			}
+			c.SetSameSite(http.SameSiteLaxMode)
+			c.SetCookie(DevSpaceSessionCookieOf(ns, name), id, int(sessionTTL.Seconds()), "/", domain,
+				original.Scheme == "https", true)
+			handedOver = true
+		} else {
+			// the ticket is used already, e.g. the page is reloaded
+			session = nil
+		}
+	}
+	if session == nil {
+		session = sessionOf(c, ns, name)
+	}
+
+	switch {
+	case session == nil && signIn != "":
+		c.Redirect(http.StatusFound, signInURL(signIn, ns, name, original))
+		return
+	case session == nil:
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	case sessions.due(session):
+		// the collaborators might be changed
+		if !getDevSpace() {
+			return
+		}
+		if !isAllowed(devSpace, session.user) {
+			sessions.revoke(session)
+			c.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+	}
+
+	if handedOver && signIn != "" {
+		// Traefik returns the redirection with the cookie to the browser
+		query := original.Query()
+		query.Del(TicketParam)
+		original.RawQuery = query.Encode()
+		c.Redirect(http.StatusFound, original.String())
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+func signInURL(signIn, namespace, name string, original *url.URL) string {
+	query := url.Values{}
+	query.Set("namespace", namespace)
+	query.Set("devspace", name)
+	query.Set("rd", original.String())
+	return signIn + "?" + query.Encode()
+}
+
+// DevSpaceSignIn is served on the kde host, it logs the user in through the OAuth provider,
+// then hands a ticket over to the DevSpace host since the session cookie is scoped to it
+func (s *Server) DevSpaceSignIn(c *gin.Context) {
+	ctx := c.Request.Context()
+	ns := c.Query("namespace")
+	name := c.Query("devspace")
+
+	var session *authSession
+	if id, err := c.Cookie(SignInSessionCookie); err == nil {
+		if session = sessions.get(id, false); session != nil && session.devSpace != "" {
+			session = nil
+		}
+	}
+	if session == nil {
+		c.SetSameSite(http.SameSiteLaxMode)
+		c.SetCookie(signInRedirectCookie, c.Request.URL.RequestURI(), int(10*time.Minute/time.Second), "/", "",
+			isSecure(c.Request), true)
+		// the OAuth callback is derived from the referer if there is one
+		c.Header("Referrer-Policy", "no-referrer")
+		c.Redirect(http.StatusFound, "/oauth2/login")
+		return
+	}
+
+	devSpace, err := s.KClient.LinuxsurenV1alpha1().DevSpaces(ns).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusNotFound, err)
+		return
+	}
+	if !isAllowed(devSpace, session.user) {
+		c.String(http.StatusForbidden, "%s is not allowed to open %s/%s", UsernameOf(session.user), ns, name)
+		return
+	}
+
+	var target *url.URL
+	if target, err = url.Parse(c.Query("rd")); err != nil || !isHostOf(devSpace, target.Hostname()) {
+		// it is not an open redirection, the ticket is for the DevSpace only
+		c.String(http.StatusBadRequest, "invalid redirection %q", c.Query("rd"))
+		return
+	}
+
+	var ticket string
+	if ticket, err = tickets.create(session.user, ns+"/"+name, ticketTTL); err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, err)
+		return
+	}
+	query := target.Query()
+	query.Set(TicketParam, ticket)
+	target.RawQuery = query.Encode()
+	c.Redirect(http.StatusFound, target.String())
+}
+
+// recordedResponse keeps the response of the OAuth callback, the sign-in of the DevSpaces continues after it
+type recordedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (r *recordedResponse) Header() http.Header {
+	return r.header
+}
+
+func (r *recordedResponse) Write(data []byte) (int, error) {
+	return r.body.Write(data)
+}
+
+func (r *recordedResponse) WriteHeader(status int) {
+	r.status = status
+}
+
+// continueSignIn creates the session of the kde host after the OAuth login, then goes back to the sign-in page.
+// It returns false if the login is not started by the sign-in page.
+func continueSignIn(c *gin.Context, callback *recordedResponse) bool {
+	rd, err := c.Cookie(signInRedirectCookie)
+	if err != nil || !strings.HasPrefix(rd, signInPath+"?") {
+		return false
+	}
+	location, err := url.Parse(callback.header.Get("Location"))
+	if err != nil {
+		return false
+	}
+	user := oauth.GetUser(location.Query().Get("access_token"))
+	if user == nil {
+		return false
+	}
+
+	var id string
+	if id, err = sessions.create(user, "", sessionTTL); err != nil {
+		c.Error(err)
+		return false
+	}
+	secure := isSecure(c.Request)
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(signInRedirectCookie, "", -1, "/", "", secure, true)
+	c.SetCookie(SignInSessionCookie, id, int(sessionTTL.Seconds()), "/", "", secure, true)
+	c.Redirect(http.StatusFound, rd)
+	return true
+}
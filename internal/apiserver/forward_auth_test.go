@@ -0,0 +1,373 @@
+/*
+Copyright 2024 kde authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apiserver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/linuxsuren/kde/api/linuxsuren.github.io/v1alpha1"
+	"github.com/linuxsuren/kde/pkg/client/clientset/versioned/fake"
+	"github.com/linuxsuren/oauth-hub"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func createAuthDevSpace() *v1alpha1.DevSpace {
+	return &v1alpha1.DevSpace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "fake",
+			Namespace: "default",
+			Annotations: map[string]string{
+				v1alpha1.AnnoKeyOwner: "rick",
+			},
+		},
+		Spec: v1alpha1.DevSpaceSpec{
+			Auth: v1alpha1.DevSpaceAuth{
+				Collaborators: []string{"Morty@example.com"},
+			},
+		},
+		Status: v1alpha1.DevSpaceStatus{
+			Link: "https://fake.kde.example.com",
+		},
+	}
+}
+
+func TestUsernameOf(t *testing.T) {
+	assert.Equal(t, "rick", UsernameOf(&oauth.UserInfo{PreferredUsername: "rick", Email: "rick@example.com"}))
+	assert.Equal(t, "rick@example.com", UsernameOf(&oauth.UserInfo{Email: "rick@example.com", Name: "Rick"}))
+	assert.Equal(t, "Rick", UsernameOf(&oauth.UserInfo{Name: "Rick"}))
+	assert.Empty(t, UsernameOf(&oauth.UserInfo{}))
+}
+
+func TestIsAllowed(t *testing.T) {
+	devSpace := createAuthDevSpace()
+	assert.True(t, isAllowed(devSpace, &oauth.UserInfo{PreferredUsername: "rick"}))
+	assert.True(t, isAllowed(devSpace, &oauth.UserInfo{Email: "morty@example.com"}))
+	assert.False(t, isAllowed(devSpace, &oauth.UserInfo{PreferredUsername: "summer"}))
+	assert.False(t, isAllowed(devSpace, &oauth.UserInfo{}))
+
+	delete(devSpace.Annotations, v1alpha1.AnnoKeyOwner)
+	assert.False(t, isAllowed(devSpace, &oauth.UserInfo{}))
+}
+
+func TestDevSpaceForwardAuth(t *testing.T) {
+	server := &Server{KClient: fake.NewSimpleClientset(createAuthDevSpace())}
+	engine := gin.New()
+	engine.GET("/auth/devspace", server.DevSpaceForwardAuth)
+
+	request := func(query, original, cookie string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(http.MethodGet, "/auth/devspace?namespace=default&devspace=fake"+query, nil)
+		req.Header.Set("X-Original-URL", original)
+		if cookie != "" {
+			req.AddCookie(&http.Cookie{Name: DevSpaceSessionCookieOf("default", "fake"), Value: cookie})
+		}
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("without session", func(t *testing.T) {
+		w := request("", "https://fake.kde.example.com/", "")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		w = request("&signin=https://kde.example.com/auth/devspace/signin", "https://fake.kde.example.com/?folder=/workspace", "")
+		assert.Equal(t, http.StatusFound, w.Code)
+		location, err := url.Parse(w.Header().Get("Location"))
+		assert.NoError(t, err)
+		assert.Equal(t, "kde.example.com", location.Host)
+		assert.Equal(t, signInPath, location.Path)
+		assert.Equal(t, "fake", location.Query().Get("devspace"))
+		assert.Equal(t, "https://fake.kde.example.com/?folder=/workspace", location.Query().Get("rd"))
+	})
+
+	t.Run("hand over the ticket", func(t *testing.T) {
+		ticket, err := tickets.create(&oauth.UserInfo{PreferredUsername: "rick"}, "default/fake", ticketTTL)
+		assert.NoError(t, err)
+
+		w := request("", "https://fake.kde.example.com/?"+TicketParam+"="+ticket, "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		cookies := w.Result().Cookies()
+		if !assert.Len(t, cookies, 1) {
+			return
+		}
+		assert.Equal(t, "kde-devspace-session_default_fake", cookies[0].Name)
+		assert.Equal(t, "fake.kde.example.com", cookies[0].Domain)
+		assert.True(t, cookies[0].Secure)
+		assert.True(t, cookies[0].HttpOnly)
+
+		// the session works for the exposed ports as well
+		w = request("", "https://8080.fake.kde.example.com/", cookies[0].Value)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		// the ticket is taken already
+		w = request("", "https://fake.kde.example.com/?"+TicketParam+"="+ticket, "")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("hand over the ticket through Traefik", func(t *testing.T) {
+		ticket, err := tickets.create(&oauth.UserInfo{PreferredUsername: "rick"}, "default/fake", ticketTTL)
+		assert.NoError(t, err)
+
+		w := request("&signin=https://kde.example.com/auth/devspace/signin",
+			"https://fake.kde.example.com/?folder=/workspace&"+TicketParam+"="+ticket, "")
+		assert.Equal(t, http.StatusFound, w.Code)
+		assert.Equal(t, "https://fake.kde.example.com/?folder=%2Fworkspace", w.Header().Get("Location"))
+		assert.Len(t, w.Result().Cookies(), 1)
+	})
+
+	t.Run("the session of another DevSpace", func(t *testing.T) {
+		id, err := sessions.create(&oauth.UserInfo{PreferredUsername: "rick"}, "default/another", sessionTTL)
+		assert.NoError(t, err)
+		w := request("", "https://fake.kde.example.com/", id)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("the DevSpaces on the same host", func(t *testing.T) {
+		// all the DevSpaces share the host in the path ingress mode
+		ticket, err := tickets.create(&oauth.UserInfo{PreferredUsername: "rick"}, "default/fake", ticketTTL)
+		assert.NoError(t, err)
+		w := request("", "https://kde.example.com/fake/?"+TicketParam+"="+ticket, "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		cookies := w.Result().Cookies()
+		if !assert.Len(t, cookies, 1) {
+			return
+		}
+		assert.Empty(t, cookies[0].Domain)
+
+		another, err := sessions.create(&oauth.UserInfo{PreferredUsername: "rick"}, "default/another", sessionTTL)
+		assert.NoError(t, err)
+		req, _ := http.NewRequest(http.MethodGet, "/auth/devspace?namespace=default&devspace=fake", nil)
+		req.Header.Set("X-Original-URL", "https://kde.example.com/fake/")
+		req.AddCookie(&http.Cookie{Name: DevSpaceSessionCookieOf("default", "another"), Value: another})
+		req.AddCookie(cookies[0])
+		w = httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("not a collaborator anymore", func(t *testing.T) {
+		id, err := sessions.create(&oauth.UserInfo{PreferredUsername: "summer"}, "default/fake", sessionTTL)
+		assert.NoError(t, err)
+		// the check is not due yet
+		w := request("", "https://fake.kde.example.com/", id)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		sessions.get(id, false).checkedAt = time.Now().Add(-2 * authCheckInterval)
+		w = request("", "https://fake.kde.example.com/", id)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		// the session is revoked
+		w = request("", "https://fake.kde.example.com/", id)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
+
+func TestWakeUpManualDevSpace(t *testing.T) {
+	request := func(server *Server, cookie string) *httptest.ResponseRecorder {
+		engine := gin.New()
+		engine.GET("/wakeup/:namespace/:devspace", server.WakeUpDevSpace)
+		req, _ := http.NewRequest(http.MethodGet, "/wakeup/default/fake?token=activity", nil)
+		if cookie != "" {
+			req.AddCookie(&http.Cookie{Name: DevSpaceSessionCookieOf("default", "fake"), Value: cookie})
+		}
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		return w
+	}
+	newServer := func() *Server {
+		devSpace := createAuthDevSpace()
+		devSpace.Spec.Replicas = new(int32)
+		devSpace.Status.SuspendReason = v1alpha1.DevSpaceSuspendReasonManual
+		return &Server{
+			Client: k8sfake.NewSimpleClientset(&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "fake-credentials", Namespace: "default"},
+				Data:       map[string][]byte{"activity-token": []byte("activity")},
+			}),
+			KClient: fake.NewSimpleClientset(devSpace),
+		}
+	}
+	replicasOf := func(server *Server) int32 {
+		devSpace, err := server.KClient.LinuxsurenV1alpha1().DevSpaces("default").Get(context.Background(), "fake", metav1.GetOptions{})
+		assert.NoError(t, err)
+		return *devSpace.Spec.Replicas
+	}
+
+	t.Run("collaborator", func(t *testing.T) {
+		id, err := sessions.create(&oauth.UserInfo{Email: "morty@example.com"}, "default/fake", sessionTTL)
+		assert.NoError(t, err)
+
+		server := newServer()
+		w := request(server, id)
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.Contains(t, w.Body.String(), `http-equiv="refresh"`)
+		assert.Equal(t, int32(1), replicasOf(server))
+	})
+
+	t.Run("stays off", func(t *testing.T) {
+		stranger, err := sessions.create(&oauth.UserInfo{PreferredUsername: "summer"}, "default/fake", sessionTTL)
+		assert.NoError(t, err)
+		another, err := sessions.create(&oauth.UserInfo{PreferredUsername: "rick"}, "default/another", sessionTTL)
+		assert.NoError(t, err)
+
+		for _, cookie := range []string{"", "invalid", stranger, another} {
+			server := newServer()
+			w := request(server, cookie)
+			assert.Equal(t, http.StatusServiceUnavailable, w.Code, cookie)
+			assert.NotContains(t, w.Body.String(), `http-equiv="refresh"`, cookie)
+			assert.Contains(t, w.Body.String(), "turned off by its owner", cookie)
+			assert.Equal(t, int32(0), replicasOf(server), cookie)
+		}
+	})
+}
+
+func TestDevSpaceSignIn(t *testing.T) {
+	server := &Server{KClient: fake.NewSimpleClientset(createAuthDevSpace())}
+	engine := gin.New()
+	engine.GET(signInPath, server.DevSpaceSignIn)
+
+	request := func(name, rd, cookie string) *httptest.ResponseRecorder {
+		query := url.Values{}
+		query.Set("namespace", "default")
+		query.Set("devspace", name)
+		query.Set("rd", rd)
+		req, _ := http.NewRequest(http.MethodGet, signInPath+"?"+query.Encode(), nil)
+		if cookie != "" {
+			req.AddCookie(&http.Cookie{Name: SignInSessionCookie, Value: cookie})
+		}
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		return w
+	}
+	login := func(username string) string {
+		id, err := sessions.create(&oauth.UserInfo{PreferredUsername: username}, "", sessionTTL)
+		assert.NoError(t, err)
+		return id
+	}
+
+	t.Run("without session", func(t *testing.T) {
+		w := request("fake", "https://fake.kde.example.com/", "")
+		assert.Equal(t, http.StatusFound, w.Code)
+		assert.Equal(t, "/oauth2/login", w.Header().Get("Location"))
+		assert.Equal(t, "no-referrer", w.Header().Get("Referrer-Policy"))
+		cookies := w.Result().Cookies()
+		if assert.Len(t, cookies, 1) {
+			assert.Equal(t, signInRedirectCookie, cookies[0].Name)
+			// gin escapes the cookie values
+			rd, err := url.QueryUnescape(cookies[0].Value)
+			assert.NoError(t, err)
+			assert.Contains(t, rd, signInPath+"?")
+		}
+	})
+
+	t.Run("issue a ticket", func(t *testing.T) {
+		w := request("fake", "https://8080.fake.kde.example.com/api", login("rick"))
+		assert.Equal(t, http.StatusFound, w.Code)
+		location, err := url.Parse(w.Header().Get("Location"))
+		assert.NoError(t, err)
+		assert.Equal(t, "8080.fake.kde.example.com", location.Host)
+		assert.Equal(t, "/api", location.Path)
+
+		session := tickets.get(location.Query().Get(TicketParam), true)
+		if assert.NotNil(t, session) {
+			assert.Equal(t, "default/fake", session.devSpace)
+			assert.Equal(t, "rick", session.user.PreferredUsername)
+		}
+	})
+
+	t.Run("invalid redirection", func(t *testing.T) {
+		w := request("fake", "https://evil.example.com/", login("rick"))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("not allowed", func(t *testing.T) {
+		w := request("fake", "https://fake.kde.example.com/", login("summer"))
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		w := request("missing", "https://missing.kde.example.com/", login("rick"))
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+func TestContinueSignIn(t *testing.T) {
+	oauth.SetUser("fake-token", &oauth.UserInfo{PreferredUsername: "rick"})
+	callback := func(location string) *recordedResponse {
+		response := &recordedResponse{header: http.Header{}, status: http.StatusFound}
+		response.header.Set("Location", location)
+		return response
+	}
+	context := func(rd string) (*gin.Context, *httptest.ResponseRecorder) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request, _ = http.NewRequest(http.MethodGet, "/oauth2/callback", nil)
+		if rd != "" {
+			c.Request.AddCookie(&http.Cookie{Name: signInRedirectCookie, Value: rd})
+		}
+		return c, w
+	}
+
+	t.Run("not started by the sign-in page", func(t *testing.T) {
+		c, _ := context("")
+		assert.False(t, continueSignIn(c, callback("/?access_token=fake-token")))
+		c, _ = context("/devspaces")
+		assert.False(t, continueSignIn(c, callback("/?access_token=fake-token")))
+	})
+
+	t.Run("unknown token", func(t *testing.T) {
+		c, _ := context(signInPath + "?devspace=fake")
+		assert.False(t, continueSignIn(c, callback("/?access_token=unknown")))
+	})
+
+	t.Run("back to the sign-in page", func(t *testing.T) {
+		c, w := context(signInPath + "?devspace=fake")
+		assert.True(t, continueSignIn(c, callback("/?access_token=fake-token")))
+		assert.Equal(t, http.StatusFound, w.Code)
+		assert.Equal(t, signInPath+"?devspace=fake", w.Header().Get("Location"))
+
+		var sessionID string
+		for _, cookie := range w.Result().Cookies() {
+			if cookie.Name == SignInSessionCookie {
+				sessionID = cookie.Value
+			}
+		}
+		session := sessions.get(sessionID, false)
+		if assert.NotNil(t, session) {
+			assert.Empty(t, session.devSpace)
+			assert.Equal(t, "rick", session.user.PreferredUsername)
+		}
+	})
+}
+
+func TestSetOwner(t *testing.T) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	devSpace := &v1alpha1.DevSpace{}
+	setOwner(c, devSpace)
+	assert.NotContains(t, devSpace.Annotations, v1alpha1.AnnoKeyOwner)
+
+	c.Set(ContextKeyUser, &oauth.UserInfo{Email: "rick@example.com"})
+	setOwner(c, devSpace)
+	assert.Equal(t, "rick@example.com", devSpace.Annotations[v1alpha1.AnnoKeyOwner])
+}
@@ -74,12 +74,21 @@ func (s *Server) Install(c *gin.Context) {
 		},
 	}, metav1.CreateOptions{})
 
-	crdDevSpace := getCRD("linuxsuren.github.io_devspaces.yaml")
+	crdDevSpace := withoutConversion(getCRD("linuxsuren.github.io_devspaces.yaml"))
 	_, crdDevSpaceErr := s.ExtClient.ApiextensionsV1().CustomResourceDefinitions().Create(ctx, crdDevSpace, metav1.CreateOptions{})
 
 	crdUser := getCRD("linuxsuren.github.io_users.yaml")
 	_, crdUserErr := s.ExtClient.ApiextensionsV1().CustomResourceDefinitions().Create(ctx, crdUser, metav1.CreateOptions{})
 
+	crdSnapshot := getCRD("linuxsuren.github.io_devspacesnapshots.yaml")
+	_, crdSnapshotErr := s.ExtClient.ApiextensionsV1().CustomResourceDefinitions().Create(ctx, crdSnapshot, metav1.CreateOptions{})
+
+	crdTemplate := getCRD("linuxsuren.github.io_devspacetemplates.yaml")
+	_, crdTemplateErr := s.ExtClient.ApiextensionsV1().CustomResourceDefinitions().Create(ctx, crdTemplate, metav1.CreateOptions{})
+
+	crdClusterTemplate := getCRD("linuxsuren.github.io_clusterdevspacetemplates.yaml")
+	_, crdClusterTemplateErr := s.ExtClient.ApiextensionsV1().CustomResourceDefinitions().Create(ctx, crdClusterTemplate, metav1.CreateOptions{})
+
 	sa := getServiceAccount("service_account.yaml")
 	sa.SetNamespace(namespace)
 	_, saErr := s.Client.CoreV1().ServiceAccounts(namespace).Create(ctx, sa, metav1.CreateOptions{})
@@ -106,6 +115,8 @@ func (s *Server) Install(c *gin.Context) {
 	if installReq.Image != "" {
 		deploy.Spec.Template.Spec.Containers[0].Image = installReq.Image
 	}
+	// there are no certificates of the webhook server here, see withoutConversion
+	deploy.Spec.Template.Spec.Containers[0].Args = append(deploy.Spec.Template.Spec.Containers[0].Args, "--enable-webhooks=false")
 	_, deployErr := s.Client.AppsV1().Deployments(namespace).Create(ctx, deploy, metav1.CreateOptions{})
 
 	apiserverDeploy := getDeployment("apiserver-deploy.yaml")
@@ -123,7 +134,9 @@ func (s *Server) Install(c *gin.Context) {
 	ingress.SetNamespace(namespace)
 	_, ingressErr := s.Client.NetworkingV1().Ingresses(namespace).Create(ctx, ingress, metav1.CreateOptions{})
 
-	err = errors.Join(client.IgnoreAlreadyExists(crdDevSpaceErr), client.IgnoreAlreadyExists(crdUserErr), client.IgnoreAlreadyExists(nsErr),
+	err = errors.Join(client.IgnoreAlreadyExists(crdDevSpaceErr), client.IgnoreAlreadyExists(crdUserErr),
+		client.IgnoreAlreadyExists(crdSnapshotErr), client.IgnoreAlreadyExists(crdTemplateErr),
+		client.IgnoreAlreadyExists(crdClusterTemplateErr), client.IgnoreAlreadyExists(nsErr),
 		client.IgnoreAlreadyExists(saErr), client.IgnoreNotFound(clusterRoleErr), client.IgnoreNotFound(clusterRoleBindingErr),
 		client.IgnoreAlreadyExists(cmErr),
 		client.IgnoreAlreadyExists(deployErr), client.IgnoreAlreadyExists(apiserverDeployErr),
@@ -146,6 +159,15 @@ func (s *Server) Uninstall(c *gin.Context) {
 	crdUser := getCRD("linuxsuren.github.io_users.yaml")
 	crdUserErr := s.ExtClient.ApiextensionsV1().CustomResourceDefinitions().Delete(ctx, crdUser.GetName(), metav1.DeleteOptions{})
 
+	crdSnapshot := getCRD("linuxsuren.github.io_devspacesnapshots.yaml")
+	crdSnapshotErr := s.ExtClient.ApiextensionsV1().CustomResourceDefinitions().Delete(ctx, crdSnapshot.GetName(), metav1.DeleteOptions{})
+
+	crdTemplate := getCRD("linuxsuren.github.io_devspacetemplates.yaml")
+	crdTemplateErr := s.ExtClient.ApiextensionsV1().CustomResourceDefinitions().Delete(ctx, crdTemplate.GetName(), metav1.DeleteOptions{})
+
+	crdClusterTemplate := getCRD("linuxsuren.github.io_clusterdevspacetemplates.yaml")
+	crdClusterTemplateErr := s.ExtClient.ApiextensionsV1().CustomResourceDefinitions().Delete(ctx, crdClusterTemplate.GetName(), metav1.DeleteOptions{})
+
 	sa := getServiceAccount("service_account.yaml")
 	saErr := s.Client.CoreV1().ServiceAccounts(namespace).Delete(ctx, sa.GetName(), metav1.DeleteOptions{})
 
@@ -172,7 +194,8 @@ func (s *Server) Uninstall(c *gin.Context) {
 
 	nsErr := s.Client.CoreV1().Namespaces().Delete(ctx, namespace, metav1.DeleteOptions{})
 
-	err := errors.Join(client.IgnoreNotFound(crdDevSpaceErr), client.IgnoreNotFound(crdUserErr),
+	err := errors.Join(client.IgnoreNotFound(crdDevSpaceErr), client.IgnoreNotFound(crdUserErr), client.IgnoreNotFound(crdSnapshotErr),
+		client.IgnoreNotFound(crdTemplateErr), client.IgnoreNotFound(crdClusterTemplateErr),
 		client.IgnoreNotFound(saErr), client.IgnoreNotFound(clusterRoleErr), client.IgnoreNotFound(clusterRoleBindingErr),
 		client.IgnoreNotFound(cmErr), client.IgnoreNotFound(deployErr),
 		client.IgnoreNotFound(apiserverDeployErr), client.IgnoreNotFound(serviceErr),
@@ -196,6 +219,15 @@ func getCRD(name string) *extv1.CustomResourceDefinition {
 	return crd
 }
 
+// withoutConversion serves the storage version only. The built-in installer does not issue
+// the certificates of the webhook server, so there is no conversion webhook for the other versions.
+func withoutConversion(crd *extv1.CustomResourceDefinition) *extv1.CustomResourceDefinition {
+	for i := range crd.Spec.Versions {
+		crd.Spec.Versions[i].Served = crd.Spec.Versions[i].Storage
+	}
+	return crd
+}
+
 func getDeployment(name string) *appsv1.Deployment {
 	var err error
 	data, _ := config.GetFile(filepath.Join("manager", name))
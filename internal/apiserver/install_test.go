@@ -0,0 +1,39 @@
+/*
+Copyright 2024 kde authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apiserver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithoutConversion(t *testing.T) {
+	crd := getCRD("linuxsuren.github.io_devspaces.yaml")
+	served := map[string]bool{}
+	for _, version := range crd.Spec.Versions {
+		served[version.Name] = version.Served
+	}
+	// the v1beta1 is served along with the conversion webhook
+	assert.Equal(t, map[string]bool{"v1alpha1": true, "v1beta1": true}, served)
+
+	served = map[string]bool{}
+	for _, version := range withoutConversion(crd).Spec.Versions {
+		served[version.Name] = version.Served
+	}
+	assert.Equal(t, map[string]bool{"v1alpha1": true, "v1beta1": false}, served)
+}
@@ -21,6 +21,7 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/linuxsuren/kde/api/linuxsuren.github.io/v1alpha1"
 	ginhttp "github.com/linuxsuren/kde/pkg/http"
 	"github.com/linuxsuren/oauth-hub"
 	"golang.org/x/oauth2"
@@ -58,7 +59,17 @@ func RegisterOAuth(r ginhttp.GinEngine, providerName, clientID, clientSecret str
 		authHandler.RequestLocalToken(c.Writer, c.Request, nil)
 	})
 	r.GET("/oauth2/callback", func(c *gin.Context) {
-		authHandler.Callback(c.Writer, c.Request, nil)
+		callback := &recordedResponse{header: http.Header{}, status: http.StatusOK}
+		authHandler.Callback(callback, c.Request, nil)
+		if continueSignIn(c, callback) {
+			return
+		}
+
+		for key, values := range callback.header {
+			c.Writer.Header()[key] = values
+		}
+		c.Writer.WriteHeader(callback.status)
+		_, _ = c.Writer.Write(callback.body.Bytes())
 	})
 	return
 }
@@ -81,3 +92,16 @@ func OAuthHandler(provider string) func(*gin.Context) {
 		c.Set(ContextKeyUser, user)
 	}
 }
+
+// setOwner records the logged-in user as the owner of the DevSpace
+func setOwner(c *gin.Context, devSpace *v1alpha1.DevSpace) {
+	value, _ := c.Get(ContextKeyUser)
+	user, ok := value.(*oauth.UserInfo)
+	if !ok || user == nil {
+		return
+	}
+	if devSpace.Annotations == nil {
+		devSpace.Annotations = map[string]string{}
+	}
+	devSpace.Annotations[v1alpha1.AnnoKeyOwner] = UsernameOf(user)
+}
@@ -0,0 +1,115 @@
+/*
+Copyright 2024 kde authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apiserver
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/linuxsuren/kde/api/linuxsuren.github.io/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// maxScheduleOverride is the longest time a schedule override can last
+const maxScheduleOverride = 7 * 24 * time.Hour
+
+// ExtendDevSpace keeps the DevSpace alive until the given time, even if it is out of the alive windows.
+// The query parameter until could be a RFC3339 time or a duration, e.g. 2h.
+func (s *Server) ExtendDevSpace(c *gin.Context) {
+	s.setScheduleOverride(c, v1alpha1.ScheduleOverrideKeepAlive)
+}
+
+// SuspendDevSpace turns the DevSpace off until the given time, even if it is in the alive windows
+func (s *Server) SuspendDevSpace(c *gin.Context) {
+	s.setScheduleOverride(c, v1alpha1.ScheduleOverrideOff)
+}
+
+// CancelScheduleOverride removes the schedule override of the DevSpace
+func (s *Server) CancelScheduleOverride(c *gin.Context) {
+	name := c.Params.ByName("devspace")
+	namespace := getNamespaceFromQuery(c)
+
+	if err := s.updateScheduleOverride(c.Request.Context(), namespace, name, nil); err != nil {
+		c.Error(err)
+		c.JSON(http.StatusBadRequest, err)
+		return
+	}
+	c.JSON(http.StatusOK, "")
+}
+
+func (s *Server) setScheduleOverride(c *gin.Context, mode v1alpha1.ScheduleOverrideMode) {
+	name := c.Params.ByName("devspace")
+	namespace := getNamespaceFromQuery(c)
+
+	until, err := parseUntil(c.Query("until"), time.Now())
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	override := &v1alpha1.ScheduleOverride{
+		Mode:  mode,
+		Until: metav1.NewTime(until),
+	}
+	if err = s.updateScheduleOverride(c.Request.Context(), namespace, name, override); err != nil {
+		c.Error(err)
+		c.JSON(http.StatusBadRequest, err)
+		return
+	}
+	c.JSON(http.StatusOK, override)
+}
+
+func (s *Server) updateScheduleOverride(ctx context.Context, namespace, name string, override *v1alpha1.ScheduleOverride) (err error) {
+	var devSpace *v1alpha1.DevSpace
+	if devSpace, err = s.KClient.LinuxsurenV1alpha1().DevSpaces(namespace).Get(ctx, name, metav1.GetOptions{}); err != nil {
+		return
+	}
+
+	devSpace.Spec.ScheduleOverride = override
+	_, err = s.KClient.LinuxsurenV1alpha1().DevSpaces(namespace).Update(ctx, devSpace, metav1.UpdateOptions{})
+	return
+}
+
+// parseUntil parses a RFC3339 time or a duration relative to now,
+// the result must be in the future and not later than the max override duration
+func parseUntil(until string, now time.Time) (result time.Time, err error) {
+	if until == "" {
+		err = fmt.Errorf("the query parameter until is required")
+		return
+	}
+
+	if result, err = time.Parse(time.RFC3339, until); err != nil {
+		var duration time.Duration
+		if duration, err = time.ParseDuration(until); err != nil {
+			err = fmt.Errorf("invalid until %q, it should be a RFC3339 time or a duration", until)
+			return
+		}
+		result = now.Add(duration)
+	}
+
+	switch {
+	case !result.After(now):
+		err = fmt.Errorf("until %q is not in the future", until)
+	case result.Sub(now) > maxScheduleOverride:
+		err = fmt.Errorf("until %q is later than %v from now", until, maxScheduleOverride)
+	}
+	return
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2024 kde authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apiserver_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/linuxsuren/kde/api/linuxsuren.github.io/v1alpha1"
+	"github.com/linuxsuren/kde/internal/apiserver"
+	"github.com/linuxsuren/kde/pkg/client/clientset/versioned/fake"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestScheduleOverride(t *testing.T) {
+	request := func(server *apiserver.Server, method, path string) *httptest.ResponseRecorder {
+		engine := gin.New()
+		engine.POST("/devspace/:devspace/extend", server.ExtendDevSpace)
+		engine.POST("/devspace/:devspace/suspend", server.SuspendDevSpace)
+		engine.DELETE("/devspace/:devspace/scheduleOverride", server.CancelScheduleOverride)
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, nil)
+		engine.ServeHTTP(w, req)
+		return w
+	}
+	getDevSpace := func(t *testing.T, server *apiserver.Server) *v1alpha1.DevSpace {
+		devSpace, err := server.KClient.LinuxsurenV1alpha1().DevSpaces("default").Get(context.Background(), "fake", metav1.GetOptions{})
+		assert.NoError(t, err)
+		return devSpace
+	}
+
+	t.Run("extend with a duration", func(t *testing.T) {
+		server := &apiserver.Server{KClient: fake.NewSimpleClientset(createDefaultDevSpace())}
+		w := request(server, http.MethodPost, "/devspace/fake/extend?until=2h")
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		override := getDevSpace(t, server).Spec.ScheduleOverride
+		if assert.NotNil(t, override) {
+			assert.Equal(t, v1alpha1.ScheduleOverrideKeepAlive, override.Mode)
+			assert.WithinDuration(t, time.Now().Add(2*time.Hour), override.Until.Time, time.Minute)
+		}
+	})
+
+	t.Run("suspend until a time", func(t *testing.T) {
+		server := &apiserver.Server{KClient: fake.NewSimpleClientset(createDefaultDevSpace())}
+		until := time.Now().Add(time.Hour).Truncate(time.Second)
+		w := request(server, http.MethodPost, "/devspace/fake/suspend?until="+url.QueryEscape(until.Format(time.RFC3339)))
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		override := getDevSpace(t, server).Spec.ScheduleOverride
+		if assert.NotNil(t, override) {
+			assert.Equal(t, v1alpha1.ScheduleOverrideOff, override.Mode)
+			assert.True(t, until.Equal(override.Until.Time))
+		}
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		devSpace := createDefaultDevSpace()
+		devSpace.Spec.ScheduleOverride = &v1alpha1.ScheduleOverride{
+			Mode:  v1alpha1.ScheduleOverrideKeepAlive,
+			Until: metav1.NewTime(time.Now().Add(time.Hour)),
+		}
+		server := &apiserver.Server{KClient: fake.NewSimpleClientset(devSpace)}
+		w := request(server, http.MethodDelete, "/devspace/fake/scheduleOverride")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Nil(t, getDevSpace(t, server).Spec.ScheduleOverride)
+	})
+
+	for _, until := range []string{"", "invalid", "-1h", "240h", "2006-01-02T15:04:05Z"} {
+		t.Run("invalid until: "+until, func(t *testing.T) {
+			server := &apiserver.Server{KClient: fake.NewSimpleClientset(createDefaultDevSpace())}
+			w := request(server, http.MethodPost, "/devspace/fake/extend?until="+until)
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Nil(t, getDevSpace(t, server).Spec.ScheduleOverride)
+		})
+	}
+
+	t.Run("not found", func(t *testing.T) {
+		server := &apiserver.Server{KClient: fake.NewSimpleClientset()}
+		w := request(server, http.MethodPost, "/devspace/fake/extend?until=1h")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
@@ -0,0 +1,138 @@
+/*
+Copyright 2024 kde authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apiserver
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/linuxsuren/kde/api/linuxsuren.github.io/v1alpha1"
+	"github.com/linuxsuren/kde/internal/controller"
+	"github.com/linuxsuren/kde/pkg/core"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+var (
+	devSpaceTemplateResource        = v1alpha1.GroupVersion.WithResource("devspacetemplates")
+	clusterDevSpaceTemplateResource = v1alpha1.GroupVersion.WithResource("clusterdevspacetemplates")
+)
+
+// ListTemplates returns the DevSpaceTemplates in the namespace, and all the ClusterDevSpaceTemplates.
+// The kind of each item tells how to refer to it via spec.templateRef.
+func (s *Server) ListTemplates(c *gin.Context) {
+	ctx := c.Request.Context()
+	namespace := getNamespaceFromQuery(c)
+
+	result := &unstructured.UnstructuredList{Object: map[string]interface{}{}}
+	templates, err := s.DClient.Resource(devSpaceTemplateResource).Namespace(namespace).List(ctx, metav1.ListOptions{})
+	if err == nil {
+		result.Items = append(result.Items, templates.Items...)
+		templates, err = s.DClient.Resource(clusterDevSpaceTemplateResource).List(ctx, metav1.ListOptions{})
+	}
+	if err == nil {
+		result.Items = append(result.Items, templates.Items...)
+	}
+
+	// the CRDs might not be installed
+	if err != nil && !apierrors.IsNotFound(err) {
+		c.Error(err)
+		c.JSON(http.StatusBadRequest, err)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// PreviewTemplates renders the child resources of a DevSpace with the override templates given in the body,
+// or the ones in the system namespace if the body is empty. The DevSpace is given by the query, or a sample one.
+// The templates are validated like the controller does, the invalid ones are reported as the errors and the
+// built-in ones are rendered instead.
+func (s *Server) PreviewTemplates(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	overrides := map[string]string{}
+	if c.Request.ContentLength != 0 {
+		if err := c.BindJSON(&overrides); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	} else {
+		cm, err := s.Client.CoreV1().ConfigMaps(s.SystemNamespace).Get(ctx, controller.OverrideTemplatesConfigMap, metav1.GetOptions{})
+		if err == nil {
+			overrides = cm.Data
+		} else if !apierrors.IsNotFound(err) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	config, _ := core.GetConfigFromConfigMap(ctx, s.Client.CoreV1().ConfigMaps(s.SystemNamespace), getConfigMap("config.yaml").GetName())
+	if config == nil {
+		config = &core.Config{}
+	}
+	templates, validationErr := controller.ValidateTemplates(overrides, config)
+
+	devSpace := controller.SampleDevSpace(config)
+	if name := c.Query("devspace"); name != "" {
+		found, err := s.KClient.LinuxsurenV1alpha1().DevSpaces(getNamespaceFromQuery(c)).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			c.Error(err)
+			c.JSON(http.StatusNotFound, err)
+			return
+		}
+
+		var applied *v1alpha1.DevSpaceSpec
+		if found.Status.Template != nil {
+			cm, cmErr := s.Client.CoreV1().ConfigMaps(found.Namespace).Get(ctx, v1alpha1.AppliedTemplateConfigMapName(name), metav1.GetOptions{})
+			if cmErr == nil {
+				applied, _ = controller.AppliedTemplateSpecOf(cm)
+			}
+		}
+		devSpace = hideCredentials(found)
+		controller.PrepareForRender(devSpace, applied, config, s.SystemNamespace)
+	}
+
+	objs, fallbacks, err := templates.Render(devSpace)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	manifests := []*unstructured.Unstructured{}
+	for _, name := range controller.TemplateNames {
+		if obj := objs[name]; obj != nil {
+			manifests = append(manifests, obj)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"manifests": manifests,
+		"errors":    errorMessages(validationErr, fallbacks),
+	})
+}
+
+// errorMessages returns the messages of the errors, the joined ones are split
+func errorMessages(errs ...error) (messages []string) {
+	messages = []string{}
+	for _, err := range errs {
+		if joined, ok := err.(interface{ Unwrap() []error }); ok {
+			messages = append(messages, errorMessages(joined.Unwrap()...)...)
+		} else if err != nil {
+			messages = append(messages, err.Error())
+		}
+	}
+	return
+}
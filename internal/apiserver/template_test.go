@@ -0,0 +1,180 @@
+/*
+Copyright 2024 kde authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apiserver_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/linuxsuren/kde/api/linuxsuren.github.io/v1alpha1"
+	"github.com/linuxsuren/kde/internal/apiserver"
+	"github.com/linuxsuren/kde/internal/controller"
+	"github.com/linuxsuren/kde/pkg/client/clientset/versioned/fake"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestListTemplates(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.NoError(t, v1alpha1.AddToScheme(scheme))
+	listKinds := map[schema.GroupVersionResource]string{
+		v1alpha1.GroupVersion.WithResource("devspacetemplates"):        "DevSpaceTemplateList",
+		v1alpha1.GroupVersion.WithResource("clusterdevspacetemplates"): "ClusterDevSpaceTemplateList",
+	}
+
+	javaTemplate := &v1alpha1.DevSpaceTemplate{
+		TypeMeta:   metav1.TypeMeta{APIVersion: v1alpha1.GroupVersion.String(), Kind: v1alpha1.KindDevSpaceTemplate},
+		ObjectMeta: metav1.ObjectMeta{Name: "java", Namespace: "default"},
+		Spec: v1alpha1.DevSpaceTemplateSpec{
+			Description: "Java backend with MySQL and RabbitMQ",
+			Template:    v1alpha1.DevSpaceSpec{CPU: "4"},
+		},
+	}
+	otherNamespaceTemplate := javaTemplate.DeepCopy()
+	otherNamespaceTemplate.Namespace = "other"
+	otherNamespaceTemplate.Name = "other"
+	goTemplate := &v1alpha1.ClusterDevSpaceTemplate{
+		TypeMeta:   metav1.TypeMeta{APIVersion: v1alpha1.GroupVersion.String(), Kind: v1alpha1.KindClusterDevSpaceTemplate},
+		ObjectMeta: metav1.ObjectMeta{Name: "golang"},
+	}
+
+	server := &apiserver.Server{
+		DClient: dynamicfake.NewSimpleDynamicClientWithCustomListKinds(scheme, listKinds, javaTemplate, otherNamespaceTemplate, goTemplate),
+	}
+	engine := gin.New()
+	engine.GET("/templates", server.ListTemplates)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/templates?namespace=default", nil)
+	engine.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	result := struct {
+		Items []struct {
+			Kind     string                        `json:"kind"`
+			Metadata metav1.ObjectMeta             `json:"metadata"`
+			Spec     v1alpha1.DevSpaceTemplateSpec `json:"spec"`
+		} `json:"items"`
+	}{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+	if assert.Len(t, result.Items, 2) {
+		assert.Equal(t, v1alpha1.KindDevSpaceTemplate, result.Items[0].Kind)
+		assert.Equal(t, "java", result.Items[0].Metadata.Name)
+		assert.Equal(t, "4", result.Items[0].Spec.Template.CPU)
+		assert.Equal(t, v1alpha1.KindClusterDevSpaceTemplate, result.Items[1].Kind)
+		assert.Equal(t, "golang", result.Items[1].Metadata.Name)
+	}
+}
+
+func TestPreviewTemplates(t *testing.T) {
+	serviceWithLabel := `apiVersion: v1
+kind: Service
+metadata:
+  name: {{.ObjectMeta.Name}}
+  namespace: {{.ObjectMeta.Namespace}}
+  labels:
+    corp.example.com/cost-center: dev
+spec:
+  ports:
+    - port: 3000
+`
+	devSpace := createDefaultDevSpace()
+	devSpace.Spec.Repository = &v1alpha1.GitRepository{URL: "https://github.com/linuxsuren/kde", Password: "plaintext-password"}
+	server := &apiserver.Server{
+		Client: k8sfake.NewSimpleClientset(&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: controller.OverrideTemplatesConfigMap, Namespace: "kde-system"},
+			Data:       map[string]string{controller.TemplateService: serviceWithLabel},
+		}),
+		KClient:         fake.NewSimpleClientset(devSpace),
+		SystemNamespace: "kde-system",
+	}
+	engine := gin.New()
+	engine.POST("/templates/preview", server.PreviewTemplates)
+
+	type previewResult struct {
+		Manifests []unstructured.Unstructured `json:"manifests"`
+		Errors    []string                    `json:"errors"`
+	}
+	preview := func(t *testing.T, query string, body string) (result previewResult) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/templates/preview"+query, bytes.NewBufferString(body))
+		engine.ServeHTTP(w, req)
+		if assert.Equal(t, http.StatusOK, w.Code, w.Body.String()) {
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+		}
+		return
+	}
+	findService := func(manifests []unstructured.Unstructured) *unstructured.Unstructured {
+		for i := range manifests {
+			if manifests[i].GetKind() == "Service" && !strings.HasSuffix(manifests[i].GetName(), "-wakeup") {
+				return &manifests[i]
+			}
+		}
+		return nil
+	}
+
+	t.Run("the configured templates with a sample", func(t *testing.T) {
+		result := preview(t, "", "")
+		assert.Empty(t, result.Errors)
+		if service := findService(result.Manifests); assert.NotNil(t, service) {
+			assert.Equal(t, "sample", service.GetName())
+			assert.Equal(t, "dev", service.GetLabels()["corp.example.com/cost-center"])
+		}
+	})
+
+	t.Run("the configured templates with a DevSpace", func(t *testing.T) {
+		result := preview(t, "?namespace=default&devspace=fake", "")
+		assert.Empty(t, result.Errors)
+		if service := findService(result.Manifests); assert.NotNil(t, service) {
+			assert.Equal(t, "fake", service.GetName())
+		}
+		for _, manifest := range result.Manifests {
+			data, _ := manifest.MarshalJSON()
+			assert.NotContains(t, string(data), "plaintext-password")
+		}
+	})
+
+	t.Run("the given templates", func(t *testing.T) {
+		body, _ := json.Marshal(map[string]string{
+			controller.TemplateService: "{{ .Spec.Unknown }}",
+			"job.yaml":                 serviceWithLabel,
+		})
+		result := preview(t, "", string(body))
+		assert.Len(t, result.Errors, 2)
+		if service := findService(result.Manifests); assert.NotNil(t, service) {
+			// the built-in one
+			assert.Empty(t, service.GetLabels()["corp.example.com/cost-center"])
+		}
+	})
+
+	t.Run("DevSpace not found", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/templates/preview?devspace=missing", nil)
+		engine.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
@@ -0,0 +1,134 @@
+/*
+Copyright 2024 kde authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apiserver
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/linuxsuren/kde/api/linuxsuren.github.io/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// wakeUpRefreshSeconds is the interval of reloading the wake-up page
+const wakeUpRefreshSeconds = 5
+
+// ActivityTokenHeader carries the activity token of the DevSpace when the ingress cannot put it into the query
+const ActivityTokenHeader = "X-KDE-Activity-Token"
+
+type wakeUpPage struct {
+	Namespace string
+	Name      string
+	Phase     v1alpha1.DevSpacePhase
+	Title     string
+	Message   string
+	Refresh   int
+}
+
+// WakeUpDevSpace is the fallback backend of the DevSpace ingress when the DevSpace is not running.
+// It wakes up the idle DevSpace, then reloads the page until the ingress points to the IDE again.
+// The ingress passes the activity token of the DevSpace, since waking up is the same as recording an activity.
+// A DevSpace turned off by its owner is turned on only for the owner or a collaborator with a session of it,
+// while the ones out of the alive windows or turned off by a schedule override stay off.
+func (s *Server) WakeUpDevSpace(c *gin.Context) {
+	ns := c.Param("namespace")
+	name := c.Param("devspace")
+	ctx := c.Request.Context()
+
+	token := c.Query("token")
+	if token == "" {
+		token = c.GetHeader(ActivityTokenHeader)
+	}
+	if ok, err := s.isActivityToken(ctx, ns, name, token); err != nil {
+		c.Error(err)
+		c.JSON(http.StatusBadRequest, err)
+		return
+	} else if !ok {
+		c.JSON(http.StatusForbidden, fmt.Errorf("token is incorrect"))
+		return
+	}
+
+	devSpace, err := s.KClient.LinuxsurenV1alpha1().DevSpaces(ns).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusNotFound, err)
+		return
+	}
+
+	page := wakeUpPage{
+		Namespace: ns,
+		Name:      name,
+		Phase:     devSpace.Status.Phase,
+		Title:     "Starting your workspace",
+		Message:   "This page will be reloaded automatically once the workspace is ready.",
+		Refresh:   wakeUpRefreshSeconds,
+	}
+
+	switch devSpace.Status.SuspendReason {
+	case v1alpha1.DevSpaceSuspendReasonIdle:
+		devSpace.Status.LastActivityTime = nil // make sure the activity will be recorded
+		err = s.recordActivity(ctx, devSpace)
+	case v1alpha1.DevSpaceSuspendReasonManual:
+		if session := sessionOf(c, ns, name); session != nil && isAllowed(devSpace, session.user) {
+			err = s.updateReplicas(ctx, ns, name, 1)
+			break
+		}
+		page.Title = "Your workspace is off"
+		page.Message = "The workspace is turned off by its owner, please sign in as the owner or a collaborator, " +
+			"or turn it on from the kde dashboard."
+		page.Refresh = 0
+	case v1alpha1.DevSpaceSuspendReasonOutOfWindow:
+		page.Title = "Your workspace is off"
+		page.Message = "The current time is not in any alive windows of this workspace."
+		page.Refresh = 0
+	case v1alpha1.DevSpaceSuspendReasonScheduleOverride:
+		page.Title = "Your workspace is off"
+		page.Message = "The workspace is turned off on purpose."
+		if override := devSpace.Status.ScheduleOverride; override != nil {
+			page.Message = fmt.Sprintf("The workspace is turned off until %s.", override.Until.Format(time.RFC1123))
+		}
+		page.Refresh = 0
+	default:
+		if devSpace.Status.Phase == v1alpha1.DevSpacePhaseFailed {
+			page.Title = "Your workspace failed to start"
+			page.Message = "Please check the conditions of the workspace, or contact the administrator."
+		}
+	}
+
+	if err != nil {
+		c.Error(err)
+		page.Title = "Failed to start your workspace"
+		page.Message = err.Error()
+	}
+
+	var tpl *template.Template
+	if tpl, err = template.ParseFS(embedFS, "data/wakeup.html"); err == nil {
+		c.Status(http.StatusServiceUnavailable)
+		c.Header("Content-Type", "text/html; charset=utf-8")
+		c.Header("Retry-After", strconv.Itoa(wakeUpRefreshSeconds))
+		err = tpl.Execute(c.Writer, page)
+	}
+
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, err)
+	}
+}
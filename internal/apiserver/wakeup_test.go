@@ -0,0 +1,144 @@
+/*
+Copyright 2024 kde authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apiserver_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/linuxsuren/kde/api/linuxsuren.github.io/v1alpha1"
+	"github.com/linuxsuren/kde/internal/apiserver"
+	"github.com/linuxsuren/kde/pkg/client/clientset/versioned/fake"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestWakeUpDevSpace(t *testing.T) {
+	requestWithToken := func(server *apiserver.Server, token string) *httptest.ResponseRecorder {
+		if server.Client == nil {
+			server.Client = k8sfake.NewSimpleClientset(createActivityTokenSecret())
+		}
+		engine := gin.New()
+		engine.GET("/wakeup/:namespace/:devspace", server.WakeUpDevSpace)
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/wakeup/default/fake?token="+token, nil)
+		engine.ServeHTTP(w, req)
+		return w
+	}
+	request := func(server *apiserver.Server) *httptest.ResponseRecorder {
+		return requestWithToken(server, "activity")
+	}
+
+	t.Run("not found", func(t *testing.T) {
+		w := request(&apiserver.Server{KClient: fake.NewSimpleClientset()})
+		assert.Equal(t, http.StatusNotFound, w.Result().StatusCode)
+	})
+
+	t.Run("token is incorrect", func(t *testing.T) {
+		devSpace := createDefaultDevSpace()
+		devSpace.Status.SuspendReason = v1alpha1.DevSpaceSuspendReasonIdle
+		for _, token := range []string{"", "wrong", "token"} {
+			w := requestWithToken(&apiserver.Server{KClient: fake.NewSimpleClientset(devSpace)}, token)
+			assert.Equal(t, http.StatusForbidden, w.Result().StatusCode, token)
+			assert.NotContains(t, w.Body.String(), "workspace", token)
+		}
+
+		// not telling whether the DevSpace exists
+		w := requestWithToken(&apiserver.Server{
+			Client:  k8sfake.NewSimpleClientset(),
+			KClient: fake.NewSimpleClientset(),
+		}, "activity")
+		assert.Equal(t, http.StatusForbidden, w.Result().StatusCode)
+	})
+
+	t.Run("token in the header", func(t *testing.T) {
+		devSpace := createDefaultDevSpace()
+		devSpace.Status.SuspendReason = v1alpha1.DevSpaceSuspendReasonIdle
+		server := &apiserver.Server{
+			Client:  k8sfake.NewSimpleClientset(createActivityTokenSecret()),
+			KClient: fake.NewSimpleClientset(devSpace),
+		}
+		engine := gin.New()
+		engine.GET("/wakeup/:namespace/:devspace", server.WakeUpDevSpace)
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/wakeup/default/fake", nil)
+		req.Header.Set(apiserver.ActivityTokenHeader, "activity")
+		engine.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusServiceUnavailable, w.Result().StatusCode)
+	})
+
+	t.Run("idle", func(t *testing.T) {
+		devSpace := createDefaultDevSpace()
+		devSpace.Status.Phase = v1alpha1.DevSpacePhaseStopped
+		devSpace.Status.SuspendReason = v1alpha1.DevSpaceSuspendReasonIdle
+		kClient := fake.NewSimpleClientset(devSpace)
+
+		w := request(&apiserver.Server{KClient: kClient})
+		assert.Equal(t, http.StatusServiceUnavailable, w.Result().StatusCode)
+		assert.Contains(t, w.Body.String(), `http-equiv="refresh"`)
+		assert.Contains(t, w.Body.String(), "Stopped")
+
+		devSpace, err := kClient.LinuxsurenV1alpha1().DevSpaces("default").Get(context.Background(), "fake", metav1.GetOptions{})
+		assert.NoError(t, err)
+		assert.NotNil(t, devSpace.Status.LastActivityTime)
+	})
+
+	t.Run("turned off manually", func(t *testing.T) {
+		devSpace := createDefaultDevSpace()
+		devSpace.Spec.Replicas = new(int32)
+		devSpace.Status.SuspendReason = v1alpha1.DevSpaceSuspendReasonManual
+		kClient := fake.NewSimpleClientset(devSpace)
+
+		w := request(&apiserver.Server{KClient: kClient})
+		assert.Equal(t, http.StatusServiceUnavailable, w.Result().StatusCode)
+		assert.NotContains(t, w.Body.String(), `http-equiv="refresh"`)
+		assert.Contains(t, w.Body.String(), "turned off by its owner")
+
+		// it stays off
+		devSpace, err := kClient.LinuxsurenV1alpha1().DevSpaces("default").Get(context.Background(), "fake", metav1.GetOptions{})
+		assert.NoError(t, err)
+		assert.Equal(t, int32(0), *devSpace.Spec.Replicas)
+	})
+
+	t.Run("out of window", func(t *testing.T) {
+		devSpace := createDefaultDevSpace()
+		devSpace.Status.SuspendReason = v1alpha1.DevSpaceSuspendReasonOutOfWindow
+
+		w := request(&apiserver.Server{KClient: fake.NewSimpleClientset(devSpace)})
+		assert.Equal(t, http.StatusServiceUnavailable, w.Result().StatusCode)
+		assert.NotContains(t, w.Body.String(), `http-equiv="refresh"`)
+		assert.Contains(t, w.Body.String(), "alive windows")
+	})
+	t.Run("turned off by a schedule override", func(t *testing.T) {
+		devSpace := createDefaultDevSpace()
+		devSpace.Status.SuspendReason = v1alpha1.DevSpaceSuspendReasonScheduleOverride
+		devSpace.Status.ScheduleOverride = &v1alpha1.ScheduleOverride{
+			Mode:  v1alpha1.ScheduleOverrideOff,
+			Until: metav1.NewTime(time.Date(2024, 7, 17, 18, 0, 0, 0, time.UTC)),
+		}
+
+		w := request(&apiserver.Server{KClient: fake.NewSimpleClientset(devSpace)})
+		assert.Equal(t, http.StatusServiceUnavailable, w.Result().StatusCode)
+		assert.NotContains(t, w.Body.String(), `http-equiv="refresh"`)
+		assert.Contains(t, w.Body.String(), "turned off until Wed, 17 Jul 2024 18:00:00 UTC")
+	})
+}
@@ -90,6 +90,11 @@ func (s *Server) IDEWebhook(c *gin.Context) {
 	if err == nil {
 		if devspace, err = s.KClient.LinuxsurenV1alpha1().DevSpaces(ns).Get(ctx, name, metav1.GetOptions{}); err == nil {
 			result = devspace.Status.ExposeLinks
+
+			// the heartbeat of the IDE keeps the DevSpace alive
+			if activityErr := s.recordActivity(ctx, devspace); activityErr != nil {
+				c.Error(activityErr)
+			}
 		}
 	}
 }
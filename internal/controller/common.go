@@ -18,12 +18,12 @@ package controller
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"sort"
 	"strconv"
 	"strings"
 
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -32,39 +32,30 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
-func createOrUpdate(ctx context.Context, cli client.Client, obj *unstructured.Unstructured) (err error) {
-	copiedObj := obj.DeepCopy()
-	objKey := client.ObjectKeyFromObject(copiedObj)
-	if err = cli.Get(ctx, objKey, copiedObj); err != nil {
-		if obj.GetName() == "" {
-			// skip those resources only have the generatedName
-			err = nil
-		}
+// FieldManager is the field manager of the objects applied by the controller
+const FieldManager = "kde"
 
-		if err = client.IgnoreNotFound(err); err == nil {
-			if err = cli.Create(ctx, obj); err != nil {
-				err = fmt.Errorf("failed to create %v, error: %v", objKey, err)
-			}
-		} else {
-			err = fmt.Errorf("failed to get %v, error: %v", objKey, err)
-		}
-	} else {
-		toUpdate := obj.DeepCopy()
-		toUpdate.SetResourceVersion(copiedObj.GetResourceVersion())
-
-		if err = cli.Update(ctx, toUpdate); err != nil {
-			err = fmt.Errorf("failed to update %v, error: %v", objKey, err)
+// apply applies the object via the server-side apply, the fields set by others are kept.
+// A conflict means some fields managed by kde were changed by others, onDrift is called
+// with it before taking the fields back.
+func apply(ctx context.Context, cli client.Client, obj *unstructured.Unstructured, onDrift func(error)) (err error) {
+	objKey := client.ObjectKeyFromObject(obj)
+	if obj.GetName() == "" {
+		// those resources only have the generatedName cannot be applied
+		if err = cli.Create(ctx, obj); err != nil {
+			err = fmt.Errorf("failed to create %v, error: %v", objKey, err)
 		}
+		return
 	}
-	return
-}
 
-func createOrUpdateObjs(ctx context.Context, cli client.Client, objs ...*unstructured.Unstructured) (err error) {
-	for _, obj := range objs {
-		if obj == nil {
-			continue
-		}
-		err = errors.Join(err, createOrUpdate(ctx, cli, obj))
+	obj.SetManagedFields(nil)
+	obj.SetResourceVersion("")
+	if err = cli.Patch(ctx, obj, client.Apply, client.FieldOwner(FieldManager)); apierrors.IsConflict(err) {
+		onDrift(err)
+		err = cli.Patch(ctx, obj, client.Apply, client.FieldOwner(FieldManager), client.ForceOwnership)
+	}
+	if err != nil {
+		err = fmt.Errorf("failed to apply %v, error: %v", objKey, err)
 	}
 	return
 }
@@ -0,0 +1,55 @@
+/*
+Copyright 2024 kde authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	"github.com/linuxsuren/kde/api/linuxsuren.github.io/v1alpha1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+// newFakeClientBuilder returns a fake client builder which supports the server-side apply,
+// and the status subresource of DevSpace like the real API server
+func newFakeClientBuilder(schema *runtime.Scheme) *fake.ClientBuilder {
+	return fake.NewClientBuilder().WithScheme(schema).
+		WithStatusSubresource(&v1alpha1.DevSpace{}).
+		WithInterceptorFuncs(interceptor.Funcs{Patch: fakeApply})
+}
+
+// fakeApply handles the server-side apply which is not supported by the fake client,
+// it creates or replaces the whole object like there are no other field managers
+func fakeApply(ctx context.Context, cli client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) (err error) {
+	if patch.Type() != types.ApplyPatchType {
+		return cli.Patch(ctx, obj, patch, opts...)
+	}
+
+	existing := obj.DeepCopyObject().(client.Object)
+	if err = cli.Get(ctx, client.ObjectKeyFromObject(obj), existing); err != nil {
+		if apierrors.IsNotFound(err) {
+			err = cli.Create(ctx, obj)
+		}
+		return
+	}
+	obj.SetResourceVersion(existing.GetResourceVersion())
+	return cli.Update(ctx, obj)
+}
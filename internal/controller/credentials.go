@@ -0,0 +1,208 @@
+/*
+Copyright 2024 kde authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"maps"
+	"regexp"
+
+	"github.com/linuxsuren/kde/api/linuxsuren.github.io/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// legacyPasswords were the passwords of the services before they are generated,
+// the data of the existing DevSpaces was initialized with them
+var legacyPasswords = map[string]string{
+	v1alpha1.CredentialKeyMySQL:    "root",
+	v1alpha1.CredentialKeyPostgres: "root",
+	v1alpha1.CredentialKeyRabbitMQ: "guest",
+}
+
+// credential is a password field of the DevSpace spec along with its Secret reference
+type credential struct {
+	key      string
+	password *string
+	ref      **v1.SecretKeySelector
+	// generate is true if a random password should be given when it is missing
+	generate bool
+}
+
+func credentialsOf(spec *v1alpha1.DevSpaceSpec) (credentials []credential) {
+	services := spec.Services
+	if mysql := services.MySQL; mysql != nil {
+		credentials = append(credentials, credential{
+			key: v1alpha1.CredentialKeyMySQL, password: &mysql.Password, ref: &mysql.PasswordSecretRef, generate: mysql.IsEnabled(),
+		})
+	}
+	if postgres := services.Postgres; postgres != nil {
+		credentials = append(credentials, credential{
+			key: v1alpha1.CredentialKeyPostgres, password: &postgres.Password, ref: &postgres.PasswordSecretRef, generate: postgres.IsEnabled(),
+		})
+	}
+	if rabbitMQ := services.RabbitMQ; rabbitMQ != nil {
+		credentials = append(credentials, credential{
+			key: v1alpha1.CredentialKeyRabbitMQ, password: &rabbitMQ.Password, ref: &rabbitMQ.PasswordSecretRef, generate: rabbitMQ.IsEnabled(),
+		})
+	}
+	credentials = append(credentials, credential{
+		key: v1alpha1.CredentialKeySSHPrivateKey, password: &spec.Auth.SSHPrivateKey, ref: &spec.Auth.SSHPrivateKeySecretRef,
+	})
+	// a public repository does not need any password
+	if repo := spec.Repository; repo != nil {
+		credentials = append(credentials, credential{
+			key: v1alpha1.CredentialKeyGit, password: &repo.Password, ref: &repo.PasswordSecretRef,
+		})
+	}
+	for i := range spec.Repositories {
+		repo := &spec.Repositories[i]
+		credentials = append(credentials, credential{
+			key: repositoryCredentialKey(*repo), password: &repo.Password, ref: &repo.PasswordSecretRef,
+		})
+	}
+	return
+}
+
+var invalidSecretKeyChars = regexp.MustCompile(`[^-._a-zA-Z0-9]`)
+
+// repositoryCredentialKey returns the key in the credentials Secret for one of spec.repositories,
+// the target directory is unique among the repositories
+func repositoryCredentialKey(repo v1alpha1.GitRepository) string {
+	return v1alpha1.CredentialKeyGit + "-" + invalidSecretKeyChars.ReplaceAllString(repo.TargetDirectory(), ".")
+}
+
+// ensureCredentials makes sure every credential of the DevSpace comes from a Secret.
+// The plaintext passwords are moved into the credentials Secret of the DevSpace, the missing
+// ones of the enabled services are generated randomly. The existing values in the Secret are
+// kept, so the passwords stay the same with the data of the services. The DevSpaces created
+// before having the credentials Secret keep the legacy passwords for the same reason.
+// The references are set into the given DevSpace only, they are not persisted.
+// The activity token is generated as well, the ingress authenticates the mirrored traffic with it.
+func (r *DevSpaceReconciler) ensureCredentials(ctx context.Context, devSpace *v1alpha1.DevSpace) (err error) {
+	secret := &v1.Secret{}
+	secretKey := types.NamespacedName{Namespace: devSpace.Namespace, Name: v1alpha1.CredentialsSecretName(devSpace.Name)}
+	exists := true
+	if err = r.Get(ctx, secretKey, secret); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return
+		}
+		exists = false
+		err = nil
+	}
+
+	data := maps.Clone(secret.Data)
+	if data == nil {
+		data = map[string][]byte{}
+	}
+	var upgrading bool
+	if !exists {
+		// a DevSpace which was created before having the credentials Secret
+		upgrading = r.Get(ctx, client.ObjectKeyFromObject(devSpace), &appsv1.Deployment{}) == nil
+	}
+	for _, c := range credentialsOf(&devSpace.Spec) {
+		if *c.ref != nil {
+			// the user manages the password
+			*c.password = ""
+			continue
+		}
+
+		if *c.password != "" {
+			data[c.key] = []byte(*c.password)
+			*c.password = ""
+		} else if len(data[c.key]) == 0 {
+			if !c.generate {
+				continue
+			}
+
+			passwd, ok := legacyPasswords[c.key]
+			if !upgrading || !ok {
+				if passwd, err = randomPassword(); err != nil {
+					return
+				}
+			}
+			data[c.key] = []byte(passwd)
+		}
+		*c.ref = &v1.SecretKeySelector{
+			LocalObjectReference: v1.LocalObjectReference{Name: secretKey.Name},
+			Key:                  c.key,
+		}
+	}
+
+	if len(data[v1alpha1.CredentialKeyActivityToken]) == 0 {
+		var token string
+		if token, err = randomPassword(); err != nil {
+			return
+		}
+		data[v1alpha1.CredentialKeyActivityToken] = []byte(token)
+	}
+	if devSpace.Annotations == nil {
+		devSpace.Annotations = map[string]string{}
+	}
+	devSpace.Annotations[v1alpha1.AnnoKeyActivityToken] = string(data[v1alpha1.CredentialKeyActivityToken])
+
+	switch {
+	case exists && !maps.EqualFunc(secret.Data, data, bytes.Equal):
+		secret.Data = data
+		err = r.Update(ctx, secret)
+	case !exists:
+		secret = &v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      secretKey.Name,
+				Namespace: secretKey.Namespace,
+				Labels: map[string]string{
+					LabelAppKind: "devspace",
+					LabelApp:     devSpace.Name,
+				},
+				OwnerReferences: []metav1.OwnerReference{
+					*metav1.NewControllerRef(devSpace, v1alpha1.GroupVersion.WithKind("DevSpace")),
+				},
+			},
+			Type: v1.SecretTypeOpaque,
+			Data: data,
+		}
+		err = r.Create(ctx, secret)
+	}
+	return
+}
+
+// clearPlaintextCredentials removes the plaintext passwords from the given spec,
+// it returns true if there is any.
+func clearPlaintextCredentials(spec *v1alpha1.DevSpaceSpec) (cleared bool) {
+	for _, c := range credentialsOf(spec) {
+		if *c.password != "" {
+			*c.password = ""
+			cleared = true
+		}
+	}
+	return
+}
+
+func randomPassword() (passwd string, err error) {
+	data := make([]byte, 16)
+	if _, err = rand.Read(data); err == nil {
+		passwd = hex.EncodeToString(data)
+	}
+	return
+}
@@ -0,0 +1,200 @@
+/*
+Copyright 2024 kde authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/linuxsuren/kde/api/linuxsuren.github.io/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestEnsureCredentials(t *testing.T) {
+	schema, err := v1alpha1.SchemeBuilder.Register().Build()
+	assert.NoError(t, err)
+	assert.NoError(t, v1.AddToScheme(schema))
+	assert.NoError(t, appsv1.AddToScheme(schema))
+
+	secretKey := types.NamespacedName{Namespace: "default", Name: "demo-credentials"}
+	withServices := func() *v1alpha1.DevSpace {
+		devSpace := createDefaultGitPod()
+		devSpace.UID = "uid"
+		devSpace.Spec.Services = v1alpha1.Services{
+			MySQL:    &v1alpha1.MySQL{Enabled: ptr.To(true)},
+			Postgres: &v1alpha1.Postgres{Enabled: ptr.To(true), Password: "postgres"},
+			RabbitMQ: &v1alpha1.RabbitMQ{Enabled: ptr.To(false)},
+		}
+		devSpace.Spec.Repository = &v1alpha1.GitRepository{URL: "https://github.com/linuxsuren/kde"}
+		devSpace.Spec.Repositories = []v1alpha1.GitRepository{{URL: "https://github.com/linuxsuren/api-testing", Password: "secret"}}
+		return devSpace
+	}
+	credentialsSecret := func(data map[string]string) *v1.Secret {
+		secret := &v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: secretKey.Name, Namespace: secretKey.Namespace},
+			Data:       map[string][]byte{},
+		}
+		for k, v := range data {
+			secret.Data[k] = []byte(v)
+		}
+		return secret
+	}
+	expectedRef := func(key string) *v1.SecretKeySelector {
+		return &v1.SecretKeySelector{LocalObjectReference: v1.LocalObjectReference{Name: secretKey.Name}, Key: key}
+	}
+
+	tests := []struct {
+		name     string
+		devSpace func() *v1alpha1.DevSpace
+		objects  []client.Object
+		verify   func(*testing.T, *v1alpha1.DevSpace, *v1.Secret)
+	}{{
+		name:     "generate the missing passwords",
+		devSpace: withServices,
+		verify: func(t *testing.T, devSpace *v1alpha1.DevSpace, secret *v1.Secret) {
+			assert.Len(t, secret.Data[v1alpha1.CredentialKeyMySQL], 32)
+			assert.Equal(t, "postgres", string(secret.Data[v1alpha1.CredentialKeyPostgres]))
+			assert.NotContains(t, secret.Data, v1alpha1.CredentialKeyRabbitMQ)
+			assert.NotContains(t, secret.Data, v1alpha1.CredentialKeyGit)
+			assert.Equal(t, "uid", string(secret.OwnerReferences[0].UID))
+
+			services := devSpace.Spec.Services
+			assert.Equal(t, expectedRef(v1alpha1.CredentialKeyMySQL), services.MySQL.PasswordSecretRef)
+			assert.Equal(t, expectedRef(v1alpha1.CredentialKeyPostgres), services.Postgres.PasswordSecretRef)
+			assert.Empty(t, services.Postgres.Password)
+			assert.Nil(t, services.RabbitMQ.PasswordSecretRef)
+			assert.Nil(t, devSpace.Spec.Repository.PasswordSecretRef)
+			assert.Equal(t, "secret", string(secret.Data["git-password-api-testing"]))
+			assert.Equal(t, expectedRef("git-password-api-testing"), devSpace.Spec.Repositories[0].PasswordSecretRef)
+			assert.Empty(t, devSpace.Spec.Repositories[0].Password)
+			assert.Contains(t, string(secret.Data[v1alpha1.CredentialKeySSHPrivateKey]), "privateKey")
+			assert.Equal(t, expectedRef(v1alpha1.CredentialKeySSHPrivateKey), devSpace.Spec.Auth.SSHPrivateKeySecretRef)
+			assert.Empty(t, devSpace.Spec.Auth.SSHPrivateKey)
+			assert.Len(t, secret.Data[v1alpha1.CredentialKeyActivityToken], 32)
+			assert.Equal(t, string(secret.Data[v1alpha1.CredentialKeyActivityToken]), devSpace.Annotations[v1alpha1.AnnoKeyActivityToken])
+		},
+	}, {
+		name:     "keep the existing passwords",
+		devSpace: withServices,
+		objects: []client.Object{credentialsSecret(map[string]string{
+			v1alpha1.CredentialKeyMySQL:         "mysql",
+			v1alpha1.CredentialKeyGit:           "token",
+			v1alpha1.CredentialKeyActivityToken: "activity",
+		})},
+		verify: func(t *testing.T, devSpace *v1alpha1.DevSpace, secret *v1.Secret) {
+			assert.Equal(t, "activity", devSpace.Annotations[v1alpha1.AnnoKeyActivityToken])
+			assert.Equal(t, "mysql", string(secret.Data[v1alpha1.CredentialKeyMySQL]))
+			assert.Equal(t, "postgres", string(secret.Data[v1alpha1.CredentialKeyPostgres]))
+			assert.Equal(t, "token", string(secret.Data[v1alpha1.CredentialKeyGit]))
+			assert.Equal(t, expectedRef(v1alpha1.CredentialKeyGit), devSpace.Spec.Repository.PasswordSecretRef)
+		},
+	}, {
+		name: "user managed secret",
+		devSpace: func() *v1alpha1.DevSpace {
+			devSpace := createDefaultGitPod()
+			devSpace.Spec.Auth.SSHPrivateKey = ""
+			devSpace.Spec.Services.MySQL = &v1alpha1.MySQL{
+				Enabled:  ptr.To(true),
+				Password: "plaintext",
+				PasswordSecretRef: &v1.SecretKeySelector{
+					LocalObjectReference: v1.LocalObjectReference{Name: "mine"}, Key: "password",
+				},
+			}
+			return devSpace
+		},
+		verify: func(t *testing.T, devSpace *v1alpha1.DevSpace, secret *v1.Secret) {
+			assert.Len(t, secret.Data, 1)
+			assert.Contains(t, secret.Data, v1alpha1.CredentialKeyActivityToken)
+			assert.Empty(t, devSpace.Spec.Services.MySQL.Password)
+			assert.Equal(t, "mine", devSpace.Spec.Services.MySQL.PasswordSecretRef.Name)
+		},
+	}, {
+		name:     "upgrade from the legacy passwords",
+		devSpace: withServices,
+		objects: []client.Object{&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "demo", Namespace: "default"},
+		}},
+		verify: func(t *testing.T, devSpace *v1alpha1.DevSpace, secret *v1.Secret) {
+			assert.Equal(t, "root", string(secret.Data[v1alpha1.CredentialKeyMySQL]))
+			assert.Equal(t, "postgres", string(secret.Data[v1alpha1.CredentialKeyPostgres]))
+		},
+	}, {
+		name: "no credentials",
+		devSpace: func() *v1alpha1.DevSpace {
+			devSpace := createDefaultGitPod()
+			devSpace.Spec.Auth.SSHPrivateKey = ""
+			return devSpace
+		},
+		verify: func(t *testing.T, devSpace *v1alpha1.DevSpace, secret *v1.Secret) {
+			// only the activity token
+			assert.Len(t, secret.Data, 1)
+			assert.Nil(t, devSpace.Spec.Auth.SSHPrivateKeySecretRef)
+		},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &DevSpaceReconciler{
+				Client: fake.NewClientBuilder().WithScheme(schema).WithObjects(tt.objects...).Build(),
+			}
+			devSpace := tt.devSpace()
+			err := r.ensureCredentials(context.Background(), devSpace)
+			assert.NoError(t, err)
+
+			var secret *v1.Secret
+			if found := (&v1.Secret{}); r.Get(context.Background(), secretKey, found) == nil {
+				secret = found
+			}
+			tt.verify(t, devSpace, secret)
+		})
+	}
+
+	t.Run("generate only once", func(t *testing.T) {
+		r := &DevSpaceReconciler{Client: fake.NewClientBuilder().WithScheme(schema).Build()}
+		assert.NoError(t, r.ensureCredentials(context.Background(), withServices()))
+		first := &v1.Secret{}
+		assert.NoError(t, r.Get(context.Background(), secretKey, first))
+
+		assert.NoError(t, r.ensureCredentials(context.Background(), withServices()))
+		second := &v1.Secret{}
+		assert.NoError(t, r.Get(context.Background(), secretKey, second))
+		assert.Equal(t, first.Data, second.Data)
+		assert.Equal(t, first.ResourceVersion, second.ResourceVersion)
+	})
+}
+
+func TestClearPlaintextCredentials(t *testing.T) {
+	spec := &v1alpha1.DevSpaceSpec{
+		Services: v1alpha1.Services{
+			MySQL:    &v1alpha1.MySQL{Password: "mysql"},
+			RabbitMQ: &v1alpha1.RabbitMQ{},
+		},
+		Repository: &v1alpha1.GitRepository{Password: "token"},
+	}
+	assert.True(t, clearPlaintextCredentials(spec))
+	assert.Empty(t, spec.Services.MySQL.Password)
+	assert.Empty(t, spec.Repository.Password)
+
+	assert.False(t, clearPlaintextCredentials(spec))
+	assert.False(t, clearPlaintextCredentials(&v1alpha1.DevSpaceSpec{}))
+}
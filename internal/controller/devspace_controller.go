@@ -19,16 +19,22 @@ package controller
 import (
 	"context"
 	"encoding/base64"
-	"errors"
 	"fmt"
 	"time"
 
+	appsv1 "k8s.io/api/apps/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 
 	_ "embed"
 
@@ -47,15 +53,21 @@ type DevSpaceReconciler struct {
 	Recorder        record.EventRecorder
 	SystemNamespace string
 	// inner fields
-	ctx context.Context
-	log logr.Logger
+	ctx    context.Context
+	log    logr.Logger
+	config *core.Config
 }
 
 // +kubebuilder:rbac:groups=linuxsuren.github.io,resources=devspaces,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=linuxsuren.github.io,resources=devspaces/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=linuxsuren.github.io,resources=devspaces/finalizers,verbs=update
-// +kubebuilder:rbac:groups="",resources=persistentvolumeclaims,verbs=get;list;delete;create;update;watch
-// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;delete;create;update;watch
+// +kubebuilder:rbac:groups="",resources=persistentvolumeclaims,verbs=get;list;delete;create;update;patch;watch
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;delete;create;update;patch;watch
+// +kubebuilder:rbac:groups="networking.k8s.io",resources=networkpolicies,verbs=get;list;delete;create;update;patch;watch
+// +kubebuilder:rbac:groups=traefik.io,resources=middlewares,verbs=get;list;delete;create;update;patch;watch
+// +kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=httproutes,verbs=get;list;delete;create;update;patch;watch
+// +kubebuilder:rbac:groups=gateway.envoyproxy.io,resources=securitypolicies,verbs=get;list;delete;create;update;patch;watch
+// +kubebuilder:rbac:groups=linuxsuren.github.io,resources=devspacesnapshots,verbs=get;list;watch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -77,47 +89,79 @@ func (r *DevSpaceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (r
 		return
 	}
 
-	config := &core.Config{}
-	configCM := &corev1.ConfigMap{}
-	if cfgErr := r.Get(ctx, types.NamespacedName{
-		Name:      "config",
-		Namespace: r.SystemNamespace,
-	}, configCM); cfgErr == nil {
-		if config, cfgErr = core.ReadConfigFromConfigMap(configCM); err != nil {
-			r.log.Error(cfgErr, "failed to parse config")
-		}
-	} else {
-		r.log.Error(cfgErr, "failed to get config")
-	}
+	config := r.loadConfig(ctx)
+	r.config = config
 
-	setDefaultValueForDevSpace(devSpace, config.Host)
+	if err = r.applyTemplate(ctx, devSpace); err != nil {
+		return
+	}
+	setDefaultValueForDevSpace(devSpace, config)
+	prepareSecurity(devSpace, config)
+	// the links are https only if the ingresses will have the certificates
+	setServiceAnnotations(devSpace, r.SystemNamespace)
+	prepareIngress(devSpace, config)
+	prepareTLS(devSpace, config)
+	if err = r.ensureTLSSecret(ctx, devSpace, config); err != nil {
+		return
+	}
+	tls := tlsAnnotationsOf(devSpace)
 	devSpace = r.updateStatus(devSpace)
 
 	_ = r.Status().Update(ctx, devSpace.DeepCopy())
+	if devSpace.Status.Phase == v1alpha1.DevSpacePhaseDeleting {
+		// the child resources will be collected via the owner references
+		return
+	}
 
 	if err = r.Get(ctx, req.NamespacedName, devSpace); err != nil {
 		err = client.IgnoreNotFound(err)
 		return
 	}
-	setDefaultValueForDevSpace(devSpace, config.Host)
-	devSpace.Annotations[v1alpha1.AnnoKeyServiceNamespace] = r.SystemNamespace
-	devSpace.Annotations[v1alpha1.AnnoKeyServiceName] = "kde-apiserver"
-	configmap, configmapErr := turnTemplateToUnstructured(gitpodConfigMap, devSpace)
-	secret, secretErr := turnTemplateToUnstructured(gitpodSecret, devSpace)
-	pvc, pvcErr := turnTemplateToUnstructured(gitpodPvc, devSpace)
-	deploy, deployErr := turnTemplateToUnstructured(gitpodDeployment, devSpace)
-	service, serviceErr := turnTemplateToUnstructured(gitpodService, devSpace)
-	ingress, ingressErr := turnTemplateToUnstructured(gitpodIngress, devSpace)
-	exposeIngress, exposeIngressErr := turnTemplateToUnstructured(gitpodExposeIngress, devSpace)
-
+	var restoring bool
+	if restoring, err = r.restore(ctx, devSpace); err != nil {
+		return
+	}
+	userSpec := devSpace.Spec.DeepCopy()
+	if err = r.applyTemplate(ctx, devSpace); err != nil {
+		return
+	}
+	setDefaultValueForDevSpace(devSpace, config)
+	for _, feature := range prepareSecurity(devSpace, config) {
+		r.Recorder.Eventf(devSpace, v1.EventTypeWarning, "Security", "%s is disabled: %s", feature.Path, feature.Reason)
+	}
+	if err = r.ensureCredentials(ctx, devSpace); err != nil {
+		return
+	}
+	prepareRepositories(devSpace, config)
+	prepareServices(devSpace)
+	prepareResources(devSpace, config)
+	prepareScheduling(devSpace, config)
+	setServiceAnnotations(devSpace, r.SystemNamespace)
+	prepareIngress(devSpace, config)
+	prepareNetworkPolicy(devSpace, config)
+	if config.ForwardAuth && ingressProviderOf(devSpace).Name == IngressProviderGateway {
+		r.Recorder.Event(devSpace, v1.EventTypeWarning, "Ingress", "the forward auth is not supported by the gateway ingress provider")
+	}
+	setTLSAnnotations(devSpace, tls)
+	objs, fallbacks, err := r.loadTemplates(ctx, devSpace).Render(devSpace)
+	if fallbacks != nil {
+		r.Recorder.Eventf(devSpace, v1.EventTypeWarning, "Render", "fall back to the built-in templates: %v", fallbacks)
+	}
 	// check the object templates render result
-	if err = errors.Join(configmapErr, secretErr, pvcErr, deployErr, serviceErr, ingressErr, exposeIngressErr); err != nil {
+	if err != nil {
 		r.Recorder.Event(devSpace, v1.EventTypeWarning, "Render", err.Error())
 		return
 	}
 
-	result.RequeueAfter = time.Minute
+	result.RequeueAfter = getRequeueAfter(devSpace, getIdleTimeout(devSpace, config), time.Now())
+	if restoring {
+		// the storage will be created once it is restorable
+		objs[TemplatePVC] = nil
+		result.RequeueAfter = restorePollInterval
+	}
 
+	// the plaintext passwords are in the credentials Secret already
+	specChanged := clearPlaintextCredentials(userSpec)
 	auth := devSpace.Spec.Auth.BasicAuth
 	if auth != nil {
 		passwd := auth.Password
@@ -131,71 +175,69 @@ func (r *DevSpaceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (r
 			base64Str := base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%s:%s", auth.Username, hash)))
 			devSpace.Annotations[v1alpha1.AnnoKeyBasicAuth] = base64Str
 			auth.Password = "" // keep the password safe
-			if err = r.Update(ctx, devSpace); err != nil {
-				return
-			}
+			specChanged = true
 		}
+	}
+	if specChanged {
+		// the fields from the template should not be persisted into the DevSpace
+		toUpdate := devSpace.DeepCopy()
+		toUpdate.Spec = *userSpec
+		delete(toUpdate.Annotations, v1alpha1.AnnoKeyActivityToken)
+		if toUpdate.Spec.Auth.BasicAuth != nil {
+			toUpdate.Spec.Auth.BasicAuth.Password = ""
+		}
+		if err = r.Update(ctx, toUpdate); err != nil {
+			return
+		}
+	}
 
-		err = createOrUpdate(ctx, r.Client, secret)
-	} else {
-		err = r.Delete(ctx, secret)
-		err = client.IgnoreNotFound(err)
+	if auth == nil {
+		// it is pruned if there is one
+		objs[TemplateSecret] = nil
 	}
 
-	err = errors.Join(err, createOrUpdateObjs(ctx, r.Client, configmap, pvc, deploy, service, ingress, exposeIngress))
+	children := make([]*unstructured.Unstructured, 0, len(TemplateNames))
+	for _, name := range TemplateNames {
+		children = append(children, flattenList(objs[name])...)
+	}
+	// the storage is kept until the restoring is done
+	err = r.applyChildren(ctx, devSpace, !restoring, children...)
 	return
 }
 
-func setDefaultValueForDevSpace(devspace *v1alpha1.DevSpace, ingress string) {
-	if devspace.Spec.Image == "" {
-		devspace.Spec.Image = "ghcr.io/linuxsuren/openvscode-server-full:v0.0.8"
-	}
-	if devspace.Spec.Host == "" {
-		devspace.Spec.Host = ingress
+// loadConfig reads the config from the system namespace.
+// It never returns nil, an empty config is returned if the config is missing or invalid.
+func (r *DevSpaceReconciler) loadConfig(ctx context.Context) (config *core.Config) {
+	configCM := &corev1.ConfigMap{}
+	if cfgErr := r.Get(ctx, types.NamespacedName{
+		Name:      "config",
+		Namespace: r.SystemNamespace,
+	}, configCM); cfgErr == nil {
+		if config, cfgErr = core.ReadConfigFromConfigMap(configCM); cfgErr != nil {
+			r.log.Error(cfgErr, "failed to parse config")
+		}
+	} else {
+		r.log.Error(cfgErr, "failed to get config")
 	}
 
-	if devspace.Annotations == nil {
-		devspace.Annotations = map[string]string{}
-	}
-	if policy := devspace.Annotations[v1alpha1.AnnoKeyImagePullPolicy]; policy != PolicyAlways {
-		devspace.Annotations[v1alpha1.AnnoKeyImagePullPolicy] = PolicyIfNotPresent
+	if config == nil {
+		config = &core.Config{}
 	}
+	return
 }
 
-func isInAliveWindows(specifyTime time.Time, windows []v1alpha1.Window) (hasWin, ok bool, err error) {
-	nowTime, _ := time.ParseInLocation(time.TimeOnly, specifyTime.Format(time.TimeOnly), time.Local)
-	ok = true
-	hasWin = len(windows) > 0
-	if hasWin {
-		for _, win := range windows {
-			from, fErr := time.ParseInLocation(time.TimeOnly, win.From, time.Local)
-			to, tErr := time.ParseInLocation(time.TimeOnly, win.To, time.Local)
-			if fErr != nil {
-				err = errors.Join(err, fmt.Errorf("failed to parse window from time: %q, error: %v", win.From, fErr))
-				continue
-			}
-			if tErr != nil {
-				err = errors.Join(err, fmt.Errorf("failed to parse window to time: %q, error: %v", win.To, tErr))
-				continue
-			}
-			if from.After(to) {
-				err = errors.Join(err, fmt.Errorf("from (%q) should smaller than to (%q)", win.From, win.To))
-				continue
-			}
-
-			if nowTime.After(from) && nowTime.Before(to) {
-				return
-			}
-		}
-	}
-	ok = false
-	return
+// setDefaultValueForDevSpace fills the missing fields after the template is merged,
+// it is the same defaulting as the admission webhook and the apiserver do
+func setDefaultValueForDevSpace(devspace *v1alpha1.DevSpace, config *core.Config) {
+	core.SetDefaultAnnotations(devspace, config)
+	core.SetDefaultSpec(&devspace.Spec, config)
 }
 
 func (r *DevSpaceReconciler) updateStatus(devSpace *v1alpha1.DevSpace) *v1alpha1.DevSpace {
-	devSpace.Status.Link = fmt.Sprintf("%s.%s", devSpace.Name, devSpace.Spec.Host)
+	host := fmt.Sprintf("%s.%s", devSpace.Name, devSpace.Spec.Host)
+	devSpace.Status.Link = devSpaceLinkOf(host, devSpace)
 	devSpace.Status.ExposeLinks = nil
-	devSpace.Status.Phase = v1alpha1.DevSpacePhaseReady
+	devSpace.Status.ObservedGeneration = devSpace.Generation
 	ports := devSpace.Annotations[v1alpha1.AnnoKeyExposePorts]
 	if ports != "" {
 		portSlice := StringToIntSlice(ports)
@@ -211,40 +253,65 @@ func (r *DevSpaceReconciler) updateStatus(devSpace *v1alpha1.DevSpace) *v1alpha1
 			}
 
 			devSpace.Status.ExposeLinks = append(devSpace.Status.ExposeLinks, v1alpha1.ExposeLink{
-				Link: fmt.Sprintf("%d.%s", port, devSpace.Status.Link),
+				Link: devSpaceLinkOf(fmt.Sprintf("%d.%s", port, host), devSpace),
 				Port: port,
 			})
 		}
 	}
 
+	observation, err := r.observe(r.ctx, devSpace)
+	if err != nil {
+		r.log.Error(err, "failed to observe the child resources", "key", client.ObjectKeyFromObject(devSpace))
+	}
+
 	// check the alive windows
 	var (
 		hasWin, ok bool
 		wErr       error
 	)
-	if hasWin, ok, wErr = isInAliveWindows(time.Now(), devSpace.Spec.Windows); hasWin {
-		if wErr != nil {
-			r.log.Error(wErr, "got error when parsing windows time")
-		}
+	var suspendReason v1alpha1.DevSpaceSuspendReason
+	now := time.Now()
+	if devSpace.Spec.Replicas != nil && *(devSpace.Spec.Replicas) <= 0 {
+		suspendReason = v1alpha1.DevSpaceSuspendReasonManual
+	}
 
-		if !ok {
-			devSpace.Status.Phase = v1alpha1.DevSpacePhaseOff
+	// the schedule override takes precedence over the windows and the idle detection
+	devSpace.Status.ScheduleOverride = nil
+	override := devSpace.Spec.ScheduleOverride
+	if override.IsActive(now) {
+		devSpace.Status.ScheduleOverride = override.DeepCopy()
+		if override.Mode == v1alpha1.ScheduleOverrideOff && suspendReason == "" {
+			suspendReason = v1alpha1.DevSpaceSuspendReasonScheduleOverride
 		}
-	}
-	r.log.Info("check alive window", "enable", hasWin, "in", ok, "now", time.Now().Format(time.TimeOnly))
-
-	// check if all the pods are deleted
-	if devSpace.Status.Phase != v1alpha1.DevSpacePhaseOff && devSpace.Spec.Replicas != nil && *(devSpace.Spec.Replicas) <= 0 {
-		podList := &corev1.PodList{}
-		listErr := r.List(r.ctx, podList, client.MatchingLabels{LabelApp: devSpace.Name})
-		if listErr == nil {
-			if len(podList.Items) == 0 {
-				devSpace.Status.Pods = nil
-				devSpace.Status.DeployStatus = ""
+	} else {
+		if hasWin, ok, wErr = isInAliveWindows(now, devSpace.Spec.Windows); hasWin {
+			if wErr != nil {
+				r.log.Error(wErr, "got error when parsing windows time")
+			}
+
+			if !ok && suspendReason == "" {
+				suspendReason = v1alpha1.DevSpaceSuspendReasonOutOfWindow
 			}
-		} else {
-			r.log.Error(listErr, "failed to list pods", LabelApp, devSpace.Name)
 		}
+		r.log.Info("check alive window", "enable", hasWin, "in", ok, "now", now.Format(time.TimeOnly))
+
+		if suspendReason == "" && isIdle(devSpace, getIdleTimeout(devSpace, r.config), now) {
+			suspendReason = v1alpha1.DevSpaceSuspendReasonIdle
+		}
+	}
+	if needsRestore(devSpace) && observation.pvc != nil {
+		// the storage cannot be replaced before all the pods are gone
+		suspendReason = v1alpha1.DevSpaceSuspendReasonRestoring
+	}
+	devSpace.Status.SuspendReason = suspendReason
+	shouldBeOff := suspendReason != ""
+
+	setConditions(devSpace, observation)
+	devSpace.Status.Phase = computePhase(devSpace, shouldBeOff, observation)
+
+	if err == nil {
+		setPodsStatus(&devSpace.Status, observation.pods)
+		setServicesStatus(&devSpace.Status, servicesOf(devSpace), observation.pods)
 	}
 	return devSpace
 }
@@ -260,9 +327,15 @@ var gitpodDeployment string
 //go:embed data/service.yaml
 var gitpodService string
 
+//go:embed data/service-wakeup.yaml
+var gitpodWakeUpService string
+
 //go:embed data/pvc.yaml
 var gitpodPvc string
 
+//go:embed data/networkpolicy.yaml
+var gitpodNetworkPolicy string
+
 //go:embed data/ingress.yaml
 var gitpodIngress string
 
@@ -277,7 +350,26 @@ var gitpodSecret string
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *DevSpaceReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	podPredicate, err := predicate.LabelSelectorPredicate(metav1.LabelSelector{
+		MatchLabels: map[string]string{LabelAppKind: "devspace"},
+	})
+	if err != nil {
+		return err
+	}
+
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&v1alpha1.DevSpace{}).
+		Owns(&appsv1.Deployment{}).
+		Owns(&corev1.Service{}).
+		Owns(&networkingv1.Ingress{}).
+		Owns(&networkingv1.NetworkPolicy{}).
+		Owns(&corev1.PersistentVolumeClaim{}).
+		Owns(&corev1.ConfigMap{}).
+		Owns(&corev1.Secret{}).
+		Watches(&corev1.Pod{}, handler.EnqueueRequestsFromMapFunc(findDevSpaceForPod), builder.WithPredicates(podPredicate)).
+		Watches(&corev1.ConfigMap{}, handler.EnqueueRequestsFromMapFunc(r.findDevSpacesForOverrideTemplates)).
+		Watches(&v1alpha1.DevSpaceTemplate{}, handler.EnqueueRequestsFromMapFunc(r.findDevSpacesForTemplate)).
+		Watches(&v1alpha1.ClusterDevSpaceTemplate{}, handler.EnqueueRequestsFromMapFunc(r.findDevSpacesForTemplate)).
+		Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(r.findDevSpacesForTLSSecret)).
 		Complete(r)
 }
@@ -20,7 +20,9 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
-	"fmt"
+	"io/fs"
+	"os"
+	"regexp"
 	"strings"
 	"testing"
 	"time"
@@ -29,9 +31,9 @@ import (
 
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
-	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 
 	"github.com/linuxsuren/kde/api/linuxsuren.github.io/v1alpha1"
+	"github.com/linuxsuren/kde/pkg/core"
 	"github.com/stretchr/testify/assert"
 	appsv1 "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/core/v1"
@@ -39,6 +41,7 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
 )
 
 func TestGitPodTemplateRender(t *testing.T) {
@@ -65,6 +68,43 @@ func TestGitPodTemplateRender(t *testing.T) {
 		data, err := pvc.MarshalJSON()
 		assert.NoError(t, err, err)
 		assert.Contains(t, string(data), `"storageClassName":"storageClassName"`, string(data))
+		assert.Contains(t, string(data), `"volumeMode":"volumeMode"`, string(data))
+		assert.Contains(t, string(data), `"accessModes":["ReadWriteOnce"]`, string(data))
+		assert.NotContains(t, string(data), "dataSource", string(data))
+
+		shared := gitpod.DeepCopy()
+		shared.Annotations[v1alpha1.AnnoKeyVolumeAccessMode] = "ReadWriteMany"
+		pvc, err = turnTemplateToUnstructured(gitpodPvc, shared)
+		assert.NoError(t, err, err)
+		data, err = pvc.MarshalJSON()
+		assert.NoError(t, err, err)
+		assert.Contains(t, string(data), `"accessModes":["ReadWriteMany"]`, string(data))
+	})
+
+	t.Run("pvc cloned from the same namespace", func(t *testing.T) {
+		cloned := gitpod.DeepCopy()
+		cloned.Name = "copy"
+		cloned.UID = "copy-uid"
+		cloned.Annotations[v1alpha1.AnnoKeyCloneFrom] = "default/source"
+		pvc, err := turnTemplateToUnstructured(gitpodPvc, cloned)
+		assert.NoError(t, err, err)
+
+		data, err := pvc.MarshalJSON()
+		assert.NoError(t, err, err)
+		assert.Contains(t, string(data), `"dataSource":{"kind":"PersistentVolumeClaim","name":"source"}`, string(data))
+		// owned by the new DevSpace
+		assert.Contains(t, string(data), `"name":"copy","uid":"copy-uid"`, string(data))
+	})
+
+	t.Run("pvc cloned from another namespace", func(t *testing.T) {
+		cloned := gitpod.DeepCopy()
+		cloned.Annotations[v1alpha1.AnnoKeyCloneFrom] = "another/source"
+		pvc, err := turnTemplateToUnstructured(gitpodPvc, cloned)
+		assert.NoError(t, err, err)
+
+		data, err := pvc.MarshalJSON()
+		assert.NoError(t, err, err)
+		assert.Contains(t, string(data), `"dataSourceRef":{"kind":"PersistentVolumeClaim","name":"source","namespace":"another"}`, string(data))
 	})
 
 	t.Run("service", func(t *testing.T) {
@@ -84,19 +124,63 @@ func TestGitPodTemplateRender(t *testing.T) {
 		assert.Contains(t, string(data), `"host":"8080.demo.gitpod.linuxsuren.github.io"`, string(data))
 	})
 
+	t.Run("wake-up ingress", func(t *testing.T) {
+		sleeping := gitpod.DeepCopy()
+		sleeping.Annotations[v1alpha1.AnnoKeyActivityToken] = "activity"
+		ingress, err := turnTemplateToUnstructured(gitpodIngress, sleeping)
+		assert.NoError(t, err, err)
+		data, err := ingress.MarshalJSON()
+		assert.NoError(t, err, err)
+		assert.Contains(t, string(data), `"name":"demo-wakeup"`, string(data))
+		assert.Contains(t, string(data), `"nginx.ingress.kubernetes.io/rewrite-target":"/wakeup/default/demo?token=activity"`, string(data))
+
+		running := gitpod.DeepCopy()
+		running.Status.Phase = v1alpha1.DevSpacePhaseRunning
+		ingress, err = turnTemplateToUnstructured(gitpodIngress, running)
+		assert.NoError(t, err, err)
+		data, err = ingress.MarshalJSON()
+		assert.NoError(t, err, err)
+		assert.NotContains(t, string(data), "wakeup", string(data))
+		assert.Contains(t, string(data), `"number":3000`, string(data))
+	})
+
+	t.Run("ingress in path mode", func(t *testing.T) {
+		pathMode := gitpod.DeepCopy()
+		pathMode.Annotations[v1alpha1.AnnoKeyIngressMode] = "path"
+		ingress, err := turnTemplateToUnstructured(gitpodIngress, pathMode)
+		assert.NoError(t, err, err)
+		data, err := ingress.MarshalJSON()
+		assert.NoError(t, err, err)
+		assert.Contains(t, string(data), `"path":"/demo"`, string(data))
+		assert.NotContains(t, string(data), "ingressClassName", string(data))
+	})
+
+	t.Run("wake-up service", func(t *testing.T) {
+		withService := gitpod.DeepCopy()
+		withService.Annotations[v1alpha1.AnnoKeyServiceName] = "kde-apiserver"
+		withService.Annotations[v1alpha1.AnnoKeyServiceNamespace] = "kde-system"
+		service, err := turnTemplateToUnstructured(gitpodWakeUpService, withService)
+		assert.NoError(t, err, err)
+		data, err := service.MarshalJSON()
+		assert.NoError(t, err, err)
+		assert.Contains(t, string(data), `"externalName":"kde-apiserver.kde-system.svc.cluster.local"`, string(data))
+	})
+
 	t.Run("configmap", func(t *testing.T) {
 		configmap, err := turnTemplateToUnstructured(gitpodConfigMap, gitpod.DeepCopy())
 		assert.NoError(t, err, err)
 		data, err := configmap.MarshalJSON()
 		assert.NoError(t, err, err)
-		assert.Contains(t, string(data), `"id_rsa":"---key---\nprivateKey\n---end---\n"`, string(data))
+		// the private key is mounted from the credentials Secret instead
+		assert.NotContains(t, string(data), "privateKey", string(data))
+		assert.Contains(t, string(data), `"clone.sh"`, string(data))
 		assert.NotContains(t, string(data), "daemon.json")
 	})
 
 	t.Run("set insecure registry", func(t *testing.T) {
 		gitpodInsecure := gitpod.DeepCopy()
 		gitpodInsecure.Spec.Services.Docker = &v1alpha1.Docker{
-			Enabled: true,
+			Enabled: ptr.To(true),
 			InsecureRegistries: []string{
 				"example.com",
 				"foo.com",
@@ -115,7 +199,7 @@ func TestGitPodTemplateRender(t *testing.T) {
 	t.Run("mysql ui", func(t *testing.T) {
 		gitpodMysqlUI := gitpod.DeepCopy()
 		gitpodMysqlUI.Spec.Services.MySQLUI = &v1alpha1.MySQLUI{
-			Enabled: true,
+			Enabled: ptr.To(true),
 			Image:   "foo.image.mysql.ui",
 		}
 		configmap, err := turnTemplateToUnstructured(gitpodDeployment, gitpodMysqlUI)
@@ -126,21 +210,62 @@ func TestGitPodTemplateRender(t *testing.T) {
 	})
 }
 
+// TestTemplatesSyntax makes sure the built-in templates can be parsed by text/template of go1.22,
+// the `else with` form is supported since go1.23
+func TestTemplatesSyntax(t *testing.T) {
+	elseWith := regexp.MustCompile(`{{-?\s*else\s+with\b`)
+	err := fs.WalkDir(os.DirFS("data"), ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile("data/" + path)
+		if err == nil {
+			assert.False(t, elseWith.Match(data), "%s uses `else with` which requires go1.23", path)
+		}
+		return err
+	})
+	assert.NoError(t, err)
+}
+
 func TestUpdateStatus(t *testing.T) {
-	reconciler := &DevSpaceReconciler{}
+	schema, err := v1alpha1.SchemeBuilder.Register().Build()
+	assert.NoError(t, err)
+	assert.NoError(t, v1.SchemeBuilder.AddToScheme(schema))
+	assert.NoError(t, appsv1.SchemeBuilder.AddToScheme(schema))
+	assert.NoError(t, networkv1.SchemeBuilder.AddToScheme(schema))
+
+	reconciler := &DevSpaceReconciler{
+		Client: newFakeClientBuilder(schema).Build(),
+		ctx:    context.Background(),
+	}
 	gitpod := createDefaultGitPod()
 	gitpod = reconciler.updateStatus(gitpod)
-	assert.Equal(t, "demo.gitpod.linuxsuren.github.io", gitpod.Status.Link)
+	assert.Equal(t, "http://demo.gitpod.linuxsuren.github.io", gitpod.Status.Link)
 	assert.Equal(t, []v1alpha1.ExposeLink{
 		{
 			Port: 8080,
-			Link: "8080.demo.gitpod.linuxsuren.github.io",
+			Link: "http://8080.demo.gitpod.linuxsuren.github.io",
 		},
 		{
 			Port: 9090,
-			Link: "9090.demo.gitpod.linuxsuren.github.io",
+			Link: "http://9090.demo.gitpod.linuxsuren.github.io",
 		},
 	}, gitpod.Status.ExposeLinks)
+
+	t.Run("over TLS", func(t *testing.T) {
+		gitpod := createDefaultGitPod()
+		prepareTLS(gitpod, &core.Config{TLS: core.TLS{SecretName: "wildcard"}})
+		gitpod = reconciler.updateStatus(gitpod)
+		assert.Equal(t, "https://demo.gitpod.linuxsuren.github.io", gitpod.Status.Link)
+		assert.Equal(t, "https://8080.demo.gitpod.linuxsuren.github.io", gitpod.Status.ExposeLinks[0].Link)
+	})
+
+	t.Run("the ingresses have no certificates", func(t *testing.T) {
+		// e.g. the wildcard certificate is missing
+		reconciler.config = &core.Config{TLS: core.TLS{SecretName: "wildcard"}}
+		gitpod := reconciler.updateStatus(createDefaultGitPod())
+		assert.Equal(t, "http://demo.gitpod.linuxsuren.github.io", gitpod.Status.Link)
+	})
 }
 
 func createDefaultGitPod() *v1alpha1.DevSpace {
@@ -149,10 +274,10 @@ func createDefaultGitPod() *v1alpha1.DevSpace {
 			Name:      "demo",
 			Namespace: "default",
 			Annotations: map[string]string{
-				"storageClassName":           "storageClassName",
-				"volumeMode":                 "volumeMode",
-				v1alpha1.AnnoKeyExposePorts:  strings.Join([]string{"8080", "9090", "abc", "8080", "-1", "", "65536", "3000"}, ","),
-				v1alpha1.AnnoKeyMaintainMode: PolicyAlways,
+				v1alpha1.AnnoKeyStorageClassName: "storageClassName",
+				v1alpha1.AnnoKeyVolumeMode:       "volumeMode",
+				v1alpha1.AnnoKeyExposePorts:      strings.Join([]string{"8080", "9090", "abc", "8080", "-1", "", "65536", "3000"}, ","),
+				v1alpha1.AnnoKeyMaintainMode:     PolicyAlways,
 			},
 		},
 		Spec: v1alpha1.DevSpaceSpec{
@@ -195,6 +320,7 @@ func TestGitPodController(t *testing.T) {
 	assert.Nil(t, err)
 	err = appsv1.SchemeBuilder.AddToScheme(schema)
 	assert.NoError(t, err, err)
+	assert.NoError(t, networkv1.SchemeBuilder.AddToScheme(schema))
 	defaultRequest := ctrl.Request{NamespacedName: types.NamespacedName{Name: "demo", Namespace: "default"}}
 
 	withoutDefaultValue := createDefaultGitPod().DeepCopy()
@@ -218,6 +344,41 @@ func TestGitPodController(t *testing.T) {
 	zeroReplicas.Spec.Replicas = new(int32)
 	zeroReplicas.Spec.Windows = nil
 
+	idleDevSpace := createDefaultGitPod().DeepCopy()
+	idleDevSpace.Spec.Windows = nil
+	idleDevSpace.Spec.IdleTimeout = &metav1.Duration{Duration: time.Minute}
+	idleDevSpace.Status.LastActivityTime = &metav1.Time{Time: time.Now().Add(-time.Hour)}
+	idleDevSpace.Status.SuspendReason = v1alpha1.DevSpaceSuspendReasonIdle
+
+	now := time.Now().UTC()
+	one := int32(1)
+	keepAliveDevSpace := createDefaultGitPod().DeepCopy()
+	keepAliveDevSpace.Spec.Replicas = &one
+	keepAliveDevSpace.Spec.Windows = []v1alpha1.Window{{
+		From:     now.Add(2 * time.Hour).Format(time.TimeOnly),
+		To:       now.Add(3 * time.Hour).Format(time.TimeOnly),
+		TimeZone: "UTC",
+	}}
+	keepAliveDevSpace.Spec.ScheduleOverride = &v1alpha1.ScheduleOverride{
+		Mode:  v1alpha1.ScheduleOverrideKeepAlive,
+		Until: metav1.NewTime(now.Add(time.Hour)),
+	}
+
+	turnedOffDevSpace := createDefaultGitPod().DeepCopy()
+	turnedOffDevSpace.Spec.Windows = nil
+	turnedOffDevSpace.Spec.Replicas = &one
+	turnedOffDevSpace.Spec.ScheduleOverride = &v1alpha1.ScheduleOverride{
+		Mode:  v1alpha1.ScheduleOverrideOff,
+		Until: metav1.NewTime(now.Add(time.Hour)),
+	}
+
+	expiredOverrideDevSpace := turnedOffDevSpace.DeepCopy()
+	expiredOverrideDevSpace.Spec.ScheduleOverride.Until = metav1.NewTime(now.Add(-time.Minute))
+
+	withCredentials := createDefaultGitPod().DeepCopy()
+	withCredentials.Spec.Services.MySQL = &v1alpha1.MySQL{Enabled: ptr.To(true), Password: "mysql"}
+	withCredentials.Spec.Repository = &v1alpha1.GitRepository{URL: "https://github.com/linuxsuren/kde", Password: "token"}
+
 	type fields struct {
 		Client   client.Client
 		recorder record.EventRecorder
@@ -230,7 +391,7 @@ func TestGitPodController(t *testing.T) {
 	}{{
 		name: "not found",
 		fields: fields{
-			Client: fake.NewClientBuilder().WithScheme(schema).Build(),
+			Client: newFakeClientBuilder(schema).Build(),
 		},
 		req: defaultRequest,
 		verify: func(t *testing.T, r ctrl.Result, Client client.Client, err error) {
@@ -239,11 +400,13 @@ func TestGitPodController(t *testing.T) {
 	}, {
 		name: "normal",
 		fields: fields{
-			Client: fake.NewClientBuilder().WithScheme(schema).WithObjects(createDefaultGitPod()).Build(),
+			Client: newFakeClientBuilder(schema).WithObjects(createDefaultGitPod()).Build(),
 		},
 		req: defaultRequest,
 		verify: func(t *testing.T, r ctrl.Result, Client client.Client, err error) {
-			assert.Equal(t, time.Minute, r.RequeueAfter)
+			// requeue at the next window boundary
+			assert.Greater(t, r.RequeueAfter, time.Duration(0))
+			assert.LessOrEqual(t, r.RequeueAfter, 24*time.Hour+time.Second)
 
 			assert.NoError(t, err, err)
 			ctx := context.TODO()
@@ -278,13 +441,17 @@ func TestGitPodController(t *testing.T) {
 
 			ingress := &networkv1.Ingress{}
 			err = Client.Get(ctx, types.NamespacedName{Name: "demo", Namespace: "default"}, ingress)
-			assert.Error(t, err, err)
+			assert.NoError(t, err, err)
+
+			exposeIngress := &networkv1.Ingress{}
+			err = Client.Get(ctx, types.NamespacedName{Name: "demo-expose", Namespace: "default"}, exposeIngress)
+			assert.NoError(t, err, err)
 		},
 	}, {
 		name: "without default value",
 		req:  defaultRequest,
 		fields: fields{
-			Client: fake.NewClientBuilder().WithScheme(schema).WithObjects(withoutDefaultValue.DeepCopy()).Build(),
+			Client: newFakeClientBuilder(schema).WithObjects(withoutDefaultValue.DeepCopy()).Build(),
 		},
 		verify: func(t *testing.T, r ctrl.Result, Client client.Client, err error) {
 			assert.NoError(t, err)
@@ -309,7 +476,7 @@ func TestGitPodController(t *testing.T) {
 		name: "the replicas number is zero",
 		req:  defaultRequest,
 		fields: fields{
-			Client: fake.NewClientBuilder().WithScheme(schema).WithObjects(zeroReplicas.DeepCopy()).Build(),
+			Client: newFakeClientBuilder(schema).WithObjects(zeroReplicas.DeepCopy()).Build(),
 		},
 		verify: func(t *testing.T, r ctrl.Result, Client client.Client, err error) {
 			assert.NoError(t, err)
@@ -319,6 +486,138 @@ func TestGitPodController(t *testing.T) {
 			assert.Equal(t, 0, len(gitpod.Status.Pods))
 			assert.Empty(t, gitpod.Status.DeployStatus)
 		},
+	}, {
+		name: "with pods",
+		req:  defaultRequest,
+		fields: fields{
+			Client: newFakeClientBuilder(schema).WithObjects(createDefaultGitPod(), createDefaultPod()).Build(),
+		},
+		verify: func(t *testing.T, r ctrl.Result, Client client.Client, err error) {
+			assert.NoError(t, err)
+
+			gitpod := &v1alpha1.DevSpace{}
+			assert.NoError(t, Client.Get(context.TODO(), defaultRequest.NamespacedName, gitpod))
+			assert.Equal(t, []v1.LocalObjectReference{{Name: "demo"}}, gitpod.Status.Pods)
+			assert.Equal(t, string(v1.PodRunning), gitpod.Status.DeployStatus)
+		},
+	}, {
+		name: "idle for too long",
+		req:  defaultRequest,
+		fields: fields{
+			Client: newFakeClientBuilder(schema).WithObjects(idleDevSpace.DeepCopy()).
+				WithStatusSubresource(idleDevSpace.DeepCopy()).Build(),
+		},
+		verify: func(t *testing.T, r ctrl.Result, Client client.Client, err error) {
+			assert.NoError(t, err)
+
+			gitpod := &v1alpha1.DevSpace{}
+			assert.NoError(t, Client.Get(context.TODO(), defaultRequest.NamespacedName, gitpod))
+			assert.Equal(t, v1alpha1.DevSpaceSuspendReasonIdle, gitpod.Status.SuspendReason)
+			assert.Equal(t, v1alpha1.DevSpacePhaseStopped, gitpod.Status.Phase)
+
+			deploy := &appsv1.Deployment{}
+			assert.NoError(t, Client.Get(context.TODO(), defaultRequest.NamespacedName, deploy))
+			assert.Equal(t, int32(0), *deploy.Spec.Replicas)
+		},
+	}, {
+		name: "kept alive out of the windows",
+		req:  defaultRequest,
+		fields: fields{
+			Client: newFakeClientBuilder(schema).WithObjects(keepAliveDevSpace.DeepCopy()).
+				WithStatusSubresource(keepAliveDevSpace.DeepCopy()).Build(),
+		},
+		verify: func(t *testing.T, r ctrl.Result, Client client.Client, err error) {
+			assert.NoError(t, err)
+			// requeue once the override expires
+			assert.InDelta(t, time.Hour, r.RequeueAfter, float64(time.Minute))
+
+			gitpod := &v1alpha1.DevSpace{}
+			assert.NoError(t, Client.Get(context.TODO(), defaultRequest.NamespacedName, gitpod))
+			assert.Empty(t, gitpod.Status.SuspendReason)
+			assert.Equal(t, keepAliveDevSpace.Spec.ScheduleOverride.Mode, gitpod.Status.ScheduleOverride.Mode)
+
+			deploy := &appsv1.Deployment{}
+			assert.NoError(t, Client.Get(context.TODO(), defaultRequest.NamespacedName, deploy))
+			assert.Equal(t, int32(1), *deploy.Spec.Replicas)
+		},
+	}, {
+		name: "turned off early",
+		req:  defaultRequest,
+		fields: fields{
+			Client: newFakeClientBuilder(schema).WithObjects(turnedOffDevSpace.DeepCopy()).
+				WithStatusSubresource(turnedOffDevSpace.DeepCopy()).Build(),
+		},
+		verify: func(t *testing.T, r ctrl.Result, Client client.Client, err error) {
+			assert.NoError(t, err)
+
+			gitpod := &v1alpha1.DevSpace{}
+			assert.NoError(t, Client.Get(context.TODO(), defaultRequest.NamespacedName, gitpod))
+			assert.Equal(t, v1alpha1.DevSpaceSuspendReasonScheduleOverride, gitpod.Status.SuspendReason)
+			assert.Equal(t, v1alpha1.DevSpacePhaseStopped, gitpod.Status.Phase)
+			assert.NotNil(t, gitpod.Status.ScheduleOverride)
+
+			deploy := &appsv1.Deployment{}
+			assert.NoError(t, Client.Get(context.TODO(), defaultRequest.NamespacedName, deploy))
+			assert.Equal(t, int32(0), *deploy.Spec.Replicas)
+		},
+	}, {
+		name: "expired override",
+		req:  defaultRequest,
+		fields: fields{
+			Client: newFakeClientBuilder(schema).WithObjects(expiredOverrideDevSpace.DeepCopy()).
+				WithStatusSubresource(expiredOverrideDevSpace.DeepCopy()).Build(),
+		},
+		verify: func(t *testing.T, r ctrl.Result, Client client.Client, err error) {
+			assert.NoError(t, err)
+
+			gitpod := &v1alpha1.DevSpace{}
+			assert.NoError(t, Client.Get(context.TODO(), defaultRequest.NamespacedName, gitpod))
+			assert.Empty(t, gitpod.Status.SuspendReason)
+			assert.Nil(t, gitpod.Status.ScheduleOverride)
+
+			deploy := &appsv1.Deployment{}
+			assert.NoError(t, Client.Get(context.TODO(), defaultRequest.NamespacedName, deploy))
+			assert.Equal(t, int32(1), *deploy.Spec.Replicas)
+		},
+	}, {
+		name: "plaintext passwords",
+		req:  defaultRequest,
+		fields: fields{
+			Client: newFakeClientBuilder(schema).WithObjects(withCredentials.DeepCopy()).
+				WithStatusSubresource(withCredentials.DeepCopy()).Build(),
+		},
+		verify: func(t *testing.T, r ctrl.Result, Client client.Client, err error) {
+			assert.NoError(t, err)
+
+			gitpod := &v1alpha1.DevSpace{}
+			assert.NoError(t, Client.Get(context.TODO(), defaultRequest.NamespacedName, gitpod))
+			assert.Empty(t, gitpod.Spec.Services.MySQL.Password)
+			assert.Empty(t, gitpod.Spec.Repository.Password)
+			// the generated references are not persisted
+			assert.Nil(t, gitpod.Spec.Services.MySQL.PasswordSecretRef)
+
+			secret := &v1.Secret{}
+			assert.NoError(t, Client.Get(context.TODO(), types.NamespacedName{Name: "demo-credentials", Namespace: "default"}, secret))
+			assert.Equal(t, "mysql", string(secret.Data[v1alpha1.CredentialKeyMySQL]))
+			assert.Equal(t, "token", string(secret.Data[v1alpha1.CredentialKeyGit]))
+
+			deploy := &appsv1.Deployment{}
+			assert.NoError(t, Client.Get(context.TODO(), defaultRequest.NamespacedName, deploy))
+			for _, container := range deploy.Spec.Template.Spec.Containers {
+				if container.Name == "mysql" {
+					assert.Equal(t, &v1.SecretKeySelector{
+						LocalObjectReference: v1.LocalObjectReference{Name: "demo-credentials"},
+						Key:                  v1alpha1.CredentialKeyMySQL,
+					}, container.Env[0].ValueFrom.SecretKeyRef)
+				}
+			}
+			initEnv := deploy.Spec.Template.Spec.InitContainers[0].Env
+			if assert.Len(t, initEnv, 2) {
+				assert.Equal(t, "GIT_SSH_COMMAND", initEnv[0].Name)
+				assert.Equal(t, "GIT_PASSWORD_KDE", initEnv[1].Name)
+				assert.Equal(t, v1alpha1.CredentialKeyGit, initEnv[1].ValueFrom.SecretKeyRef.Key)
+			}
+		},
 	}}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -336,74 +635,3 @@ func TestGitPodController(t *testing.T) {
 		})
 	}
 }
-
-func TestIsInAliveWindows(t *testing.T) {
-	now := time.Now()
-
-	tests := []struct {
-		name         string
-		windows      []v1alpha1.Window
-		expectHasWin bool
-		expectOk     bool
-		expectErr    bool
-	}{{
-		name:         "no windows",
-		windows:      nil,
-		expectHasWin: false,
-		expectOk:     true,
-	}, {
-		name: "invalid time format",
-		windows: []v1alpha1.Window{{
-			From: "aa:bb",
-		}, {
-			From: "00:12:00",
-			To:   "cc:dd",
-		}},
-		expectHasWin: true,
-		expectErr:    true,
-		expectOk:     true,
-	}, {
-		name: "before the window",
-		windows: []v1alpha1.Window{{
-			From: now.Add(time.Minute).Format(time.TimeOnly),
-			To:   now.Add(time.Minute * 2).Format(time.TimeOnly),
-		}},
-		expectHasWin: true,
-		expectErr:    false,
-		expectOk:     false,
-	}, {
-		name: "after the window",
-		windows: []v1alpha1.Window{{
-			From: now.Add(-2 * time.Minute).Format(time.TimeOnly),
-			To:   now.Add(-1 * time.Minute).Format(time.TimeOnly),
-		}},
-		expectHasWin: true,
-		expectErr:    false,
-		expectOk:     false,
-	}, {
-		name: "from bigger than to",
-		windows: []v1alpha1.Window{{
-			From: now.Add(2 * time.Minute).Format(time.TimeOnly),
-			To:   now.Add(-1 * time.Minute).Format(time.TimeOnly),
-		}},
-		expectHasWin: true,
-		expectErr:    true,
-		expectOk:     false,
-	}, {
-		name: "in the window",
-		windows: []v1alpha1.Window{{
-			From: now.Add(-1 * time.Minute).Format(time.TimeOnly),
-			To:   now.Add(1 * time.Minute).Format(time.TimeOnly),
-		}},
-		expectHasWin: true,
-		expectErr:    false,
-		expectOk:     true,
-	}}
-	for i, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			hasWin, _, err := isInAliveWindows(now, tt.windows)
-			assert.Equal(t, tt.expectHasWin, hasWin, fmt.Sprintf("case %d", i))
-			assert.Equal(t, tt.expectErr, err != nil, fmt.Sprintf("case %d, error: %v", i, err))
-		})
-	}
-}
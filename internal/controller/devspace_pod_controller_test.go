@@ -22,109 +22,62 @@ import (
 
 	"github.com/linuxsuren/kde/api/linuxsuren.github.io/v1alpha1"
 	"github.com/stretchr/testify/assert"
-	appsv1 "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
-	ctrl "sigs.k8s.io/controller-runtime"
-	"sigs.k8s.io/controller-runtime/pkg/client"
-	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
-func TestGitPodPodController(t *testing.T) {
-	schema, err := v1alpha1.SchemeBuilder.Register().Build()
-	assert.Nil(t, err)
-	err = v1.SchemeBuilder.AddToScheme(schema)
-	assert.Nil(t, err)
-	err = appsv1.SchemeBuilder.AddToScheme(schema)
-	assert.NoError(t, err, err)
-	defaultRequest := ctrl.Request{NamespacedName: types.NamespacedName{Name: "demo", Namespace: "default"}}
+func TestFindDevSpaceForPod(t *testing.T) {
+	pod := createDefaultPod()
+	assert.Equal(t, []reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: "default", Name: "demo"}}},
+		findDevSpaceForPod(context.Background(), pod))
 
-	defaultPod := createDefaultPod()
-	withoutRepoName := defaultPod.DeepCopy()
+	withoutKind := pod.DeepCopy()
+	delete(withoutKind.Labels, LabelAppKind)
+	assert.Empty(t, findDevSpaceForPod(context.Background(), withoutKind))
+
+	withoutRepoName := pod.DeepCopy()
 	withoutRepoName.Labels = nil
+	assert.Empty(t, findDevSpaceForPod(context.Background(), withoutRepoName))
+}
 
-	pendingPhasePod := defaultPod.DeepCopy()
-	pendingPhasePod.Status.Phase = v1.PodPending
+func TestSetPodsStatus(t *testing.T) {
+	pendingPod := createDefaultPod()
+	pendingPod.Name = "pending"
+	pendingPod.Status.Phase = v1.PodPending
 
-	type fields struct {
-		Client client.Client
-	}
 	tests := []struct {
-		name   string
-		fields fields
-		req    ctrl.Request
-		verify func(*testing.T, ctrl.Result, client.Client, error)
+		name         string
+		pods         []v1.Pod
+		deployStatus string
+		podNames     []string
 	}{{
-		name: "not found",
-		fields: fields{
-			Client: fake.NewClientBuilder().WithScheme(schema).Build(),
-		},
-		req: defaultRequest,
-		verify: func(t *testing.T, r ctrl.Result, Client client.Client, err error) {
-			assert.NoError(t, err, err)
-		},
+		name: "no pods",
 	}, {
-		name: "without repo name",
-		fields: fields{
-			Client: fake.NewClientBuilder().WithScheme(schema).WithObjects(withoutRepoName.DeepCopy()).Build(),
-		},
-		req: defaultRequest,
-		verify: func(t *testing.T, r ctrl.Result, Client client.Client, err error) {
-			assert.NoError(t, err, err)
-		},
+		name:         "pending",
+		pods:         []v1.Pod{*pendingPod},
+		deployStatus: string(v1.PodPending),
+		podNames:     []string{"pending"},
 	}, {
-		name: "without gitpod found",
-		fields: fields{
-			Client: fake.NewClientBuilder().WithScheme(schema).WithObjects(defaultPod.DeepCopy()).Build(),
-		},
-		req: defaultRequest,
-		verify: func(t *testing.T, r ctrl.Result, Client client.Client, err error) {
-			assert.NoError(t, err, err)
-		},
-	}, {
-		name: "normal",
-		fields: fields{
-			Client: fake.NewClientBuilder().WithScheme(schema).WithObjects(defaultPod.DeepCopy(),
-				createDefaultGitPod().DeepCopy()).WithStatusSubresource(createDefaultGitPod().DeepCopy()).Build(),
-		},
-		req: defaultRequest,
-		verify: func(t *testing.T, r ctrl.Result, Client client.Client, err error) {
-			assert.NoError(t, err, err)
-
-			gitpod := &v1alpha1.DevSpace{}
-			err = Client.Get(context.TODO(), defaultRequest.NamespacedName, gitpod)
-			assert.NoError(t, err, err)
-			assert.Equal(t, string(v1.PodRunning), gitpod.Status.DeployStatus)
-		},
-	}, {
-		name: "pending status",
-		fields: fields{
-			Client: fake.NewClientBuilder().WithScheme(schema).WithObjects(pendingPhasePod.DeepCopy(),
-				createDefaultGitPod().DeepCopy()).WithStatusSubresource(createDefaultGitPod().DeepCopy()).Build(),
-		},
-		req: defaultRequest,
-		verify: func(t *testing.T, r ctrl.Result, Client client.Client, err error) {
-			assert.NoError(t, err, err)
-
-			gitpod := &v1alpha1.DevSpace{}
-			err = Client.Get(context.TODO(), defaultRequest.NamespacedName, gitpod)
-			assert.NoError(t, err, err)
-			assert.Equal(t, string(v1.PodPending), gitpod.Status.DeployStatus)
-		},
+		name:         "any pod is running",
+		pods:         []v1.Pod{*pendingPod, *createDefaultPod()},
+		deployStatus: string(v1.PodRunning),
+		podNames:     []string{"pending", "demo"},
 	}}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			r := &DevSpacePodPodReconciler{
-				Client: tt.fields.Client,
+			status := &v1alpha1.DevSpaceStatus{
+				DeployStatus: "Running",
+				Pods:         []v1.LocalObjectReference{{Name: "gone"}},
 			}
-			mgr := &FakeManager{
-				Client: tt.fields.Client,
-				Scheme: schema,
+			setPodsStatus(status, tt.pods)
+			assert.Equal(t, tt.deployStatus, status.DeployStatus)
+			var podNames []string
+			for _, pod := range status.Pods {
+				podNames = append(podNames, pod.Name)
 			}
-			r.SetupWithManager(mgr)
-			got, err := r.Reconcile(context.Background(), tt.req)
-			tt.verify(t, got, tt.fields.Client, err)
+			assert.Equal(t, tt.podNames, podNames)
 		})
 	}
 }
@@ -135,7 +88,8 @@ func createDefaultPod() *v1.Pod {
 			Name:      "demo",
 			Namespace: "default",
 			Labels: map[string]string{
-				LabelApp: "demo",
+				LabelApp:     "demo",
+				LabelAppKind: "devspace",
 			},
 		},
 		Spec: v1.PodSpec{
@@ -0,0 +1,263 @@
+/*
+Copyright 2024 kde authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/linuxsuren/kde/api/linuxsuren.github.io/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// ContainerNameServer is the name of the IDE container
+	ContainerNameServer = "server"
+	// ContainerNameInit is the name of the init container which clones the repositories
+	ContainerNameInit = "init"
+)
+
+// reasons of the conditions
+const (
+	ReasonNotFound      = "NotFound"
+	ReasonBound         = "Bound"
+	ReasonEphemeral     = "Ephemeral"
+	ReasonAvailable     = "Available"
+	ReasonUnavailable   = "Unavailable"
+	ReasonScaledDown    = "ScaledDown"
+	ReasonAdmitted      = "Admitted"
+	ReasonNoAddress     = "NoAddress"
+	ReasonReady         = "Ready"
+	ReasonNotReady      = "NotReady"
+	ReasonNoServices    = "NoServices"
+	ReasonNoRepository  = "NoRepository"
+	ReasonNoPods        = "NoPods"
+	ReasonCloning       = "Cloning"
+	ReasonCloned        = "Cloned"
+	ReasonCloneFailed   = "CloneFailed"
+	ReasonUnknownStatus = "Unknown"
+)
+
+// failedWaitingReasons are the container waiting reasons which need manual intervention
+var failedWaitingReasons = map[string]bool{
+	"CrashLoopBackOff":           true,
+	"ErrImagePull":               true,
+	"ImagePullBackOff":           true,
+	"InvalidImageName":           true,
+	"CreateContainerConfigError": true,
+	"CreateContainerError":       true,
+}
+
+// devSpaceObservation holds the child resources of a DevSpace found in the cluster
+type devSpaceObservation struct {
+	pvc        *v1.PersistentVolumeClaim
+	deployment *appsv1.Deployment
+	ingress    *networkingv1.Ingress
+	pods       []v1.Pod
+}
+
+// observe collects the child resources of the DevSpace.
+// The missing resources are left as nil.
+func (r *DevSpaceReconciler) observe(ctx context.Context, devSpace *v1alpha1.DevSpace) (observation *devSpaceObservation, err error) {
+	observation = &devSpaceObservation{}
+	key := client.ObjectKeyFromObject(devSpace)
+
+	pvc := &v1.PersistentVolumeClaim{}
+	if err = r.Get(ctx, key, pvc); err == nil {
+		observation.pvc = pvc
+	} else if !apierrors.IsNotFound(err) {
+		return
+	}
+
+	deploy := &appsv1.Deployment{}
+	if err = r.Get(ctx, key, deploy); err == nil {
+		observation.deployment = deploy
+	} else if !apierrors.IsNotFound(err) {
+		return
+	}
+
+	ingress := &networkingv1.Ingress{}
+	if err = r.Get(ctx, key, ingress); err == nil {
+		observation.ingress = ingress
+	} else if !apierrors.IsNotFound(err) {
+		return
+	}
+
+	podList := &v1.PodList{}
+	if err = r.List(ctx, podList, client.InNamespace(devSpace.Namespace), client.MatchingLabels{LabelApp: devSpace.Name}); err == nil {
+		observation.pods = podList.Items
+	}
+	return
+}
+
+// setConditions updates all the conditions of the DevSpace according to the observation
+func setConditions(devSpace *v1alpha1.DevSpace, observation *devSpaceObservation) {
+	generation := devSpace.Generation
+	setCondition := func(conditionType string, status metav1.ConditionStatus, reason, message string) {
+		meta.SetStatusCondition(&devSpace.Status.Conditions, metav1.Condition{
+			Type:               conditionType,
+			Status:             status,
+			Reason:             reason,
+			Message:            message,
+			ObservedGeneration: generation,
+		})
+	}
+
+	setCondition(storageBoundCondition(devSpace, observation.pvc))
+	setCondition(workloadAvailableCondition(observation.deployment))
+	setCondition(ingressReadyCondition(observation.ingress))
+	setCondition(servicesReadyCondition(observation.pods))
+	setCondition(repositoryClonedCondition(devSpace, observation.pods))
+}
+
+func storageBoundCondition(devSpace *v1alpha1.DevSpace, pvc *v1.PersistentVolumeClaim) (string, metav1.ConditionStatus, string, string) {
+	conditionType := v1alpha1.DevSpaceConditionStorageBound
+	switch {
+	case devSpace.Annotations[v1alpha1.AnnoKeyStorageTemporary] != "":
+		return conditionType, metav1.ConditionTrue, ReasonEphemeral, "the workspace uses a temporary volume"
+	case pvc == nil:
+		return conditionType, metav1.ConditionFalse, ReasonNotFound, "the PersistentVolumeClaim is not created yet"
+	case pvc.Status.Phase == v1.ClaimBound:
+		return conditionType, metav1.ConditionTrue, ReasonBound, fmt.Sprintf("bound to volume %q", pvc.Spec.VolumeName)
+	default:
+		return conditionType, metav1.ConditionFalse, string(pvc.Status.Phase), "the PersistentVolumeClaim is not bound"
+	}
+}
+
+func workloadAvailableCondition(deploy *appsv1.Deployment) (string, metav1.ConditionStatus, string, string) {
+	conditionType := v1alpha1.DevSpaceConditionWorkloadAvailable
+	switch {
+	case deploy == nil:
+		return conditionType, metav1.ConditionFalse, ReasonNotFound, "the Deployment is not created yet"
+	case deploy.Spec.Replicas != nil && *deploy.Spec.Replicas == 0:
+		return conditionType, metav1.ConditionFalse, ReasonScaledDown, "the Deployment is scaled down to zero"
+	case deploy.Status.AvailableReplicas > 0:
+		return conditionType, metav1.ConditionTrue, ReasonAvailable, fmt.Sprintf("%d replica(s) available", deploy.Status.AvailableReplicas)
+	default:
+		return conditionType, metav1.ConditionFalse, ReasonUnavailable, "no available replicas"
+	}
+}
+
+func ingressReadyCondition(ingress *networkingv1.Ingress) (string, metav1.ConditionStatus, string, string) {
+	conditionType := v1alpha1.DevSpaceConditionIngressReady
+	switch {
+	case ingress == nil:
+		return conditionType, metav1.ConditionFalse, ReasonNotFound, "the Ingress is not created yet"
+	case len(ingress.Status.LoadBalancer.Ingress) > 0:
+		return conditionType, metav1.ConditionTrue, ReasonAdmitted, "the Ingress got an address"
+	default:
+		return conditionType, metav1.ConditionFalse, ReasonNoAddress, "waiting for the ingress controller to admit the Ingress"
+	}
+}
+
+func servicesReadyCondition(pods []v1.Pod) (string, metav1.ConditionStatus, string, string) {
+	conditionType := v1alpha1.DevSpaceConditionServicesReady
+	if len(pods) == 0 {
+		return conditionType, metav1.ConditionUnknown, ReasonNoPods, "no pods found"
+	}
+
+	var total int
+	var notReady []string
+	for _, status := range serviceContainerStatuses(&pods[0]) {
+		total++
+		if !status.Ready {
+			notReady = append(notReady, status.Name)
+		}
+	}
+
+	switch {
+	case total == 0:
+		return conditionType, metav1.ConditionTrue, ReasonNoServices, "no built-in services enabled"
+	case len(notReady) > 0:
+		return conditionType, metav1.ConditionFalse, ReasonNotReady, fmt.Sprintf("not ready: %s", strings.Join(notReady, ","))
+	default:
+		return conditionType, metav1.ConditionTrue, ReasonReady, fmt.Sprintf("%d service(s) ready", total)
+	}
+}
+
+func repositoryClonedCondition(devSpace *v1alpha1.DevSpace, pods []v1.Pod) (string, metav1.ConditionStatus, string, string) {
+	conditionType := v1alpha1.DevSpaceConditionRepositoryCloned
+	if len(repositoriesOf(&devSpace.Spec)) == 0 {
+		return conditionType, metav1.ConditionTrue, ReasonNoRepository, "no repository given"
+	}
+	if len(pods) == 0 {
+		return conditionType, metav1.ConditionUnknown, ReasonNoPods, "no pods found"
+	}
+
+	for _, status := range pods[0].Status.InitContainerStatuses {
+		if status.Name != ContainerNameInit {
+			continue
+		}
+
+		if terminated := status.State.Terminated; terminated != nil {
+			if terminated.ExitCode == 0 {
+				return conditionType, metav1.ConditionTrue, ReasonCloned, "the repositories are cloned"
+			}
+			return conditionType, metav1.ConditionFalse, ReasonCloneFailed, terminated.Message
+		}
+		if status.LastTerminationState.Terminated != nil && status.LastTerminationState.Terminated.ExitCode != 0 {
+			return conditionType, metav1.ConditionFalse, ReasonCloneFailed, status.LastTerminationState.Terminated.Message
+		}
+		return conditionType, metav1.ConditionUnknown, ReasonCloning, "the init container is still running"
+	}
+	return conditionType, metav1.ConditionUnknown, ReasonUnknownStatus, "the init container status is unknown"
+}
+
+// computePhase works out the lifecycle phase from the desired state and the observation
+func computePhase(devSpace *v1alpha1.DevSpace, shouldBeOff bool, observation *devSpaceObservation) v1alpha1.DevSpacePhase {
+	switch {
+	case devSpace.DeletionTimestamp != nil:
+		return v1alpha1.DevSpacePhaseDeleting
+	case shouldBeOff && len(observation.pods) > 0:
+		return v1alpha1.DevSpacePhaseStopping
+	case shouldBeOff:
+		return v1alpha1.DevSpacePhaseStopped
+	case hasFailedPod(observation.pods):
+		return v1alpha1.DevSpacePhaseFailed
+	case meta.IsStatusConditionTrue(devSpace.Status.Conditions, v1alpha1.DevSpaceConditionWorkloadAvailable):
+		return v1alpha1.DevSpacePhaseRunning
+	case len(observation.pods) > 0:
+		return v1alpha1.DevSpacePhaseStarting
+	case observation.deployment != nil || observation.pvc != nil:
+		return v1alpha1.DevSpacePhaseProvisioning
+	default:
+		return v1alpha1.DevSpacePhasePending
+	}
+}
+
+func hasFailedPod(pods []v1.Pod) bool {
+	for _, pod := range pods {
+		if pod.Status.Phase == v1.PodFailed {
+			return true
+		}
+
+		statuses := append(append([]v1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+		for _, status := range statuses {
+			if waiting := status.State.Waiting; waiting != nil && failedWaitingReasons[waiting.Reason] {
+				return true
+			}
+		}
+	}
+	return false
+}
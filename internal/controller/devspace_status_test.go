@@ -0,0 +1,212 @@
+/*
+Copyright 2024 kde authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/linuxsuren/kde/api/linuxsuren.github.io/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestComputePhase(t *testing.T) {
+	runningPod := *createDefaultPod()
+	crashPod := *createDefaultPod()
+	crashPod.Status.ContainerStatuses = []v1.ContainerStatus{{
+		Name: ContainerNameServer,
+		State: v1.ContainerState{
+			Waiting: &v1.ContainerStateWaiting{Reason: "CrashLoopBackOff"},
+		},
+	}}
+
+	available := createDefaultGitPod()
+	meta.SetStatusCondition(&available.Status.Conditions, metav1.Condition{
+		Type:   v1alpha1.DevSpaceConditionWorkloadAvailable,
+		Status: metav1.ConditionTrue,
+		Reason: ReasonAvailable,
+	})
+
+	deleting := createDefaultGitPod()
+	deleting.DeletionTimestamp = &metav1.Time{}
+
+	tests := []struct {
+		name        string
+		devSpace    *v1alpha1.DevSpace
+		shouldBeOff bool
+		observation *devSpaceObservation
+		expect      v1alpha1.DevSpacePhase
+	}{{
+		name:        "nothing deployed",
+		devSpace:    createDefaultGitPod(),
+		observation: &devSpaceObservation{},
+		expect:      v1alpha1.DevSpacePhasePending,
+	}, {
+		name:        "only the pvc exists",
+		devSpace:    createDefaultGitPod(),
+		observation: &devSpaceObservation{pvc: &v1.PersistentVolumeClaim{}},
+		expect:      v1alpha1.DevSpacePhaseProvisioning,
+	}, {
+		name:        "pods are not available",
+		devSpace:    createDefaultGitPod(),
+		observation: &devSpaceObservation{pods: []v1.Pod{runningPod}},
+		expect:      v1alpha1.DevSpacePhaseStarting,
+	}, {
+		name:        "workload is available",
+		devSpace:    available,
+		observation: &devSpaceObservation{pods: []v1.Pod{runningPod}},
+		expect:      v1alpha1.DevSpacePhaseRunning,
+	}, {
+		name:        "crash loop",
+		devSpace:    available,
+		observation: &devSpaceObservation{pods: []v1.Pod{crashPod}},
+		expect:      v1alpha1.DevSpacePhaseFailed,
+	}, {
+		name:        "should be off with pods",
+		devSpace:    available,
+		shouldBeOff: true,
+		observation: &devSpaceObservation{pods: []v1.Pod{runningPod}},
+		expect:      v1alpha1.DevSpacePhaseStopping,
+	}, {
+		name:        "should be off without pods",
+		devSpace:    createDefaultGitPod(),
+		shouldBeOff: true,
+		observation: &devSpaceObservation{},
+		expect:      v1alpha1.DevSpacePhaseStopped,
+	}, {
+		name:        "deleting",
+		devSpace:    deleting,
+		observation: &devSpaceObservation{},
+		expect:      v1alpha1.DevSpacePhaseDeleting,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expect, computePhase(tt.devSpace, tt.shouldBeOff, tt.observation))
+		})
+	}
+}
+
+func TestSetConditions(t *testing.T) {
+	devSpace := createDefaultGitPod()
+	devSpace.Generation = 3
+	devSpace.Spec.Repository = &v1alpha1.GitRepository{URL: "https://github.com/linuxsuren/kde"}
+
+	t.Run("nothing deployed", func(t *testing.T) {
+		target := devSpace.DeepCopy()
+		setConditions(target, &devSpaceObservation{})
+		assert.Len(t, target.Status.Conditions, 5)
+		for _, condition := range target.Status.Conditions {
+			assert.NotEqual(t, metav1.ConditionTrue, condition.Status, condition.Type)
+			assert.Equal(t, int64(3), condition.ObservedGeneration)
+		}
+	})
+
+	t.Run("all ready", func(t *testing.T) {
+		target := devSpace.DeepCopy()
+		pod := createDefaultPod()
+		pod.Status.InitContainerStatuses = []v1.ContainerStatus{{
+			Name: ContainerNameInit,
+			State: v1.ContainerState{
+				Terminated: &v1.ContainerStateTerminated{ExitCode: 0},
+			},
+		}}
+		pod.Status.ContainerStatuses = []v1.ContainerStatus{{
+			Name: ContainerNameServer,
+		}, {
+			Name:  "mysql",
+			Ready: true,
+		}}
+		setConditions(target, &devSpaceObservation{
+			pvc: &v1.PersistentVolumeClaim{Status: v1.PersistentVolumeClaimStatus{Phase: v1.ClaimBound}},
+			deployment: &appsv1.Deployment{Status: appsv1.DeploymentStatus{
+				AvailableReplicas: 1,
+			}},
+			ingress: &networkingv1.Ingress{Status: networkingv1.IngressStatus{
+				LoadBalancer: networkingv1.IngressLoadBalancerStatus{
+					Ingress: []networkingv1.IngressLoadBalancerIngress{{IP: "127.0.0.1"}},
+				},
+			}},
+			pods: []v1.Pod{*pod},
+		})
+		for _, condition := range target.Status.Conditions {
+			assert.Equal(t, metav1.ConditionTrue, condition.Status, condition.Type)
+		}
+	})
+
+	t.Run("clone failed", func(t *testing.T) {
+		target := devSpace.DeepCopy()
+		pod := createDefaultPod()
+		pod.Status.InitContainerStatuses = []v1.ContainerStatus{{
+			Name: ContainerNameInit,
+			State: v1.ContainerState{
+				Waiting: &v1.ContainerStateWaiting{Reason: "CrashLoopBackOff"},
+			},
+			LastTerminationState: v1.ContainerState{
+				Terminated: &v1.ContainerStateTerminated{ExitCode: 128, Message: "repository not found"},
+			},
+		}}
+		setConditions(target, &devSpaceObservation{pods: []v1.Pod{*pod}})
+		condition := meta.FindStatusCondition(target.Status.Conditions, v1alpha1.DevSpaceConditionRepositoryCloned)
+		if assert.NotNil(t, condition) {
+			assert.Equal(t, metav1.ConditionFalse, condition.Status)
+			assert.Equal(t, ReasonCloneFailed, condition.Reason)
+			assert.Equal(t, "repository not found", condition.Message)
+		}
+	})
+
+	t.Run("repositories only", func(t *testing.T) {
+		target := devSpace.DeepCopy()
+		target.Spec.Repository = nil
+		target.Spec.Repositories = []v1alpha1.GitRepository{{URL: "https://github.com/linuxsuren/kde"}}
+		setConditions(target, &devSpaceObservation{})
+		condition := meta.FindStatusCondition(target.Status.Conditions, v1alpha1.DevSpaceConditionRepositoryCloned)
+		if assert.NotNil(t, condition) {
+			assert.Equal(t, metav1.ConditionUnknown, condition.Status)
+			assert.Equal(t, ReasonNoPods, condition.Reason)
+		}
+	})
+}
+
+func TestObserve(t *testing.T) {
+	schema, err := v1alpha1.SchemeBuilder.Register().Build()
+	assert.NoError(t, err)
+	assert.NoError(t, v1.SchemeBuilder.AddToScheme(schema))
+	assert.NoError(t, appsv1.SchemeBuilder.AddToScheme(schema))
+	assert.NoError(t, networkingv1.SchemeBuilder.AddToScheme(schema))
+
+	devSpace := createDefaultGitPod()
+	pod := createDefaultPod()
+	pod.Name = "demo-abc"
+	r := &DevSpaceReconciler{
+		Client: fake.NewClientBuilder().WithScheme(schema).WithObjects(pod, &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: devSpace.Name, Namespace: devSpace.Namespace},
+		}).Build(),
+	}
+
+	observation, err := r.observe(context.Background(), devSpace)
+	assert.NoError(t, err)
+	assert.NotNil(t, observation.deployment)
+	assert.Nil(t, observation.pvc)
+	assert.Nil(t, observation.ingress)
+	assert.Len(t, observation.pods, 1)
+}
@@ -0,0 +1,258 @@
+/*
+Copyright 2024 kde authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/linuxsuren/kde/api/linuxsuren.github.io/v1alpha1"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// +kubebuilder:rbac:groups=linuxsuren.github.io,resources=devspacetemplates;clusterdevspacetemplates,verbs=get;list;watch
+
+// applyTemplate merges the referenced template into the spec of the DevSpace in memory,
+// the fields given by the DevSpace take precedence. The template being applied is
+// recorded in the status, it is replaced by the latest one according to the update policy.
+// The spec of it is kept in a ConfigMap instead of the status, the passwords are not recorded
+// since they are kept in the credentials Secret.
+func (r *DevSpaceReconciler) applyTemplate(ctx context.Context, devSpace *v1alpha1.DevSpace) (err error) {
+	ref := devSpace.Spec.TemplateRef
+	if ref == nil {
+		if devSpace.Status.Template != nil {
+			devSpace.Status.Template = nil
+			err = client.IgnoreNotFound(r.Delete(ctx, &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+				Namespace: devSpace.Namespace,
+				Name:      v1alpha1.AppliedTemplateConfigMapName(devSpace.Name),
+			}}))
+		}
+		return
+	}
+
+	applied := devSpace.Status.Template
+	if applied != nil && (applied.Kind != getTemplateKind(ref) || applied.Name != ref.Name) {
+		// the reference is changed
+		applied = nil
+	}
+	var spec *v1alpha1.DevSpaceSpec
+	if applied != nil {
+		if spec, err = r.getAppliedTemplateSpec(ctx, devSpace); err != nil {
+			return
+		}
+	}
+
+	latest, latestSpec, policy, templateErr := r.getTemplate(ctx, devSpace.Namespace, ref)
+	switch {
+	case templateErr == nil:
+		// the latest one carries the passwords which are not recorded, and the recorded spec
+		// is missing if the DevSpace was reconciled before having the ConfigMap
+		if spec == nil || shouldUpdateTemplate(applied, latest, policy, devSpace.Status.Phase) || applied.Generation == latest.Generation {
+			applied, spec = latest, latestSpec
+			if err = r.recordAppliedTemplate(ctx, devSpace, spec); err != nil {
+				return
+			}
+		}
+	case apierrors.IsNotFound(templateErr) && spec != nil:
+		r.Recorder.Eventf(devSpace, v1.EventTypeWarning, "Template", "%s %q not found, keep using the applied one", applied.Kind, applied.Name)
+	default:
+		err = fmt.Errorf("failed to get the template of DevSpace, error: %v", templateErr)
+		r.Recorder.Event(devSpace, v1.EventTypeWarning, "Template", err.Error())
+		return
+	}
+
+	devSpace.Status.Template = applied
+	var merged *v1alpha1.DevSpaceSpec
+	if merged, err = mergeTemplate(spec, &devSpace.Spec); err == nil {
+		devSpace.Spec = *merged
+	}
+	return
+}
+
+// getAppliedTemplateSpec returns the recorded spec of the applied template, it is nil if not found
+func (r *DevSpaceReconciler) getAppliedTemplateSpec(ctx context.Context, devSpace *v1alpha1.DevSpace) (spec *v1alpha1.DevSpaceSpec, err error) {
+	cm := &v1.ConfigMap{}
+	key := types.NamespacedName{Namespace: devSpace.Namespace, Name: v1alpha1.AppliedTemplateConfigMapName(devSpace.Name)}
+	if err = r.Get(ctx, key, cm); err != nil {
+		err = client.IgnoreNotFound(err)
+		return
+	}
+	return AppliedTemplateSpecOf(cm)
+}
+
+// AppliedTemplateSpecOf returns the template spec recorded in the given ConfigMap of the applied template
+func AppliedTemplateSpecOf(cm *v1.ConfigMap) (spec *v1alpha1.DevSpaceSpec, err error) {
+	spec = &v1alpha1.DevSpaceSpec{}
+	if err = json.Unmarshal([]byte(cm.Data[v1alpha1.AppliedTemplateKey]), spec); err != nil {
+		err = fmt.Errorf("invalid applied template in ConfigMap %s/%s, error: %v", cm.Namespace, cm.Name, err)
+	}
+	return
+}
+
+// recordAppliedTemplate keeps the given template spec without the passwords in the ConfigMap of the applied template
+func (r *DevSpaceReconciler) recordAppliedTemplate(ctx context.Context, devSpace *v1alpha1.DevSpace, spec *v1alpha1.DevSpaceSpec) (err error) {
+	recorded := spec.DeepCopy()
+	clearPlaintextCredentials(recorded)
+	if auth := recorded.Auth.BasicAuth; auth != nil {
+		auth.Password = ""
+	}
+	var data []byte
+	if data, err = json.Marshal(recorded); err != nil {
+		return
+	}
+
+	cm := &v1.ConfigMap{}
+	key := types.NamespacedName{Namespace: devSpace.Namespace, Name: v1alpha1.AppliedTemplateConfigMapName(devSpace.Name)}
+	if err = r.Get(ctx, key, cm); err == nil {
+		if cm.Data[v1alpha1.AppliedTemplateKey] != string(data) {
+			cm.Data = map[string]string{v1alpha1.AppliedTemplateKey: string(data)}
+			err = r.Update(ctx, cm)
+		}
+		return
+	}
+	if !apierrors.IsNotFound(err) {
+		return
+	}
+
+	cm = &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      key.Name,
+			Namespace: key.Namespace,
+			Labels: map[string]string{
+				LabelAppKind: "devspace",
+				LabelApp:     devSpace.Name,
+			},
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(devSpace, v1alpha1.GroupVersion.WithKind("DevSpace")),
+			},
+		},
+		Data: map[string]string{v1alpha1.AppliedTemplateKey: string(data)},
+	}
+	err = r.Create(ctx, cm)
+	return
+}
+
+// getTemplate returns the latest template along with its spec and update policy
+func (r *DevSpaceReconciler) getTemplate(ctx context.Context, namespace string, ref *v1alpha1.TemplateReference) (
+	latest *v1alpha1.AppliedTemplate, spec *v1alpha1.DevSpaceSpec, policy v1alpha1.TemplateUpdatePolicy, err error) {
+	var templateSpec v1alpha1.DevSpaceTemplateSpec
+	var generation int64
+	switch kind := getTemplateKind(ref); kind {
+	case v1alpha1.KindClusterDevSpaceTemplate:
+		template := &v1alpha1.ClusterDevSpaceTemplate{}
+		if err = r.Get(ctx, types.NamespacedName{Name: ref.Name}, template); err != nil {
+			return
+		}
+		templateSpec, generation = template.Spec, template.Generation
+	default:
+		template := &v1alpha1.DevSpaceTemplate{}
+		if err = r.Get(ctx, types.NamespacedName{Namespace: namespace, Name: ref.Name}, template); err != nil {
+			return
+		}
+		templateSpec, generation = template.Spec, template.Generation
+	}
+
+	latest = &v1alpha1.AppliedTemplate{
+		Kind:       getTemplateKind(ref),
+		Name:       ref.Name,
+		Generation: generation,
+	}
+	spec = templateSpec.Template.DeepCopy()
+	// a template cannot refer to another template
+	spec.TemplateRef = nil
+
+	policy = ref.UpdatePolicy
+	if policy == "" {
+		policy = templateSpec.UpdatePolicy
+	}
+	return
+}
+
+func getTemplateKind(ref *v1alpha1.TemplateReference) string {
+	if ref.Kind == "" {
+		return v1alpha1.KindDevSpaceTemplate
+	}
+	return ref.Kind
+}
+
+// shouldUpdateTemplate tells if the applied template should be replaced by the latest one
+func shouldUpdateTemplate(applied, latest *v1alpha1.AppliedTemplate, policy v1alpha1.TemplateUpdatePolicy, phase v1alpha1.DevSpacePhase) bool {
+	if applied == nil {
+		return true
+	}
+	if applied.Generation == latest.Generation {
+		return false
+	}
+
+	switch policy {
+	case v1alpha1.TemplateUpdatePolicyNever:
+		return false
+	case v1alpha1.TemplateUpdatePolicyOnRestart:
+		// nothing is running, it is safe to apply the changes
+		return phase == v1alpha1.DevSpacePhaseStopped || phase == v1alpha1.DevSpacePhasePending || phase == ""
+	default:
+		return true
+	}
+}
+
+// mergeTemplate returns the template spec overridden by the non-empty fields of the DevSpace spec.
+// The maps are merged, the lists of the DevSpace replace the ones of the template.
+func mergeTemplate(template, spec *v1alpha1.DevSpaceSpec) (merged *v1alpha1.DevSpaceSpec, err error) {
+	var templateData, specData, mergedData []byte
+	if templateData, err = json.Marshal(template); err != nil {
+		return
+	}
+	if specData, err = json.Marshal(spec); err != nil {
+		return
+	}
+	if mergedData, err = strategicpatch.StrategicMergePatch(templateData, specData, v1alpha1.DevSpaceSpec{}); err != nil {
+		return
+	}
+
+	merged = &v1alpha1.DevSpaceSpec{}
+	err = json.Unmarshal(mergedData, merged)
+	return
+}
+
+// findDevSpacesForTemplate returns the DevSpaces which refer to the template
+func (r *DevSpaceReconciler) findDevSpacesForTemplate(ctx context.Context, template client.Object) (requests []reconcile.Request) {
+	kind := v1alpha1.KindDevSpaceTemplate
+	var opts []client.ListOption
+	if _, ok := template.(*v1alpha1.ClusterDevSpaceTemplate); ok {
+		kind = v1alpha1.KindClusterDevSpaceTemplate
+	} else {
+		opts = append(opts, client.InNamespace(template.GetNamespace()))
+	}
+
+	devSpaceList := &v1alpha1.DevSpaceList{}
+	if err := r.List(ctx, devSpaceList, opts...); err != nil {
+		return
+	}
+	for _, devSpace := range devSpaceList.Items {
+		if ref := devSpace.Spec.TemplateRef; ref != nil && ref.Name == template.GetName() && getTemplateKind(ref) == kind {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&devSpace)})
+		}
+	}
+	return
+}
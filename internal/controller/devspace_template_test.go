@@ -0,0 +1,371 @@
+/*
+Copyright 2024 kde authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/linuxsuren/kde/api/linuxsuren.github.io/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestMergeTemplate(t *testing.T) {
+	template := &v1alpha1.DevSpaceSpec{
+		Image:  "java:17",
+		CPU:    "4",
+		Memory: "8Gi",
+		Environment: map[string]string{
+			"JAVA_HOME": "/opt/java",
+			"LANG":      "en_US",
+		},
+		Windows:                []v1alpha1.Window{{From: "08:00", To: "20:00"}},
+		ReadOnlyRootFilesystem: ptr.To(true),
+		Services: v1alpha1.Services{
+			MySQL:        &v1alpha1.MySQL{Enabled: ptr.To(true), Database: "test"},
+			RabbitMQ:     &v1alpha1.RabbitMQ{Enabled: ptr.To(true)},
+			Docker:       &v1alpha1.Docker{Enabled: ptr.To(true)},
+			WaitForReady: ptr.To(true),
+		},
+	}
+	spec := &v1alpha1.DevSpaceSpec{
+		CPU: "2",
+		Environment: map[string]string{
+			"LANG": "zh_CN",
+		},
+		Windows:                []v1alpha1.Window{{From: "10:00", To: "12:00"}},
+		ReadOnlyRootFilesystem: ptr.To(false),
+		Services: v1alpha1.Services{
+			MySQL:        &v1alpha1.MySQL{Database: "demo"},
+			Docker:       &v1alpha1.Docker{Enabled: ptr.To(false)},
+			WaitForReady: ptr.To(false),
+		},
+		TemplateRef: &v1alpha1.TemplateReference{Name: "java"},
+	}
+
+	merged, err := mergeTemplate(template, spec)
+	assert.NoError(t, err)
+	assert.Equal(t, "java:17", merged.Image)
+	assert.Equal(t, "2", merged.CPU)
+	assert.Equal(t, "8Gi", merged.Memory)
+	assert.Equal(t, map[string]string{"JAVA_HOME": "/opt/java", "LANG": "zh_CN"}, merged.Environment)
+	assert.Equal(t, []v1alpha1.Window{{From: "10:00", To: "12:00"}}, merged.Windows)
+	assert.Equal(t, &v1alpha1.MySQL{Enabled: ptr.To(true), Database: "demo"}, merged.Services.MySQL)
+	assert.True(t, merged.Services.RabbitMQ.IsEnabled())
+	// the DevSpace turns off what the template turns on
+	assert.False(t, merged.Services.Docker.IsEnabled())
+	assert.Equal(t, ptr.To(false), merged.Services.Docker.Enabled)
+	assert.False(t, merged.Services.ShouldWaitForReady())
+	assert.False(t, merged.IsReadOnlyRootFilesystem())
+	assert.Equal(t, "java", merged.TemplateRef.Name)
+}
+
+func TestShouldUpdateTemplate(t *testing.T) {
+	applied := &v1alpha1.AppliedTemplate{Generation: 1}
+	latest := &v1alpha1.AppliedTemplate{Generation: 2}
+
+	tests := []struct {
+		name    string
+		applied *v1alpha1.AppliedTemplate
+		latest  *v1alpha1.AppliedTemplate
+		policy  v1alpha1.TemplateUpdatePolicy
+		phase   v1alpha1.DevSpacePhase
+		expect  bool
+	}{{
+		name:    "never applied",
+		applied: nil,
+		latest:  latest,
+		policy:  v1alpha1.TemplateUpdatePolicyNever,
+		expect:  true,
+	}, {
+		name:    "not changed",
+		applied: latest,
+		latest:  latest,
+		expect:  false,
+	}, {
+		name:    "auto",
+		applied: applied,
+		latest:  latest,
+		policy:  v1alpha1.TemplateUpdatePolicyAuto,
+		phase:   v1alpha1.DevSpacePhaseRunning,
+		expect:  true,
+	}, {
+		name:    "default policy",
+		applied: applied,
+		latest:  latest,
+		phase:   v1alpha1.DevSpacePhaseRunning,
+		expect:  true,
+	}, {
+		name:    "never",
+		applied: applied,
+		latest:  latest,
+		policy:  v1alpha1.TemplateUpdatePolicyNever,
+		phase:   v1alpha1.DevSpacePhaseStopped,
+		expect:  false,
+	}, {
+		name:    "on restart, running",
+		applied: applied,
+		latest:  latest,
+		policy:  v1alpha1.TemplateUpdatePolicyOnRestart,
+		phase:   v1alpha1.DevSpacePhaseRunning,
+		expect:  false,
+	}, {
+		name:    "on restart, stopped",
+		applied: applied,
+		latest:  latest,
+		policy:  v1alpha1.TemplateUpdatePolicyOnRestart,
+		phase:   v1alpha1.DevSpacePhaseStopped,
+		expect:  true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expect, shouldUpdateTemplate(tt.applied, tt.latest, tt.policy, tt.phase))
+		})
+	}
+}
+
+func TestApplyTemplate(t *testing.T) {
+	schema, err := v1alpha1.SchemeBuilder.Register().Build()
+	assert.NoError(t, err)
+	assert.NoError(t, v1.SchemeBuilder.AddToScheme(schema))
+
+	template := &v1alpha1.DevSpaceTemplate{
+		ObjectMeta: metav1.ObjectMeta{Name: "java", Namespace: "default", Generation: 2},
+		Spec: v1alpha1.DevSpaceTemplateSpec{
+			UpdatePolicy: v1alpha1.TemplateUpdatePolicyOnRestart,
+			Template:     v1alpha1.DevSpaceSpec{Image: "java:21", CPU: "4"},
+		},
+	}
+	clusterTemplate := &v1alpha1.ClusterDevSpaceTemplate{
+		ObjectMeta: metav1.ObjectMeta{Name: "golang", Generation: 1},
+		Spec: v1alpha1.DevSpaceTemplateSpec{
+			Template: v1alpha1.DevSpaceSpec{Image: "golang:1.22"},
+		},
+	}
+	withPasswords := &v1alpha1.DevSpaceTemplate{
+		ObjectMeta: metav1.ObjectMeta{Name: "mysql", Namespace: "default", Generation: 1},
+		Spec: v1alpha1.DevSpaceTemplateSpec{
+			Template: v1alpha1.DevSpaceSpec{
+				Auth: v1alpha1.DevSpaceAuth{BasicAuth: &v1alpha1.BasicAuth{Username: "admin", Password: "admin"}},
+				Services: v1alpha1.Services{
+					MySQL: &v1alpha1.MySQL{Enabled: ptr.To(true), Password: "mysql"},
+				},
+				Repository: &v1alpha1.GitRepository{URL: "https://github.com/linuxsuren/kde", Password: "token"},
+			},
+		},
+	}
+	recorded := func(namespace, name, spec string) *v1.ConfigMap {
+		return &v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: v1alpha1.AppliedTemplateConfigMapName(name)},
+			Data:       map[string]string{v1alpha1.AppliedTemplateKey: spec},
+		}
+	}
+	r := &DevSpaceReconciler{
+		Client: fake.NewClientBuilder().WithScheme(schema).WithObjects(template, clusterTemplate, withPasswords,
+			recorded("default", "demo", `{"image": "java:17"}`),
+			recorded("default", "removed", `{"image": "java:17"}`),
+			recorded("another", "demo", `{"image": "java:17"}`)).Build(),
+		Recorder: record.NewFakeRecorder(10),
+	}
+	getRecorded := func(t *testing.T, namespace, name string) *v1alpha1.DevSpaceSpec {
+		cm := &v1.ConfigMap{}
+		if !assert.NoError(t, r.Get(context.Background(), types.NamespacedName{
+			Namespace: namespace, Name: v1alpha1.AppliedTemplateConfigMapName(name)}, cm)) {
+			return &v1alpha1.DevSpaceSpec{}
+		}
+		spec, err := AppliedTemplateSpecOf(cm)
+		assert.NoError(t, err)
+		return spec
+	}
+
+	t.Run("no template", func(t *testing.T) {
+		devSpace := createDefaultGitPod()
+		devSpace.Name = "removed"
+		devSpace.Status.Template = &v1alpha1.AppliedTemplate{Name: "java"}
+		assert.NoError(t, r.applyTemplate(context.Background(), devSpace))
+		assert.Nil(t, devSpace.Status.Template)
+		assert.Equal(t, "linuxsuren.github.io/image:tag", devSpace.Spec.Image)
+		// the recorded spec is removed along with the reference
+		err := r.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: "removed-template"}, &v1.ConfigMap{})
+		assert.True(t, apierrors.IsNotFound(err))
+	})
+
+	t.Run("apply for the first time", func(t *testing.T) {
+		devSpace := &v1alpha1.DevSpace{
+			ObjectMeta: metav1.ObjectMeta{Name: "first", Namespace: "default"},
+			Spec: v1alpha1.DevSpaceSpec{
+				CPU:         "2",
+				TemplateRef: &v1alpha1.TemplateReference{Name: "java"},
+			},
+			Status: v1alpha1.DevSpaceStatus{Phase: v1alpha1.DevSpacePhaseRunning},
+		}
+		assert.NoError(t, r.applyTemplate(context.Background(), devSpace))
+		assert.Equal(t, "java:21", devSpace.Spec.Image)
+		assert.Equal(t, "2", devSpace.Spec.CPU)
+		assert.Equal(t, &v1alpha1.AppliedTemplate{Kind: v1alpha1.KindDevSpaceTemplate, Name: "java", Generation: 2},
+			devSpace.Status.Template)
+		assert.Equal(t, &v1alpha1.DevSpaceSpec{Image: "java:21", CPU: "4"}, getRecorded(t, "default", "first"))
+	})
+
+	t.Run("keep the applied one until restart", func(t *testing.T) {
+		devSpace := &v1alpha1.DevSpace{
+			ObjectMeta: metav1.ObjectMeta{Name: "demo", Namespace: "default"},
+			Spec: v1alpha1.DevSpaceSpec{
+				TemplateRef: &v1alpha1.TemplateReference{Name: "java"},
+			},
+			Status: v1alpha1.DevSpaceStatus{
+				Phase: v1alpha1.DevSpacePhaseRunning,
+				Template: &v1alpha1.AppliedTemplate{
+					Kind:       v1alpha1.KindDevSpaceTemplate,
+					Name:       "java",
+					Generation: 1,
+				},
+			},
+		}
+		assert.NoError(t, r.applyTemplate(context.Background(), devSpace))
+		assert.Equal(t, "java:17", devSpace.Spec.Image)
+		assert.Equal(t, int64(1), devSpace.Status.Template.Generation)
+
+		// the spec is read from the cluster again in each reconciling
+		devSpace.Spec = v1alpha1.DevSpaceSpec{TemplateRef: devSpace.Spec.TemplateRef}
+		devSpace.Status.Phase = v1alpha1.DevSpacePhaseStopped
+		assert.NoError(t, r.applyTemplate(context.Background(), devSpace))
+		assert.Equal(t, "java:21", devSpace.Spec.Image)
+		assert.Equal(t, int64(2), devSpace.Status.Template.Generation)
+		assert.Equal(t, "java:21", getRecorded(t, "default", "demo").Image)
+	})
+
+	t.Run("the applied spec is not recorded", func(t *testing.T) {
+		// the DevSpace was reconciled before having the ConfigMap
+		devSpace := &v1alpha1.DevSpace{
+			ObjectMeta: metav1.ObjectMeta{Name: "upgraded", Namespace: "default"},
+			Spec: v1alpha1.DevSpaceSpec{
+				TemplateRef: &v1alpha1.TemplateReference{Name: "java"},
+			},
+			Status: v1alpha1.DevSpaceStatus{
+				Phase:    v1alpha1.DevSpacePhaseRunning,
+				Template: &v1alpha1.AppliedTemplate{Kind: v1alpha1.KindDevSpaceTemplate, Name: "java", Generation: 1},
+			},
+		}
+		assert.NoError(t, r.applyTemplate(context.Background(), devSpace))
+		assert.Equal(t, "java:21", devSpace.Spec.Image)
+		assert.Equal(t, int64(2), devSpace.Status.Template.Generation)
+	})
+
+	t.Run("the passwords are not recorded", func(t *testing.T) {
+		devSpace := &v1alpha1.DevSpace{
+			ObjectMeta: metav1.ObjectMeta{Name: "passwords", Namespace: "default"},
+			Spec: v1alpha1.DevSpaceSpec{
+				TemplateRef: &v1alpha1.TemplateReference{Name: "mysql"},
+			},
+		}
+		for i := 0; i < 2; i++ {
+			// the recorded one is there in the second round
+			devSpace.Spec = v1alpha1.DevSpaceSpec{TemplateRef: devSpace.Spec.TemplateRef}
+			assert.NoError(t, r.applyTemplate(context.Background(), devSpace))
+			assert.Equal(t, "mysql", devSpace.Spec.Services.MySQL.Password)
+			assert.Equal(t, "token", devSpace.Spec.Repository.Password)
+			assert.Equal(t, "admin", devSpace.Spec.Auth.BasicAuth.Password)
+
+			recorded := getRecorded(t, "default", "passwords")
+			assert.Empty(t, recorded.Services.MySQL.Password)
+			assert.Empty(t, recorded.Repository.Password)
+			assert.Empty(t, recorded.Auth.BasicAuth.Password)
+			assert.Equal(t, "admin", recorded.Auth.BasicAuth.Username)
+		}
+	})
+
+	t.Run("cluster template", func(t *testing.T) {
+		devSpace := &v1alpha1.DevSpace{
+			ObjectMeta: metav1.ObjectMeta{Name: "golang", Namespace: "another"},
+			Spec: v1alpha1.DevSpaceSpec{
+				TemplateRef: &v1alpha1.TemplateReference{Kind: v1alpha1.KindClusterDevSpaceTemplate, Name: "golang"},
+			},
+		}
+		assert.NoError(t, r.applyTemplate(context.Background(), devSpace))
+		assert.Equal(t, "golang:1.22", devSpace.Spec.Image)
+	})
+
+	t.Run("template not found", func(t *testing.T) {
+		devSpace := &v1alpha1.DevSpace{
+			ObjectMeta: metav1.ObjectMeta{Name: "demo", Namespace: "another"},
+			Spec: v1alpha1.DevSpaceSpec{
+				TemplateRef: &v1alpha1.TemplateReference{Name: "java"},
+			},
+		}
+		assert.Error(t, r.applyTemplate(context.Background(), devSpace))
+	})
+
+	t.Run("the applied template is deleted", func(t *testing.T) {
+		devSpace := &v1alpha1.DevSpace{
+			ObjectMeta: metav1.ObjectMeta{Name: "demo", Namespace: "another"},
+			Spec: v1alpha1.DevSpaceSpec{
+				TemplateRef: &v1alpha1.TemplateReference{Name: "java"},
+			},
+			Status: v1alpha1.DevSpaceStatus{
+				Template: &v1alpha1.AppliedTemplate{Kind: v1alpha1.KindDevSpaceTemplate, Name: "java"},
+			},
+		}
+		assert.NoError(t, r.applyTemplate(context.Background(), devSpace))
+		assert.Equal(t, "java:17", devSpace.Spec.Image)
+
+		// nothing to apply without the recorded spec
+		devSpace.Name = "missing"
+		assert.Error(t, r.applyTemplate(context.Background(), devSpace))
+	})
+}
+
+func TestFindDevSpacesForTemplate(t *testing.T) {
+	schema, err := v1alpha1.SchemeBuilder.Register().Build()
+	assert.NoError(t, err)
+
+	withTemplate := func(name, namespace string, ref *v1alpha1.TemplateReference) *v1alpha1.DevSpace {
+		return &v1alpha1.DevSpace{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+			Spec:       v1alpha1.DevSpaceSpec{TemplateRef: ref},
+		}
+	}
+	r := &DevSpaceReconciler{
+		Client: fake.NewClientBuilder().WithScheme(schema).WithObjects(
+			withTemplate("a", "default", &v1alpha1.TemplateReference{Name: "java"}),
+			withTemplate("b", "another", &v1alpha1.TemplateReference{Name: "java"}),
+			withTemplate("c", "another", &v1alpha1.TemplateReference{Kind: v1alpha1.KindClusterDevSpaceTemplate, Name: "java"}),
+			withTemplate("d", "default", nil),
+		).Build(),
+	}
+
+	requests := r.findDevSpacesForTemplate(context.Background(), &v1alpha1.DevSpaceTemplate{
+		ObjectMeta: metav1.ObjectMeta{Name: "java", Namespace: "default"},
+	})
+	if assert.Len(t, requests, 1) {
+		assert.Equal(t, "a", requests[0].Name)
+	}
+
+	requests = r.findDevSpacesForTemplate(context.Background(), &v1alpha1.ClusterDevSpaceTemplate{
+		ObjectMeta: metav1.ObjectMeta{Name: "java"},
+	})
+	if assert.Len(t, requests, 1) {
+		assert.Equal(t, "c", requests[0].Name)
+	}
+}
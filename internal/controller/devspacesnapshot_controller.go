@@ -0,0 +1,157 @@
+/*
+Copyright 2024 kde authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"time"
+
+	"github.com/linuxsuren/kde/api/linuxsuren.github.io/v1alpha1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// snapshotPollInterval is the interval of checking the VolumeSnapshot before it is ready to use
+const snapshotPollInterval = 10 * time.Second
+
+// DevSpaceSnapshotReconciler reconciles a DevSpaceSnapshot object
+type DevSpaceSnapshotReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+}
+
+// +kubebuilder:rbac:groups=linuxsuren.github.io,resources=devspacesnapshots,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=linuxsuren.github.io,resources=devspacesnapshots/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=linuxsuren.github.io,resources=devspacesnapshots/finalizers,verbs=update
+// +kubebuilder:rbac:groups=snapshot.storage.k8s.io,resources=volumesnapshots,verbs=get;list;watch;create;delete
+
+// Reconcile takes a CSI VolumeSnapshot of the DevSpace storage, then keeps the status in sync with it.
+// The VolumeSnapshot is deleted along with the DevSpaceSnapshot via the owner reference.
+func (r *DevSpaceSnapshotReconciler) Reconcile(ctx context.Context, req ctrl.Request) (result ctrl.Result, err error) {
+	logger := log.FromContext(ctx)
+
+	snapshot := &v1alpha1.DevSpaceSnapshot{}
+	if err = r.Get(ctx, req.NamespacedName, snapshot); err != nil || snapshot.DeletionTimestamp != nil {
+		err = client.IgnoreNotFound(err)
+		return
+	}
+
+	status := snapshot.Status.DeepCopy()
+	volumeSnapshot := newVolumeSnapshot()
+	if err = r.Get(ctx, req.NamespacedName, volumeSnapshot); err == nil {
+		syncVolumeSnapshotStatus(status, volumeSnapshot)
+	} else if apierrors.IsNotFound(err) {
+		err = r.takeSnapshot(ctx, snapshot, status)
+	}
+
+	if err != nil {
+		logger.Error(err, "failed to take the snapshot")
+		status.Error = err.Error()
+		r.Recorder.Event(snapshot, v1.EventTypeWarning, "Snapshot", err.Error())
+		// the error is kept in the status, keep polling instead of backing off
+		err = nil
+	}
+	if !status.ReadyToUse {
+		result.RequeueAfter = snapshotPollInterval
+	}
+
+	if !equality.Semantic.DeepEqual(&snapshot.Status, status) {
+		snapshot.Status = *status
+		err = r.Status().Update(ctx, snapshot)
+	}
+	return
+}
+
+// takeSnapshot creates the VolumeSnapshot of the DevSpace storage
+func (r *DevSpaceSnapshotReconciler) takeSnapshot(ctx context.Context, snapshot *v1alpha1.DevSpaceSnapshot, status *v1alpha1.DevSpaceSnapshotStatus) (err error) {
+	if status.VolumeSnapshotName != "" {
+		err = fmt.Errorf("the VolumeSnapshot %q is gone", status.VolumeSnapshotName)
+		return
+	}
+
+	devSpace := &v1alpha1.DevSpace{}
+	if err = r.Get(ctx, types.NamespacedName{Namespace: snapshot.Namespace, Name: snapshot.Spec.DevSpace}, devSpace); err != nil {
+		return
+	}
+	if devSpace.Annotations[v1alpha1.AnnoKeyStorageTemporary] != "" {
+		err = fmt.Errorf("the DevSpace %q has no persistent storage", devSpace.Name)
+		return
+	}
+
+	var volumeSnapshot *unstructured.Unstructured
+	if volumeSnapshot, err = turnTemplateToUnstructured(devSpaceVolumeSnapshot, snapshot); err != nil {
+		return
+	}
+	if err = r.Create(ctx, volumeSnapshot); err == nil {
+		status.VolumeSnapshotName = volumeSnapshot.GetName()
+		status.SourceGeneration = devSpace.Generation
+		status.Error = ""
+		r.Recorder.Eventf(snapshot, v1.EventTypeNormal, "Snapshot", "created VolumeSnapshot of DevSpace %q", devSpace.Name)
+	}
+	return
+}
+
+// syncVolumeSnapshotStatus copies the status of the VolumeSnapshot
+func syncVolumeSnapshotStatus(status *v1alpha1.DevSpaceSnapshotStatus, volumeSnapshot *unstructured.Unstructured) {
+	status.VolumeSnapshotName = volumeSnapshot.GetName()
+	status.ReadyToUse, _, _ = unstructured.NestedBool(volumeSnapshot.Object, "status", "readyToUse")
+	status.Error, _, _ = unstructured.NestedString(volumeSnapshot.Object, "status", "error", "message")
+
+	status.RestoreSize = nil
+	if size, ok, _ := unstructured.NestedString(volumeSnapshot.Object, "status", "restoreSize"); ok {
+		if quantity, err := resource.ParseQuantity(size); err == nil {
+			status.RestoreSize = &quantity
+		}
+	}
+
+	status.CreationTime = nil
+	if creationTime, ok, _ := unstructured.NestedString(volumeSnapshot.Object, "status", "creationTime"); ok {
+		if t, err := time.Parse(time.RFC3339, creationTime); err == nil {
+			status.CreationTime = &metav1.Time{Time: t}
+		}
+	}
+}
+
+func newVolumeSnapshot() *unstructured.Unstructured {
+	volumeSnapshot := &unstructured.Unstructured{}
+	volumeSnapshot.SetAPIVersion("snapshot.storage.k8s.io/v1")
+	volumeSnapshot.SetKind("VolumeSnapshot")
+	return volumeSnapshot
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *DevSpaceSnapshotReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&v1alpha1.DevSpaceSnapshot{}).
+		Complete(r)
+}
+
+//go:embed data/volumesnapshot.yaml
+var devSpaceVolumeSnapshot string
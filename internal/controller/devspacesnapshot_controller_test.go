@@ -0,0 +1,162 @@
+/*
+Copyright 2024 kde authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/linuxsuren/kde/api/linuxsuren.github.io/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestDevSpaceSnapshotReconciler(t *testing.T) {
+	schema, err := v1alpha1.SchemeBuilder.Register().Build()
+	assert.NoError(t, err)
+	assert.NoError(t, v1.SchemeBuilder.AddToScheme(schema))
+
+	snapshot := &v1alpha1.DevSpaceSnapshot{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "demo-snapshot",
+			Namespace: "default",
+			UID:       "uid",
+		},
+		Spec: v1alpha1.DevSpaceSnapshotSpec{
+			DevSpace:                "demo",
+			VolumeSnapshotClassName: "csi-snapclass",
+		},
+	}
+	devSpace := createDefaultGitPod()
+	devSpace.Generation = 3
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "demo-snapshot"}}
+
+	readyVolumeSnapshot := newVolumeSnapshot()
+	readyVolumeSnapshot.SetName("demo-snapshot")
+	readyVolumeSnapshot.SetNamespace("default")
+	readyVolumeSnapshot.Object["status"] = map[string]interface{}{
+		"readyToUse":   true,
+		"restoreSize":  "50Gi",
+		"creationTime": "2024-07-17T10:30:00Z",
+	}
+
+	temporaryDevSpace := devSpace.DeepCopy()
+	temporaryDevSpace.Annotations[v1alpha1.AnnoKeyStorageTemporary] = "true"
+
+	tests := []struct {
+		name    string
+		objects []client.Object
+		verify  func(*testing.T, ctrl.Result, client.Client)
+	}{{
+		name:    "snapshot not found",
+		objects: []client.Object{devSpace.DeepCopy()},
+		verify: func(t *testing.T, result ctrl.Result, c client.Client) {
+			assert.Zero(t, result.RequeueAfter)
+		},
+	}, {
+		name:    "take a snapshot",
+		objects: []client.Object{snapshot.DeepCopy(), devSpace.DeepCopy()},
+		verify: func(t *testing.T, result ctrl.Result, c client.Client) {
+			assert.Equal(t, snapshotPollInterval, result.RequeueAfter)
+
+			volumeSnapshot := newVolumeSnapshot()
+			assert.NoError(t, c.Get(context.TODO(), req.NamespacedName, volumeSnapshot))
+			pvcName, _, _ := unstructured.NestedString(volumeSnapshot.Object, "spec", "source", "persistentVolumeClaimName")
+			assert.Equal(t, "demo", pvcName)
+			className, _, _ := unstructured.NestedString(volumeSnapshot.Object, "spec", "volumeSnapshotClassName")
+			assert.Equal(t, "csi-snapclass", className)
+			assert.Equal(t, "DevSpaceSnapshot", volumeSnapshot.GetOwnerReferences()[0].Kind)
+
+			snapshot := &v1alpha1.DevSpaceSnapshot{}
+			assert.NoError(t, c.Get(context.TODO(), req.NamespacedName, snapshot))
+			assert.Equal(t, "demo-snapshot", snapshot.Status.VolumeSnapshotName)
+			assert.Equal(t, int64(3), snapshot.Status.SourceGeneration)
+			assert.False(t, snapshot.Status.ReadyToUse)
+		},
+	}, {
+		name:    "the VolumeSnapshot is ready",
+		objects: []client.Object{snapshot.DeepCopy(), devSpace.DeepCopy(), readyVolumeSnapshot.DeepCopy()},
+		verify: func(t *testing.T, result ctrl.Result, c client.Client) {
+			assert.Zero(t, result.RequeueAfter)
+
+			snapshot := &v1alpha1.DevSpaceSnapshot{}
+			assert.NoError(t, c.Get(context.TODO(), req.NamespacedName, snapshot))
+			assert.True(t, snapshot.Status.ReadyToUse)
+			assert.Equal(t, resource.MustParse("50Gi"), *snapshot.Status.RestoreSize)
+			assert.NotNil(t, snapshot.Status.CreationTime)
+		},
+	}, {
+		name:    "DevSpace not found",
+		objects: []client.Object{snapshot.DeepCopy()},
+		verify: func(t *testing.T, result ctrl.Result, c client.Client) {
+			snapshot := &v1alpha1.DevSpaceSnapshot{}
+			assert.NoError(t, c.Get(context.TODO(), req.NamespacedName, snapshot))
+			assert.Contains(t, snapshot.Status.Error, "not found")
+			assert.Empty(t, snapshot.Status.VolumeSnapshotName)
+		},
+	}, {
+		name:    "DevSpace without persistent storage",
+		objects: []client.Object{snapshot.DeepCopy(), temporaryDevSpace},
+		verify: func(t *testing.T, result ctrl.Result, c client.Client) {
+			snapshot := &v1alpha1.DevSpaceSnapshot{}
+			assert.NoError(t, c.Get(context.TODO(), req.NamespacedName, snapshot))
+			assert.Contains(t, snapshot.Status.Error, "no persistent storage")
+		},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := fake.NewClientBuilder().WithScheme(schema).WithObjects(tt.objects...).
+				WithStatusSubresource(&v1alpha1.DevSpaceSnapshot{}).Build()
+			r := &DevSpaceSnapshotReconciler{
+				Client:   c,
+				Recorder: record.NewFakeRecorder(10),
+			}
+			r.SetupWithManager(&FakeManager{Client: c, Scheme: schema})
+
+			result, err := r.Reconcile(context.Background(), req)
+			assert.NoError(t, err)
+			tt.verify(t, result, c)
+		})
+	}
+}
+
+func TestSyncVolumeSnapshotStatus(t *testing.T) {
+	volumeSnapshot := newVolumeSnapshot()
+	volumeSnapshot.SetName("demo")
+	volumeSnapshot.Object["status"] = map[string]interface{}{
+		"readyToUse": false,
+		"error": map[string]interface{}{
+			"message": "failed to take snapshot",
+		},
+	}
+
+	status := &v1alpha1.DevSpaceSnapshotStatus{RestoreSize: &resource.Quantity{}}
+	syncVolumeSnapshotStatus(status, volumeSnapshot)
+	assert.Equal(t, "demo", status.VolumeSnapshotName)
+	assert.False(t, status.ReadyToUse)
+	assert.Equal(t, "failed to take snapshot", status.Error)
+	assert.Nil(t, status.RestoreSize)
+	assert.Nil(t, status.CreationTime)
+}
@@ -0,0 +1,54 @@
+/*
+Copyright 2024 kde authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"time"
+
+	"github.com/linuxsuren/kde/api/linuxsuren.github.io/v1alpha1"
+	"github.com/linuxsuren/kde/pkg/core"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// getIdleTimeout returns the idle timeout of the DevSpace,
+// the value from the spec takes precedence over the global config.
+func getIdleTimeout(devSpace *v1alpha1.DevSpace, config *core.Config) time.Duration {
+	if devSpace.Spec.IdleTimeout != nil {
+		return devSpace.Spec.IdleTimeout.Duration
+	}
+	if config != nil {
+		return config.GetIdleTimeout()
+	}
+	return 0
+}
+
+// isIdle checks if there is no activity within the timeout.
+// The last activity time is reset if it was never recorded, or the DevSpace
+// was just woken up from a suspension which was not caused by being idle.
+func isIdle(devSpace *v1alpha1.DevSpace, timeout time.Duration, now time.Time) bool {
+	if timeout <= 0 {
+		return false
+	}
+
+	status := &devSpace.Status
+	if status.LastActivityTime == nil ||
+		(status.SuspendReason != "" && status.SuspendReason != v1alpha1.DevSpaceSuspendReasonIdle) {
+		status.LastActivityTime = &metav1.Time{Time: now}
+		return false
+	}
+	return now.Sub(status.LastActivityTime.Time) > timeout
+}
@@ -0,0 +1,76 @@
+/*
+Copyright 2024 kde authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/linuxsuren/kde/api/linuxsuren.github.io/v1alpha1"
+	"github.com/linuxsuren/kde/pkg/core"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGetIdleTimeout(t *testing.T) {
+	devSpace := createDefaultGitPod()
+	assert.Equal(t, time.Duration(0), getIdleTimeout(devSpace, nil))
+	assert.Equal(t, time.Hour, getIdleTimeout(devSpace, &core.Config{IdleTimeout: "1h"}))
+
+	devSpace.Spec.IdleTimeout = &metav1.Duration{}
+	assert.Equal(t, time.Duration(0), getIdleTimeout(devSpace, &core.Config{IdleTimeout: "1h"}))
+
+	devSpace.Spec.IdleTimeout = &metav1.Duration{Duration: time.Minute}
+	assert.Equal(t, time.Minute, getIdleTimeout(devSpace, &core.Config{IdleTimeout: "1h"}))
+}
+
+func TestIsIdle(t *testing.T) {
+	now := time.Now()
+
+	t.Run("disabled", func(t *testing.T) {
+		devSpace := createDefaultGitPod()
+		assert.False(t, isIdle(devSpace, 0, now))
+		assert.Nil(t, devSpace.Status.LastActivityTime)
+	})
+
+	t.Run("never recorded", func(t *testing.T) {
+		devSpace := createDefaultGitPod()
+		assert.False(t, isIdle(devSpace, time.Minute, now))
+		assert.Equal(t, now, devSpace.Status.LastActivityTime.Time)
+	})
+
+	t.Run("active", func(t *testing.T) {
+		devSpace := createDefaultGitPod()
+		devSpace.Status.LastActivityTime = &metav1.Time{Time: now.Add(-time.Second)}
+		assert.False(t, isIdle(devSpace, time.Minute, now))
+	})
+
+	t.Run("idle", func(t *testing.T) {
+		devSpace := createDefaultGitPod()
+		devSpace.Status.LastActivityTime = &metav1.Time{Time: now.Add(-time.Hour)}
+		devSpace.Status.SuspendReason = v1alpha1.DevSpaceSuspendReasonIdle
+		assert.True(t, isIdle(devSpace, time.Minute, now))
+	})
+
+	t.Run("woken up from the window", func(t *testing.T) {
+		devSpace := createDefaultGitPod()
+		devSpace.Status.LastActivityTime = &metav1.Time{Time: now.Add(-time.Hour)}
+		devSpace.Status.SuspendReason = v1alpha1.DevSpaceSuspendReasonOutOfWindow
+		assert.False(t, isIdle(devSpace, time.Minute, now))
+		assert.Equal(t, now, devSpace.Status.LastActivityTime.Time)
+	})
+}
@@ -0,0 +1,149 @@
+/*
+Copyright 2024 kde authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	_ "embed"
+
+	"github.com/linuxsuren/kde/api/linuxsuren.github.io/v1alpha1"
+	"github.com/linuxsuren/kde/pkg/core"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// Names of the ingress providers
+const (
+	IngressProviderNginx   = "nginx"
+	IngressProviderTraefik = "traefik"
+	IngressProviderGateway = "gateway"
+)
+
+// IngressProvider routes the requests to the DevSpaces with its own kind of resources,
+// the basic auth is translated into the native mechanism of it
+type IngressProvider struct {
+	// Name is the name in the config
+	Name string
+	// ingress and expose are the built-in templates of the ingress.yaml and ingress-expose.yaml,
+	// a template may render a v1/List if more than one resource is needed
+	ingress, expose string
+	// namespaces are where the controllers of the provider usually are
+	namespaces []string
+}
+
+// IngressProviders are the supported ingress providers
+var IngressProviders = []IngressProvider{{
+	// the basic auth, the forward auth and the activity mirror are the annotations of ingress-nginx
+	Name: IngressProviderNginx, ingress: gitpodIngress, expose: gitpodExposeIngress,
+	namespaces: []string{"ingress-nginx", "kube-system"},
+}, {
+	// the basic auth, the forward auth and the wake-up page are the Middlewares of Traefik
+	Name: IngressProviderTraefik, ingress: traefikIngress, expose: traefikExposeIngress,
+	// Traefik of k3s is in kube-system
+	namespaces: []string{"traefik", "kube-system"},
+}, {
+	// the basic auth is a SecurityPolicy of Envoy Gateway, since the Gateway API has no such filter
+	Name: IngressProviderGateway, ingress: gatewayHTTPRoute, expose: gatewayExposeHTTPRoute,
+	// the proxies might be in the namespace of the Gateway as well, see ingressNamespacesOf
+	namespaces: []string{"envoy-gateway-system"},
+}}
+
+// IngressProviderNames returns the names of the supported ingress providers
+func IngressProviderNames() (names []string) {
+	for _, provider := range IngressProviders {
+		names = append(names, provider.Name)
+	}
+	return
+}
+
+// ingressProviderOf returns the ingress provider of the DevSpace, it is nginx if the provider is unknown
+func ingressProviderOf(devSpace *v1alpha1.DevSpace) IngressProvider {
+	name := devSpace.Annotations[v1alpha1.AnnoKeyIngressProvider]
+	for _, provider := range IngressProviders {
+		if provider.Name == name {
+			return provider
+		}
+	}
+	return IngressProviders[0]
+}
+
+// template returns the built-in template of the given ingress template name
+func (p IngressProvider) template(name string) string {
+	switch name {
+	case TemplateIngress:
+		return p.ingress
+	case TemplateExposeIngress:
+		return p.expose
+	}
+	return ""
+}
+
+// prepareIngress tells the templates which ingress provider routes the requests to the DevSpace,
+// and where the forward auth signs the users in
+func prepareIngress(devSpace *v1alpha1.DevSpace, config *core.Config) {
+	if config == nil {
+		config = &core.Config{}
+	}
+	provider := config.IngressProvider
+	if provider == "" {
+		provider = IngressProviderNginx
+	}
+	devSpace.Annotations[v1alpha1.AnnoKeyIngressProvider] = provider
+
+	if provider == IngressProviderGateway {
+		namespace := config.Gateway.Namespace
+		if namespace == "" {
+			namespace = devSpace.Annotations[v1alpha1.AnnoKeyServiceNamespace]
+		}
+		devSpace.Annotations[v1alpha1.AnnoKeyGatewayName] = config.Gateway.Name
+		devSpace.Annotations[v1alpha1.AnnoKeyGatewayNamespace] = namespace
+	} else {
+		delete(devSpace.Annotations, v1alpha1.AnnoKeyGatewayName)
+		delete(devSpace.Annotations, v1alpha1.AnnoKeyGatewayNamespace)
+	}
+
+	if config.ForwardAuth && config.Host != "" && provider != IngressProviderGateway {
+		devSpace.Annotations[v1alpha1.AnnoKeyAuthSignIn] = linkOf(config.Host, config) + "/auth/devspace/signin"
+	} else {
+		delete(devSpace.Annotations, v1alpha1.AnnoKeyAuthSignIn)
+	}
+}
+
+// flattenList returns the items of a v1/List, or the object itself
+func flattenList(obj *unstructured.Unstructured) (objs []*unstructured.Unstructured) {
+	if obj == nil || !obj.IsList() {
+		return []*unstructured.Unstructured{obj}
+	}
+	_ = obj.EachListItem(func(item runtime.Object) error {
+		if itemObj, ok := item.(*unstructured.Unstructured); ok {
+			objs = append(objs, itemObj)
+		}
+		return nil
+	})
+	return
+}
+
+//go:embed data/traefik/ingress.yaml
+var traefikIngress string
+
+//go:embed data/traefik/ingress-expose.yaml
+var traefikExposeIngress string
+
+//go:embed data/gateway/ingress.yaml
+var gatewayHTTPRoute string
+
+//go:embed data/gateway/ingress-expose.yaml
+var gatewayExposeHTTPRoute string
@@ -0,0 +1,299 @@
+/*
+Copyright 2024 kde authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	"github.com/linuxsuren/kde/api/linuxsuren.github.io/v1alpha1"
+	"github.com/linuxsuren/kde/pkg/core"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestPrepareIngress(t *testing.T) {
+	tests := []struct {
+		name             string
+		ingressMode      string
+		config           *core.Config
+		expect           string
+		gatewayName      string
+		gatewayNamespace string
+	}{{
+		name:   "nginx by default",
+		expect: IngressProviderNginx,
+	}, {
+		name:        "not chosen by the ingress mode",
+		ingressMode: "traefik",
+		config:      &core.Config{},
+		expect:      IngressProviderNginx,
+	}, {
+		name:        "given by the config",
+		ingressMode: "traefik",
+		config:      &core.Config{IngressProvider: IngressProviderNginx},
+		expect:      IngressProviderNginx,
+	}, {
+		name: "gateway in the system namespace",
+		config: &core.Config{
+			IngressProvider: IngressProviderGateway,
+			Gateway:         core.GatewayReference{Name: "eg"},
+		},
+		expect:           IngressProviderGateway,
+		gatewayName:      "eg",
+		gatewayNamespace: "kde-system",
+	}, {
+		name: "gateway in another namespace",
+		config: &core.Config{
+			IngressProvider: IngressProviderGateway,
+			Gateway:         core.GatewayReference{Name: "eg", Namespace: "envoy-gateway-system"},
+		},
+		expect:           IngressProviderGateway,
+		gatewayName:      "eg",
+		gatewayNamespace: "envoy-gateway-system",
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			devSpace := createDefaultGitPod()
+			devSpace.Annotations[v1alpha1.AnnoKeyIngressMode] = tt.ingressMode
+			devSpace.Annotations[v1alpha1.AnnoKeyGatewayName] = "stale"
+			setServiceAnnotations(devSpace, "kde-system")
+			prepareIngress(devSpace, tt.config)
+			assert.Equal(t, tt.expect, ingressProviderOf(devSpace).Name)
+			assert.Equal(t, tt.gatewayName, devSpace.Annotations[v1alpha1.AnnoKeyGatewayName])
+			assert.Equal(t, tt.gatewayNamespace, devSpace.Annotations[v1alpha1.AnnoKeyGatewayNamespace])
+		})
+	}
+}
+
+func TestPrepareForwardAuth(t *testing.T) {
+	tests := []struct {
+		name   string
+		config *core.Config
+		expect string
+	}{{
+		name:   "disabled",
+		config: &core.Config{Host: "kde.example.com"},
+	}, {
+		name:   "enabled",
+		config: &core.Config{Host: "kde.example.com", ForwardAuth: true},
+		expect: "http://kde.example.com/auth/devspace/signin",
+	}, {
+		name:   "over TLS",
+		config: &core.Config{Host: "kde.example.com", ForwardAuth: true, TLS: core.TLS{SecretName: "wildcard"}},
+		expect: "https://kde.example.com/auth/devspace/signin",
+	}, {
+		name:   "without the host",
+		config: &core.Config{ForwardAuth: true},
+	}, {
+		name:   "not supported by the gateway",
+		config: &core.Config{Host: "kde.example.com", ForwardAuth: true, IngressProvider: IngressProviderGateway},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			devSpace := createDefaultGitPod()
+			devSpace.Annotations[v1alpha1.AnnoKeyAuthSignIn] = "stale"
+			prepareIngress(devSpace, tt.config)
+			assert.Equal(t, tt.expect, devSpace.Annotations[v1alpha1.AnnoKeyAuthSignIn])
+		})
+	}
+}
+
+func TestIngressProviderRender(t *testing.T) {
+	render := func(t *testing.T, provider string, running, basicAuth bool, name string) []*unstructured.Unstructured {
+		devSpace := createDefaultGitPod()
+		if running {
+			devSpace.Status.Phase = "Running"
+		}
+		if basicAuth {
+			devSpace.Spec.Auth.BasicAuth = &v1alpha1.BasicAuth{Username: "admin", Password: "admin"}
+		}
+		devSpace.Annotations[v1alpha1.AnnoKeyActivityToken] = "activity"
+		setServiceAnnotations(devSpace, "kde-system")
+		prepareIngress(devSpace, &core.Config{IngressProvider: provider, Gateway: core.GatewayReference{Name: "eg"}})
+		obj, err := turnTemplateToUnstructured(builtinTemplate(name, devSpace), devSpace)
+		assert.NoError(t, err)
+		return flattenList(obj)
+	}
+	kindsOf := func(objs []*unstructured.Unstructured) (kinds []string) {
+		for _, obj := range objs {
+			kinds = append(kinds, obj.GetKind()+"/"+obj.GetName())
+		}
+		return
+	}
+
+	t.Run("nginx", func(t *testing.T) {
+		objs := render(t, IngressProviderNginx, true, true, TemplateIngress)
+		if assert.Equal(t, []string{"Ingress/demo"}, kindsOf(objs)) {
+			className, _, _ := unstructured.NestedString(objs[0].Object, "spec", "ingressClassName")
+			assert.Equal(t, "nginx", className)
+		}
+	})
+
+	t.Run("traefik with basic auth", func(t *testing.T) {
+		objs := render(t, IngressProviderTraefik, false, true, TemplateIngress)
+		if !assert.Equal(t, []string{"Middleware/demo-auth", "Middleware/demo-wakeup", "Middleware/demo-wakeup-token", "Ingress/demo"},
+			kindsOf(objs)) {
+			return
+		}
+		secret, _, _ := unstructured.NestedString(objs[0].Object, "spec", "basicAuth", "secret")
+		assert.Equal(t, "demo", secret)
+		path, _, _ := unstructured.NestedString(objs[1].Object, "spec", "replacePath", "path")
+		assert.Equal(t, "/wakeup/default/demo", path)
+		token, _, _ := unstructured.NestedString(objs[2].Object, "spec", "headers", "customRequestHeaders", "X-KDE-Activity-Token")
+		assert.Equal(t, "activity", token)
+
+		ingress := objs[3]
+		className, _, _ := unstructured.NestedString(ingress.Object, "spec", "ingressClassName")
+		assert.Equal(t, "traefik", className)
+		assert.Equal(t, "default-demo-auth@kubernetescrd,default-demo-wakeup@kubernetescrd,default-demo-wakeup-token@kubernetescrd",
+			ingress.GetAnnotations()["traefik.ingress.kubernetes.io/router.middlewares"])
+	})
+
+	t.Run("traefik without basic auth", func(t *testing.T) {
+		objs := render(t, IngressProviderTraefik, true, false, TemplateIngress)
+		if assert.Equal(t, []string{"Ingress/demo"}, kindsOf(objs)) {
+			assert.NotContains(t, objs[0].GetAnnotations(), "traefik.ingress.kubernetes.io/router.middlewares")
+			assert.Equal(t, "none", objs[0].GetAnnotations()["linuxsuren.github.io/auth-type"])
+		}
+	})
+
+	t.Run("gateway with basic auth", func(t *testing.T) {
+		objs := render(t, IngressProviderGateway, true, true, TemplateIngress)
+		if !assert.Equal(t, []string{"HTTPRoute/demo", "SecurityPolicy/demo"}, kindsOf(objs)) {
+			return
+		}
+		route := objs[0]
+		parentRefs, _, _ := unstructured.NestedSlice(route.Object, "spec", "parentRefs")
+		assert.Equal(t, []interface{}{map[string]interface{}{"name": "eg", "namespace": "kde-system"}}, parentRefs)
+		hostnames, _, _ := unstructured.NestedStringSlice(route.Object, "spec", "hostnames")
+		assert.Equal(t, []string{"demo.gitpod.linuxsuren.github.io"}, hostnames)
+		rules, _, _ := unstructured.NestedSlice(route.Object, "spec", "rules")
+		if assert.Len(t, rules, 1) {
+			rule := rules[0].(map[string]interface{})
+			assert.NotContains(t, rule, "filters")
+			assert.Equal(t, []interface{}{map[string]interface{}{"name": "demo", "port": int64(3000)}}, rule["backendRefs"])
+		}
+
+		users, _, _ := unstructured.NestedString(objs[1].Object, "spec", "basicAuth", "users", "name")
+		assert.Equal(t, "demo", users)
+	})
+
+	t.Run("gateway while sleeping", func(t *testing.T) {
+		objs := render(t, IngressProviderGateway, false, false, TemplateIngress)
+		if !assert.Equal(t, []string{"HTTPRoute/demo"}, kindsOf(objs)) {
+			return
+		}
+		rules, _, _ := unstructured.NestedSlice(objs[0].Object, "spec", "rules")
+		if assert.Len(t, rules, 1) {
+			rule := rules[0].(map[string]interface{})
+			assert.Equal(t, []interface{}{map[string]interface{}{"name": "demo-wakeup", "port": int64(8080)}}, rule["backendRefs"])
+			filters := rule["filters"].([]interface{})
+			replaced, _, _ := unstructured.NestedString(filters[0].(map[string]interface{}), "urlRewrite", "path", "replaceFullPath")
+			assert.Equal(t, "/wakeup/default/demo", replaced)
+			headers, _, _ := unstructured.NestedSlice(filters[1].(map[string]interface{}), "requestHeaderModifier", "set")
+			assert.Equal(t, []interface{}{map[string]interface{}{"name": "X-KDE-Activity-Token", "value": "activity"}}, headers)
+		}
+	})
+
+	t.Run("exposed by the gateway", func(t *testing.T) {
+		objs := render(t, IngressProviderGateway, true, false, TemplateExposeIngress)
+		if assert.Equal(t, []string{"HTTPRoute/demo-expose-8080", "HTTPRoute/demo-expose-9090"}, kindsOf(objs)) {
+			hostnames, _, _ := unstructured.NestedStringSlice(objs[1].Object, "spec", "hostnames")
+			assert.Equal(t, []string{"9090.demo.gitpod.linuxsuren.github.io"}, hostnames)
+		}
+	})
+
+	t.Run("exposed by traefik", func(t *testing.T) {
+		objs := render(t, IngressProviderTraefik, true, false, TemplateExposeIngress)
+		if assert.Equal(t, []string{"Ingress/demo-expose"}, kindsOf(objs)) {
+			className, _, _ := unstructured.NestedString(objs[0].Object, "spec", "ingressClassName")
+			assert.Equal(t, "traefik", className)
+		}
+	})
+}
+
+func TestForwardAuthRender(t *testing.T) {
+	render := func(t *testing.T, provider, name string) []*unstructured.Unstructured {
+		devSpace := createDefaultGitPod()
+		devSpace.Status.Phase = "Running"
+		// the forward auth takes precedence over it
+		devSpace.Spec.Auth.BasicAuth = &v1alpha1.BasicAuth{Username: "admin", Password: "admin"}
+		setServiceAnnotations(devSpace, "kde-system")
+		prepareIngress(devSpace, &core.Config{IngressProvider: provider, Host: "kde.example.com", ForwardAuth: true})
+		obj, err := turnTemplateToUnstructured(builtinTemplate(name, devSpace), devSpace)
+		assert.NoError(t, err)
+		return flattenList(obj)
+	}
+	authURL := "http://kde-apiserver.kde-system.svc:8080/auth/devspace?namespace=default&devspace=demo"
+
+	t.Run("nginx", func(t *testing.T) {
+		for _, name := range []string{TemplateIngress, TemplateExposeIngress} {
+			objs := render(t, IngressProviderNginx, name)
+			if !assert.Len(t, objs, 1, name) {
+				continue
+			}
+			annotations := objs[0].GetAnnotations()
+			assert.Equal(t, authURL, annotations["nginx.ingress.kubernetes.io/auth-url"], name)
+			assert.Equal(t, "http://kde.example.com/auth/devspace/signin?namespace=default&devspace=demo&rd=$scheme://$host$escaped_request_uri",
+				annotations["nginx.ingress.kubernetes.io/auth-signin"], name)
+			assert.NotContains(t, annotations, "nginx.ingress.kubernetes.io/auth-type", name)
+		}
+	})
+
+	t.Run("traefik", func(t *testing.T) {
+		objs := render(t, IngressProviderTraefik, TemplateIngress)
+		if !assert.Len(t, objs, 2) {
+			return
+		}
+		assert.Equal(t, "Middleware", objs[0].GetKind())
+		assert.Equal(t, "demo-forward-auth", objs[0].GetName())
+		address, _, _ := unstructured.NestedString(objs[0].Object, "spec", "forwardAuth", "address")
+		assert.Equal(t, authURL+"&signin=http%3A%2F%2Fkde.example.com%2Fauth%2Fdevspace%2Fsignin", address)
+		cookies, _, _ := unstructured.NestedStringSlice(objs[0].Object, "spec", "forwardAuth", "addAuthCookiesToResponse")
+		assert.Equal(t, []string{"kde-devspace-session_default_demo"}, cookies)
+		assert.Equal(t, "default-demo-forward-auth@kubernetescrd",
+			objs[1].GetAnnotations()["traefik.ingress.kubernetes.io/router.middlewares"])
+
+		expose := render(t, IngressProviderTraefik, TemplateExposeIngress)
+		if assert.Len(t, expose, 1) {
+			assert.Equal(t, "default-demo-forward-auth@kubernetescrd",
+				expose[0].GetAnnotations()["traefik.ingress.kubernetes.io/router.middlewares"])
+		}
+	})
+}
+
+func TestFlattenList(t *testing.T) {
+	assert.Equal(t, []*unstructured.Unstructured{nil}, flattenList(nil))
+
+	obj := &unstructured.Unstructured{}
+	obj.SetKind("Service")
+	assert.Equal(t, []*unstructured.Unstructured{obj}, flattenList(obj))
+
+	list := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "List",
+		"items": []interface{}{
+			map[string]interface{}{"apiVersion": "v1", "kind": "Service", "metadata": map[string]interface{}{"name": "a"}},
+			map[string]interface{}{"apiVersion": "v1", "kind": "ConfigMap", "metadata": map[string]interface{}{"name": "b"}},
+		},
+	}}
+	objs := flattenList(list)
+	if assert.Len(t, objs, 2) {
+		assert.Equal(t, "a", objs[0].GetName())
+		assert.Equal(t, "ConfigMap", objs[1].GetKind())
+	}
+}
@@ -0,0 +1,112 @@
+/*
+Copyright 2024 kde authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/linuxsuren/kde/api/linuxsuren.github.io/v1alpha1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// legacyInventory is the child resources which might be left by the controller
+// before having the inventory, the storage is not in it to keep the data safe
+func legacyInventory(devSpace *v1alpha1.DevSpace) []v1alpha1.InventoryEntry {
+	return []v1alpha1.InventoryEntry{
+		{APIVersion: "v1", Kind: "Secret", Name: devSpace.Name},
+		{APIVersion: "networking.k8s.io/v1", Kind: "Ingress", Name: devSpace.Name + "-expose"},
+	}
+}
+
+func inventoryEntryOf(obj *unstructured.Unstructured) v1alpha1.InventoryEntry {
+	return v1alpha1.InventoryEntry{APIVersion: obj.GetAPIVersion(), Kind: obj.GetKind(), Name: obj.GetName()}
+}
+
+// applyChildren applies the rendered child resources of the DevSpace and records them as the inventory.
+// The ones in the previous inventory but not rendered anymore are deleted if prune is true, otherwise
+// they are kept in the inventory. Only the ones controlled by the DevSpace are deleted, see pruneChild.
+func (r *DevSpaceReconciler) applyChildren(ctx context.Context, devSpace *v1alpha1.DevSpace, prune bool,
+	objs ...*unstructured.Unstructured) (err error) {
+	var inventory []v1alpha1.InventoryEntry
+	for _, obj := range objs {
+		if obj == nil {
+			continue
+		}
+		err = errors.Join(err, apply(ctx, r.Client, obj, func(drift error) {
+			r.Recorder.Eventf(devSpace, v1.EventTypeWarning, "Drift",
+				"%s %q was changed by others, taking it back: %v", obj.GetKind(), obj.GetName(), drift)
+		}))
+		// a failed one might be there as well
+		if obj.GetName() != "" {
+			inventory = append(inventory, inventoryEntryOf(obj))
+		}
+	}
+
+	previous := devSpace.Status.Inventory
+	if previous == nil {
+		previous = legacyInventory(devSpace)
+	}
+	for _, entry := range previous {
+		if slices.Contains(inventory, entry) {
+			continue
+		}
+
+		if prune {
+			obj := &unstructured.Unstructured{}
+			obj.SetAPIVersion(entry.APIVersion)
+			obj.SetKind(entry.Kind)
+			obj.SetNamespace(devSpace.Namespace)
+			obj.SetName(entry.Name)
+			pruneErr := r.pruneChild(ctx, devSpace, obj)
+			if pruneErr == nil {
+				continue
+			}
+			err = errors.Join(err, fmt.Errorf("failed to prune %s %s, error: %v", entry.Kind, entry.Name, pruneErr))
+		}
+		// try it again next time
+		inventory = append(inventory, entry)
+	}
+
+	if !slices.Equal(inventory, devSpace.Status.Inventory) {
+		patch := client.MergeFrom(devSpace.DeepCopy())
+		devSpace.Status.Inventory = inventory
+		err = errors.Join(err, r.Status().Patch(ctx, devSpace, patch))
+	}
+	return
+}
+
+// pruneChild deletes the child resource if it is controlled by the DevSpace. The one of others which
+// has the same name, e.g. a Secret of the user named after the DevSpace, is left alone and dropped from the inventory.
+func (r *DevSpaceReconciler) pruneChild(ctx context.Context, devSpace *v1alpha1.DevSpace, obj *unstructured.Unstructured) (err error) {
+	if err = r.Get(ctx, client.ObjectKeyFromObject(obj), obj); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	if !metav1.IsControlledBy(obj, devSpace) {
+		r.log.Info("not pruning the resource which is not controlled by the DevSpace", "kind", obj.GetKind(), "name", obj.GetName())
+		return
+	}
+	if err = client.IgnoreNotFound(r.Delete(ctx, obj)); err == nil {
+		r.log.Info("pruned the child resource", "kind", obj.GetKind(), "name", obj.GetName())
+	}
+	return
+}
@@ -0,0 +1,188 @@
+/*
+Copyright 2024 kde authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/linuxsuren/kde/api/linuxsuren.github.io/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	networkv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func TestApplyChildren(t *testing.T) {
+	schema, err := v1alpha1.SchemeBuilder.Register().Build()
+	assert.NoError(t, err)
+	assert.NoError(t, v1.AddToScheme(schema))
+	assert.NoError(t, networkv1.AddToScheme(schema))
+
+	configMapEntry := v1alpha1.InventoryEntry{APIVersion: "v1", Kind: "ConfigMap", Name: "demo"}
+	exposeEntry := v1alpha1.InventoryEntry{APIVersion: "networking.k8s.io/v1", Kind: "Ingress", Name: "demo-expose"}
+	ownerRefs := []metav1.OwnerReference{{
+		APIVersion: v1alpha1.GroupVersion.String(), Kind: "DevSpace", Name: "demo", UID: "uid", Controller: ptr.To(true),
+	}}
+	exposeIngress := &networkv1.Ingress{ObjectMeta: metav1.ObjectMeta{Name: "demo-expose", Namespace: "default", OwnerReferences: ownerRefs}}
+	legacySecret := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "demo", Namespace: "default", OwnerReferences: ownerRefs}}
+	// the ones of the user which have the same names as the legacy child resources
+	userSecret := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "demo", Namespace: "default"}}
+	userIngress := &networkv1.Ingress{ObjectMeta: metav1.ObjectMeta{Name: "demo-expose", Namespace: "default"}}
+	renderConfigMap := func() *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion("v1")
+		obj.SetKind("ConfigMap")
+		obj.SetNamespace("default")
+		obj.SetName("demo")
+		return obj
+	}
+	exists := func(cli client.Client, obj client.Object) bool {
+		return cli.Get(context.Background(), client.ObjectKeyFromObject(obj), obj) == nil
+	}
+
+	tests := []struct {
+		name      string
+		inventory []v1alpha1.InventoryEntry
+		prune     bool
+		objects   []client.Object
+		verify    func(*testing.T, client.Client, []v1alpha1.InventoryEntry)
+	}{{
+		name:      "prune the ones not rendered",
+		inventory: []v1alpha1.InventoryEntry{configMapEntry, exposeEntry},
+		prune:     true,
+		objects:   []client.Object{exposeIngress.DeepCopy()},
+		verify: func(t *testing.T, cli client.Client, inventory []v1alpha1.InventoryEntry) {
+			assert.Equal(t, []v1alpha1.InventoryEntry{configMapEntry}, inventory)
+			assert.False(t, exists(cli, exposeIngress.DeepCopy()))
+			assert.True(t, exists(cli, &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "demo", Namespace: "default"}}))
+		},
+	}, {
+		name:      "keep the ones not rendered",
+		inventory: []v1alpha1.InventoryEntry{exposeEntry},
+		objects:   []client.Object{exposeIngress.DeepCopy()},
+		verify: func(t *testing.T, cli client.Client, inventory []v1alpha1.InventoryEntry) {
+			assert.Equal(t, []v1alpha1.InventoryEntry{configMapEntry, exposeEntry}, inventory)
+			assert.True(t, exists(cli, exposeIngress.DeepCopy()))
+		},
+	}, {
+		name:      "the pruned one is gone already",
+		inventory: []v1alpha1.InventoryEntry{exposeEntry},
+		prune:     true,
+		verify: func(t *testing.T, cli client.Client, inventory []v1alpha1.InventoryEntry) {
+			assert.Equal(t, []v1alpha1.InventoryEntry{configMapEntry}, inventory)
+		},
+	}, {
+		name:    "prune the legacy ones",
+		prune:   true,
+		objects: []client.Object{legacySecret.DeepCopy(), exposeIngress.DeepCopy()},
+		verify: func(t *testing.T, cli client.Client, inventory []v1alpha1.InventoryEntry) {
+			assert.Equal(t, []v1alpha1.InventoryEntry{configMapEntry}, inventory)
+			assert.False(t, exists(cli, legacySecret.DeepCopy()))
+			assert.False(t, exists(cli, exposeIngress.DeepCopy()))
+		},
+	}, {
+		name:    "leave the legacy ones of others",
+		prune:   true,
+		objects: []client.Object{userSecret.DeepCopy(), userIngress.DeepCopy()},
+		verify: func(t *testing.T, cli client.Client, inventory []v1alpha1.InventoryEntry) {
+			assert.Equal(t, []v1alpha1.InventoryEntry{configMapEntry}, inventory)
+			assert.True(t, exists(cli, userSecret.DeepCopy()))
+			assert.True(t, exists(cli, userIngress.DeepCopy()))
+		},
+	}, {
+		name:      "leave the one controlled by others",
+		inventory: []v1alpha1.InventoryEntry{exposeEntry},
+		prune:     true,
+		objects:   []client.Object{userIngress.DeepCopy()},
+		verify: func(t *testing.T, cli client.Client, inventory []v1alpha1.InventoryEntry) {
+			assert.Equal(t, []v1alpha1.InventoryEntry{configMapEntry}, inventory)
+			assert.True(t, exists(cli, userIngress.DeepCopy()))
+		},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			devSpace := createDefaultGitPod()
+			devSpace.UID = "uid"
+			devSpace.Status.Inventory = tt.inventory
+			cli := newFakeClientBuilder(schema).WithObjects(append(tt.objects, devSpace.DeepCopy())...).Build()
+			r := &DevSpaceReconciler{Client: cli, Recorder: record.NewFakeRecorder(10), log: log.FromContext(context.Background())}
+
+			err := r.applyChildren(context.Background(), devSpace, tt.prune, renderConfigMap(), nil)
+			assert.NoError(t, err)
+
+			stored := &v1alpha1.DevSpace{}
+			assert.NoError(t, cli.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: "demo"}, stored))
+			assert.Equal(t, devSpace.Status.Inventory, stored.Status.Inventory)
+			tt.verify(t, cli, stored.Status.Inventory)
+		})
+	}
+
+	t.Run("drift", func(t *testing.T) {
+		devSpace := createDefaultGitPod()
+		devSpace.Status.Inventory = []v1alpha1.InventoryEntry{configMapEntry}
+		var fieldOwners []string
+		cli := newFakeClientBuilder(schema).WithObjects(devSpace.DeepCopy()).
+			WithInterceptorFuncs(interceptor.Funcs{
+				Patch: func(ctx context.Context, cli client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+					patchOpts := &client.PatchOptions{}
+					patchOpts.ApplyOptions(opts)
+					fieldOwners = append(fieldOwners, patchOpts.FieldManager)
+					if patchOpts.Force == nil || !*patchOpts.Force {
+						return apierrors.NewApplyConflict([]metav1.StatusCause{{
+							Type: metav1.CauseTypeFieldManagerConflict, Field: ".data.settings",
+						}}, `conflict with "kubectl-edit"`)
+					}
+					return fakeApply(ctx, cli, obj, patch, opts...)
+				},
+			}).Build()
+		recorder := record.NewFakeRecorder(10)
+		r := &DevSpaceReconciler{Client: cli, Recorder: recorder, log: log.FromContext(context.Background())}
+
+		assert.NoError(t, r.applyChildren(context.Background(), devSpace, true, renderConfigMap()))
+		assert.Equal(t, []string{FieldManager, FieldManager}, fieldOwners)
+		assert.True(t, exists(cli, &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "demo", Namespace: "default"}}))
+		if assert.Len(t, recorder.Events, 1) {
+			event := <-recorder.Events
+			assert.Contains(t, event, "Warning Drift ConfigMap \"demo\" was changed by others")
+			assert.Contains(t, event, "kubectl-edit")
+		}
+	})
+
+	t.Run("failed to apply", func(t *testing.T) {
+		devSpace := createDefaultGitPod()
+		cli := newFakeClientBuilder(schema).WithObjects(devSpace.DeepCopy()).
+			WithInterceptorFuncs(interceptor.Funcs{
+				Patch: func(context.Context, client.WithWatch, client.Object, client.Patch, ...client.PatchOption) error {
+					return apierrors.NewForbidden(v1.Resource("configmaps"), "demo", nil)
+				},
+			}).Build()
+		r := &DevSpaceReconciler{Client: cli, Recorder: record.NewFakeRecorder(10), log: log.FromContext(context.Background())}
+
+		err := r.applyChildren(context.Background(), devSpace, true, renderConfigMap())
+		assert.ErrorContains(t, err, "failed to apply default/demo")
+		assert.Contains(t, devSpace.Status.Inventory, configMapEntry)
+	})
+}
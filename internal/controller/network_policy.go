@@ -0,0 +1,60 @@
+/*
+Copyright 2024 kde authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"slices"
+
+	"github.com/linuxsuren/kde/api/linuxsuren.github.io/v1alpha1"
+	"github.com/linuxsuren/kde/pkg/core"
+)
+
+// prepareNetworkPolicy gives the network policy which the template renders, the fields given by
+// the DevSpace override the ones of the config. It comes after prepareIngress, see ingressNamespacesOf.
+func prepareNetworkPolicy(devSpace *v1alpha1.DevSpace, config *core.Config) {
+	if config == nil {
+		config = &core.Config{}
+	}
+	policy := config.NetworkPolicy.DeepCopy()
+	if given := devSpace.Spec.NetworkPolicy; given != nil {
+		policy.Disabled = policy.Disabled || given.Disabled
+		if len(given.IngressNamespaces) > 0 {
+			policy.IngressNamespaces = given.IngressNamespaces
+		}
+		if given.Egress != nil {
+			policy.Egress = given.Egress
+		}
+	}
+	if len(policy.IngressNamespaces) == 0 {
+		policy.IngressNamespaces = ingressNamespacesOf(devSpace, config)
+	}
+	devSpace.Spec.NetworkPolicy = policy
+}
+
+// ingressNamespacesOf returns where the ingress controller of the DevSpace might be, they are the usual
+// namespaces of the ingress provider, the namespace of the Gateway, and the one given by the config
+func ingressNamespacesOf(devSpace *v1alpha1.DevSpace, config *core.Config) []string {
+	namespaces := slices.Clone(ingressProviderOf(devSpace).namespaces)
+	for _, namespace := range []string{
+		devSpace.Annotations[v1alpha1.AnnoKeyGatewayNamespace], config.IngressControllerNamespace,
+	} {
+		if namespace != "" && !slices.Contains(namespaces, namespace) {
+			namespaces = append(namespaces, namespace)
+		}
+	}
+	return namespaces
+}
@@ -0,0 +1,187 @@
+/*
+Copyright 2024 kde authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/linuxsuren/kde/api/linuxsuren.github.io/v1alpha1"
+	"github.com/linuxsuren/kde/pkg/core"
+	"github.com/stretchr/testify/assert"
+	networkingv1 "k8s.io/api/networking/v1"
+)
+
+func TestPrepareNetworkPolicy(t *testing.T) {
+	config := &core.Config{NetworkPolicy: v1alpha1.NetworkPolicy{
+		IngressNamespaces: []string{"traefik"},
+		Egress:            &v1alpha1.NetworkEgress{Internet: true},
+	}}
+
+	tests := []struct {
+		name   string
+		policy *v1alpha1.NetworkPolicy
+		config *core.Config
+		expect *v1alpha1.NetworkPolicy
+	}{{
+		name:   "without config",
+		expect: &v1alpha1.NetworkPolicy{IngressNamespaces: []string{"ingress-nginx", "kube-system"}},
+	}, {
+		name:   "traefik",
+		config: &core.Config{IngressProvider: IngressProviderTraefik},
+		expect: &v1alpha1.NetworkPolicy{IngressNamespaces: []string{"traefik", "kube-system"}},
+	}, {
+		name: "gateway",
+		config: &core.Config{IngressProvider: IngressProviderGateway, Gateway: core.GatewayReference{
+			Name: "kde", Namespace: "gateway",
+		}},
+		expect: &v1alpha1.NetworkPolicy{IngressNamespaces: []string{"envoy-gateway-system", "gateway"}},
+	}, {
+		name: "gateway in the system namespace",
+		config: &core.Config{IngressProvider: IngressProviderGateway, Gateway: core.GatewayReference{
+			Name: "kde",
+		}},
+		expect: &v1alpha1.NetworkPolicy{IngressNamespaces: []string{"envoy-gateway-system", "kde-system"}},
+	}, {
+		name:   "ingress controller namespace",
+		config: &core.Config{IngressProvider: IngressProviderNginx, IngressControllerNamespace: "ingress"},
+		expect: &v1alpha1.NetworkPolicy{IngressNamespaces: []string{"ingress-nginx", "kube-system", "ingress"}},
+	}, {
+		name:   "from the config",
+		config: config,
+		expect: &v1alpha1.NetworkPolicy{
+			IngressNamespaces: []string{"traefik"},
+			Egress:            &v1alpha1.NetworkEgress{Internet: true},
+		},
+	}, {
+		name:   "override the egress",
+		policy: &v1alpha1.NetworkPolicy{Egress: &v1alpha1.NetworkEgress{Namespaces: []string{"database"}}},
+		config: config,
+		expect: &v1alpha1.NetworkPolicy{
+			IngressNamespaces: []string{"traefik"},
+			Egress:            &v1alpha1.NetworkEgress{Namespaces: []string{"database"}},
+		},
+	}, {
+		name:   "disabled by the DevSpace",
+		policy: &v1alpha1.NetworkPolicy{Disabled: true, IngressNamespaces: []string{"ingress"}},
+		config: config,
+		expect: &v1alpha1.NetworkPolicy{
+			Disabled:          true,
+			IngressNamespaces: []string{"ingress"},
+			Egress:            &v1alpha1.NetworkEgress{Internet: true},
+		},
+	}, {
+		name:   "disabled by the config",
+		config: &core.Config{NetworkPolicy: v1alpha1.NetworkPolicy{Disabled: true}},
+		expect: &v1alpha1.NetworkPolicy{Disabled: true, IngressNamespaces: []string{"ingress-nginx", "kube-system"}},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			devSpace := createDefaultGitPod()
+			devSpace.Spec.NetworkPolicy = tt.policy
+			setServiceAnnotations(devSpace, "kde-system")
+			prepareIngress(devSpace, tt.config)
+			prepareNetworkPolicy(devSpace, tt.config)
+			assert.Equal(t, tt.expect, devSpace.Spec.NetworkPolicy)
+		})
+	}
+	// the config is kept as it is
+	assert.Equal(t, &v1alpha1.NetworkEgress{Internet: true}, config.NetworkPolicy.Egress)
+}
+
+func TestNetworkPolicyRender(t *testing.T) {
+	render := func(t *testing.T, policy *v1alpha1.NetworkPolicy) *networkingv1.NetworkPolicy {
+		devSpace := createDefaultGitPod()
+		devSpace.Spec.NetworkPolicy = policy
+		prepareNetworkPolicy(devSpace, nil)
+		setServiceAnnotations(devSpace, "kde-system")
+		obj, err := turnTemplateToUnstructured(gitpodNetworkPolicy, devSpace)
+		assert.NoError(t, err)
+		if obj == nil {
+			return nil
+		}
+		data, err := obj.MarshalJSON()
+		assert.NoError(t, err)
+		networkPolicy := &networkingv1.NetworkPolicy{}
+		assert.NoError(t, json.Unmarshal(data, networkPolicy))
+		return networkPolicy
+	}
+	namespaceOf := func(peer networkingv1.NetworkPolicyPeer) string {
+		if peer.NamespaceSelector == nil {
+			return ""
+		}
+		return peer.NamespaceSelector.MatchLabels["kubernetes.io/metadata.name"]
+	}
+
+	t.Run("default", func(t *testing.T) {
+		policy := render(t, nil)
+		if !assert.NotNil(t, policy) {
+			return
+		}
+		assert.Equal(t, "demo", policy.Name)
+		assert.Equal(t, "demo", policy.Spec.PodSelector.MatchLabels[LabelApp])
+		assert.Equal(t, []networkingv1.PolicyType{networkingv1.PolicyTypeIngress}, policy.Spec.PolicyTypes)
+		assert.Empty(t, policy.Spec.Egress)
+		if assert.Len(t, policy.Spec.Ingress, 1) {
+			from := policy.Spec.Ingress[0].From
+			if assert.Len(t, from, 3) {
+				assert.Equal(t, "kde-system", namespaceOf(from[0]))
+				assert.Equal(t, "kde-apiserver", from[0].PodSelector.MatchLabels["control-plane"])
+				assert.Equal(t, "ingress-nginx", namespaceOf(from[1]))
+				assert.Equal(t, "kube-system", namespaceOf(from[2]))
+			}
+		}
+	})
+
+	t.Run("egress", func(t *testing.T) {
+		policy := render(t, &v1alpha1.NetworkPolicy{Egress: &v1alpha1.NetworkEgress{
+			Internet:   true,
+			Namespaces: []string{"database"},
+			CIDRs:      []string{"10.0.1.0/24"},
+		}})
+		if !assert.NotNil(t, policy) {
+			return
+		}
+		assert.Equal(t, []networkingv1.PolicyType{networkingv1.PolicyTypeIngress, networkingv1.PolicyTypeEgress}, policy.Spec.PolicyTypes)
+		if !assert.Len(t, policy.Spec.Egress, 2) {
+			return
+		}
+		// the DNS
+		assert.Empty(t, policy.Spec.Egress[0].To)
+		assert.Len(t, policy.Spec.Egress[0].Ports, 2)
+
+		to := policy.Spec.Egress[1].To
+		if assert.Len(t, to, 4) {
+			assert.Equal(t, "kde-system", namespaceOf(to[0]))
+			assert.Equal(t, "0.0.0.0/0", to[1].IPBlock.CIDR)
+			assert.Contains(t, to[1].IPBlock.Except, "10.0.0.0/8")
+			assert.Equal(t, "database", namespaceOf(to[2]))
+			assert.Equal(t, "10.0.1.0/24", to[3].IPBlock.CIDR)
+		}
+	})
+
+	t.Run("nothing but the DNS and the kde apiserver", func(t *testing.T) {
+		policy := render(t, &v1alpha1.NetworkPolicy{Egress: &v1alpha1.NetworkEgress{}})
+		if assert.NotNil(t, policy) && assert.Len(t, policy.Spec.Egress, 2) {
+			assert.Len(t, policy.Spec.Egress[1].To, 1)
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		assert.Nil(t, render(t, &v1alpha1.NetworkPolicy{Disabled: true}))
+	})
+}
@@ -0,0 +1,279 @@
+/*
+Copyright 2024 kde authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/linuxsuren/kde/api/linuxsuren.github.io/v1alpha1"
+	"github.com/linuxsuren/kde/pkg/core"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// OverrideTemplatesConfigMap is the ConfigMap in the system namespace which has the override templates
+// of the child resources. The keys are the names of the built-in templates, e.g. deployment.yaml,
+// the missing ones are the built-in ones.
+const OverrideTemplatesConfigMap = "templates"
+
+// Names of the templates of the child resources, in the applying order
+const (
+	TemplateConfigMap     = "configmap.yaml"
+	TemplateSecret        = "secret.yaml"
+	TemplatePVC           = "pvc.yaml"
+	TemplateNetworkPolicy = "networkpolicy.yaml"
+	TemplateDeployment    = "deployment.yaml"
+	TemplateService       = "service.yaml"
+	TemplateWakeUpService = "service-wakeup.yaml"
+	TemplateIngress       = "ingress.yaml"
+	TemplateExposeIngress = "ingress-expose.yaml"
+)
+
+// TemplateNames are the names of all the templates of the child resources, in the applying order
+var TemplateNames = []string{TemplateConfigMap, TemplateSecret, TemplatePVC, TemplateNetworkPolicy, TemplateDeployment,
+	TemplateService, TemplateWakeUpService, TemplateIngress, TemplateExposeIngress}
+
+// builtinTemplate returns the built-in template of the given name, the ingress ones come from
+// the ingress provider of the DevSpace
+func builtinTemplate(name string, devSpace *v1alpha1.DevSpace) string {
+	switch name {
+	case TemplateConfigMap:
+		return gitpodConfigMap
+	case TemplateSecret:
+		return gitpodSecret
+	case TemplatePVC:
+		return gitpodPvc
+	case TemplateNetworkPolicy:
+		return gitpodNetworkPolicy
+	case TemplateDeployment:
+		return gitpodDeployment
+	case TemplateService:
+		return gitpodService
+	case TemplateWakeUpService:
+		return gitpodWakeUpService
+	case TemplateIngress, TemplateExposeIngress:
+		return ingressProviderOf(devSpace).template(name)
+	}
+	return ""
+}
+
+// Templates are the override templates of the child resources, the built-in ones are used for the missing ones
+type Templates map[string]string
+
+// ValidateTemplates renders the override templates against a sample DevSpace of the config before using them.
+// It returns the valid ones, along with the errors of the invalid ones.
+func ValidateTemplates(overrides map[string]string, config *core.Config) (valid Templates, err error) {
+	valid = Templates{}
+	for name, tpl := range overrides {
+		if !slices.Contains(TemplateNames, name) {
+			err = errors.Join(err, fmt.Errorf("unknown template %q, it should be one of %v", name, TemplateNames))
+			continue
+		}
+
+		sample := SampleDevSpace(config)
+		if _, renderErr := renderTemplate(name, tpl, sample); renderErr != nil {
+			err = errors.Join(err, renderErr)
+			continue
+		}
+		valid[name] = tpl
+	}
+	return
+}
+
+// Render renders the child resources of the DevSpace with the templates. The built-in one is used if an override
+// template fails, the errors of the override templates are returned as fallbacks. The result is keyed by the
+// template names, the value is nil if nothing is rendered.
+func (t Templates) Render(devSpace *v1alpha1.DevSpace) (objs map[string]*unstructured.Unstructured, fallbacks, err error) {
+	objs = make(map[string]*unstructured.Unstructured, len(TemplateNames))
+	for _, name := range TemplateNames {
+		if override, ok := t[name]; ok {
+			obj, overrideErr := renderTemplate(name, override, devSpace)
+			if overrideErr == nil {
+				objs[name] = obj
+				continue
+			}
+			fallbacks = errors.Join(fallbacks, overrideErr)
+		}
+
+		obj, builtinErr := turnTemplateToUnstructured(builtinTemplate(name, devSpace), devSpace)
+		err = errors.Join(err, builtinErr)
+		objs[name] = obj
+	}
+	return
+}
+
+// renderTemplate renders an override template, the result must be the same kind of resource
+// as the built-in one in the namespace of the DevSpace
+func renderTemplate(name, tpl string, devSpace *v1alpha1.DevSpace) (obj *unstructured.Unstructured, err error) {
+	if obj, err = turnTemplateToUnstructured(tpl, devSpace); err != nil || obj == nil {
+		if err != nil {
+			err = fmt.Errorf("template %q: %v", name, err)
+		}
+		return
+	}
+
+	var builtin *unstructured.Unstructured
+	if builtin, err = turnTemplateToUnstructured(builtinTemplate(name, devSpace), devSpace); err != nil {
+		return
+	}
+	switch {
+	case builtin != nil && (obj.GroupVersionKind() != builtin.GroupVersionKind()):
+		err = fmt.Errorf("template %q: it should render a %s instead of %q", name, builtin.GroupVersionKind(), obj.GroupVersionKind())
+	case obj.GetName() == "":
+		err = fmt.Errorf("template %q: the name is required", name)
+	case obj.GetNamespace() != devSpace.Namespace:
+		err = fmt.Errorf("template %q: it should be in the namespace %q instead of %q", name, devSpace.Namespace, obj.GetNamespace())
+	}
+	return
+}
+
+// loadTemplates returns the valid override templates in the system namespace,
+// the invalid ones are reported as a Render event
+func (r *DevSpaceReconciler) loadTemplates(ctx context.Context, devSpace *v1alpha1.DevSpace) (templates Templates) {
+	cm := &v1.ConfigMap{}
+	if err := r.Get(ctx, types.NamespacedName{Namespace: r.SystemNamespace, Name: OverrideTemplatesConfigMap}, cm); err != nil {
+		if !apierrors.IsNotFound(err) {
+			r.log.Error(err, "failed to get the override templates")
+		}
+		return
+	}
+
+	templates, err := ValidateTemplates(cm.Data, r.config)
+	if err != nil {
+		r.Recorder.Eventf(devSpace, v1.EventTypeWarning, "Render", "invalid override templates, fall back to the built-in ones: %v", err)
+	}
+	return
+}
+
+// findDevSpacesForOverrideTemplates reconciles all the DevSpaces once the override templates are changed
+func (r *DevSpaceReconciler) findDevSpacesForOverrideTemplates(ctx context.Context, cm client.Object) (requests []reconcile.Request) {
+	if cm.GetNamespace() != r.SystemNamespace || cm.GetName() != OverrideTemplatesConfigMap {
+		return
+	}
+
+	devSpaceList := &v1alpha1.DevSpaceList{}
+	if err := r.List(ctx, devSpaceList); err != nil {
+		return
+	}
+	for _, devSpace := range devSpaceList.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&devSpace)})
+	}
+	return
+}
+
+// PrepareForRender fills the DevSpace like the reconciling does before rendering the child resources.
+// The spec of the applied template is merged if it is given, the credentials are not looked up.
+func PrepareForRender(devSpace *v1alpha1.DevSpace, applied *v1alpha1.DevSpaceSpec, config *core.Config, systemNamespace string) {
+	if applied != nil {
+		if spec, err := mergeTemplate(applied, &devSpace.Spec); err == nil {
+			devSpace.Spec = *spec
+		}
+	}
+	setDefaultValueForDevSpace(devSpace, config)
+	prepareSecurity(devSpace, config)
+	prepareRepositories(devSpace, config)
+	prepareServices(devSpace)
+	prepareResources(devSpace, config)
+	prepareScheduling(devSpace, config)
+	setServiceAnnotations(devSpace, systemNamespace)
+	prepareIngress(devSpace, config)
+	prepareNetworkPolicy(devSpace, config)
+	prepareTLS(devSpace, config)
+}
+
+// setServiceAnnotations tells the child resources where the kde apiserver is
+func setServiceAnnotations(devSpace *v1alpha1.DevSpace, systemNamespace string) {
+	devSpace.Annotations[v1alpha1.AnnoKeyServiceNamespace] = systemNamespace
+	devSpace.Annotations[v1alpha1.AnnoKeyServiceName] = "kde-apiserver"
+}
+
+// SampleDevSpace returns a DevSpace which has all the features enabled, the override templates are validated with it.
+// It is prepared with the given config, e.g. the ingress provider, but the host.
+func SampleDevSpace(config *core.Config) *v1alpha1.DevSpace {
+	replicas := int32(1)
+	secretRef := func(key string) *v1.SecretKeySelector {
+		return &v1.SecretKeySelector{LocalObjectReference: v1.LocalObjectReference{Name: "sample-credentials"}, Key: key}
+	}
+	devSpace := &v1alpha1.DevSpace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "sample",
+			Namespace: "default",
+			UID:       "00000000-0000-0000-0000-000000000000",
+			Annotations: map[string]string{
+				v1alpha1.AnnoKeyExposePorts: "8080",
+				v1alpha1.AnnoKeyBasicAuth:   "c2FtcGxl",
+			},
+		},
+		Spec: v1alpha1.DevSpaceSpec{
+			Replicas: &replicas,
+			Auth: v1alpha1.DevSpaceAuth{
+				BasicAuth:              &v1alpha1.BasicAuth{Username: "sample"},
+				SSHPrivateKeySecretRef: secretRef(v1alpha1.CredentialKeySSHPrivateKey),
+				KnownHosts:             []string{"github.com ssh-ed25519 AAAA"},
+			},
+			Repositories: []v1alpha1.GitRepository{{
+				URL:               "https://github.com/linuxsuren/kde",
+				Branch:            "master",
+				Username:          "sample",
+				Email:             "sample@example.com",
+				PasswordSecretRef: secretRef("git-password-kde"),
+			}},
+			Services: v1alpha1.Services{
+				Docker:   &v1alpha1.Docker{Enabled: ptr.To(true)},
+				MySQL:    &v1alpha1.MySQL{Enabled: ptr.To(true), PasswordSecretRef: secretRef(v1alpha1.CredentialKeyMySQL)},
+				Postgres: &v1alpha1.Postgres{Enabled: ptr.To(true), PasswordSecretRef: secretRef(v1alpha1.CredentialKeyPostgres)},
+				RabbitMQ: &v1alpha1.RabbitMQ{Enabled: ptr.To(true), PasswordSecretRef: secretRef(v1alpha1.CredentialKeyRabbitMQ)},
+				MongoDB:  &v1alpha1.CatalogService{Enabled: ptr.To(true)},
+				Custom: []v1alpha1.CustomService{{
+					Name:        "sample",
+					Image:       "sample:latest",
+					Command:     []string{"sample"},
+					Args:        []string{"--port", "8081"},
+					Env:         []v1.EnvVar{{Name: "SAMPLE", Value: "sample"}},
+					Ports:       []v1alpha1.ServicePort{{Name: "sample", Port: 8081}},
+					Persistence: &v1alpha1.ServicePersistence{MountPath: "/data"},
+				}},
+			},
+			NetworkPolicy: &v1alpha1.NetworkPolicy{Egress: &v1alpha1.NetworkEgress{
+				Internet:   true,
+				Namespaces: []string{"default"},
+				CIDRs:      []string{"10.0.0.0/24"},
+			}},
+		},
+		Status: v1alpha1.DevSpaceStatus{
+			Phase: v1alpha1.DevSpacePhaseRunning,
+		},
+	}
+	sampleConfig := core.Config{}
+	if config != nil {
+		sampleConfig = *config
+	}
+	sampleConfig.Host = "kde.example.com"
+	PrepareForRender(devSpace, nil, &sampleConfig, "kde-system")
+	devSpace.Status.Link = devSpaceLinkOf("sample.kde.example.com", devSpace)
+	devSpace.Status.ExposeLinks = []v1alpha1.ExposeLink{{Link: devSpaceLinkOf("8080.sample.kde.example.com", devSpace), Port: 8080}}
+	return devSpace
+}
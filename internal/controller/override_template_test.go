@@ -0,0 +1,199 @@
+/*
+Copyright 2024 kde authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/linuxsuren/kde/api/linuxsuren.github.io/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	networkv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// deploymentWithSidecar is the built-in Deployment template along with a corporate sidecar and label
+var deploymentWithSidecar = strings.NewReplacer(
+	"    linuxsuren.github.io/application: {{.ObjectMeta.Name}}\n  name:",
+	"    linuxsuren.github.io/application: {{.ObjectMeta.Name}}\n    corp.example.com/cost-center: dev\n  name:",
+	"      containers:\n",
+	"      containers:\n        - name: audit\n          image: corp.example.com/audit:{{ .Spec.Services.MySQL.Database | default \"latest\" }}\n",
+).Replace(gitpodDeployment)
+
+func TestValidateTemplates(t *testing.T) {
+	t.Run("built-in templates", func(t *testing.T) {
+		builtins := map[string]string{}
+		for _, name := range TemplateNames {
+			builtins[name] = builtinTemplate(name, SampleDevSpace(nil))
+		}
+		valid, err := ValidateTemplates(builtins, nil)
+		assert.NoError(t, err)
+		assert.Len(t, valid, len(TemplateNames))
+	})
+
+	tests := []struct {
+		name      string
+		overrides map[string]string
+		err       []string
+	}{{
+		name:      "with a sidecar",
+		overrides: map[string]string{TemplateDeployment: deploymentWithSidecar},
+	}, {
+		name:      "render nothing",
+		overrides: map[string]string{TemplateWakeUpService: "{{ if false }}{{ end }}"},
+	}, {
+		name:      "unknown template",
+		overrides: map[string]string{"job.yaml": gitpodService},
+		err:       []string{`unknown template "job.yaml"`},
+	}, {
+		name:      "syntax error",
+		overrides: map[string]string{TemplateService: "{{ .ObjectMeta.Name "},
+		err:       []string{`template "service.yaml"`},
+	}, {
+		name:      "missing field",
+		overrides: map[string]string{TemplateService: "{{ .Spec.Unknown }}"},
+		err:       []string{`template "service.yaml"`, "Unknown"},
+	}, {
+		name:      "another kind",
+		overrides: map[string]string{TemplateService: gitpodConfigMap},
+		err:       []string{`template "service.yaml": it should render a /v1, Kind=Service`},
+	}, {
+		name: "another namespace",
+		overrides: map[string]string{
+			TemplateService: strings.Replace(gitpodService, "namespace: {{.ObjectMeta.Namespace}}", "namespace: kube-system", 1),
+		},
+		err: []string{`it should be in the namespace "default" instead of "kube-system"`},
+	}, {
+		name:      "without name",
+		overrides: map[string]string{TemplateService: "apiVersion: v1\nkind: Service\n"},
+		err:       []string{"the name is required"},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			valid, err := ValidateTemplates(tt.overrides, nil)
+			if len(tt.err) == 0 {
+				assert.NoError(t, err)
+				assert.Equal(t, Templates(tt.overrides), valid)
+				return
+			}
+
+			assert.Empty(t, valid)
+			for _, msg := range tt.err {
+				assert.ErrorContains(t, err, msg)
+			}
+		})
+	}
+}
+
+func TestTemplatesRender(t *testing.T) {
+	devSpace := createDefaultGitPod()
+	devSpace.Spec.Services.MySQL = &v1alpha1.MySQL{Enabled: ptr.To(true), Database: "test"}
+
+	objs, fallbacks, err := Templates{TemplateDeployment: deploymentWithSidecar}.Render(devSpace)
+	assert.NoError(t, err)
+	assert.NoError(t, fallbacks)
+	assert.Len(t, objs, len(TemplateNames))
+	assert.Equal(t, "dev", objs[TemplateDeployment].GetLabels()["corp.example.com/cost-center"])
+	assert.Equal(t, "Service", objs[TemplateService].GetKind())
+
+	// it is valid with the sample, but not with this DevSpace
+	devSpace.Spec.Services.MySQL = nil
+	objs, fallbacks, err = Templates{TemplateDeployment: deploymentWithSidecar}.Render(devSpace)
+	assert.NoError(t, err)
+	assert.ErrorContains(t, fallbacks, `template "deployment.yaml"`)
+	assert.Empty(t, objs[TemplateDeployment].GetLabels()["corp.example.com/cost-center"])
+	assert.Equal(t, "Deployment", objs[TemplateDeployment].GetKind())
+}
+
+func TestReconcileWithOverrideTemplates(t *testing.T) {
+	schema, err := v1alpha1.SchemeBuilder.Register().Build()
+	assert.NoError(t, err)
+	assert.NoError(t, v1.AddToScheme(schema))
+	assert.NoError(t, appsv1.AddToScheme(schema))
+	assert.NoError(t, networkv1.AddToScheme(schema))
+
+	overrides := func(data map[string]string) *v1.ConfigMap {
+		return &v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: OverrideTemplatesConfigMap, Namespace: "kde-system"},
+			Data:       data,
+		}
+	}
+	devSpace := createDefaultGitPod()
+	devSpace.Spec.Services.MySQL = &v1alpha1.MySQL{Enabled: ptr.To(true), Database: "test"}
+
+	tests := []struct {
+		name   string
+		cm     *v1.ConfigMap
+		label  string
+		events []string
+	}{{
+		name:  "valid",
+		cm:    overrides(map[string]string{TemplateDeployment: deploymentWithSidecar}),
+		label: "dev",
+	}, {
+		name:   "invalid",
+		cm:     overrides(map[string]string{TemplateDeployment: "{{ .Spec.Unknown }}"}),
+		events: []string{"Warning Render invalid override templates, fall back to the built-in ones"},
+	}, {
+		name: "not found",
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			builder := newFakeClientBuilder(schema).WithObjects(devSpace.DeepCopy())
+			if tt.cm != nil {
+				builder = builder.WithObjects(tt.cm)
+			}
+			cli := builder.Build()
+			recorder := record.NewFakeRecorder(10)
+			r := &DevSpaceReconciler{Client: cli, Recorder: recorder, SystemNamespace: "kde-system"}
+
+			_, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "demo"}})
+			assert.NoError(t, err)
+
+			deploy := &appsv1.Deployment{}
+			assert.NoError(t, cli.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: "demo"}, deploy))
+			assert.Equal(t, tt.label, deploy.Labels["corp.example.com/cost-center"])
+			assert.Len(t, recorder.Events, len(tt.events))
+			for _, event := range tt.events {
+				assert.Contains(t, <-recorder.Events, event)
+			}
+		})
+	}
+
+	t.Run("find the DevSpaces", func(t *testing.T) {
+		another := createDefaultGitPod()
+		another.Namespace = "another"
+		r := &DevSpaceReconciler{
+			Client:          newFakeClientBuilder(schema).WithObjects(devSpace.DeepCopy(), another).Build(),
+			SystemNamespace: "kde-system",
+			log:             log.FromContext(context.Background()),
+		}
+		assert.Len(t, r.findDevSpacesForOverrideTemplates(context.Background(), overrides(nil)), 2)
+
+		config := overrides(nil)
+		config.Name = "config"
+		assert.Empty(t, r.findDevSpacesForOverrideTemplates(context.Background(), config))
+	})
+}
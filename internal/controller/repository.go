@@ -0,0 +1,57 @@
+/*
+Copyright 2024 kde authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/linuxsuren/kde/api/linuxsuren.github.io/v1alpha1"
+	"github.com/linuxsuren/kde/pkg/core"
+)
+
+// repositoriesOf returns all the repositories to clone, spec.repository goes first
+func repositoriesOf(spec *v1alpha1.DevSpaceSpec) (repos []v1alpha1.GitRepository) {
+	if spec.Repository != nil && spec.Repository.URL != "" {
+		repos = append(repos, *spec.Repository)
+	}
+	for _, repo := range spec.Repositories {
+		if repo.URL != "" {
+			repos = append(repos, repo)
+		}
+	}
+	return
+}
+
+// prepareRepositories puts all the repositories into spec.repositories, and the known hosts
+// of the global config into spec.auth.knownHosts. The templates only handle these fields.
+func prepareRepositories(devSpace *v1alpha1.DevSpace, config *core.Config) {
+	devSpace.Spec.Repositories = repositoriesOf(&devSpace.Spec)
+	devSpace.Spec.Repository = nil
+	if config != nil {
+		devSpace.Spec.Auth.KnownHosts = append(slices.Clone(config.KnownHosts), devSpace.Spec.Auth.KnownHosts...)
+	}
+}
+
+var invalidEnvNameChars = regexp.MustCompile(`[^A-Z0-9_]`)
+
+// gitPasswordEnv returns the name of the environment variable which holds the password of the repository,
+// the git credential helper of the repository reads it
+func gitPasswordEnv(repo v1alpha1.GitRepository) string {
+	return "GIT_PASSWORD_" + invalidEnvNameChars.ReplaceAllString(strings.ToUpper(repo.TargetDirectory()), "_")
+}
@@ -0,0 +1,294 @@
+/*
+Copyright 2024 kde authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/cgi"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/linuxsuren/kde/api/linuxsuren.github.io/v1alpha1"
+	"github.com/linuxsuren/kde/pkg/core"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestRepositoriesOf(t *testing.T) {
+	assert.Empty(t, repositoriesOf(&v1alpha1.DevSpaceSpec{}))
+	assert.Empty(t, repositoriesOf(&v1alpha1.DevSpaceSpec{Repository: &v1alpha1.GitRepository{}}))
+
+	repos := repositoriesOf(&v1alpha1.DevSpaceSpec{
+		Repository: &v1alpha1.GitRepository{URL: "https://github.com/linuxsuren/kde"},
+		Repositories: []v1alpha1.GitRepository{
+			{URL: "https://github.com/linuxsuren/api-testing"},
+			{URL: ""},
+		},
+	})
+	if assert.Len(t, repos, 2) {
+		assert.Equal(t, "https://github.com/linuxsuren/kde", repos[0].URL)
+		assert.Equal(t, "https://github.com/linuxsuren/api-testing", repos[1].URL)
+	}
+}
+
+func TestGitRepository(t *testing.T) {
+	depth := int32(10)
+	tests := []struct {
+		name          string
+		repo          v1alpha1.GitRepository
+		expectDir     string
+		expectDepth   int32
+		expectCredKey string
+	}{{
+		name:          "https",
+		repo:          v1alpha1.GitRepository{URL: "https://github.com/linuxsuren/kde.git"},
+		expectDir:     "kde",
+		expectDepth:   1,
+		expectCredKey: "git-password-kde",
+	}, {
+		name:          "ssh",
+		repo:          v1alpha1.GitRepository{URL: "git@github.com:kde", Commit: "abc"},
+		expectDir:     "kde",
+		expectDepth:   0,
+		expectCredKey: "git-password-kde",
+	}, {
+		name:          "nested directory",
+		repo:          v1alpha1.GitRepository{URL: "https://github.com/linuxsuren/kde/", Directory: "/src/kde/", Depth: &depth},
+		expectDir:     "src/kde",
+		expectDepth:   10,
+		expectCredKey: "git-password-src.kde",
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expectDir, tt.repo.TargetDirectory())
+			assert.Equal(t, tt.expectDepth, tt.repo.CloneDepth())
+			assert.Equal(t, tt.expectCredKey, repositoryCredentialKey(tt.repo))
+		})
+	}
+}
+
+func TestPrepareRepositories(t *testing.T) {
+	devSpace := createDefaultGitPod()
+	devSpace.Spec.Repository = &v1alpha1.GitRepository{URL: "https://github.com/linuxsuren/kde"}
+	devSpace.Spec.Auth.KnownHosts = []string{"gitee.com ssh-ed25519 key"}
+	prepareRepositories(devSpace, &core.Config{KnownHosts: []string{"github.com ssh-ed25519 key"}})
+
+	assert.Nil(t, devSpace.Spec.Repository)
+	assert.Len(t, devSpace.Spec.Repositories, 1)
+	assert.Equal(t, []string{"github.com ssh-ed25519 key", "gitee.com ssh-ed25519 key"}, devSpace.Spec.Auth.KnownHosts)
+	assert.Equal(t, "GIT_PASSWORD_TOOLS_API_TESTING", gitPasswordEnv(v1alpha1.GitRepository{Directory: "tools/api-testing"}))
+}
+
+func TestCloneScriptRender(t *testing.T) {
+	devSpace := createDefaultGitPod()
+	devSpace.Spec.Repositories = []v1alpha1.GitRepository{{
+		URL:      "https://github.com/linuxsuren/kde",
+		Branch:   "main",
+		Username: "linuxsuren",
+	}, {
+		URL:        "https://github.com/linuxsuren/api-testing",
+		Tag:        "v0.0.1",
+		Directory:  "tools/atest",
+		Submodules: true,
+	}, {
+		URL:    "https://github.com/linuxsuren/http-downloader",
+		Commit: "abc",
+	}}
+
+	script := renderCloneScript(t, devSpace)
+	assert.Contains(t, script, `git clone 'https://github.com/linuxsuren/kde' 'kde' --branch 'main' --depth 1 \`)
+	assert.Contains(t, script, `git -C 'kde' config user.name 'linuxsuren'`)
+	assert.Contains(t, script, `git clone 'https://github.com/linuxsuren/api-testing' 'tools/atest' --branch 'v0.0.1' --depth 1 --recurse-submodules \`)
+	assert.Contains(t, script, `git clone 'https://github.com/linuxsuren/http-downloader' 'http-downloader' && git -C 'http-downloader' checkout 'abc' \`)
+	assert.Contains(t, script, "exit 1")
+	assert.NotContains(t, script, "| exit 0")
+	assert.NotContains(t, script, "credential.helper")
+
+	deploy, err := turnTemplateToUnstructured(gitpodDeployment, devSpace)
+	assert.NoError(t, err)
+	data, err := deploy.MarshalJSON()
+	assert.NoError(t, err)
+	deployment := &appsv1.Deployment{}
+	assert.NoError(t, json.Unmarshal(data, deployment))
+	assert.Contains(t, deployment.Spec.Template.Spec.InitContainers[0].Command[2], "bash /etc/kde/config/clone.sh")
+}
+
+func TestGitCredentialsRender(t *testing.T) {
+	devSpace := createDefaultGitPod()
+	devSpace.Spec.Auth.KnownHosts = []string{"github.com ssh-ed25519 key"}
+	devSpace.Spec.Auth.SSHPrivateKeySecretRef = &v1.SecretKeySelector{
+		LocalObjectReference: v1.LocalObjectReference{Name: "demo-credentials"}, Key: v1alpha1.CredentialKeySSHPrivateKey,
+	}
+	devSpace.Spec.Repositories = []v1alpha1.GitRepository{{
+		URL: "https://github.com/linuxsuren/kde",
+		PasswordSecretRef: &v1.SecretKeySelector{
+			LocalObjectReference: v1.LocalObjectReference{Name: "demo-credentials"}, Key: "git-password-kde",
+		},
+	}}
+
+	configmap, err := turnTemplateToUnstructured(gitpodConfigMap, devSpace)
+	assert.NoError(t, err)
+	data := configmap.Object["data"].(map[string]interface{})
+	assert.Equal(t, "github.com ssh-ed25519 key\n", data["known_hosts"])
+	assert.NotContains(t, data, "id_rsa")
+
+	deploy, err := turnTemplateToUnstructured(gitpodDeployment, devSpace)
+	assert.NoError(t, err)
+	deployData, err := deploy.MarshalJSON()
+	assert.NoError(t, err)
+	deployment := &appsv1.Deployment{}
+	assert.NoError(t, json.Unmarshal(deployData, deployment))
+
+	podSpec := deployment.Spec.Template.Spec
+	// both the init clone and the pushes from the IDE have the credentials
+	for _, container := range []v1.Container{podSpec.InitContainers[0], podSpec.Containers[0]} {
+		env := map[string]v1.EnvVar{}
+		for _, item := range container.Env {
+			env[item.Name] = item
+		}
+		assert.Contains(t, env["GIT_SSH_COMMAND"].Value, "StrictHostKeyChecking=yes -i /etc/kde/ssh/id_rsa", container.Name)
+		assert.Equal(t, "git-password-kde", env["GIT_PASSWORD_KDE"].ValueFrom.SecretKeyRef.Key, container.Name)
+
+		var mounted bool
+		for _, mount := range container.VolumeMounts {
+			mounted = mounted || (mount.Name == "ssh" && mount.MountPath == "/etc/kde/ssh")
+		}
+		assert.True(t, mounted, container.Name)
+	}
+	for _, volume := range podSpec.Volumes {
+		if volume.Name == "ssh" {
+			assert.Equal(t, "demo-credentials", volume.Projected.Sources[1].Secret.Name)
+			assert.Equal(t, "id_rsa", volume.Projected.Sources[1].Secret.Items[0].Path)
+		}
+	}
+}
+
+// TestCloneScript runs the clone script against a local git server which requires the basic auth
+func TestCloneScript(t *testing.T) {
+	gitPath, err := exec.LookPath("git")
+	if err != nil {
+		t.Skip("git is not found")
+	}
+
+	root := t.TempDir()
+	gitEnv := append(os.Environ(), "HOME="+root, "GIT_CONFIG_NOSYSTEM=1", "GIT_TERMINAL_PROMPT=0",
+		"GIT_AUTHOR_NAME=kde", "GIT_AUTHOR_EMAIL=kde@example.com", "GIT_COMMITTER_NAME=kde", "GIT_COMMITTER_EMAIL=kde@example.com")
+	run := func(dir string, env []string, name string, args ...string) (string, error) {
+		cmd := exec.Command(name, args...)
+		cmd.Dir = dir
+		cmd.Env = append(append([]string{}, gitEnv...), env...)
+		output, err := cmd.CombinedOutput()
+		return string(output), err
+	}
+
+	// prepare a repository on the server side
+	source := filepath.Join(root, "source")
+	for _, args := range [][]string{
+		{"init", "-b", "master", source},
+		{"-C", source, "commit", "--allow-empty", "-m", "init"},
+		{"clone", "--bare", source, filepath.Join(root, "server", "kde.git")},
+		{"-C", filepath.Join(root, "server", "kde.git"), "config", "http.receivepack", "true"},
+	} {
+		output, err := run(root, nil, gitPath, args...)
+		assert.NoError(t, err, output)
+	}
+
+	backend := &cgi.Handler{
+		Path: gitPath,
+		Args: []string{"http-backend"},
+		Env:  []string{"GIT_PROJECT_ROOT=" + filepath.Join(root, "server"), "GIT_HTTP_EXPORT_ALL=1"},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if username, password, ok := r.BasicAuth(); !ok || username != "linuxsuren" || password != "token" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="git"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		backend.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	devSpace := createDefaultGitPod()
+	devSpace.Spec.Repositories = []v1alpha1.GitRepository{{
+		URL:       server.URL + "/kde.git",
+		Directory: "src/kde",
+		Username:  "linuxsuren",
+		Email:     "linuxsuren@example.com",
+		PasswordSecretRef: &v1.SecretKeySelector{
+			LocalObjectReference: v1.LocalObjectReference{Name: "demo-credentials"}, Key: "git-password-src.kde",
+		},
+	}}
+	script := renderCloneScript(t, devSpace)
+	passwordEnv := gitPasswordEnv(devSpace.Spec.Repositories[0])
+
+	t.Run("wrong password", func(t *testing.T) {
+		workspace := t.TempDir()
+		output, err := run(workspace, []string{passwordEnv + "=wrong"}, "bash", "-c", script)
+		assert.Error(t, err, output)
+		assert.Contains(t, output, "failed to clone: "+server.URL+"/kde.git")
+		assert.NoDirExists(t, filepath.Join(workspace, "src", "kde", ".git"))
+	})
+
+	t.Run("clone and push", func(t *testing.T) {
+		workspace := t.TempDir()
+		env := []string{passwordEnv + "=token"}
+		output, err := run(workspace, env, "bash", "-c", script)
+		assert.NoError(t, err, output)
+		assert.DirExists(t, filepath.Join(workspace, "src", "kde", ".git"))
+
+		// run again with the existing repository
+		output, err = run(workspace, env, "bash", "-c", script)
+		assert.NoError(t, err, output)
+
+		// the IDE pushes without giving the credential again
+		repo := filepath.Join(workspace, "src", "kde")
+		output, err = run(repo, env, gitPath, "commit", "--allow-empty", "-m", "from the IDE")
+		assert.NoError(t, err, output)
+		output, err = run(repo, env, gitPath, "push", "origin", "HEAD")
+		assert.NoError(t, err, output)
+
+		output, err = run(repo, nil, gitPath, "config", "user.email")
+		assert.NoError(t, err, output)
+		assert.Equal(t, "linuxsuren@example.com", strings.TrimSpace(output))
+	})
+
+	t.Run("the URL is not evaluated by the shell", func(t *testing.T) {
+		workspace := t.TempDir()
+		devSpace := createDefaultGitPod()
+		devSpace.Spec.Repositories = []v1alpha1.GitRepository{{
+			URL:       filepath.Join(root, "missing") + "/$(touch injected)`touch injected`.git",
+			Directory: "missing",
+		}}
+		output, err := run(workspace, nil, "bash", "-c", renderCloneScript(t, devSpace))
+		assert.Error(t, err, output)
+		assert.Contains(t, output, "failed to clone: "+devSpace.Spec.Repositories[0].URL)
+		assert.NoFileExists(t, filepath.Join(workspace, "injected"))
+	})
+}
+
+func renderCloneScript(t *testing.T, devSpace *v1alpha1.DevSpace) string {
+	configmap, err := turnTemplateToUnstructured(gitpodConfigMap, devSpace)
+	assert.NoError(t, err)
+	script, _ := configmap.Object["data"].(map[string]interface{})["clone.sh"].(string)
+	return script
+}
@@ -0,0 +1,128 @@
+/*
+Copyright 2024 kde authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"maps"
+
+	"github.com/linuxsuren/kde/api/linuxsuren.github.io/v1alpha1"
+	"github.com/linuxsuren/kde/pkg/core"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// prepareResources gives the resources of the IDE and the enabled services which the templates render.
+// The CPU and Memory are the default limits of the IDE, the docker daemon has the same limits by default.
+// The missing requests are derived from the limits with the overcommit ratio of the config.
+func prepareResources(devSpace *v1alpha1.DevSpace, config *core.Config) {
+	var ratio float64
+	if config != nil {
+		ratio = config.OvercommitRatio
+	}
+	spec := &devSpace.Spec
+
+	ide := &v1.ResourceRequirements{Limits: v1.ResourceList{}}
+	for name, value := range map[v1.ResourceName]string{v1.ResourceCPU: spec.CPU, v1.ResourceMemory: spec.Memory} {
+		// the invalid ones are rejected by the webhook
+		if quantity, err := resource.ParseQuantity(value); err == nil {
+			ide.Limits[name] = quantity
+		}
+	}
+	if spec.Resources != nil {
+		maps.Copy(ide.Limits, spec.Resources.Limits)
+		ide.Requests = maps.Clone(spec.Resources.Requests)
+	}
+	spec.Resources = withRequests(ide, ratio)
+
+	if docker := spec.Services.Docker; docker != nil && docker.Resources == nil {
+		docker.Resources = &v1.ResourceRequirements{
+			Requests: v1.ResourceList{
+				v1.ResourceCPU:    resource.MustParse("50m"),
+				v1.ResourceMemory: resource.MustParse("50Mi"),
+			},
+			Limits: v1.ResourceList{},
+		}
+		for _, name := range []v1.ResourceName{v1.ResourceCPU, v1.ResourceMemory} {
+			if limit, ok := ide.Limits[name]; ok {
+				docker.Resources.Limits[name] = limit
+			}
+		}
+	}
+	for _, resources := range serviceResourcesOf(&spec.Services) {
+		*resources = withRequests(*resources, ratio)
+	}
+}
+
+// serviceResourcesOf returns the resources of the services which give them
+func serviceResourcesOf(services *v1alpha1.Services) (resources []**v1.ResourceRequirements) {
+	add := func(item **v1.ResourceRequirements) {
+		if *item != nil {
+			resources = append(resources, item)
+		}
+	}
+	if services.Docker != nil {
+		add(&services.Docker.Resources)
+	}
+	if services.Redis != nil {
+		add(&services.Redis.Resources)
+	}
+	if services.MySQL != nil {
+		add(&services.MySQL.Resources)
+	}
+	if services.MySQLUI != nil {
+		add(&services.MySQLUI.Resources)
+	}
+	if services.Postgres != nil {
+		add(&services.Postgres.Resources)
+	}
+	if services.TDEngine != nil {
+		add(&services.TDEngine.Resources)
+	}
+	if services.RabbitMQ != nil {
+		add(&services.RabbitMQ.Resources)
+	}
+	for i := range services.Custom {
+		add(&services.Custom[i].Resources)
+	}
+	return
+}
+
+// withRequests returns a copy of the resources which has the missing requests derived from the limits
+func withRequests(resources *v1.ResourceRequirements, ratio float64) *v1.ResourceRequirements {
+	resources = resources.DeepCopy()
+	for name, limit := range resources.Limits {
+		if _, ok := resources.Requests[name]; ok {
+			continue
+		}
+
+		if resources.Requests == nil {
+			resources.Requests = v1.ResourceList{}
+		}
+		resources.Requests[name] = overcommit(name, limit, ratio)
+	}
+	return resources
+}
+
+func overcommit(name v1.ResourceName, limit resource.Quantity, ratio float64) resource.Quantity {
+	if ratio <= 1 {
+		return limit
+	}
+	if name == v1.ResourceCPU {
+		return *resource.NewMilliQuantity(int64(float64(limit.MilliValue())/ratio), limit.Format)
+	}
+	return *resource.NewQuantity(int64(float64(limit.Value())/ratio), limit.Format)
+}
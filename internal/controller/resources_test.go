@@ -0,0 +1,160 @@
+/*
+Copyright 2024 kde authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/linuxsuren/kde/api/linuxsuren.github.io/v1alpha1"
+	"github.com/linuxsuren/kde/pkg/core"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/utils/ptr"
+)
+
+func TestPrepareResources(t *testing.T) {
+	list := func(items ...string) v1.ResourceList {
+		result := v1.ResourceList{}
+		for i := 0; i+1 < len(items); i += 2 {
+			result[v1.ResourceName(items[i])] = resource.MustParse(items[i+1])
+		}
+		return result
+	}
+
+	tests := []struct {
+		name     string
+		spec     v1alpha1.DevSpaceSpec
+		config   *core.Config
+		expect   *v1.ResourceRequirements
+		services func(*testing.T, v1alpha1.Services)
+	}{{
+		name:   "guaranteed by default",
+		spec:   v1alpha1.DevSpaceSpec{CPU: "2", Memory: "4Gi"},
+		expect: &v1.ResourceRequirements{Limits: list("cpu", "2", "memory", "4Gi"), Requests: list("cpu", "2", "memory", "4Gi")},
+	}, {
+		name:   "overcommit",
+		spec:   v1alpha1.DevSpaceSpec{CPU: "2", Memory: "4Gi"},
+		config: &core.Config{OvercommitRatio: 4},
+		expect: &v1.ResourceRequirements{Limits: list("cpu", "2", "memory", "4Gi"), Requests: list("cpu", "500m", "memory", "1Gi")},
+	}, {
+		name: "override the CPU and memory",
+		spec: v1alpha1.DevSpaceSpec{CPU: "2", Memory: "4Gi", Resources: &v1.ResourceRequirements{
+			Limits:   list("cpu", "8", "ephemeral-storage", "20Gi"),
+			Requests: list("memory", "2Gi"),
+		}},
+		config: &core.Config{OvercommitRatio: 4},
+		expect: &v1.ResourceRequirements{
+			Limits:   list("cpu", "8", "memory", "4Gi", "ephemeral-storage", "20Gi"),
+			Requests: list("cpu", "2", "memory", "2Gi", "ephemeral-storage", "5Gi"),
+		},
+	}, {
+		name: "services",
+		spec: v1alpha1.DevSpaceSpec{CPU: "2", Memory: "4Gi", Services: v1alpha1.Services{
+			Docker: &v1alpha1.Docker{Enabled: ptr.To(true)},
+			Redis:  &v1alpha1.Redis{Enabled: ptr.To(true)},
+			MySQL: &v1alpha1.MySQL{Enabled: ptr.To(true), Resources: &v1.ResourceRequirements{
+				Limits: list("cpu", "1", "memory", "1Gi"),
+			}},
+			Custom: []v1alpha1.CustomService{{Name: "clickhouse", Resources: &v1.ResourceRequirements{
+				Limits:   list("memory", "3Gi"),
+				Requests: list("cpu", "100m"),
+			}}},
+		}},
+		config: &core.Config{OvercommitRatio: 2},
+		expect: &v1.ResourceRequirements{Limits: list("cpu", "2", "memory", "4Gi"), Requests: list("cpu", "1", "memory", "2Gi")},
+		services: func(t *testing.T, services v1alpha1.Services) {
+			assertResources(t, &v1.ResourceRequirements{
+				Limits: list("cpu", "2", "memory", "4Gi"), Requests: list("cpu", "50m", "memory", "50Mi"),
+			}, services.Docker.Resources)
+			assert.Nil(t, services.Redis.Resources)
+			assertResources(t, &v1.ResourceRequirements{
+				Limits: list("cpu", "1", "memory", "1Gi"), Requests: list("cpu", "500m", "memory", "512Mi"),
+			}, services.MySQL.Resources)
+			assertResources(t, &v1.ResourceRequirements{
+				Limits: list("memory", "3Gi"), Requests: list("cpu", "100m", "memory", "1536Mi"),
+			}, services.Custom[0].Resources)
+		},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			devSpace := createDefaultGitPod()
+			devSpace.Spec = *tt.spec.DeepCopy()
+			prepareResources(devSpace, tt.config)
+
+			assertResources(t, tt.expect, devSpace.Spec.Resources)
+			if tt.services != nil {
+				tt.services(t, devSpace.Spec.Services)
+			}
+		})
+	}
+}
+
+// assertResources compares the quantities by their values instead of the formats
+func assertResources(t *testing.T, expect, actual *v1.ResourceRequirements) {
+	t.Helper()
+	if !assert.NotNil(t, actual) {
+		return
+	}
+	for _, pair := range []struct{ expect, actual v1.ResourceList }{
+		{expect.Limits, actual.Limits}, {expect.Requests, actual.Requests},
+	} {
+		if !assert.Len(t, pair.actual, len(pair.expect)) {
+			continue
+		}
+		for name, quantity := range pair.expect {
+			actualQuantity := pair.actual[name]
+			assert.Zero(t, quantity.Cmp(actualQuantity), "%s: expect %s, got %s", name, quantity.String(), actualQuantity.String())
+		}
+	}
+}
+
+func TestResourcesRender(t *testing.T) {
+	devSpace := createDefaultGitPod()
+	devSpace.Spec.CPU = "2"
+	devSpace.Spec.Memory = "4Gi"
+	devSpace.Spec.Services = v1alpha1.Services{
+		Docker: &v1alpha1.Docker{Enabled: ptr.To(true)},
+		Postgres: &v1alpha1.Postgres{Enabled: ptr.To(true), Resources: &v1.ResourceRequirements{
+			Limits: v1.ResourceList{v1.ResourceMemory: resource.MustParse("1Gi")},
+		}},
+		Redis: &v1alpha1.Redis{Enabled: ptr.To(true)},
+	}
+	prepareResources(devSpace, &core.Config{OvercommitRatio: 2})
+
+	deploy, err := turnTemplateToUnstructured(gitpodDeployment, devSpace)
+	assert.NoError(t, err)
+	data, err := deploy.MarshalJSON()
+	assert.NoError(t, err)
+	deployment := &appsv1.Deployment{}
+	assert.NoError(t, json.Unmarshal(data, deployment))
+
+	containers := map[string]v1.Container{}
+	for _, container := range deployment.Spec.Template.Spec.Containers {
+		containers[container.Name] = container
+	}
+	if assert.Len(t, containers, 4) {
+		server, docker, postgres := containers[ContainerNameServer], containers["docker"], containers["postgres"]
+		assert.Equal(t, "1", server.Resources.Requests.Cpu().String())
+		assert.Equal(t, "2", server.Resources.Limits.Cpu().String())
+		assert.Equal(t, "50m", docker.Resources.Requests.Cpu().String())
+		assert.Equal(t, "512Mi", postgres.Resources.Requests.Memory().String())
+		assert.Empty(t, containers["redis"].Resources)
+	}
+}
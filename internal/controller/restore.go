@@ -0,0 +1,93 @@
+/*
+Copyright 2024 kde authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	"github.com/linuxsuren/kde/api/linuxsuren.github.io/v1alpha1"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// restorePollInterval is the interval of checking the progress of restoring a DevSpace
+const restorePollInterval = 5 * time.Second
+
+// needsRestore returns true if the storage is not populated from the expected snapshot yet
+func needsRestore(devSpace *v1alpha1.DevSpace) bool {
+	restoreFrom := devSpace.Spec.RestoreFrom
+	if restoreFrom == "" || devSpace.Annotations[v1alpha1.AnnoKeyStorageTemporary] != "" {
+		return false
+	}
+	return devSpace.Status.Restore == nil || devSpace.Status.Restore.Snapshot != restoreFrom
+}
+
+// restore populates the storage from the DevSpaceSnapshot given in spec.restoreFrom.
+// Rolling back an existing DevSpace deletes its storage once all the pods are gone,
+// then a new one is created with the VolumeSnapshot as the data source.
+// It returns true if the storage should not be rendered yet.
+func (r *DevSpaceReconciler) restore(ctx context.Context, devSpace *v1alpha1.DevSpace) (restoring bool, err error) {
+	if !needsRestore(devSpace) {
+		return
+	}
+	restoring = true
+
+	snapshot := &v1alpha1.DevSpaceSnapshot{}
+	if err = r.Get(ctx, types.NamespacedName{Namespace: devSpace.Namespace, Name: devSpace.Spec.RestoreFrom}, snapshot); err != nil {
+		if apierrors.IsNotFound(err) {
+			r.Recorder.Eventf(devSpace, v1.EventTypeWarning, "Restore", "DevSpaceSnapshot %q not found", devSpace.Spec.RestoreFrom)
+			err = nil
+		}
+		return
+	}
+	if !snapshot.Status.ReadyToUse || snapshot.Status.VolumeSnapshotName == "" {
+		r.log.Info("waiting for the snapshot to be ready", "snapshot", snapshot.Name)
+		return
+	}
+
+	pvc := &v1.PersistentVolumeClaim{}
+	if err = r.Get(ctx, client.ObjectKeyFromObject(devSpace), pvc); err == nil {
+		// the storage is in use until all the pods are gone
+		podList := &v1.PodList{}
+		if err = r.List(ctx, podList, client.InNamespace(devSpace.Namespace), client.MatchingLabels{LabelApp: devSpace.Name}); err != nil || len(podList.Items) > 0 {
+			return
+		}
+
+		if pvc.DeletionTimestamp == nil {
+			r.Recorder.Eventf(devSpace, v1.EventTypeNormal, "Restore", "deleting the storage to restore from snapshot %q", snapshot.Name)
+			err = client.IgnoreNotFound(r.Delete(ctx, pvc))
+		}
+		return
+	} else if !apierrors.IsNotFound(err) {
+		return
+	}
+
+	devSpace.Status.Restore = &v1alpha1.DevSpaceRestoreStatus{
+		Snapshot:           snapshot.Name,
+		VolumeSnapshotName: snapshot.Status.VolumeSnapshotName,
+		RestoreTime:        metav1.Now(),
+	}
+	if err = r.Status().Update(ctx, devSpace); err == nil {
+		r.Recorder.Eventf(devSpace, v1.EventTypeNormal, "Restore", "restoring the storage from snapshot %q", snapshot.Name)
+		restoring = false
+	}
+	return
+}
@@ -0,0 +1,211 @@
+/*
+Copyright 2024 kde authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/linuxsuren/kde/api/linuxsuren.github.io/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func TestNeedsRestore(t *testing.T) {
+	tests := []struct {
+		name     string
+		devSpace *v1alpha1.DevSpace
+		expect   bool
+	}{{
+		name:     "no snapshot",
+		devSpace: &v1alpha1.DevSpace{},
+		expect:   false,
+	}, {
+		name: "not restored yet",
+		devSpace: &v1alpha1.DevSpace{
+			Spec: v1alpha1.DevSpaceSpec{RestoreFrom: "snapshot"},
+		},
+		expect: true,
+	}, {
+		name: "restored",
+		devSpace: &v1alpha1.DevSpace{
+			Spec:   v1alpha1.DevSpaceSpec{RestoreFrom: "snapshot"},
+			Status: v1alpha1.DevSpaceStatus{Restore: &v1alpha1.DevSpaceRestoreStatus{Snapshot: "snapshot"}},
+		},
+		expect: false,
+	}, {
+		name: "roll back to another snapshot",
+		devSpace: &v1alpha1.DevSpace{
+			Spec:   v1alpha1.DevSpaceSpec{RestoreFrom: "another"},
+			Status: v1alpha1.DevSpaceStatus{Restore: &v1alpha1.DevSpaceRestoreStatus{Snapshot: "snapshot"}},
+		},
+		expect: true,
+	}, {
+		name: "temporary storage",
+		devSpace: &v1alpha1.DevSpace{
+			ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{v1alpha1.AnnoKeyStorageTemporary: "true"}},
+			Spec:       v1alpha1.DevSpaceSpec{RestoreFrom: "snapshot"},
+		},
+		expect: false,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expect, needsRestore(tt.devSpace))
+		})
+	}
+}
+
+func TestRestore(t *testing.T) {
+	schema, err := v1alpha1.SchemeBuilder.Register().Build()
+	assert.NoError(t, err)
+	assert.NoError(t, v1.SchemeBuilder.AddToScheme(schema))
+	assert.NoError(t, appsv1.SchemeBuilder.AddToScheme(schema))
+	assert.NoError(t, networkingv1.SchemeBuilder.AddToScheme(schema))
+
+	devSpace := createDefaultGitPod()
+	devSpace.Spec.RestoreFrom = "demo-snapshot"
+	snapshot := &v1alpha1.DevSpaceSnapshot{
+		ObjectMeta: metav1.ObjectMeta{Name: "demo-snapshot", Namespace: "default"},
+		Spec:       v1alpha1.DevSpaceSnapshotSpec{DevSpace: "another"},
+		Status: v1alpha1.DevSpaceSnapshotStatus{
+			VolumeSnapshotName: "demo-snapshot",
+			ReadyToUse:         true,
+		},
+	}
+	notReadySnapshot := snapshot.DeepCopy()
+	notReadySnapshot.Status.ReadyToUse = false
+	pvc := &v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "demo", Namespace: "default"},
+	}
+	key := types.NamespacedName{Namespace: "default", Name: "demo"}
+
+	tests := []struct {
+		name            string
+		objects         []client.Object
+		expectRestoring bool
+		verify          func(*testing.T, client.Client)
+	}{{
+		name:            "snapshot not found",
+		objects:         []client.Object{devSpace.DeepCopy()},
+		expectRestoring: true,
+	}, {
+		name:            "snapshot is not ready",
+		objects:         []client.Object{devSpace.DeepCopy(), notReadySnapshot},
+		expectRestoring: true,
+	}, {
+		name:            "new DevSpace",
+		objects:         []client.Object{devSpace.DeepCopy(), snapshot.DeepCopy()},
+		expectRestoring: false,
+		verify: func(t *testing.T, c client.Client) {
+			result := &v1alpha1.DevSpace{}
+			assert.NoError(t, c.Get(context.TODO(), key, result))
+			if assert.NotNil(t, result.Status.Restore) {
+				assert.Equal(t, "demo-snapshot", result.Status.Restore.Snapshot)
+				assert.Equal(t, "demo-snapshot", result.Status.Restore.VolumeSnapshotName)
+			}
+		},
+	}, {
+		name:            "wait for the pods to be gone",
+		objects:         []client.Object{devSpace.DeepCopy(), snapshot.DeepCopy(), pvc.DeepCopy(), createDefaultPod()},
+		expectRestoring: true,
+		verify: func(t *testing.T, c client.Client) {
+			assert.NoError(t, c.Get(context.TODO(), key, &v1.PersistentVolumeClaim{}))
+		},
+	}, {
+		name:            "delete the storage",
+		objects:         []client.Object{devSpace.DeepCopy(), snapshot.DeepCopy(), pvc.DeepCopy()},
+		expectRestoring: true,
+		verify: func(t *testing.T, c client.Client) {
+			err := c.Get(context.TODO(), key, &v1.PersistentVolumeClaim{})
+			assert.True(t, apierrors.IsNotFound(err))
+		},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newFakeClientBuilder(schema).WithObjects(tt.objects...).
+				WithStatusSubresource(&v1alpha1.DevSpace{}).Build()
+			r := &DevSpaceReconciler{
+				Client:   c,
+				Recorder: record.NewFakeRecorder(10),
+				log:      log.FromContext(context.Background()),
+			}
+
+			target := &v1alpha1.DevSpace{}
+			assert.NoError(t, c.Get(context.TODO(), key, target))
+			restoring, err := r.restore(context.Background(), target)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectRestoring, restoring)
+			if tt.verify != nil {
+				tt.verify(t, c)
+			}
+		})
+	}
+
+	t.Run("render the storage from the snapshot", func(t *testing.T) {
+		c := newFakeClientBuilder(schema).WithObjects(devSpace.DeepCopy(), snapshot.DeepCopy()).
+			WithStatusSubresource(&v1alpha1.DevSpace{}).Build()
+		r := &DevSpaceReconciler{
+			Client:   c,
+			Recorder: record.NewFakeRecorder(10),
+		}
+		_, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+		assert.NoError(t, err)
+
+		pvc := &v1.PersistentVolumeClaim{}
+		assert.NoError(t, c.Get(context.TODO(), key, pvc))
+		if assert.NotNil(t, pvc.Spec.DataSource) {
+			assert.Equal(t, "VolumeSnapshot", pvc.Spec.DataSource.Kind)
+			assert.Equal(t, "demo-snapshot", pvc.Spec.DataSource.Name)
+		}
+	})
+
+	t.Run("roll back an existing DevSpace", func(t *testing.T) {
+		c := newFakeClientBuilder(schema).WithObjects(devSpace.DeepCopy(), snapshot.DeepCopy(), pvc.DeepCopy()).
+			WithStatusSubresource(&v1alpha1.DevSpace{}).Build()
+		r := &DevSpaceReconciler{
+			Client:   c,
+			Recorder: record.NewFakeRecorder(10),
+		}
+		result, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+		assert.NoError(t, err)
+		assert.Equal(t, restorePollInterval, result.RequeueAfter)
+
+		target := &v1alpha1.DevSpace{}
+		assert.NoError(t, c.Get(context.TODO(), key, target))
+		assert.Equal(t, v1alpha1.DevSpaceSuspendReasonRestoring, target.Status.SuspendReason)
+
+		deploy := &appsv1.Deployment{}
+		assert.NoError(t, c.Get(context.TODO(), key, deploy))
+		assert.Equal(t, int32(0), *deploy.Spec.Replicas)
+
+		// the storage is recreated from the snapshot in the next round
+		_, err = r.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+		assert.NoError(t, err)
+		pvc := &v1.PersistentVolumeClaim{}
+		assert.NoError(t, c.Get(context.TODO(), key, pvc))
+		assert.NotNil(t, pvc.Spec.DataSource)
+	})
+}
@@ -0,0 +1,62 @@
+/*
+Copyright 2024 kde authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/linuxsuren/kde/api/linuxsuren.github.io/v1alpha1"
+	"github.com/linuxsuren/kde/pkg/core"
+)
+
+// prepareScheduling fills the missing scheduling fields of the DevSpace with the defaults of its language,
+// which is found by the image, and then the global ones of the config. Each field is taken as a whole
+// from the first one which gives it.
+func prepareScheduling(devSpace *v1alpha1.DevSpace, config *core.Config) {
+	if config == nil {
+		return
+	}
+
+	var defaults []*v1alpha1.Scheduling
+	for _, language := range config.Languages {
+		if language.Scheduling != nil && strings.TrimSpace(language.Image) == devSpace.Spec.Image {
+			defaults = append(defaults, language.Scheduling)
+		}
+	}
+	defaults = append(defaults, &config.Scheduling)
+
+	scheduling := &devSpace.Spec.Scheduling
+	for _, item := range defaults {
+		if scheduling.NodeSelector == nil {
+			scheduling.NodeSelector = maps.Clone(item.NodeSelector)
+		}
+		if scheduling.Tolerations == nil {
+			scheduling.Tolerations = slices.Clone(item.Tolerations)
+		}
+		if scheduling.Affinity == nil {
+			scheduling.Affinity = item.Affinity.DeepCopy()
+		}
+		if scheduling.PriorityClassName == "" {
+			scheduling.PriorityClassName = item.PriorityClassName
+		}
+		if scheduling.TopologySpreadConstraints == nil {
+			scheduling.TopologySpreadConstraints = slices.Clone(item.TopologySpreadConstraints)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+/*
+Copyright 2024 kde authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/linuxsuren/kde/api/linuxsuren.github.io/v1alpha1"
+	"github.com/linuxsuren/kde/pkg/core"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestPrepareScheduling(t *testing.T) {
+	devPool := []v1.Toleration{{Key: "dedicated", Value: "dev", Effect: v1.TaintEffectNoSchedule}}
+	highCPU := &v1.Affinity{NodeAffinity: &v1.NodeAffinity{
+		RequiredDuringSchedulingIgnoredDuringExecution: &v1.NodeSelector{NodeSelectorTerms: []v1.NodeSelectorTerm{{
+			MatchExpressions: []v1.NodeSelectorRequirement{{Key: "node.example.com/cpu", Operator: v1.NodeSelectorOpIn, Values: []string{"high"}}},
+		}}},
+	}}
+	config := &core.Config{
+		Scheduling: v1alpha1.Scheduling{
+			NodeSelector:      map[string]string{"node.example.com/pool": "dev"},
+			Tolerations:       devPool,
+			PriorityClassName: "devspace",
+		},
+		Languages: []core.Language{{
+			Name: "golang", Image: "ide-golang",
+		}, {
+			Name: "rust", Image: " ide-rust ",
+			Scheduling: &v1alpha1.Scheduling{Affinity: highCPU, PriorityClassName: "devspace-high"},
+		}},
+	}
+
+	tests := []struct {
+		name       string
+		image      string
+		scheduling v1alpha1.Scheduling
+		config     *core.Config
+		expect     v1alpha1.Scheduling
+	}{{
+		name:  "without config",
+		image: "ide-rust",
+	}, {
+		name:   "global defaults",
+		image:  "ide-golang",
+		config: config,
+		expect: config.Scheduling,
+	}, {
+		name:   "language defaults",
+		image:  "ide-rust",
+		config: config,
+		expect: v1alpha1.Scheduling{
+			NodeSelector:      map[string]string{"node.example.com/pool": "dev"},
+			Tolerations:       devPool,
+			Affinity:          highCPU,
+			PriorityClassName: "devspace-high",
+		},
+	}, {
+		name:  "given by the DevSpace",
+		image: "ide-rust",
+		scheduling: v1alpha1.Scheduling{
+			NodeSelector:      map[string]string{"node.example.com/size": "large"},
+			Tolerations:       []v1.Toleration{},
+			PriorityClassName: "devspace-low",
+		},
+		config: config,
+		expect: v1alpha1.Scheduling{
+			NodeSelector:      map[string]string{"node.example.com/size": "large"},
+			Tolerations:       []v1.Toleration{},
+			Affinity:          highCPU,
+			PriorityClassName: "devspace-low",
+		},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			devSpace := createDefaultGitPod()
+			devSpace.Spec.Image = tt.image
+			devSpace.Spec.Scheduling = tt.scheduling
+			prepareScheduling(devSpace, tt.config)
+			assert.Equal(t, tt.expect, devSpace.Spec.Scheduling)
+		})
+	}
+}
+
+func TestSchedulingRender(t *testing.T) {
+	render := func(t *testing.T, devSpace *v1alpha1.DevSpace) v1.PodSpec {
+		deploy, err := turnTemplateToUnstructured(gitpodDeployment, devSpace)
+		assert.NoError(t, err)
+		data, err := deploy.MarshalJSON()
+		assert.NoError(t, err)
+		deployment := &appsv1.Deployment{}
+		assert.NoError(t, json.Unmarshal(data, deployment))
+		return deployment.Spec.Template.Spec
+	}
+
+	t.Run("default", func(t *testing.T) {
+		podSpec := render(t, createDefaultGitPod())
+		assert.Empty(t, podSpec.NodeSelector)
+		assert.Empty(t, podSpec.Tolerations)
+		assert.Empty(t, podSpec.PriorityClassName)
+		assert.Nil(t, podSpec.Affinity.NodeAffinity)
+		if assert.NotNil(t, podSpec.Affinity.PodAntiAffinity) {
+			assert.Len(t, podSpec.Affinity.PodAntiAffinity.PreferredDuringSchedulingIgnoredDuringExecution, 1)
+		}
+	})
+
+	t.Run("given", func(t *testing.T) {
+		devSpace := createDefaultGitPod()
+		devSpace.Spec.Scheduling = v1alpha1.Scheduling{
+			NodeSelector: map[string]string{"node.example.com/pool": "dev"},
+			Tolerations:  []v1.Toleration{{Key: "dedicated", Value: "dev", Effect: v1.TaintEffectNoSchedule}},
+			Affinity: &v1.Affinity{NodeAffinity: &v1.NodeAffinity{
+				PreferredDuringSchedulingIgnoredDuringExecution: []v1.PreferredSchedulingTerm{{
+					Weight: 10,
+					Preference: v1.NodeSelectorTerm{MatchExpressions: []v1.NodeSelectorRequirement{
+						{Key: "node.example.com/cpu", Operator: v1.NodeSelectorOpIn, Values: []string{"high"}},
+					}},
+				}},
+			}},
+			PriorityClassName: "devspace-high",
+			TopologySpreadConstraints: []v1.TopologySpreadConstraint{{
+				MaxSkew: 1, TopologyKey: "topology.kubernetes.io/zone", WhenUnsatisfiable: v1.ScheduleAnyway,
+			}},
+		}
+
+		podSpec := render(t, devSpace)
+		assert.Equal(t, devSpace.Spec.NodeSelector, podSpec.NodeSelector)
+		assert.Equal(t, devSpace.Spec.Tolerations, podSpec.Tolerations)
+		assert.Equal(t, "devspace-high", podSpec.PriorityClassName)
+		assert.Equal(t, devSpace.Spec.TopologySpreadConstraints, podSpec.TopologySpreadConstraints)
+		assert.Equal(t, devSpace.Spec.Affinity.NodeAffinity, podSpec.Affinity.NodeAffinity)
+		// the anti-affinity between the replicas is kept
+		assert.NotNil(t, podSpec.Affinity.PodAntiAffinity)
+
+		devSpace.Spec.Affinity.PodAntiAffinity = &v1.PodAntiAffinity{}
+		podSpec = render(t, devSpace)
+		assert.Empty(t, podSpec.Affinity.PodAntiAffinity.PreferredDuringSchedulingIgnoredDuringExecution)
+	})
+}
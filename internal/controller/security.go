@@ -0,0 +1,64 @@
+/*
+Copyright 2024 kde authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"github.com/linuxsuren/kde/api/linuxsuren.github.io/v1alpha1"
+	"github.com/linuxsuren/kde/pkg/core"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/utils/ptr"
+)
+
+// IncompatibleFeature is a feature of a DevSpace which its security profile does not allow
+type IncompatibleFeature struct {
+	Path   *field.Path
+	Reason string
+}
+
+// IncompatibleFeatures returns the features of the spec which cannot run under the given profile
+func IncompatibleFeatures(spec *v1alpha1.DevSpaceSpec, profile v1alpha1.SecurityProfile, specPath *field.Path) (features []IncompatibleFeature) {
+	if !profile.IsStricterThan(v1alpha1.SecurityProfilePrivileged) {
+		return
+	}
+	if spec.Services.Docker.IsEnabled() {
+		features = append(features, IncompatibleFeature{
+			Path: specPath.Child("services", "docker", "enabled"),
+			Reason: "Docker-in-Docker needs a privileged container, and a rootless BuildKit needs the unconfined seccomp and AppArmor profiles, " +
+				"the Pod Security Standards forbid both above the privileged level, disable it or choose the privileged profile",
+		})
+	}
+	return
+}
+
+// prepareSecurity takes the stricter one of the security profiles of the DevSpace and the config,
+// the features which the profile does not allow are disabled and returned
+func prepareSecurity(devSpace *v1alpha1.DevSpace, config *core.Config) (disabled []IncompatibleFeature) {
+	if config == nil {
+		config = &core.Config{}
+	}
+	spec := &devSpace.Spec
+	spec.SecurityProfile = spec.SecurityProfile.Stricter(config.SecurityProfile)
+
+	disabled = IncompatibleFeatures(spec, spec.SecurityProfile, field.NewPath("spec"))
+	// Docker is the only one which may be incompatible for now
+	if len(disabled) > 0 {
+		docker := *spec.Services.Docker
+		docker.Enabled = ptr.To(false)
+		spec.Services.Docker = &docker
+	}
+	return
+}
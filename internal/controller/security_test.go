@@ -0,0 +1,203 @@
+/*
+Copyright 2024 kde authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/linuxsuren/kde/api/linuxsuren.github.io/v1alpha1"
+	"github.com/linuxsuren/kde/pkg/core"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/utils/ptr"
+)
+
+func TestPrepareSecurity(t *testing.T) {
+	tests := []struct {
+		name     string
+		profile  v1alpha1.SecurityProfile
+		config   *core.Config
+		expect   v1alpha1.SecurityProfile
+		disabled []string
+	}{{
+		name:   "privileged by default",
+		expect: v1alpha1.SecurityProfilePrivileged,
+	}, {
+		name:    "given by the DevSpace",
+		profile: v1alpha1.SecurityProfileBaseline,
+		config:  &core.Config{},
+		expect:  v1alpha1.SecurityProfileBaseline,
+		disabled: []string{
+			"spec.services.docker.enabled",
+		},
+	}, {
+		name:    "stricter than the config",
+		profile: v1alpha1.SecurityProfileRestricted,
+		config:  &core.Config{SecurityProfile: v1alpha1.SecurityProfileBaseline},
+		expect:  v1alpha1.SecurityProfileRestricted,
+		disabled: []string{
+			"spec.services.docker.enabled",
+		},
+	}, {
+		name:    "required by the config",
+		profile: v1alpha1.SecurityProfilePrivileged,
+		config:  &core.Config{SecurityProfile: v1alpha1.SecurityProfileRestricted},
+		expect:  v1alpha1.SecurityProfileRestricted,
+		disabled: []string{
+			"spec.services.docker.enabled",
+		},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			devSpace := createDefaultGitPod()
+			devSpace.Spec.SecurityProfile = tt.profile
+			docker := &v1alpha1.Docker{Enabled: ptr.To(true)}
+			devSpace.Spec.Services.Docker = docker
+
+			var disabled []string
+			for _, feature := range prepareSecurity(devSpace, tt.config) {
+				disabled = append(disabled, feature.Path.String())
+			}
+			assert.Equal(t, tt.expect, devSpace.Spec.SecurityProfile)
+			assert.Equal(t, tt.disabled, disabled)
+			assert.Equal(t, len(tt.disabled) == 0, devSpace.Spec.Services.Docker.IsEnabled())
+			// the given one is kept as it is
+			assert.True(t, docker.IsEnabled())
+		})
+	}
+}
+
+func TestSecurityRender(t *testing.T) {
+	render := func(t *testing.T, profile v1alpha1.SecurityProfile) *appsv1.Deployment {
+		devSpace := createDefaultGitPod()
+		devSpace.Spec.SecurityProfile = profile
+		devSpace.Spec.Services = v1alpha1.Services{
+			Docker: &v1alpha1.Docker{Enabled: ptr.To(true)},
+			MySQL:  &v1alpha1.MySQL{Enabled: ptr.To(true)},
+		}
+		prepareSecurity(devSpace, nil)
+		deploy, err := turnTemplateToUnstructured(gitpodDeployment, devSpace)
+		assert.NoError(t, err)
+		data, err := deploy.MarshalJSON()
+		assert.NoError(t, err)
+		deployment := &appsv1.Deployment{}
+		assert.NoError(t, json.Unmarshal(data, deployment))
+		return deployment
+	}
+	containerNames := func(containers []v1.Container) (names []string) {
+		for _, container := range containers {
+			names = append(names, container.Name)
+		}
+		return
+	}
+
+	t.Run("privileged", func(t *testing.T) {
+		podSpec := render(t, "").Spec.Template.Spec
+		assert.Equal(t, []string{ContainerNameServer, "docker", "mysql"}, containerNames(podSpec.Containers))
+		assert.True(t, *podSpec.Containers[0].SecurityContext.Privileged)
+		assert.True(t, *podSpec.InitContainers[0].SecurityContext.Privileged)
+		assert.Contains(t, podSpec.InitContainers[0].Command[2], "chmod 1777 /tmp")
+		assert.False(t, *podSpec.SecurityContext.RunAsNonRoot)
+	})
+
+	t.Run("baseline", func(t *testing.T) {
+		deployment := render(t, v1alpha1.SecurityProfileBaseline)
+		podSpec := deployment.Spec.Template.Spec
+		assert.Equal(t, []string{ContainerNameServer, "mysql"}, containerNames(podSpec.Containers))
+		server := podSpec.Containers[0]
+		assert.Nil(t, server.SecurityContext.Privileged)
+		assert.Equal(t, int64(0), *server.SecurityContext.RunAsUser)
+		for _, mount := range server.VolumeMounts {
+			assert.NotEqual(t, "/var/lib/docker", mount.MountPath)
+		}
+		assert.Nil(t, podSpec.InitContainers[0].SecurityContext.Privileged)
+		assertPodSecurityBaseline(t, &deployment.Spec.Template)
+	})
+
+	t.Run("restricted", func(t *testing.T) {
+		deployment := render(t, v1alpha1.SecurityProfileRestricted)
+		podSpec := deployment.Spec.Template.Spec
+		assert.Equal(t, []string{ContainerNameServer, "mysql"}, containerNames(podSpec.Containers))
+		assert.True(t, *podSpec.SecurityContext.RunAsNonRoot)
+		assert.Equal(t, int64(1000), *podSpec.SecurityContext.RunAsUser)
+		assert.Equal(t, int64(1000), *podSpec.SecurityContext.FSGroup)
+		assert.Equal(t, v1.SeccompProfileTypeRuntimeDefault, podSpec.SecurityContext.SeccompProfile.Type)
+		assert.NotContains(t, podSpec.InitContainers[0].Command[2], "chmod")
+
+		for _, container := range append(podSpec.InitContainers, podSpec.Containers...) {
+			securityContext := container.SecurityContext
+			assert.Nil(t, securityContext.Privileged, container.Name)
+			assert.Nil(t, securityContext.RunAsUser, container.Name)
+			assert.False(t, *securityContext.AllowPrivilegeEscalation, container.Name)
+			assert.Equal(t, []v1.Capability{"ALL"}, securityContext.Capabilities.Drop, container.Name)
+		}
+		assertPodSecurityBaseline(t, &deployment.Spec.Template)
+	})
+}
+
+// assertPodSecurityBaseline checks the pod against the baseline level of the Pod Security Standards,
+// see https://kubernetes.io/docs/concepts/security/pod-security-standards/#baseline
+func assertPodSecurityBaseline(t *testing.T, pod *v1.PodTemplateSpec) {
+	t.Helper()
+	allowedCapabilities := []v1.Capability{"AUDIT_WRITE", "CHOWN", "DAC_OVERRIDE", "FOWNER", "FSETID", "KILL", "MKNOD",
+		"NET_BIND_SERVICE", "SETFCAP", "SETGID", "SETPCAP", "SETUID", "SYS_CHROOT"}
+	allowedSeccomp := func(profile *v1.SeccompProfile) bool {
+		return profile == nil || profile.Type != v1.SeccompProfileTypeUnconfined
+	}
+	allowedAppArmor := func(profile *v1.AppArmorProfile) bool {
+		return profile == nil || profile.Type != v1.AppArmorProfileTypeUnconfined
+	}
+
+	for key, value := range pod.Annotations {
+		if strings.HasPrefix(key, v1.DeprecatedAppArmorBetaContainerAnnotationKeyPrefix) {
+			assert.True(t, value == v1.DeprecatedAppArmorBetaProfileRuntimeDefault ||
+				strings.HasPrefix(value, v1.DeprecatedAppArmorBetaProfileNamePrefix), "AppArmor profile of %s: %s", key, value)
+		}
+	}
+	spec := pod.Spec
+	assert.False(t, spec.HostNetwork || spec.HostPID || spec.HostIPC, "host namespaces")
+	for _, volume := range spec.Volumes {
+		assert.Nil(t, volume.HostPath, "hostPath volume %s", volume.Name)
+	}
+	if podContext := spec.SecurityContext; podContext != nil {
+		assert.True(t, allowedSeccomp(podContext.SeccompProfile), "seccomp profile of the pod")
+		assert.True(t, allowedAppArmor(podContext.AppArmorProfile), "AppArmor profile of the pod")
+		assert.Empty(t, podContext.Sysctls, "sysctls of the pod")
+	}
+
+	for _, container := range append(spec.InitContainers, spec.Containers...) {
+		for _, port := range container.Ports {
+			assert.Zero(t, port.HostPort, "host port of %s", container.Name)
+		}
+		securityContext := container.SecurityContext
+		if securityContext == nil {
+			continue
+		}
+		assert.False(t, securityContext.Privileged != nil && *securityContext.Privileged, "privileged %s", container.Name)
+		assert.True(t, allowedSeccomp(securityContext.SeccompProfile), "seccomp profile of %s", container.Name)
+		assert.True(t, allowedAppArmor(securityContext.AppArmorProfile), "AppArmor profile of %s", container.Name)
+		assert.True(t, securityContext.ProcMount == nil || *securityContext.ProcMount == v1.DefaultProcMount, "proc mount of %s", container.Name)
+		if securityContext.Capabilities != nil {
+			for _, capability := range securityContext.Capabilities.Add {
+				assert.Contains(t, allowedCapabilities, capability, "capabilities of %s", container.Name)
+			}
+		}
+	}
+}
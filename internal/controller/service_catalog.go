@@ -0,0 +1,147 @@
+/*
+Copyright 2024 kde authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"path"
+	"slices"
+
+	"github.com/linuxsuren/kde/api/linuxsuren.github.io/v1alpha1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// serviceCatalog is the built-in catalog, each one is rendered as a custom service
+var serviceCatalog = []v1alpha1.CustomService{{
+	Name:           "mongodb",
+	Image:          "docker.io/library/mongo:7.0.14",
+	Ports:          []v1alpha1.ServicePort{{Name: "mongodb", Port: 27017}},
+	Persistence:    &v1alpha1.ServicePersistence{MountPath: "/data/db"},
+	ReadinessProbe: tcpProbe(27017),
+}, {
+	// a single node in the KRaft mode with the default config of the image
+	Name:           "kafka",
+	Image:          "docker.io/apache/kafka:3.8.0",
+	Ports:          []v1alpha1.ServicePort{{Name: "kafka", Port: 9092}},
+	Persistence:    &v1alpha1.ServicePersistence{MountPath: "/tmp/kraft-combined-logs"},
+	ReadinessProbe: tcpProbe(9092),
+}, {
+	Name:  "elasticsearch",
+	Image: "docker.elastic.co/elasticsearch/elasticsearch:8.15.0",
+	Env: []v1.EnvVar{
+		{Name: "discovery.type", Value: "single-node"},
+		{Name: "xpack.security.enabled", Value: "false"},
+		{Name: "ES_JAVA_OPTS", Value: "-Xms512m -Xmx512m"},
+	},
+	Ports:          []v1alpha1.ServicePort{{Name: "elasticsearch", Port: 9200}},
+	Persistence:    &v1alpha1.ServicePersistence{MountPath: "/usr/share/elasticsearch/data"},
+	ReadinessProbe: httpProbe("/_cluster/health?wait_for_status=yellow&timeout=1s", 9200),
+}, {
+	Name:  "minio",
+	Image: "quay.io/minio/minio:RELEASE.2024-08-17T01-24-54Z",
+	Args:  []string{"server", "/data", "--console-address", ":9001"},
+	Ports: []v1alpha1.ServicePort{
+		{Name: "minio", Port: 9000},
+		{Name: "minio-console", Port: 9001},
+	},
+	Persistence:    &v1alpha1.ServicePersistence{MountPath: "/data"},
+	ReadinessProbe: httpProbe("/minio/health/ready", 9000),
+}, {
+	Name:  "etcd",
+	Image: "quay.io/coreos/etcd:v3.5.15",
+	Command: []string{"etcd", "--data-dir=/var/lib/etcd",
+		"--listen-client-urls=http://0.0.0.0:2379", "--advertise-client-urls=http://localhost:2379"},
+	Ports:          []v1alpha1.ServicePort{{Name: "etcd", Port: 2379}},
+	Persistence:    &v1alpha1.ServicePersistence{MountPath: "/var/lib/etcd"},
+	ReadinessProbe: httpProbe("/health", 2379),
+}, {
+	Name:           "nginx",
+	Image:          "docker.io/library/nginx:1.27.1",
+	Ports:          []v1alpha1.ServicePort{{Name: "nginx", Port: 80}},
+	ReadinessProbe: httpProbe("/", 80),
+}}
+
+func tcpProbe(port int) *v1.Probe {
+	return &v1.Probe{ProbeHandler: v1.ProbeHandler{
+		TCPSocket: &v1.TCPSocketAction{Port: intstr.FromInt(port)},
+	}}
+}
+
+func httpProbe(endpoint string, port int) *v1.Probe {
+	return &v1.Probe{ProbeHandler: v1.ProbeHandler{
+		HTTPGet: &v1.HTTPGetAction{Path: endpoint, Port: intstr.FromInt(port)},
+	}}
+}
+
+// catalogServicesOf returns the catalog fields of the services by the names in the catalog
+func catalogServicesOf(services *v1alpha1.Services) map[string]*v1alpha1.CatalogService {
+	return map[string]*v1alpha1.CatalogService{
+		"mongodb":       services.MongoDB,
+		"kafka":         services.Kafka,
+		"elasticsearch": services.Elasticsearch,
+		"minio":         services.MinIO,
+		"etcd":          services.Etcd,
+		"nginx":         services.Nginx,
+	}
+}
+
+// builtinServiceNames are the container names of the DevSpace and its hard-coded services
+var builtinServiceNames = []string{"init", "server", "docker", "redis", "mysql", "mysqlUI", "postgres", "taos", "rabbitmq"}
+
+// IsReservedServiceName returns true if a custom service cannot have the name,
+// it is taken by the DevSpace itself or a built-in service
+func IsReservedServiceName(name string) bool {
+	return slices.Contains(builtinServiceNames, name) || slices.ContainsFunc(serviceCatalog, func(service v1alpha1.CustomService) bool {
+		return service.Name == name
+	})
+}
+
+// prepareServices puts the enabled services of the catalog in front of spec.services.custom, and gives
+// the default sub paths of the persistence under services/. The templates only handle the custom services.
+func prepareServices(devSpace *v1alpha1.DevSpace) {
+	var services []v1alpha1.CustomService
+	enabled := catalogServicesOf(&devSpace.Spec.Services)
+	for _, item := range serviceCatalog {
+		catalog := enabled[item.Name]
+		if !catalog.IsEnabled() {
+			continue
+		}
+
+		service := *item.DeepCopy()
+		if catalog.Image != "" {
+			service.Image = catalog.Image
+		}
+		service.Resources = catalog.Resources.DeepCopy()
+		for _, env := range catalog.Env {
+			if i := slices.IndexFunc(service.Env, func(e v1.EnvVar) bool { return e.Name == env.Name }); i >= 0 {
+				service.Env[i] = env
+			} else {
+				service.Env = append(service.Env, env)
+			}
+		}
+		services = append(services, service)
+	}
+
+	devSpace.Spec.Services.Custom = append(services, devSpace.Spec.Services.Custom...)
+	for i := range devSpace.Spec.Services.Custom {
+		service := &devSpace.Spec.Services.Custom[i]
+		if service.Persistence != nil && service.Persistence.SubPath == "" {
+			service.Persistence = service.Persistence.DeepCopy()
+			service.Persistence.SubPath = path.Join("services", service.Name)
+		}
+	}
+}
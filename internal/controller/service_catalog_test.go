@@ -0,0 +1,128 @@
+/*
+Copyright 2024 kde authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/linuxsuren/kde/api/linuxsuren.github.io/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/utils/ptr"
+)
+
+func TestPrepareServices(t *testing.T) {
+	devSpace := createDefaultGitPod()
+	prepareServices(devSpace)
+	assert.Empty(t, devSpace.Spec.Services.Custom)
+
+	custom := v1alpha1.CustomService{
+		Name:        "clickhouse",
+		Image:       "clickhouse/clickhouse-server:24.8",
+		Persistence: &v1alpha1.ServicePersistence{MountPath: "/var/lib/clickhouse"},
+	}
+	devSpace.Spec.Services = v1alpha1.Services{
+		Kafka:         &v1alpha1.CatalogService{},
+		MongoDB:       &v1alpha1.CatalogService{Enabled: ptr.To(true), Image: "mongo:6"},
+		Elasticsearch: &v1alpha1.CatalogService{Enabled: ptr.To(true), Env: []v1.EnvVar{{Name: "ES_JAVA_OPTS", Value: "-Xmx1g"}, {Name: "TZ", Value: "UTC"}}},
+		Custom:        []v1alpha1.CustomService{custom},
+	}
+	prepareServices(devSpace)
+
+	services := devSpace.Spec.Services.Custom
+	if assert.Len(t, services, 3) {
+		assert.Equal(t, "mongodb", services[0].Name)
+		assert.Equal(t, "mongo:6", services[0].Image)
+		assert.Equal(t, "services/mongodb", services[0].Persistence.SubPath)
+
+		assert.Equal(t, "elasticsearch", services[1].Name)
+		assert.Equal(t, []v1.EnvVar{
+			{Name: "discovery.type", Value: "single-node"},
+			{Name: "xpack.security.enabled", Value: "false"},
+			{Name: "ES_JAVA_OPTS", Value: "-Xmx1g"},
+			{Name: "TZ", Value: "UTC"},
+		}, services[1].Env)
+
+		assert.Equal(t, "clickhouse", services[2].Name)
+		assert.Equal(t, "services/clickhouse", services[2].Persistence.SubPath)
+	}
+	// the catalog is kept as it is
+	assert.Equal(t, "docker.io/library/mongo:7.0.14", serviceCatalog[0].Image)
+	assert.Empty(t, serviceCatalog[0].Persistence.SubPath)
+	assert.Len(t, serviceCatalog[2].Env, 3)
+	assert.Empty(t, custom.Persistence.SubPath)
+}
+
+func TestIsReservedServiceName(t *testing.T) {
+	assert.True(t, IsReservedServiceName("server"))
+	assert.True(t, IsReservedServiceName("mysql"))
+	assert.True(t, IsReservedServiceName("kafka"))
+	assert.False(t, IsReservedServiceName("clickhouse"))
+}
+
+func TestCustomServicesRender(t *testing.T) {
+	devSpace := createDefaultGitPod()
+	devSpace.Spec.Services = v1alpha1.Services{
+		Etcd: &v1alpha1.CatalogService{Enabled: ptr.To(true)},
+		Custom: []v1alpha1.CustomService{{
+			Name:    "clickhouse",
+			Image:   "clickhouse/clickhouse-server:24.8",
+			Command: []string{"/entrypoint.sh"},
+			Args:    []string{"--config", "/etc/clickhouse-server/config.xml"},
+			Env: []v1.EnvVar{{Name: "CLICKHOUSE_PASSWORD", ValueFrom: &v1.EnvVarSource{
+				SecretKeyRef: &v1.SecretKeySelector{LocalObjectReference: v1.LocalObjectReference{Name: "clickhouse"}, Key: "password"},
+			}}},
+			Ports:          []v1alpha1.ServicePort{{Name: "clickhouse", Port: 8123}, {Port: 9000}},
+			Persistence:    &v1alpha1.ServicePersistence{MountPath: "/var/lib/clickhouse", SubPath: "ch"},
+			ReadinessProbe: httpProbe("/ping", 8123),
+		}},
+	}
+	prepareServices(devSpace)
+
+	deploy, err := turnTemplateToUnstructured(gitpodDeployment, devSpace)
+	assert.NoError(t, err)
+	data, err := deploy.MarshalJSON()
+	assert.NoError(t, err)
+	deployment := &appsv1.Deployment{}
+	assert.NoError(t, json.Unmarshal(data, deployment))
+
+	containers := deployment.Spec.Template.Spec.Containers
+	if !assert.Len(t, containers, 3) {
+		return
+	}
+	etcd := containers[1]
+	assert.Equal(t, "etcd", etcd.Name)
+	assert.Equal(t, "quay.io/coreos/etcd:v3.5.15", etcd.Image)
+	assert.Equal(t, serviceCatalog[4].Command, etcd.Command)
+	assert.Equal(t, []v1.VolumeMount{{Name: "cache", MountPath: "/var/lib/etcd", SubPath: "services/etcd"}}, etcd.VolumeMounts)
+
+	clickhouse := containers[2]
+	assert.Equal(t, "clickhouse", clickhouse.Name)
+	assert.Equal(t, []string{"/entrypoint.sh"}, clickhouse.Command)
+	assert.Equal(t, []string{"--config", "/etc/clickhouse-server/config.xml"}, clickhouse.Args)
+	assert.Equal(t, devSpace.Spec.Services.Custom[1].Env, clickhouse.Env)
+	assert.Equal(t, []v1.ContainerPort{
+		{Name: "clickhouse", ContainerPort: 8123, Protocol: v1.ProtocolTCP},
+		{ContainerPort: 9000, Protocol: v1.ProtocolTCP},
+	}, clickhouse.Ports)
+	assert.Equal(t, intstr.FromInt(8123), clickhouse.ReadinessProbe.HTTPGet.Port)
+	assert.Equal(t, "/ping", clickhouse.ReadinessProbe.HTTPGet.Path)
+	assert.Equal(t, []v1.VolumeMount{{Name: "cache", MountPath: "/var/lib/clickhouse", SubPath: "ch"}}, clickhouse.VolumeMounts)
+}
@@ -0,0 +1,125 @@
+/*
+Copyright 2024 kde authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+
+	"github.com/linuxsuren/kde/api/linuxsuren.github.io/v1alpha1"
+	v1 "k8s.io/api/core/v1"
+)
+
+// servicesOf returns the enabled services of the DevSpace in the order of the containers, the services
+// share the network with the IDE container, so they are reachable via localhost
+func servicesOf(devSpace *v1alpha1.DevSpace) (services []v1alpha1.ServiceStatus) {
+	add := func(name, credentialsSecret string, ports ...int32) {
+		service := v1alpha1.ServiceStatus{Name: name, CredentialsSecret: credentialsSecret}
+		for _, port := range ports {
+			service.Endpoints = append(service.Endpoints, fmt.Sprintf("localhost:%d", port))
+		}
+		services = append(services, service)
+	}
+	// the generated passwords are in the credentials Secret of the DevSpace
+	credentialsSecret := func(ref *v1.SecretKeySelector) string {
+		if ref != nil {
+			return ref.Name
+		}
+		return v1alpha1.CredentialsSecretName(devSpace.Name)
+	}
+
+	builtin := devSpace.Spec.Services
+	if builtin.Docker.IsEnabled() {
+		add("docker", "", 2376)
+	}
+	if builtin.Redis.IsEnabled() {
+		add("redis", "", 6379)
+	}
+	if mysql := builtin.MySQL; mysql.IsEnabled() {
+		add("mysql", credentialsSecret(mysql.PasswordSecretRef), 3306)
+	}
+	if builtin.MySQLUI.IsEnabled() {
+		add("mysqlUI", "", 9000)
+	}
+	if postgres := builtin.Postgres; postgres.IsEnabled() {
+		add("postgres", credentialsSecret(postgres.PasswordSecretRef), 5432)
+	}
+	if builtin.TDEngine.IsEnabled() {
+		add("taos", "", 6030)
+	}
+	if rabbitMQ := builtin.RabbitMQ; rabbitMQ.IsEnabled() {
+		add("rabbitmq", credentialsSecret(rabbitMQ.PasswordSecretRef), 5672)
+	}
+
+	prepared := devSpace.DeepCopy()
+	prepareServices(prepared)
+	for _, custom := range prepared.Spec.Services.Custom {
+		var ports []int32
+		for _, port := range custom.Ports {
+			ports = append(ports, port.Port)
+		}
+		add(custom.Name, envSecretOf(custom.Env), ports...)
+	}
+	return
+}
+
+// envSecretOf returns the first Secret which the environment variables refer to
+func envSecretOf(env []v1.EnvVar) string {
+	for _, item := range env {
+		if item.ValueFrom != nil && item.ValueFrom.SecretKeyRef != nil {
+			return item.ValueFrom.SecretKeyRef.Name
+		}
+	}
+	return ""
+}
+
+// serviceContainerStatuses returns the status of the service containers in the pod,
+// they are the sidecar containers in the init containers when waiting for the services
+func serviceContainerStatuses(pod *v1.Pod) (statuses []v1.ContainerStatus) {
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.Name != ContainerNameServer {
+			statuses = append(statuses, status)
+		}
+	}
+	for _, container := range pod.Spec.InitContainers {
+		if container.RestartPolicy == nil || *container.RestartPolicy != v1.ContainerRestartPolicyAlways {
+			continue
+		}
+		for _, status := range pod.Status.InitContainerStatuses {
+			if status.Name == container.Name {
+				statuses = append(statuses, status)
+			}
+		}
+	}
+	return
+}
+
+// setServicesStatus records the enabled services along with their container status in the pod
+func setServicesStatus(status *v1alpha1.DevSpaceStatus, services []v1alpha1.ServiceStatus, pods []v1.Pod) {
+	var statuses []v1.ContainerStatus
+	if len(pods) > 0 {
+		statuses = serviceContainerStatuses(&pods[0])
+	}
+	for i := range services {
+		for _, containerStatus := range statuses {
+			if containerStatus.Name == services[i].Name {
+				services[i].Ready = containerStatus.Ready
+				services[i].RestartCount = containerStatus.RestartCount
+			}
+		}
+	}
+	status.Services = services
+}
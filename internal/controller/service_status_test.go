@@ -0,0 +1,161 @@
+/*
+Copyright 2024 kde authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/linuxsuren/kde/api/linuxsuren.github.io/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/utils/ptr"
+)
+
+func TestServicesOf(t *testing.T) {
+	devSpace := createDefaultGitPod()
+	assert.Empty(t, servicesOf(devSpace))
+
+	devSpace.Spec.Services = v1alpha1.Services{
+		Docker:   &v1alpha1.Docker{Enabled: ptr.To(true)},
+		Redis:    &v1alpha1.Redis{},
+		MySQL:    &v1alpha1.MySQL{Enabled: ptr.To(true)},
+		Postgres: &v1alpha1.Postgres{Enabled: ptr.To(true), PasswordSecretRef: &v1.SecretKeySelector{LocalObjectReference: v1.LocalObjectReference{Name: "postgres"}}},
+		MinIO:    &v1alpha1.CatalogService{Enabled: ptr.To(true)},
+		Custom: []v1alpha1.CustomService{{
+			Name:  "clickhouse",
+			Ports: []v1alpha1.ServicePort{{Port: 8123}},
+			Env: []v1.EnvVar{{Name: "CLICKHOUSE_USER", Value: "default"}, {Name: "CLICKHOUSE_PASSWORD", ValueFrom: &v1.EnvVarSource{
+				SecretKeyRef: &v1.SecretKeySelector{LocalObjectReference: v1.LocalObjectReference{Name: "clickhouse"}, Key: "password"},
+			}}},
+		}},
+	}
+	assert.Equal(t, []v1alpha1.ServiceStatus{
+		{Name: "docker", Endpoints: []string{"localhost:2376"}},
+		{Name: "mysql", Endpoints: []string{"localhost:3306"}, CredentialsSecret: "demo-credentials"},
+		{Name: "postgres", Endpoints: []string{"localhost:5432"}, CredentialsSecret: "postgres"},
+		{Name: "minio", Endpoints: []string{"localhost:9000", "localhost:9001"}},
+		{Name: "clickhouse", Endpoints: []string{"localhost:8123"}, CredentialsSecret: "clickhouse"},
+	}, servicesOf(devSpace))
+	// the spec is kept as it is
+	assert.Len(t, devSpace.Spec.Services.Custom, 1)
+}
+
+func TestSetServicesStatus(t *testing.T) {
+	always := v1.ContainerRestartPolicyAlways
+	services := func() []v1alpha1.ServiceStatus {
+		return []v1alpha1.ServiceStatus{{Name: "mysql"}, {Name: "redis"}}
+	}
+
+	tests := []struct {
+		name   string
+		pods   []v1.Pod
+		expect []v1alpha1.ServiceStatus
+	}{{
+		name:   "no pods",
+		expect: services(),
+	}, {
+		name: "containers",
+		pods: []v1.Pod{{
+			Status: v1.PodStatus{ContainerStatuses: []v1.ContainerStatus{
+				{Name: ContainerNameServer, Ready: true},
+				{Name: "mysql", Ready: true, RestartCount: 1},
+				{Name: "redis", RestartCount: 3},
+			}},
+		}},
+		expect: []v1alpha1.ServiceStatus{{Name: "mysql", Ready: true, RestartCount: 1}, {Name: "redis", RestartCount: 3}},
+	}, {
+		name: "sidecar containers",
+		pods: []v1.Pod{{
+			Spec: v1.PodSpec{InitContainers: []v1.Container{
+				{Name: "mysql", RestartPolicy: &always},
+				{Name: ContainerNameInit},
+			}},
+			Status: v1.PodStatus{
+				InitContainerStatuses: []v1.ContainerStatus{
+					{Name: "mysql", Ready: true},
+					{Name: ContainerNameInit, RestartCount: 2},
+				},
+				ContainerStatuses: []v1.ContainerStatus{{Name: ContainerNameServer}},
+			},
+		}},
+		expect: []v1alpha1.ServiceStatus{{Name: "mysql", Ready: true}, {Name: "redis"}},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status := &v1alpha1.DevSpaceStatus{}
+			setServicesStatus(status, services(), tt.pods)
+			assert.Equal(t, tt.expect, status.Services)
+		})
+	}
+}
+
+func TestWaitForServicesRender(t *testing.T) {
+	render := func(t *testing.T, devSpace *v1alpha1.DevSpace) *appsv1.Deployment {
+		prepareServices(devSpace)
+		deploy, err := turnTemplateToUnstructured(gitpodDeployment, devSpace)
+		assert.NoError(t, err)
+		data, err := deploy.MarshalJSON()
+		assert.NoError(t, err)
+		deployment := &appsv1.Deployment{}
+		assert.NoError(t, json.Unmarshal(data, deployment))
+		return deployment
+	}
+	devSpace := createDefaultGitPod()
+	devSpace.Spec.Services = v1alpha1.Services{
+		MySQL:  &v1alpha1.MySQL{Enabled: ptr.To(true)},
+		Custom: []v1alpha1.CustomService{{Name: "clickhouse", Image: "clickhouse", ReadinessProbe: httpProbe("/ping", 8123)}},
+	}
+
+	t.Run("without waiting", func(t *testing.T) {
+		podSpec := render(t, devSpace.DeepCopy()).Spec.Template.Spec
+		if assert.Len(t, podSpec.Containers, 3) {
+			mysql := podSpec.Containers[1]
+			assert.Equal(t, "mysql", mysql.Name)
+			assert.Equal(t, int32(3306), mysql.ReadinessProbe.TCPSocket.Port.IntVal)
+			assert.Nil(t, mysql.StartupProbe)
+			assert.Nil(t, mysql.RestartPolicy)
+		}
+		if assert.Len(t, podSpec.InitContainers, 1) {
+			assert.Equal(t, ContainerNameInit, podSpec.InitContainers[0].Name)
+		}
+	})
+
+	t.Run("wait for the services", func(t *testing.T) {
+		waiting := devSpace.DeepCopy()
+		waiting.Spec.Services.WaitForReady = ptr.To(true)
+		podSpec := render(t, waiting).Spec.Template.Spec
+		if assert.Len(t, podSpec.Containers, 1) {
+			assert.Equal(t, ContainerNameServer, podSpec.Containers[0].Name)
+		}
+		if !assert.Len(t, podSpec.InitContainers, 3) {
+			return
+		}
+		mysql, clickhouse := podSpec.InitContainers[0], podSpec.InitContainers[1]
+		assert.Equal(t, ContainerNameInit, podSpec.InitContainers[2].Name)
+
+		assert.Equal(t, "mysql", mysql.Name)
+		assert.Equal(t, v1.ContainerRestartPolicyAlways, *mysql.RestartPolicy)
+		assert.Equal(t, int32(3306), mysql.StartupProbe.TCPSocket.Port.IntVal)
+		assert.Equal(t, int32(3306), mysql.ReadinessProbe.TCPSocket.Port.IntVal)
+
+		assert.Equal(t, "clickhouse", clickhouse.Name)
+		assert.Equal(t, v1.ContainerRestartPolicyAlways, *clickhouse.RestartPolicy)
+		assert.Equal(t, "/ping", clickhouse.StartupProbe.HTTPGet.Path)
+	})
+}
@@ -26,9 +26,17 @@ import (
 	"k8s.io/apimachinery/pkg/util/yaml"
 )
 
+// templateFuncs are the functions of the object templates in addition to the sprig ones
+func templateFuncs() template.FuncMap {
+	funcs := sprig.FuncMap()
+	funcs["gitPasswordEnv"] = gitPasswordEnv
+	funcs["hostOf"] = hostOf
+	return funcs
+}
+
 func turnTemplateToUnstructured(tplText string, app interface{}) (result *unstructured.Unstructured, err error) {
 	var tpl *template.Template
-	if tpl, err = template.New("turnTemplateToUnstructured").Funcs(sprig.FuncMap()).Parse(tplText); err == nil {
+	if tpl, err = template.New("turnTemplateToUnstructured").Funcs(templateFuncs()).Parse(tplText); err == nil {
 		buf := new(bytes.Buffer)
 		if err = tpl.Execute(buf, app); err == nil {
 			if strings.TrimSpace(buf.String()) == "" {
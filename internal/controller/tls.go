@@ -0,0 +1,195 @@
+/*
+Copyright 2024 kde authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"maps"
+	"strings"
+
+	"github.com/linuxsuren/kde/api/linuxsuren.github.io/v1alpha1"
+	"github.com/linuxsuren/kde/pkg/core"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// linkOf returns the URL of the given host, it is https if the DevSpaces are served over TLS
+func linkOf(host string, config *core.Config) string {
+	return schemeLinkOf(host, config != nil && config.TLS.Enabled())
+}
+
+// devSpaceLinkOf returns the URL of the given host of the DevSpace, it is https only if the ingresses
+// of the DevSpace have the TLS, see prepareTLS and ensureTLSSecret
+func devSpaceLinkOf(host string, devSpace *v1alpha1.DevSpace) string {
+	var tls bool
+	for _, key := range tlsAnnotations {
+		tls = tls || devSpace.Annotations[key] != ""
+	}
+	return schemeLinkOf(host, tls)
+}
+
+func schemeLinkOf(host string, tls bool) string {
+	scheme := "http"
+	if tls {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s", scheme, host)
+}
+
+// hostOf returns the host of the given link, the links in the status of the DevSpaces which were
+// reconciled before having the schemes are hosts already
+func hostOf(link string) string {
+	if _, host, ok := strings.Cut(link, "://"); ok {
+		return host
+	}
+	return link
+}
+
+// tlsSecretName returns the name of the copy of the wildcard certificate Secret
+func tlsSecretName(devSpace string) string {
+	return devSpace + "-wildcard-tls"
+}
+
+// tlsAnnotations tell the templates where the certificates of the DevSpace come from
+var tlsAnnotations = []string{
+	v1alpha1.AnnoKeyTLSSecret, v1alpha1.AnnoKeyTLSIssuer, v1alpha1.AnnoKeyTLSClusterIssuer, v1alpha1.AnnoKeyTLSDefaultCertificate,
+}
+
+// prepareTLS tells the templates where the certificates of the DevSpace come from,
+// it comes after prepareIngress since the listeners of the Gateway serve the certificates of the gateway provider
+func prepareTLS(devSpace *v1alpha1.DevSpace, config *core.Config) {
+	for _, key := range tlsAnnotations {
+		delete(devSpace.Annotations, key)
+	}
+	if config == nil || !config.TLS.Enabled() {
+		return
+	}
+
+	switch tls := config.TLS; {
+	case ingressProviderOf(devSpace).Name == IngressProviderGateway:
+		devSpace.Annotations[v1alpha1.AnnoKeyTLSDefaultCertificate] = "true"
+	case tls.ClusterIssuer != "":
+		devSpace.Annotations[v1alpha1.AnnoKeyTLSClusterIssuer] = tls.ClusterIssuer
+	case tls.Issuer != "":
+		devSpace.Annotations[v1alpha1.AnnoKeyTLSIssuer] = tls.Issuer
+	case tls.DefaultCertificate:
+		devSpace.Annotations[v1alpha1.AnnoKeyTLSDefaultCertificate] = "true"
+	case tls.SecretName != "":
+		devSpace.Annotations[v1alpha1.AnnoKeyTLSSecret] = tlsSecretName(devSpace.Name)
+	}
+}
+
+// tlsAnnotationsOf returns the TLS annotations which are prepared by prepareTLS and ensureTLSSecret
+func tlsAnnotationsOf(devSpace *v1alpha1.DevSpace) map[string]string {
+	annotations := map[string]string{}
+	for _, key := range tlsAnnotations {
+		if value, ok := devSpace.Annotations[key]; ok {
+			annotations[key] = value
+		}
+	}
+	return annotations
+}
+
+// setTLSAnnotations sets the prepared TLS annotations, see tlsAnnotationsOf
+func setTLSAnnotations(devSpace *v1alpha1.DevSpace, annotations map[string]string) {
+	for _, key := range tlsAnnotations {
+		delete(devSpace.Annotations, key)
+	}
+	maps.Copy(devSpace.Annotations, annotations)
+}
+
+// ensureTLSSecret copies the wildcard certificate Secret into the namespace of the DevSpace,
+// since an Ingress can only refer to the Secrets in its own namespace. The copy is deleted once
+// it is not needed. The DevSpace is served without TLS if the wildcard certificate is missing.
+func (r *DevSpaceReconciler) ensureTLSSecret(ctx context.Context, devSpace *v1alpha1.DevSpace, config *core.Config) (err error) {
+	secret := &v1.Secret{}
+	secretKey := types.NamespacedName{Namespace: devSpace.Namespace, Name: tlsSecretName(devSpace.Name)}
+	if devSpace.Annotations[v1alpha1.AnnoKeyTLSSecret] == "" {
+		secret.SetName(secretKey.Name)
+		secret.SetNamespace(secretKey.Namespace)
+		err = client.IgnoreNotFound(r.Delete(ctx, secret))
+		return
+	}
+
+	if !devSpace.DeletionTimestamp.IsZero() {
+		// the copy goes along with the DevSpace
+		return
+	}
+
+	source := &v1.Secret{}
+	if err = r.Get(ctx, types.NamespacedName{Namespace: r.SystemNamespace, Name: config.TLS.SecretName}, source); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return
+		}
+		r.Recorder.Eventf(devSpace, v1.EventTypeWarning, "TLS", "the wildcard certificate %q is not found", config.TLS.SecretName)
+		delete(devSpace.Annotations, v1alpha1.AnnoKeyTLSSecret)
+		err = nil
+		return
+	}
+
+	if err = r.Get(ctx, secretKey, secret); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return
+		}
+		secret = &v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      secretKey.Name,
+				Namespace: secretKey.Namespace,
+				Labels: map[string]string{
+					LabelAppKind: "devspace",
+					LabelApp:     devSpace.Name,
+				},
+				OwnerReferences: []metav1.OwnerReference{
+					*metav1.NewControllerRef(devSpace, v1alpha1.GroupVersion.WithKind("DevSpace")),
+				},
+			},
+			Type: source.Type,
+			Data: maps.Clone(source.Data),
+		}
+		err = r.Create(ctx, secret)
+	} else if !maps.EqualFunc(secret.Data, source.Data, bytes.Equal) {
+		secret.Data = maps.Clone(source.Data)
+		err = r.Update(ctx, secret)
+	}
+	return
+}
+
+// findDevSpacesForTLSSecret reconciles all the DevSpaces once the wildcard certificate is changed,
+// e.g. renewed, so that the copies are refreshed
+func (r *DevSpaceReconciler) findDevSpacesForTLSSecret(ctx context.Context, secret client.Object) (requests []reconcile.Request) {
+	if secret.GetNamespace() != r.SystemNamespace {
+		return
+	}
+	if config := r.loadConfig(ctx); config.TLS.SecretName != secret.GetName() {
+		return
+	}
+
+	devSpaceList := &v1alpha1.DevSpaceList{}
+	if err := r.List(ctx, devSpaceList); err != nil {
+		return
+	}
+	for _, devSpace := range devSpaceList.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&devSpace)})
+	}
+	return
+}
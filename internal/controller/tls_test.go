@@ -0,0 +1,251 @@
+/*
+Copyright 2024 kde authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/linuxsuren/kde/api/linuxsuren.github.io/v1alpha1"
+	"github.com/linuxsuren/kde/pkg/core"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestHostOf(t *testing.T) {
+	assert.Equal(t, "demo.kde.example.com", hostOf("https://demo.kde.example.com"))
+	assert.Equal(t, "demo.kde.example.com", hostOf("http://demo.kde.example.com"))
+	assert.Equal(t, "demo.kde.example.com", hostOf("demo.kde.example.com"))
+}
+
+func TestDevSpaceLinkOf(t *testing.T) {
+	devSpace := createDefaultGitPod()
+	assert.Equal(t, "http://demo.kde.example.com", devSpaceLinkOf("demo.kde.example.com", devSpace))
+
+	// the wildcard certificate is missing
+	prepareTLS(devSpace, &core.Config{TLS: core.TLS{SecretName: "wildcard"}})
+	delete(devSpace.Annotations, v1alpha1.AnnoKeyTLSSecret)
+	assert.Equal(t, "http://demo.kde.example.com", devSpaceLinkOf("demo.kde.example.com", devSpace))
+
+	for _, tls := range []core.TLS{{SecretName: "wildcard"}, {DefaultCertificate: true}, {ClusterIssuer: "letsencrypt"}} {
+		prepareTLS(devSpace, &core.Config{TLS: tls})
+		assert.Equal(t, "https://demo.kde.example.com", devSpaceLinkOf("demo.kde.example.com", devSpace), tls)
+	}
+}
+
+func TestPrepareTLS(t *testing.T) {
+	tests := []struct {
+		name     string
+		tls      *core.TLS
+		provider string
+		expect   map[string]string
+	}{{
+		name:   "without config",
+		expect: map[string]string{},
+	}, {
+		name:   "wildcard secret",
+		tls:    &core.TLS{SecretName: "wildcard"},
+		expect: map[string]string{v1alpha1.AnnoKeyTLSSecret: "demo-wildcard-tls"},
+	}, {
+		name:   "cert-manager issuer",
+		tls:    &core.TLS{SecretName: "wildcard", Issuer: "letsencrypt"},
+		expect: map[string]string{v1alpha1.AnnoKeyTLSIssuer: "letsencrypt"},
+	}, {
+		name:   "cert-manager cluster issuer",
+		tls:    &core.TLS{Issuer: "letsencrypt", ClusterIssuer: "letsencrypt-prod"},
+		expect: map[string]string{v1alpha1.AnnoKeyTLSClusterIssuer: "letsencrypt-prod"},
+	}, {
+		name:   "default certificate",
+		tls:    &core.TLS{SecretName: "wildcard", DefaultCertificate: true},
+		expect: map[string]string{v1alpha1.AnnoKeyTLSDefaultCertificate: "true"},
+	}, {
+		name:     "the listeners of the Gateway",
+		tls:      &core.TLS{Issuer: "letsencrypt"},
+		provider: IngressProviderGateway,
+		expect:   map[string]string{v1alpha1.AnnoKeyTLSDefaultCertificate: "true"},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			devSpace := createDefaultGitPod()
+			devSpace.Annotations[v1alpha1.AnnoKeyTLSSecret] = "stale"
+			var config *core.Config
+			if tt.tls != nil {
+				config = &core.Config{TLS: *tt.tls, IngressProvider: tt.provider}
+			}
+			prepareIngress(devSpace, config)
+			prepareTLS(devSpace, config)
+			assert.Equal(t, tt.expect, tlsAnnotationsOf(devSpace))
+		})
+	}
+}
+
+func TestTLSRender(t *testing.T) {
+	render := func(t *testing.T, provider string, tls core.TLS, name string) *networkingv1.Ingress {
+		devSpace := createDefaultGitPod()
+		config := &core.Config{IngressProvider: provider, TLS: tls}
+		setServiceAnnotations(devSpace, "kde-system")
+		prepareIngress(devSpace, config)
+		prepareTLS(devSpace, config)
+		devSpace.Status.Link = devSpaceLinkOf("demo.gitpod.linuxsuren.github.io", devSpace)
+		for i, link := range devSpace.Status.ExposeLinks {
+			devSpace.Status.ExposeLinks[i].Link = devSpaceLinkOf(link.Link, devSpace)
+		}
+		obj, err := turnTemplateToUnstructured(builtinTemplate(name, devSpace), devSpace)
+		assert.NoError(t, err)
+		objs := flattenList(obj)
+		data, err := objs[len(objs)-1].MarshalJSON()
+		assert.NoError(t, err)
+		ingress := &networkingv1.Ingress{}
+		assert.NoError(t, json.Unmarshal(data, ingress))
+		return ingress
+	}
+
+	t.Run("without TLS", func(t *testing.T) {
+		ingress := render(t, IngressProviderNginx, core.TLS{}, TemplateIngress)
+		assert.Empty(t, ingress.Spec.TLS)
+		assert.Equal(t, "demo.gitpod.linuxsuren.github.io", ingress.Spec.Rules[0].Host)
+	})
+
+	t.Run("wildcard secret", func(t *testing.T) {
+		ingress := render(t, IngressProviderNginx, core.TLS{SecretName: "wildcard"}, TemplateIngress)
+		assert.Equal(t, []networkingv1.IngressTLS{{
+			Hosts:      []string{"demo.gitpod.linuxsuren.github.io"},
+			SecretName: "demo-wildcard-tls",
+		}}, ingress.Spec.TLS)
+		assert.Equal(t, "demo.gitpod.linuxsuren.github.io", ingress.Spec.Rules[0].Host)
+		assert.NotContains(t, ingress.Annotations, "cert-manager.io/cluster-issuer")
+	})
+
+	t.Run("default certificate", func(t *testing.T) {
+		for _, provider := range []string{IngressProviderNginx, IngressProviderTraefik} {
+			ingress := render(t, provider, core.TLS{SecretName: "wildcard", DefaultCertificate: true}, TemplateIngress)
+			assert.Equal(t, []networkingv1.IngressTLS{{
+				Hosts: []string{"demo.gitpod.linuxsuren.github.io"},
+			}}, ingress.Spec.TLS, provider)
+
+			ingress = render(t, provider, core.TLS{DefaultCertificate: true}, TemplateExposeIngress)
+			assert.Equal(t, []networkingv1.IngressTLS{{
+				Hosts: []string{"8080.demo.gitpod.linuxsuren.github.io", "9090.demo.gitpod.linuxsuren.github.io"},
+			}}, ingress.Spec.TLS, provider)
+		}
+	})
+
+	t.Run("cert-manager by traefik", func(t *testing.T) {
+		ingress := render(t, IngressProviderTraefik, core.TLS{ClusterIssuer: "letsencrypt"}, TemplateIngress)
+		assert.Equal(t, "letsencrypt", ingress.Annotations["cert-manager.io/cluster-issuer"])
+		assert.Equal(t, []networkingv1.IngressTLS{{
+			Hosts:      []string{"demo.gitpod.linuxsuren.github.io"},
+			SecretName: "demo-tls",
+		}}, ingress.Spec.TLS)
+	})
+
+	t.Run("exposed ports", func(t *testing.T) {
+		for _, provider := range []string{IngressProviderNginx, IngressProviderTraefik} {
+			ingress := render(t, provider, core.TLS{Issuer: "letsencrypt"}, TemplateExposeIngress)
+			assert.Equal(t, "letsencrypt", ingress.Annotations["cert-manager.io/issuer"], provider)
+			assert.Equal(t, []networkingv1.IngressTLS{{
+				Hosts:      []string{"8080.demo.gitpod.linuxsuren.github.io", "9090.demo.gitpod.linuxsuren.github.io"},
+				SecretName: "demo-expose-tls",
+			}}, ingress.Spec.TLS, provider)
+			assert.Equal(t, "9090.demo.gitpod.linuxsuren.github.io", ingress.Spec.Rules[1].Host, provider)
+		}
+	})
+}
+
+func TestEnsureTLSSecret(t *testing.T) {
+	schema, err := v1alpha1.SchemeBuilder.Register().Build()
+	assert.NoError(t, err)
+	assert.NoError(t, v1.AddToScheme(schema))
+
+	config := &core.Config{TLS: core.TLS{SecretName: "wildcard"}}
+	wildcard := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "wildcard", Namespace: "kde-system"},
+		Type:       v1.SecretTypeTLS,
+		Data:       map[string][]byte{"tls.crt": []byte("crt"), "tls.key": []byte("key")},
+	}
+	copyKey := types.NamespacedName{Namespace: "default", Name: "demo-wildcard-tls"}
+
+	t.Run("copy and refresh", func(t *testing.T) {
+		reconciler := &DevSpaceReconciler{
+			Client:          newFakeClientBuilder(schema).WithObjects(wildcard.DeepCopy()).Build(),
+			SystemNamespace: "kde-system",
+		}
+		devSpace := createDefaultGitPod()
+		prepareTLS(devSpace, config)
+		assert.NoError(t, reconciler.ensureTLSSecret(context.Background(), devSpace, config))
+
+		copied := &v1.Secret{}
+		assert.NoError(t, reconciler.Get(context.Background(), copyKey, copied))
+		assert.Equal(t, v1.SecretTypeTLS, copied.Type)
+		assert.Equal(t, wildcard.Data, copied.Data)
+		assert.Equal(t, "demo", copied.OwnerReferences[0].Name)
+
+		// the renewed certificate
+		renewed := wildcard.DeepCopy()
+		assert.NoError(t, reconciler.Get(context.Background(), client.ObjectKeyFromObject(renewed), renewed))
+		renewed.Data["tls.crt"] = []byte("renewed")
+		assert.NoError(t, reconciler.Update(context.Background(), renewed))
+		assert.NoError(t, reconciler.ensureTLSSecret(context.Background(), devSpace, config))
+		assert.NoError(t, reconciler.Get(context.Background(), copyKey, copied))
+		assert.Equal(t, "renewed", string(copied.Data["tls.crt"]))
+
+		// not needed anymore
+		prepareTLS(devSpace, nil)
+		assert.NoError(t, reconciler.ensureTLSSecret(context.Background(), devSpace, nil))
+		assert.True(t, apierrors.IsNotFound(reconciler.Get(context.Background(), copyKey, copied)))
+	})
+
+	t.Run("find the DevSpaces once the wildcard certificate is changed", func(t *testing.T) {
+		configMap := &v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "config", Namespace: "kde-system"},
+			Data:       map[string]string{core.ConfigFileName: `{"tls": {"secretName": "wildcard"}}`},
+		}
+		reconciler := &DevSpaceReconciler{
+			Client:          newFakeClientBuilder(schema).WithObjects(configMap, createDefaultGitPod()).Build(),
+			SystemNamespace: "kde-system",
+		}
+		assert.Len(t, reconciler.findDevSpacesForTLSSecret(context.Background(), wildcard.DeepCopy()), 1)
+
+		another := wildcard.DeepCopy()
+		another.Name = "another"
+		assert.Empty(t, reconciler.findDevSpacesForTLSSecret(context.Background(), another))
+		another = wildcard.DeepCopy()
+		another.Namespace = "default"
+		assert.Empty(t, reconciler.findDevSpacesForTLSSecret(context.Background(), another))
+	})
+
+	t.Run("the wildcard certificate is missing", func(t *testing.T) {
+		recorder := record.NewFakeRecorder(10)
+		reconciler := &DevSpaceReconciler{
+			Client:          newFakeClientBuilder(schema).Build(),
+			SystemNamespace: "kde-system",
+			Recorder:        recorder,
+		}
+		devSpace := createDefaultGitPod()
+		prepareTLS(devSpace, config)
+		assert.NoError(t, reconciler.ensureTLSSecret(context.Background(), devSpace, config))
+		assert.NotContains(t, devSpace.Annotations, v1alpha1.AnnoKeyTLSSecret)
+		assert.Contains(t, <-recorder.Events, `the wildcard certificate "wildcard" is not found`)
+	})
+}
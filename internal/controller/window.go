@@ -0,0 +1,174 @@
+/*
+Copyright 2024 kde authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/linuxsuren/kde/api/linuxsuren.github.io/v1alpha1"
+)
+
+var weekdays = map[v1alpha1.Weekday]time.Weekday{
+	"Sun": time.Sunday,
+	"Mon": time.Monday,
+	"Tue": time.Tuesday,
+	"Wed": time.Wednesday,
+	"Thu": time.Thursday,
+	"Fri": time.Friday,
+	"Sat": time.Saturday,
+}
+
+// windowOccurrence is a concrete time range of a window
+type windowOccurrence struct {
+	from, to time.Time
+}
+
+func (o windowOccurrence) contains(specifyTime time.Time) bool {
+	return !specifyTime.Before(o.from) && specifyTime.Before(o.to)
+}
+
+func isInAliveWindows(specifyTime time.Time, windows []v1alpha1.Window) (hasWin, ok bool, err error) {
+	ok = true
+	hasWin = len(windows) > 0
+	if hasWin {
+		for _, win := range windows {
+			occurrences, wErr := getWindowOccurrences(specifyTime, win)
+			if wErr != nil {
+				err = errors.Join(err, wErr)
+				continue
+			}
+
+			for _, occurrence := range occurrences {
+				if occurrence.contains(specifyTime) {
+					return
+				}
+			}
+		}
+	}
+	ok = false
+	return
+}
+
+// nextWindowBoundary returns the closest time after the specified time at which any window opens or closes
+func nextWindowBoundary(specifyTime time.Time, windows []v1alpha1.Window) (next time.Time, found bool) {
+	for _, win := range windows {
+		occurrences, err := getWindowOccurrences(specifyTime, win)
+		if err != nil {
+			continue
+		}
+
+		for _, occurrence := range occurrences {
+			for _, boundary := range []time.Time{occurrence.from, occurrence.to} {
+				if boundary.After(specifyTime) && (!found || boundary.Before(next)) {
+					next = boundary
+					found = true
+				}
+			}
+		}
+	}
+	return
+}
+
+// getWindowOccurrences returns the occurrences of a window from the day before
+// the specified time to one week later, in the time zone of the window
+func getWindowOccurrences(specifyTime time.Time, win v1alpha1.Window) (occurrences []windowOccurrence, err error) {
+	location := time.Local
+	if win.TimeZone != "" {
+		if location, err = time.LoadLocation(win.TimeZone); err != nil {
+			err = fmt.Errorf("failed to load window time zone: %q, error: %v", win.TimeZone, err)
+			return
+		}
+	}
+
+	var from, to time.Time
+	if from, err = parseClock(win.From); err != nil {
+		err = fmt.Errorf("failed to parse window from time: %q, error: %v", win.From, err)
+		return
+	}
+	if to, err = parseClock(win.To); err != nil {
+		err = fmt.Errorf("failed to parse window to time: %q, error: %v", win.To, err)
+		return
+	}
+
+	days := make(map[time.Weekday]bool, len(win.Days))
+	for _, day := range win.Days {
+		weekday, ok := weekdays[day]
+		if !ok {
+			err = fmt.Errorf("invalid window day: %q", day)
+			return
+		}
+		days[weekday] = true
+	}
+
+	local := specifyTime.In(location)
+	for offset := -1; offset <= 7; offset++ {
+		year, month, day := local.Year(), local.Month(), local.Day()+offset
+		start := time.Date(year, month, day, from.Hour(), from.Minute(), from.Second(), 0, location)
+		if len(days) > 0 && !days[start.Weekday()] {
+			continue
+		}
+
+		end := time.Date(year, month, day, to.Hour(), to.Minute(), to.Second(), 0, location)
+		if !end.After(start) {
+			// cross midnight
+			end = time.Date(year, month, day+1, to.Hour(), to.Minute(), to.Second(), 0, location)
+		}
+		occurrences = append(occurrences, windowOccurrence{from: start, to: end})
+	}
+	return
+}
+
+// ValidateWindow checks the clocks, days and time zone of a window
+func ValidateWindow(win v1alpha1.Window) (err error) {
+	_, err = getWindowOccurrences(time.Now(), win)
+	return
+}
+
+// parseClock parses the time in the format of 15:04:05 or 15:04
+func parseClock(clock string) (result time.Time, err error) {
+	if result, err = time.Parse(time.TimeOnly, clock); err != nil {
+		result, err = time.Parse("15:04", clock)
+	}
+	return
+}
+
+// getRequeueAfter returns the duration until the DevSpace might need to be turned on or off.
+// It is the next window boundary, the moment of being idle, or the expiry of the schedule override, whichever comes first.
+// It is zero if there is no such moment, the changes of the DevSpace and its child resources trigger the reconciling instead.
+func getRequeueAfter(devSpace *v1alpha1.DevSpace, idleTimeout time.Duration, now time.Time) (after time.Duration) {
+	// be a little bit late to make sure the boundary is passed
+	const delay = time.Second
+
+	if override := devSpace.Spec.ScheduleOverride; override.IsActive(now) {
+		// neither the windows nor the idle detection matter before the override expires
+		after = override.Until.Sub(now) + delay
+		return
+	}
+
+	if next, ok := nextWindowBoundary(now, devSpace.Spec.Windows); ok {
+		after = next.Sub(now) + delay
+	}
+
+	if lastActivity := devSpace.Status.LastActivityTime; idleTimeout > 0 && lastActivity != nil {
+		if untilIdle := lastActivity.Add(idleTimeout).Sub(now) + delay; untilIdle > delay && (after == 0 || untilIdle < after) {
+			after = untilIdle
+		}
+	}
+	return
+}
@@ -0,0 +1,332 @@
+/*
+Copyright 2024 kde authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/linuxsuren/kde/api/linuxsuren.github.io/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestIsInAliveWindows(t *testing.T) {
+	// it is a Wednesday
+	now := time.Date(2024, 7, 17, 10, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		windows      []v1alpha1.Window
+		expectHasWin bool
+		expectOk     bool
+		expectErr    bool
+	}{{
+		name:         "no windows",
+		windows:      nil,
+		expectHasWin: false,
+		expectOk:     false,
+	}, {
+		name: "invalid time format",
+		windows: []v1alpha1.Window{{
+			From: "aa:bb",
+		}, {
+			From: "00:12:00",
+			To:   "cc:dd",
+		}},
+		expectHasWin: true,
+		expectErr:    true,
+		expectOk:     false,
+	}, {
+		name: "before the window",
+		windows: []v1alpha1.Window{{
+			From:     "10:31",
+			To:       "10:32",
+			TimeZone: "UTC",
+		}},
+		expectHasWin: true,
+		expectOk:     false,
+	}, {
+		name: "after the window",
+		windows: []v1alpha1.Window{{
+			From:     "10:28:00",
+			To:       "10:29:00",
+			TimeZone: "UTC",
+		}},
+		expectHasWin: true,
+		expectOk:     false,
+	}, {
+		name: "in the window",
+		windows: []v1alpha1.Window{{
+			From:     "10:29",
+			To:       "10:31",
+			TimeZone: "UTC",
+		}},
+		expectHasWin: true,
+		expectOk:     true,
+	}, {
+		name: "cross midnight, in the window",
+		windows: []v1alpha1.Window{{
+			From:     "22:00",
+			To:       "11:00",
+			TimeZone: "UTC",
+		}},
+		expectHasWin: true,
+		expectOk:     true,
+	}, {
+		name: "cross midnight, out of the window",
+		windows: []v1alpha1.Window{{
+			From:     "22:00",
+			To:       "10:00",
+			TimeZone: "UTC",
+		}},
+		expectHasWin: true,
+		expectOk:     false,
+	}, {
+		name: "cross midnight from the previous day only",
+		windows: []v1alpha1.Window{{
+			From:     "22:00",
+			To:       "11:00",
+			Days:     []v1alpha1.Weekday{"Tue"},
+			TimeZone: "UTC",
+		}},
+		expectHasWin: true,
+		expectOk:     true,
+	}, {
+		name: "in the weekdays",
+		windows: []v1alpha1.Window{{
+			From:     "09:00",
+			To:       "18:00",
+			Days:     []v1alpha1.Weekday{"Mon", "Tue", "Wed", "Thu", "Fri"},
+			TimeZone: "UTC",
+		}},
+		expectHasWin: true,
+		expectOk:     true,
+	}, {
+		name: "out of the weekdays",
+		windows: []v1alpha1.Window{{
+			From:     "09:00",
+			To:       "18:00",
+			Days:     []v1alpha1.Weekday{"Sat", "Sun"},
+			TimeZone: "UTC",
+		}},
+		expectHasWin: true,
+		expectOk:     false,
+	}, {
+		name: "in the window of another time zone",
+		windows: []v1alpha1.Window{{
+			From:     "18:00",
+			To:       "19:00",
+			TimeZone: "Asia/Shanghai",
+		}},
+		expectHasWin: true,
+		expectOk:     true,
+	}, {
+		name: "out of the window of another time zone",
+		windows: []v1alpha1.Window{{
+			From:     "10:00",
+			To:       "11:00",
+			TimeZone: "Asia/Shanghai",
+		}},
+		expectHasWin: true,
+		expectOk:     false,
+	}, {
+		name: "invalid time zone",
+		windows: []v1alpha1.Window{{
+			From:     "10:00",
+			To:       "11:00",
+			TimeZone: "Mars/Olympus",
+		}},
+		expectHasWin: true,
+		expectErr:    true,
+		expectOk:     false,
+	}, {
+		name: "invalid day",
+		windows: []v1alpha1.Window{{
+			From: "10:00",
+			To:   "11:00",
+			Days: []v1alpha1.Weekday{"Someday"},
+		}},
+		expectHasWin: true,
+		expectErr:    true,
+		expectOk:     false,
+	}}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hasWin, ok, err := isInAliveWindows(now, tt.windows)
+			assert.Equal(t, tt.expectHasWin, hasWin, fmt.Sprintf("case %d", i))
+			assert.Equal(t, tt.expectOk, ok, fmt.Sprintf("case %d", i))
+			assert.Equal(t, tt.expectErr, err != nil, fmt.Sprintf("case %d, error: %v", i, err))
+		})
+	}
+}
+
+func TestNextWindowBoundary(t *testing.T) {
+	// it is a Wednesday
+	now := time.Date(2024, 7, 17, 10, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		windows     []v1alpha1.Window
+		expect      time.Time
+		expectFound bool
+	}{{
+		name:        "no windows",
+		expectFound: false,
+	}, {
+		name: "in the window",
+		windows: []v1alpha1.Window{{
+			From:     "09:00",
+			To:       "18:00",
+			TimeZone: "UTC",
+		}},
+		expect:      time.Date(2024, 7, 17, 18, 0, 0, 0, time.UTC),
+		expectFound: true,
+	}, {
+		name: "the next working day",
+		windows: []v1alpha1.Window{{
+			From:     "09:00",
+			To:       "10:00",
+			Days:     []v1alpha1.Weekday{"Mon", "Fri"},
+			TimeZone: "UTC",
+		}},
+		expect:      time.Date(2024, 7, 19, 9, 0, 0, 0, time.UTC),
+		expectFound: true,
+	}, {
+		name: "the closest one of multiple windows",
+		windows: []v1alpha1.Window{{
+			From:     "20:00",
+			To:       "21:00",
+			TimeZone: "UTC",
+		}, {
+			From:     "19:00",
+			To:       "19:30",
+			TimeZone: "Asia/Shanghai",
+		}},
+		expect:      time.Date(2024, 7, 17, 11, 0, 0, 0, time.UTC),
+		expectFound: true,
+	}, {
+		name: "invalid window",
+		windows: []v1alpha1.Window{{
+			From: "aa:bb",
+		}},
+		expectFound: false,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next, found := nextWindowBoundary(now, tt.windows)
+			assert.Equal(t, tt.expectFound, found)
+			if tt.expectFound {
+				assert.True(t, tt.expect.Equal(next), "expect %v, got %v", tt.expect, next)
+			}
+		})
+	}
+}
+
+func TestGetRequeueAfter(t *testing.T) {
+	now := time.Date(2024, 7, 17, 10, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		devSpace    *v1alpha1.DevSpace
+		idleTimeout time.Duration
+		expect      time.Duration
+	}{{
+		name:     "no windows",
+		devSpace: &v1alpha1.DevSpace{},
+		expect:   0,
+	}, {
+		name: "the next window boundary",
+		devSpace: &v1alpha1.DevSpace{
+			Spec: v1alpha1.DevSpaceSpec{
+				Windows: []v1alpha1.Window{{
+					From:     "09:00",
+					To:       "12:00",
+					TimeZone: "UTC",
+				}},
+			},
+		},
+		expect: 90*time.Minute + time.Second,
+	}, {
+		name: "idle before the window closes",
+		devSpace: &v1alpha1.DevSpace{
+			Spec: v1alpha1.DevSpaceSpec{
+				Windows: []v1alpha1.Window{{
+					From:     "09:00",
+					To:       "12:00",
+					TimeZone: "UTC",
+				}},
+			},
+			Status: v1alpha1.DevSpaceStatus{
+				LastActivityTime: &metav1.Time{Time: now.Add(-20 * time.Minute)},
+			},
+		},
+		idleTimeout: 30 * time.Minute,
+		expect:      10*time.Minute + time.Second,
+	}, {
+		name: "idle without windows",
+		devSpace: &v1alpha1.DevSpace{
+			Status: v1alpha1.DevSpaceStatus{
+				LastActivityTime: &metav1.Time{Time: now.Add(-20 * time.Minute)},
+			},
+		},
+		idleTimeout: 30 * time.Minute,
+		expect:      10*time.Minute + time.Second,
+	}, {
+		name: "already idle",
+		devSpace: &v1alpha1.DevSpace{
+			Status: v1alpha1.DevSpaceStatus{
+				LastActivityTime: &metav1.Time{Time: now.Add(-time.Hour)},
+			},
+		},
+		idleTimeout: 30 * time.Minute,
+		expect:      0,
+	}, {
+		name: "the schedule override expires",
+		devSpace: &v1alpha1.DevSpace{
+			Spec: v1alpha1.DevSpaceSpec{
+				Windows: []v1alpha1.Window{{
+					From:     "09:00",
+					To:       "10:00",
+					TimeZone: "UTC",
+				}},
+				ScheduleOverride: &v1alpha1.ScheduleOverride{
+					Mode:  v1alpha1.ScheduleOverrideKeepAlive,
+					Until: metav1.NewTime(now.Add(3 * time.Hour)),
+				},
+			},
+		},
+		expect: 3*time.Hour + time.Second,
+	}, {
+		name: "the schedule override was expired",
+		devSpace: &v1alpha1.DevSpace{
+			Spec: v1alpha1.DevSpaceSpec{
+				ScheduleOverride: &v1alpha1.ScheduleOverride{
+					Mode:  v1alpha1.ScheduleOverrideOff,
+					Until: metav1.NewTime(now.Add(-time.Hour)),
+				},
+			},
+		},
+		expect: 0,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expect, getRequeueAfter(tt.devSpace, tt.idleTimeout, now))
+		})
+	}
+}
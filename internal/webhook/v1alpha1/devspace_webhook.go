@@ -0,0 +1,535 @@
+/*
+Copyright 2024 kde authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"net"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/linuxsuren/kde/api/linuxsuren.github.io/v1alpha1"
+	"github.com/linuxsuren/kde/internal/controller"
+	"github.com/linuxsuren/kde/pkg/core"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+var devSpaceLog = logf.Log.WithName("devspace-webhook")
+
+// reservedPorts are used by the IDE and the docker daemon, they cannot be exposed
+var reservedPorts = map[int]string{
+	3000: "the IDE",
+	2376: "the docker daemon",
+}
+
+// ingressModes are the supported values of the ingress mode annotation,
+// nginx and traefik are kept from the old versions and route by the host
+var ingressModes = []string{"host", "path", "nginx", "traefik"}
+
+// SetupDevSpaceWebhookWithManager registers the webhooks for DevSpace in the manager.
+// The conversion webhook is registered as well, since v1alpha1 is the hub of the other versions.
+func SetupDevSpaceWebhookWithManager(mgr ctrl.Manager, systemNamespace string) error {
+	return ctrl.NewWebhookManagedBy(mgr).For(&v1alpha1.DevSpace{}).
+		WithDefaulter(&DevSpaceCustomDefaulter{
+			Reader:          mgr.GetAPIReader(),
+			SystemNamespace: systemNamespace,
+		}).
+		WithValidator(&DevSpaceCustomValidator{
+			Reader:          mgr.GetAPIReader(),
+			SystemNamespace: systemNamespace,
+		}).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/mutate-linuxsuren-github-io-v1alpha1-devspace,mutating=true,failurePolicy=fail,sideEffects=None,groups=linuxsuren.github.io,resources=devspaces,verbs=create;update,versions=v1alpha1,name=mdevspace-v1alpha1.kb.io,admissionReviewVersions=v1
+
+// DevSpaceCustomDefaulter sets the same default values as the apiserver does for the DevSpaces
+// created by other clients, e.g. kubectl.
+type DevSpaceCustomDefaulter struct {
+	Reader          client.Reader
+	SystemNamespace string
+}
+
+var _ webhook.CustomDefaulter = &DevSpaceCustomDefaulter{}
+
+// Default implements webhook.CustomDefaulter so a webhook will be registered for the type DevSpace.
+func (d *DevSpaceCustomDefaulter) Default(ctx context.Context, obj runtime.Object) error {
+	devSpace, ok := obj.(*v1alpha1.DevSpace)
+	if !ok {
+		return fmt.Errorf("expected a DevSpace object but got %T", obj)
+	}
+	devSpaceLog.Info("defaulting", "name", devSpace.GetName())
+
+	core.SetDefaultDevSpace(devSpace, loadConfig(ctx, d.Reader, d.SystemNamespace))
+	return nil
+}
+
+// loadConfig reads the config from the system namespace, the missing config is not an error
+func loadConfig(ctx context.Context, reader client.Reader, systemNamespace string) (config *core.Config) {
+	if reader == nil {
+		return
+	}
+	cm := &corev1.ConfigMap{}
+	if err := reader.Get(ctx, types.NamespacedName{Namespace: systemNamespace, Name: "config"}, cm); err != nil {
+		if !apierrors.IsNotFound(err) {
+			devSpaceLog.Error(err, "failed to get config")
+		}
+		return
+	}
+
+	var err error
+	if config, err = core.ReadConfigFromConfigMap(cm); err != nil {
+		devSpaceLog.Error(err, "failed to parse config")
+	}
+	return
+}
+
+// +kubebuilder:webhook:path=/validate-linuxsuren-github-io-v1alpha1-devspace,mutating=false,failurePolicy=fail,sideEffects=None,groups=linuxsuren.github.io,resources=devspaces,verbs=create;update,versions=v1alpha1,name=vdevspace-v1alpha1.kb.io,admissionReviewVersions=v1
+
+// DevSpaceCustomValidator rejects the DevSpaces which cannot be rendered into valid resources.
+// The config is read for the security profile which the cluster requires.
+type DevSpaceCustomValidator struct {
+	Reader          client.Reader
+	SystemNamespace string
+}
+
+var _ webhook.CustomValidator = &DevSpaceCustomValidator{}
+
+// ValidateCreate implements webhook.CustomValidator so a webhook will be registered for the type DevSpace.
+func (v *DevSpaceCustomValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	devSpace, ok := obj.(*v1alpha1.DevSpace)
+	if !ok {
+		return nil, fmt.Errorf("expected a DevSpace object but got %T", obj)
+	}
+	return validateDevSpace(devSpace, nil, loadConfig(ctx, v.Reader, v.SystemNamespace))
+}
+
+// ValidateUpdate implements webhook.CustomValidator so a webhook will be registered for the type DevSpace.
+func (v *DevSpaceCustomValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	devSpace, ok := newObj.(*v1alpha1.DevSpace)
+	if !ok {
+		return nil, fmt.Errorf("expected a DevSpace object for the newObj but got %T", newObj)
+	}
+	if devSpace.DeletionTimestamp != nil {
+		// do not block removing the finalizers
+		return nil, nil
+	}
+	oldDevSpace, _ := oldObj.(*v1alpha1.DevSpace)
+	return validateDevSpace(devSpace, oldDevSpace, loadConfig(ctx, v.Reader, v.SystemNamespace))
+}
+
+// ValidateDelete implements webhook.CustomValidator so a webhook will be registered for the type DevSpace.
+func (v *DevSpaceCustomValidator) ValidateDelete(_ context.Context, _ runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// validateDevSpace validates the DevSpace, the old one is nil when it is being created.
+// The invalid values which the old one has already are warned instead of rejected, otherwise
+// the existing DevSpaces could not be updated at all, including by the controller itself.
+func validateDevSpace(devSpace, oldDevSpace *v1alpha1.DevSpace, config *core.Config) (warnings admission.Warnings, err error) {
+	allErrs := validateDevSpaceFields(devSpace, config)
+	var oldSpec *v1alpha1.DevSpaceSpec
+	if oldDevSpace != nil {
+		oldSpec = &oldDevSpace.Spec
+		var existing field.ErrorList
+		allErrs, existing = ratchetErrors(allErrs, validateDevSpaceFields(oldDevSpace, config))
+		for _, item := range existing {
+			warnings = append(warnings, item.Error())
+		}
+	}
+	allErrs = append(allErrs, validateSecurityProfile(&devSpace.Spec, oldSpec, config, field.NewPath("spec"))...)
+	if len(allErrs) > 0 {
+		err = apierrors.NewInvalid(v1alpha1.GroupVersion.WithKind("DevSpace").GroupKind(), devSpace.Name, allErrs)
+	}
+	return
+}
+
+func validateDevSpaceFields(devSpace *v1alpha1.DevSpace, config *core.Config) (allErrs field.ErrorList) {
+	allErrs = append(allErrs, validateDevSpaceSpec(&devSpace.Spec, field.NewPath("spec"))...)
+	allErrs = append(allErrs, validateDevSpaceAnnotations(devSpace.Annotations, field.NewPath("metadata", "annotations"))...)
+	allErrs = append(allErrs, validateFeatures(&devSpace.Spec, config, field.NewPath("spec"))...)
+	return
+}
+
+// ratchetErrors splits the errors into the ones caused by the changes, and the existing ones which the old object has
+func ratchetErrors(allErrs, oldErrs field.ErrorList) (changed, existing field.ErrorList) {
+	old := map[string]bool{}
+	for _, item := range oldErrs {
+		old[item.Error()] = true
+	}
+	for _, item := range allErrs {
+		if old[item.Error()] {
+			existing = append(existing, item)
+		} else {
+			changed = append(changed, item)
+		}
+	}
+	return
+}
+
+func securityProfileOf(spec *v1alpha1.DevSpaceSpec, config *core.Config) v1alpha1.SecurityProfile {
+	if spec.SecurityProfile == "" && config != nil {
+		return config.SecurityProfile
+	}
+	return spec.SecurityProfile
+}
+
+// validateSecurityProfile rejects the profile which is less strict than the one of the cluster.
+// The existing DevSpaces keep their profiles until they change them, the controller disables
+// the incompatible features of them instead.
+func validateSecurityProfile(spec, oldSpec *v1alpha1.DevSpaceSpec, config *core.Config, specPath *field.Path) (allErrs field.ErrorList) {
+	if config == nil {
+		config = &core.Config{}
+	}
+
+	changed := oldSpec == nil || oldSpec.SecurityProfile != spec.SecurityProfile
+	if changed && config.SecurityProfile.IsStricterThan(securityProfileOf(spec, config)) {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("securityProfile"),
+			fmt.Sprintf("the cluster requires the %s profile or a stricter one", config.SecurityProfile)))
+	}
+	return
+}
+
+// validateFeatures rejects the features which the security profile of the DevSpace does not allow
+func validateFeatures(spec *v1alpha1.DevSpaceSpec, config *core.Config, specPath *field.Path) (allErrs field.ErrorList) {
+	profile := securityProfileOf(spec, config)
+	for _, feature := range controller.IncompatibleFeatures(spec, profile, specPath) {
+		allErrs = append(allErrs, field.Forbidden(feature.Path, fmt.Sprintf("not allowed by the %s security profile, %s", profile, feature.Reason)))
+	}
+	return
+}
+
+func validateDevSpaceSpec(spec *v1alpha1.DevSpaceSpec, specPath *field.Path) (allErrs field.ErrorList) {
+	quantities := []struct {
+		name  string
+		value string
+	}{{
+		name: "cpu", value: spec.CPU,
+	}, {
+		name: "memory", value: spec.Memory,
+	}, {
+		name: "storage", value: spec.Storage,
+	}}
+	for _, quantity := range quantities {
+		if quantity.value == "" {
+			continue
+		}
+		if q, err := resource.ParseQuantity(quantity.value); err != nil {
+			allErrs = append(allErrs, field.Invalid(specPath.Child(quantity.name), quantity.value, "must be a quantity, e.g. 2, 500m or 4Gi"))
+		} else if q.Sign() <= 0 {
+			allErrs = append(allErrs, field.Invalid(specPath.Child(quantity.name), quantity.value, "must be greater than zero"))
+		}
+	}
+
+	for i, win := range spec.Windows {
+		if err := controller.ValidateWindow(win); err != nil {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("windows").Index(i), win, err.Error()))
+		}
+	}
+
+	directories := map[string]bool{}
+	// an empty repository is ignored for the compatibility
+	if spec.Repository != nil && spec.Repository.URL != "" {
+		allErrs = append(allErrs, validateRepository(spec.Repository, specPath.Child("repository"))...)
+		directories[spec.Repository.TargetDirectory()] = true
+	}
+	for i := range spec.Repositories {
+		repo := &spec.Repositories[i]
+		repoPath := specPath.Child("repositories").Index(i)
+		allErrs = append(allErrs, validateRepository(repo, repoPath)...)
+
+		if dir := repo.TargetDirectory(); directories[dir] {
+			allErrs = append(allErrs, field.Duplicate(repoPath.Child("directory"), dir))
+		} else {
+			directories[dir] = true
+		}
+	}
+	allErrs = append(allErrs, validateCustomServices(spec.Services.Custom, specPath.Child("services", "custom"))...)
+	allErrs = append(allErrs, validateAllResources(spec, specPath)...)
+	allErrs = append(allErrs, validateScheduling(&spec.Scheduling, specPath)...)
+	allErrs = append(allErrs, validateNetworkPolicy(spec.NetworkPolicy, specPath.Child("networkPolicy"))...)
+	return
+}
+
+func validateScheduling(scheduling *v1alpha1.Scheduling, specPath *field.Path) (allErrs field.ErrorList) {
+	for key, value := range scheduling.NodeSelector {
+		for _, msg := range validation.IsQualifiedName(key) {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("nodeSelector"), key, msg))
+		}
+		for _, msg := range validation.IsValidLabelValue(value) {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("nodeSelector").Key(key), value, msg))
+		}
+	}
+
+	for i, toleration := range scheduling.Tolerations {
+		tolerationPath := specPath.Child("tolerations").Index(i)
+		switch toleration.Operator {
+		case corev1.TolerationOpExists:
+			if toleration.Value != "" {
+				allErrs = append(allErrs, field.Invalid(tolerationPath.Child("value"), toleration.Value, "must be empty when the operator is Exists"))
+			}
+		case corev1.TolerationOpEqual, "":
+			if toleration.Key == "" {
+				allErrs = append(allErrs, field.Invalid(tolerationPath.Child("operator"), toleration.Operator, "must be Exists when the key is empty"))
+			}
+		default:
+			allErrs = append(allErrs, field.NotSupported(tolerationPath.Child("operator"), toleration.Operator,
+				[]corev1.TolerationOperator{corev1.TolerationOpEqual, corev1.TolerationOpExists}))
+		}
+		switch toleration.Effect {
+		case "", corev1.TaintEffectNoSchedule, corev1.TaintEffectPreferNoSchedule, corev1.TaintEffectNoExecute:
+		default:
+			allErrs = append(allErrs, field.NotSupported(tolerationPath.Child("effect"), toleration.Effect,
+				[]corev1.TaintEffect{corev1.TaintEffectNoSchedule, corev1.TaintEffectPreferNoSchedule, corev1.TaintEffectNoExecute}))
+		}
+	}
+
+	if name := scheduling.PriorityClassName; name != "" {
+		for _, msg := range validation.IsDNS1123Subdomain(name) {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("priorityClassName"), name, msg))
+		}
+	}
+	return
+}
+
+func validateNetworkPolicy(policy *v1alpha1.NetworkPolicy, policyPath *field.Path) (allErrs field.ErrorList) {
+	if policy == nil {
+		return
+	}
+	validateNamespaces := func(namespaces []string, namespacesPath *field.Path) {
+		for i, namespace := range namespaces {
+			for _, msg := range validation.IsDNS1123Label(namespace) {
+				allErrs = append(allErrs, field.Invalid(namespacesPath.Index(i), namespace, msg))
+			}
+		}
+	}
+	validateNamespaces(policy.IngressNamespaces, policyPath.Child("ingressNamespaces"))
+	if egress := policy.Egress; egress != nil {
+		egressPath := policyPath.Child("egress")
+		validateNamespaces(egress.Namespaces, egressPath.Child("namespaces"))
+		for i, cidr := range egress.CIDRs {
+			if _, _, err := net.ParseCIDR(cidr); err != nil {
+				allErrs = append(allErrs, field.Invalid(egressPath.Child("cidrs").Index(i), cidr, "must be a CIDR, e.g. 10.0.1.0/24"))
+			}
+		}
+	}
+	return
+}
+
+// validateAllResources validates the resources of the IDE and the services, the CPU and Memory
+// are the limits of the IDE unless the resources override them
+func validateAllResources(spec *v1alpha1.DevSpaceSpec, specPath *field.Path) (allErrs field.ErrorList) {
+	ide := &corev1.ResourceRequirements{Limits: corev1.ResourceList{}}
+	for name, value := range map[corev1.ResourceName]string{corev1.ResourceCPU: spec.CPU, corev1.ResourceMemory: spec.Memory} {
+		if quantity, err := resource.ParseQuantity(value); err == nil {
+			ide.Limits[name] = quantity
+		}
+	}
+	if spec.Resources != nil {
+		maps.Copy(ide.Limits, spec.Resources.Limits)
+		ide.Requests = spec.Resources.Requests
+	}
+	allErrs = append(allErrs, validateResources(ide, specPath.Child("resources"))...)
+
+	servicesPath := specPath.Child("services")
+	check := func(resources *corev1.ResourceRequirements, path *field.Path) {
+		if resources != nil {
+			allErrs = append(allErrs, validateResources(resources, path.Child("resources"))...)
+		}
+	}
+	services := spec.Services
+	if services.Docker != nil {
+		check(services.Docker.Resources, servicesPath.Child("docker"))
+	}
+	if services.Redis != nil {
+		check(services.Redis.Resources, servicesPath.Child("redis"))
+	}
+	if services.MySQL != nil {
+		check(services.MySQL.Resources, servicesPath.Child("mysql"))
+	}
+	if services.MySQLUI != nil {
+		check(services.MySQLUI.Resources, servicesPath.Child("mysqlUI"))
+	}
+	if services.Postgres != nil {
+		check(services.Postgres.Resources, servicesPath.Child("postgres"))
+	}
+	if services.TDEngine != nil {
+		check(services.TDEngine.Resources, servicesPath.Child("tdEngine"))
+	}
+	if services.RabbitMQ != nil {
+		check(services.RabbitMQ.Resources, servicesPath.Child("rabbitMQ"))
+	}
+	catalog := []struct {
+		name    string
+		service *v1alpha1.CatalogService
+	}{{"mongodb", services.MongoDB}, {"kafka", services.Kafka}, {"elasticsearch", services.Elasticsearch},
+		{"minio", services.MinIO}, {"etcd", services.Etcd}, {"nginx", services.Nginx}}
+	for _, item := range catalog {
+		if item.service != nil {
+			check(item.service.Resources, servicesPath.Child(item.name))
+		}
+	}
+	for i := range services.Custom {
+		check(services.Custom[i].Resources, servicesPath.Child("custom").Index(i))
+	}
+	return
+}
+
+// sortedResourceNames keeps the order of the errors stable
+func sortedResourceNames(list corev1.ResourceList) (names []corev1.ResourceName) {
+	for name := range list {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return
+}
+
+func validateResources(resources *corev1.ResourceRequirements, resourcesPath *field.Path) (allErrs field.ErrorList) {
+	for _, name := range sortedResourceNames(resources.Limits) {
+		if limit := resources.Limits[name]; limit.Sign() <= 0 {
+			allErrs = append(allErrs, field.Invalid(resourcesPath.Child("limits").Key(string(name)), limit.String(), "must be greater than zero"))
+		}
+	}
+	for _, name := range sortedResourceNames(resources.Requests) {
+		request := resources.Requests[name]
+		requestPath := resourcesPath.Child("requests").Key(string(name))
+		if request.Sign() < 0 {
+			allErrs = append(allErrs, field.Invalid(requestPath, request.String(), "must not be negative"))
+		} else if limit, ok := resources.Limits[name]; ok && request.Cmp(limit) > 0 {
+			allErrs = append(allErrs, field.Invalid(requestPath, request.String(), fmt.Sprintf("must be less than or equal to the limit %s", limit.String())))
+		}
+	}
+	return
+}
+
+func validateCustomServices(services []v1alpha1.CustomService, servicesPath *field.Path) (allErrs field.ErrorList) {
+	names := map[string]bool{}
+	ports := map[int32]bool{}
+	portNames := map[string]bool{}
+	for i, service := range services {
+		servicePath := servicesPath.Index(i)
+		switch {
+		case service.Name == "":
+			allErrs = append(allErrs, field.Required(servicePath.Child("name"), "the name of the service is required"))
+		case controller.IsReservedServiceName(service.Name):
+			allErrs = append(allErrs, field.Forbidden(servicePath.Child("name"), fmt.Sprintf("%q is reserved for a built-in service", service.Name)))
+		case names[service.Name]:
+			allErrs = append(allErrs, field.Duplicate(servicePath.Child("name"), service.Name))
+		}
+		names[service.Name] = true
+		if service.Image == "" {
+			allErrs = append(allErrs, field.Required(servicePath.Child("image"), "the image of the service is required"))
+		}
+
+		for j, port := range service.Ports {
+			portPath := servicePath.Child("ports").Index(j)
+			switch {
+			case port.Port <= 0 || port.Port > 65535:
+				allErrs = append(allErrs, field.Invalid(portPath.Child("port"), port.Port, "must be between 1 and 65535"))
+			case reservedPorts[int(port.Port)] != "":
+				allErrs = append(allErrs, field.Forbidden(portPath.Child("port"), fmt.Sprintf("port %d is reserved for %s", port.Port, reservedPorts[int(port.Port)])))
+			case ports[port.Port]:
+				allErrs = append(allErrs, field.Duplicate(portPath.Child("port"), port.Port))
+			}
+			ports[port.Port] = true
+
+			if port.Name == "" {
+				continue
+			}
+			for _, msg := range validation.IsValidPortName(port.Name) {
+				allErrs = append(allErrs, field.Invalid(portPath.Child("name"), port.Name, msg))
+			}
+			if portNames[port.Name] || port.Name == "http" {
+				allErrs = append(allErrs, field.Duplicate(portPath.Child("name"), port.Name))
+			}
+			portNames[port.Name] = true
+		}
+
+		if persistence := service.Persistence; persistence != nil {
+			if !path.IsAbs(persistence.MountPath) {
+				allErrs = append(allErrs, field.Invalid(servicePath.Child("persistence", "mountPath"), persistence.MountPath, "must be an absolute path"))
+			}
+			if subPath := persistence.SubPath; subPath != "" && (path.IsAbs(subPath) || slices.Contains(strings.Split(subPath, "/"), "..")) {
+				allErrs = append(allErrs, field.Invalid(servicePath.Child("persistence", "subPath"), subPath, "must be a relative path inside the storage"))
+			}
+		}
+	}
+	return
+}
+
+func validateRepository(repo *v1alpha1.GitRepository, repoPath *field.Path) (allErrs field.ErrorList) {
+	if repo.URL == "" {
+		allErrs = append(allErrs, field.Required(repoPath.Child("url"), "the URL of the repository is required"))
+	}
+	if repo.Branch != "" && repo.Tag != "" {
+		allErrs = append(allErrs, field.Invalid(repoPath.Child("tag"), repo.Tag, "cannot be given along with the branch"))
+	}
+	if dir := repo.Directory; dir != "" && (path.IsAbs(dir) || slices.Contains(strings.Split(dir, "/"), "..")) {
+		allErrs = append(allErrs, field.Invalid(repoPath.Child("directory"), dir, "must be a relative path inside the workspace"))
+	}
+	switch {
+	case repo.Depth != nil && *repo.Depth < 0:
+		allErrs = append(allErrs, field.Invalid(repoPath.Child("depth"), *repo.Depth, "must not be negative"))
+	case repo.Depth != nil && repo.Commit != "":
+		// a shallow clone might not contain the commit
+		allErrs = append(allErrs, field.Invalid(repoPath.Child("depth"), *repo.Depth, "cannot be given along with the commit"))
+	}
+	return
+}
+
+func validateDevSpaceAnnotations(annotations map[string]string, annotationsPath *field.Path) (allErrs field.ErrorList) {
+	if mode, ok := annotations[v1alpha1.AnnoKeyIngressMode]; ok && mode != "" {
+		if !slices.Contains(ingressModes, mode) {
+			allErrs = append(allErrs, field.NotSupported(annotationsPath.Key(v1alpha1.AnnoKeyIngressMode), mode, ingressModes))
+		}
+	}
+
+	if ports := annotations[v1alpha1.AnnoKeyExposePorts]; ports != "" {
+		portsPath := annotationsPath.Key(v1alpha1.AnnoKeyExposePorts)
+		for _, item := range strings.Split(ports, ",") {
+			if item == "" {
+				continue
+			}
+
+			port, err := strconv.Atoi(item)
+			switch {
+			case err != nil:
+				allErrs = append(allErrs, field.Invalid(portsPath, item, "must be a comma separated list of port numbers"))
+			case port <= 0 || port > 65535:
+				allErrs = append(allErrs, field.Invalid(portsPath, port, "must be between 1 and 65535"))
+			case reservedPorts[port] != "":
+				allErrs = append(allErrs, field.Forbidden(portsPath, fmt.Sprintf("port %d is reserved for %s", port, reservedPorts[port])))
+			}
+		}
+	}
+	return
+}
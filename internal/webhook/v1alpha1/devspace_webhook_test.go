@@ -0,0 +1,493 @@
+/*
+Copyright 2024 kde authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"testing"
+
+	"github.com/linuxsuren/kde/api/linuxsuren.github.io/v1alpha1"
+	"github.com/linuxsuren/kde/pkg/core"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestDevSpaceDefault(t *testing.T) {
+	schema := runtime.NewScheme()
+	assert.NoError(t, corev1.AddToScheme(schema))
+
+	t.Run("with config", func(t *testing.T) {
+		defaulter := &DevSpaceCustomDefaulter{
+			Reader: fake.NewClientBuilder().WithScheme(schema).WithObjects(&corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "config", Namespace: "kde-system"},
+				Data: map[string]string{
+					core.ConfigFileName: `{"host": "devspace.com", "storageClassName": "local-path", "ingressMode": "nginx"}`,
+				},
+			}).Build(),
+			SystemNamespace: "kde-system",
+		}
+
+		devSpace := &v1alpha1.DevSpace{
+			ObjectMeta: metav1.ObjectMeta{Name: "demo", Namespace: "default"},
+			Spec:       v1alpha1.DevSpaceSpec{CPU: "4"},
+		}
+		assert.NoError(t, defaulter.Default(context.Background(), devSpace))
+		assert.Equal(t, "devspace.com", devSpace.Spec.Host)
+		assert.Equal(t, "4", devSpace.Spec.CPU)
+		assert.Equal(t, core.DefaultMemory, devSpace.Spec.Memory)
+		assert.Equal(t, core.DefaultImage, devSpace.Spec.Image)
+		assert.Equal(t, "local-path", devSpace.Annotations[v1alpha1.AnnoKeyStorageClassName])
+		assert.Equal(t, "nginx", devSpace.Annotations[v1alpha1.AnnoKeyIngressMode])
+		assert.Equal(t, core.ImagePullPolicyIfNotPresent, devSpace.Annotations[v1alpha1.AnnoKeyImagePullPolicy])
+	})
+
+	t.Run("without config", func(t *testing.T) {
+		defaulter := &DevSpaceCustomDefaulter{
+			Reader:          fake.NewClientBuilder().WithScheme(schema).Build(),
+			SystemNamespace: "kde-system",
+		}
+
+		devSpace := &v1alpha1.DevSpace{}
+		assert.NoError(t, defaulter.Default(context.Background(), devSpace))
+		assert.Equal(t, core.DefaultStorage, devSpace.Spec.Storage)
+		assert.Empty(t, devSpace.Spec.Host)
+	})
+
+	t.Run("unexpected object", func(t *testing.T) {
+		defaulter := &DevSpaceCustomDefaulter{}
+		assert.Error(t, defaulter.Default(context.Background(), &v1alpha1.User{}))
+	})
+}
+
+func TestDevSpaceValidate(t *testing.T) {
+	tests := []struct {
+		name        string
+		spec        v1alpha1.DevSpaceSpec
+		annotations map[string]string
+		fields      []string
+	}{{
+		name: "valid",
+		spec: v1alpha1.DevSpaceSpec{
+			CPU:     "500m",
+			Memory:  "4Gi",
+			Storage: "50Gi",
+			Windows: []v1alpha1.Window{{From: "22:00", To: "02:00", Days: []v1alpha1.Weekday{"Mon"}, TimeZone: "Asia/Shanghai"}},
+		},
+		annotations: map[string]string{
+			v1alpha1.AnnoKeyIngressMode: "path",
+			v1alpha1.AnnoKeyExposePorts: "8080,9090,",
+		},
+	}, {
+		name: "empty",
+	}, {
+		name: "invalid quantities",
+		spec: v1alpha1.DevSpaceSpec{
+			CPU:     "two",
+			Memory:  "4GB",
+			Storage: "0",
+		},
+		fields: []string{"spec.cpu", "spec.memory", "spec.storage"},
+	}, {
+		name: "invalid windows",
+		spec: v1alpha1.DevSpaceSpec{
+			Windows: []v1alpha1.Window{
+				{From: "08:00", To: "18:00"},
+				{From: "8am", To: "18:00"},
+				{From: "08:00", To: "18:00", TimeZone: "Mars/Olympus"},
+			},
+		},
+		fields: []string{"spec.windows[1]", "spec.windows[2]"},
+	}, {
+		name: "unknown ingress mode",
+		annotations: map[string]string{
+			v1alpha1.AnnoKeyIngressMode: "haproxy",
+		},
+		fields: []string{"metadata.annotations[linuxsuren.github.io/ingress-mode]"},
+	}, {
+		name: "host ingress mode",
+		annotations: map[string]string{
+			v1alpha1.AnnoKeyIngressMode: "host",
+		},
+	}, {
+		name: "legacy ingress mode",
+		annotations: map[string]string{
+			v1alpha1.AnnoKeyIngressMode: "traefik",
+		},
+	}, {
+		name: "invalid expose ports",
+		annotations: map[string]string{
+			v1alpha1.AnnoKeyExposePorts: "3000,8080,http,70000,2376",
+		},
+		fields: []string{
+			"metadata.annotations[linuxsuren.github.io/expose-ports]",
+			"metadata.annotations[linuxsuren.github.io/expose-ports]",
+			"metadata.annotations[linuxsuren.github.io/expose-ports]",
+			"metadata.annotations[linuxsuren.github.io/expose-ports]",
+		},
+	}, {
+		name: "valid repositories",
+		spec: v1alpha1.DevSpaceSpec{
+			Repository: &v1alpha1.GitRepository{URL: "https://github.com/linuxsuren/kde", Branch: "master"},
+			Repositories: []v1alpha1.GitRepository{
+				{URL: "https://github.com/linuxsuren/api-testing", Tag: "v0.0.1"},
+				{URL: "https://github.com/linuxsuren/kde", Directory: "tools/kde"},
+				{URL: "https://github.com/linuxsuren/api-testing", Directory: "pinned", Commit: "9c7a019"},
+				{URL: "https://github.com/linuxsuren/api-testing", Directory: "shallow", Depth: ptr.To[int32](10)},
+			},
+		},
+	}, {
+		name: "invalid repositories",
+		spec: v1alpha1.DevSpaceSpec{
+			Repository: &v1alpha1.GitRepository{URL: "https://github.com/linuxsuren/kde"},
+			Repositories: []v1alpha1.GitRepository{
+				{URL: "https://gitee.com/linuxsuren/kde.git"},
+				{},
+				{URL: "https://github.com/linuxsuren/api-testing", Branch: "master", Tag: "v0.0.1"},
+				{URL: "https://github.com/linuxsuren/a", Directory: "../a"},
+				{URL: "https://github.com/linuxsuren/b", Directory: "/b"},
+				{URL: "https://github.com/linuxsuren/c", Commit: "9c7a019", Depth: ptr.To[int32](1)},
+				{URL: "https://github.com/linuxsuren/d", Depth: ptr.To[int32](-1)},
+			},
+		},
+		fields: []string{
+			"spec.repositories[0].directory",
+			"spec.repositories[1].url",
+			"spec.repositories[2].tag",
+			"spec.repositories[3].directory",
+			"spec.repositories[4].directory",
+			"spec.repositories[5].depth",
+			"spec.repositories[6].depth",
+		},
+	}, {
+		name: "valid custom services",
+		spec: v1alpha1.DevSpaceSpec{
+			Services: v1alpha1.Services{
+				MongoDB: &v1alpha1.CatalogService{Enabled: ptr.To(true)},
+				Custom: []v1alpha1.CustomService{{
+					Name:        "clickhouse",
+					Image:       "clickhouse/clickhouse-server:24.8",
+					Ports:       []v1alpha1.ServicePort{{Name: "clickhouse", Port: 8123}, {Port: 9000}},
+					Persistence: &v1alpha1.ServicePersistence{MountPath: "/var/lib/clickhouse"},
+				}, {
+					Name:        "mailpit",
+					Image:       "axllent/mailpit:v1.20",
+					Ports:       []v1alpha1.ServicePort{{Port: 8025}},
+					Persistence: &v1alpha1.ServicePersistence{MountPath: "/data", SubPath: "mail"},
+				}},
+			},
+		},
+	}, {
+		name: "invalid custom services",
+		spec: v1alpha1.DevSpaceSpec{
+			Services: v1alpha1.Services{
+				Custom: []v1alpha1.CustomService{{
+					Name:  "mysql",
+					Image: "mysql:8",
+				}, {
+					Name:  "a",
+					Ports: []v1alpha1.ServicePort{{Port: 3000}, {Name: "http", Port: 8080}},
+				}, {
+					Name:        "a",
+					Image:       "a",
+					Ports:       []v1alpha1.ServicePort{{Name: "a_port", Port: 8080}},
+					Persistence: &v1alpha1.ServicePersistence{MountPath: "data", SubPath: "../data"},
+				}, {
+					Image: "b",
+				}},
+			},
+		},
+		fields: []string{
+			"spec.services.custom[0].name",
+			"spec.services.custom[1].image",
+			"spec.services.custom[1].ports[0].port",
+			"spec.services.custom[1].ports[1].name",
+			"spec.services.custom[2].name",
+			"spec.services.custom[2].ports[0].port",
+			"spec.services.custom[2].ports[0].name",
+			"spec.services.custom[2].persistence.mountPath",
+			"spec.services.custom[2].persistence.subPath",
+			"spec.services.custom[3].name",
+		},
+	}, {
+		name: "valid resources",
+		spec: v1alpha1.DevSpaceSpec{
+			CPU: "2",
+			Resources: &corev1.ResourceRequirements{
+				Limits:   corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("4"), corev1.ResourceEphemeralStorage: resource.MustParse("10Gi")},
+				Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("3"), corev1.ResourceMemory: resource.MustParse("1Gi")},
+			},
+			Services: v1alpha1.Services{
+				MySQL: &v1alpha1.MySQL{Resources: &corev1.ResourceRequirements{
+					Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("1Gi")},
+				}},
+			},
+		},
+	}, {
+		name: "invalid resources",
+		spec: v1alpha1.DevSpaceSpec{
+			CPU: "2",
+			Resources: &corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("4")},
+			},
+			Services: v1alpha1.Services{
+				MySQL: &v1alpha1.MySQL{Resources: &corev1.ResourceRequirements{
+					Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("0")},
+				}},
+				MongoDB: &v1alpha1.CatalogService{Resources: &corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("-1")},
+				}},
+				Custom: []v1alpha1.CustomService{{
+					Name:  "clickhouse",
+					Image: "clickhouse",
+					Resources: &corev1.ResourceRequirements{
+						Limits:   corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("1Gi")},
+						Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("2Gi")},
+					},
+				}},
+			},
+		},
+		fields: []string{
+			"spec.resources.requests[cpu]",
+			"spec.services.mysql.resources.limits[memory]",
+			"spec.services.mongodb.resources.requests[cpu]",
+			"spec.services.custom[0].resources.requests[memory]",
+		},
+	}, {
+		name: "valid scheduling",
+		spec: v1alpha1.DevSpaceSpec{
+			Scheduling: v1alpha1.Scheduling{
+				NodeSelector: map[string]string{"node.example.com/pool": "dev"},
+				Tolerations: []corev1.Toleration{
+					{Key: "dedicated", Value: "dev", Effect: corev1.TaintEffectNoSchedule},
+					{Operator: corev1.TolerationOpExists},
+				},
+				PriorityClassName: "devspace-high",
+			},
+		},
+	}, {
+		name: "invalid scheduling",
+		spec: v1alpha1.DevSpaceSpec{
+			Scheduling: v1alpha1.Scheduling{
+				NodeSelector: map[string]string{"pool": "dev pool"},
+				Tolerations: []corev1.Toleration{
+					{Key: "dedicated", Operator: corev1.TolerationOpExists, Value: "dev"},
+					{Value: "dev"},
+					{Key: "dedicated", Operator: "In", Effect: "NoRun"},
+				},
+				PriorityClassName: "High",
+			},
+		},
+		fields: []string{
+			"spec.nodeSelector[pool]",
+			"spec.tolerations[0].value",
+			"spec.tolerations[1].operator",
+			"spec.tolerations[2].operator",
+			"spec.tolerations[2].effect",
+			"spec.priorityClassName",
+		},
+	}, {
+		name: "invalid network policy",
+		spec: v1alpha1.DevSpaceSpec{
+			NetworkPolicy: &v1alpha1.NetworkPolicy{
+				IngressNamespaces: []string{"ingress-nginx", "Ingress_Nginx"},
+				Egress: &v1alpha1.NetworkEgress{
+					Namespaces: []string{"kube-system"},
+					CIDRs:      []string{"10.0.1.0/24", "10.0.1.0", "fd00::/8"},
+				},
+			},
+		},
+		fields: []string{
+			"spec.networkPolicy.ingressNamespaces[1]",
+			"spec.networkPolicy.egress.cidrs[1]",
+		},
+	}, {
+		name: "Docker under the restricted profile",
+		spec: v1alpha1.DevSpaceSpec{
+			SecurityProfile: v1alpha1.SecurityProfileRestricted,
+			Services:        v1alpha1.Services{Docker: &v1alpha1.Docker{Enabled: ptr.To(true)}},
+		},
+		fields: []string{"spec.services.docker.enabled"},
+	}}
+	validator := &DevSpaceCustomValidator{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			devSpace := &v1alpha1.DevSpace{
+				ObjectMeta: metav1.ObjectMeta{Name: "demo", Annotations: tt.annotations},
+				Spec:       tt.spec,
+			}
+
+			_, err := validator.ValidateCreate(context.Background(), devSpace)
+			assertFieldErrors(t, err, tt.fields)
+
+			valid := &v1alpha1.DevSpace{ObjectMeta: metav1.ObjectMeta{Name: "demo"}}
+			_, err = validator.ValidateUpdate(context.Background(), valid, devSpace)
+			assertFieldErrors(t, err, tt.fields)
+
+			// the existing invalid values are warned only
+			warnings, err := validator.ValidateUpdate(context.Background(), devSpace.DeepCopy(), devSpace)
+			assert.NoError(t, err)
+			assert.Len(t, warnings, len(tt.fields))
+		})
+	}
+
+	t.Run("only the changes are rejected", func(t *testing.T) {
+		old := &v1alpha1.DevSpace{
+			ObjectMeta: metav1.ObjectMeta{Name: "demo", Annotations: map[string]string{
+				v1alpha1.AnnoKeyExposePorts: "3000,abc",
+			}},
+			Spec: v1alpha1.DevSpaceSpec{CPU: "two"},
+		}
+
+		// e.g. the controller writes the hash of the basic auth
+		devSpace := old.DeepCopy()
+		devSpace.Annotations[v1alpha1.AnnoKeyBasicAuth] = "hash"
+		warnings, err := validator.ValidateUpdate(context.Background(), old, devSpace)
+		assert.NoError(t, err)
+		assert.Len(t, warnings, 3)
+
+		devSpace.Annotations[v1alpha1.AnnoKeyExposePorts] = "3000,abc,2376"
+		devSpace.Spec.Memory = "1Gx"
+		warnings, err = validator.ValidateUpdate(context.Background(), old, devSpace)
+		assertFieldErrors(t, err, []string{
+			"spec.memory",
+			"metadata.annotations[linuxsuren.github.io/expose-ports]",
+		})
+		assert.Len(t, warnings, 3)
+	})
+
+	t.Run("deleting", func(t *testing.T) {
+		devSpace := &v1alpha1.DevSpace{
+			ObjectMeta: metav1.ObjectMeta{Name: "demo", DeletionTimestamp: &metav1.Time{}},
+			Spec:       v1alpha1.DevSpaceSpec{CPU: "two"},
+		}
+		_, err := validator.ValidateUpdate(context.Background(), devSpace, devSpace)
+		assert.NoError(t, err)
+
+		_, err = validator.ValidateDelete(context.Background(), devSpace)
+		assert.NoError(t, err)
+	})
+}
+
+func TestDevSpaceValidateSecurityProfile(t *testing.T) {
+	schema := runtime.NewScheme()
+	assert.NoError(t, corev1.AddToScheme(schema))
+	newValidator := func(profile v1alpha1.SecurityProfile) *DevSpaceCustomValidator {
+		return &DevSpaceCustomValidator{
+			Reader: fake.NewClientBuilder().WithScheme(schema).WithObjects(&corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "config", Namespace: "kde-system"},
+				Data: map[string]string{
+					core.ConfigFileName: `{"securityProfile": "` + string(profile) + `"}`,
+				},
+			}).Build(),
+			SystemNamespace: "kde-system",
+		}
+	}
+	newDevSpace := func(profile v1alpha1.SecurityProfile, docker bool) *v1alpha1.DevSpace {
+		return &v1alpha1.DevSpace{
+			ObjectMeta: metav1.ObjectMeta{Name: "demo"},
+			Spec: v1alpha1.DevSpaceSpec{
+				SecurityProfile: profile,
+				Services:        v1alpha1.Services{Docker: &v1alpha1.Docker{Enabled: ptr.To(docker)}},
+			},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		cluster  v1alpha1.SecurityProfile
+		old      *v1alpha1.DevSpace
+		devSpace *v1alpha1.DevSpace
+		fields   []string
+		warnings int
+	}{{
+		name:     "stricter than the cluster",
+		cluster:  v1alpha1.SecurityProfileBaseline,
+		devSpace: newDevSpace(v1alpha1.SecurityProfileRestricted, false),
+	}, {
+		name:     "less strict than the cluster",
+		cluster:  v1alpha1.SecurityProfileRestricted,
+		devSpace: newDevSpace(v1alpha1.SecurityProfilePrivileged, false),
+		fields:   []string{"spec.securityProfile"},
+	}, {
+		name:     "the profile of the cluster is taken",
+		cluster:  v1alpha1.SecurityProfileRestricted,
+		devSpace: newDevSpace("", true),
+		fields:   []string{"spec.services.docker.enabled"},
+	}, {
+		name:     "Docker is not allowed by the baseline profile",
+		cluster:  v1alpha1.SecurityProfileBaseline,
+		devSpace: newDevSpace(v1alpha1.SecurityProfileBaseline, true),
+		fields:   []string{"spec.services.docker.enabled"},
+	}, {
+		name:     "the profile is kept",
+		cluster:  v1alpha1.SecurityProfileRestricted,
+		old:      newDevSpace(v1alpha1.SecurityProfilePrivileged, false),
+		devSpace: newDevSpace(v1alpha1.SecurityProfilePrivileged, true),
+	}, {
+		name:     "the profile is changed",
+		cluster:  v1alpha1.SecurityProfileRestricted,
+		old:      newDevSpace(v1alpha1.SecurityProfilePrivileged, true),
+		devSpace: newDevSpace(v1alpha1.SecurityProfileBaseline, true),
+		fields:   []string{"spec.services.docker.enabled", "spec.securityProfile"},
+	}, {
+		name:     "Docker of an existing baseline DevSpace",
+		cluster:  v1alpha1.SecurityProfileBaseline,
+		old:      newDevSpace(v1alpha1.SecurityProfileBaseline, true),
+		devSpace: newDevSpace(v1alpha1.SecurityProfileBaseline, true),
+		warnings: 1,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			validator := newValidator(tt.cluster)
+			var warnings []string
+			var err error
+			if tt.old == nil {
+				warnings, err = validator.ValidateCreate(context.Background(), tt.devSpace)
+			} else {
+				warnings, err = validator.ValidateUpdate(context.Background(), tt.old, tt.devSpace)
+			}
+			assertFieldErrors(t, err, tt.fields)
+			assert.Len(t, warnings, tt.warnings)
+		})
+	}
+}
+
+func assertFieldErrors(t *testing.T, err error, fields []string) {
+	t.Helper()
+	if len(fields) == 0 {
+		assert.NoError(t, err)
+		return
+	}
+
+	assert.True(t, apierrors.IsInvalid(err), err)
+	statusErr, ok := err.(*apierrors.StatusError)
+	if !assert.True(t, ok) {
+		return
+	}
+
+	var actual []string
+	for _, cause := range statusErr.ErrStatus.Details.Causes {
+		actual = append(actual, cause.Field)
+	}
+	assert.Equal(t, fields, actual)
+}
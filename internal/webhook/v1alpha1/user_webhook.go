@@ -0,0 +1,96 @@
+/*
+Copyright 2024 kde authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/linuxsuren/kde/api/linuxsuren.github.io/v1alpha1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// SetupUserWebhookWithManager registers the webhook for User in the manager.
+func SetupUserWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).For(&v1alpha1.User{}).
+		WithValidator(&UserCustomValidator{}).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/validate-linuxsuren-github-io-v1alpha1-user,mutating=false,failurePolicy=fail,sideEffects=None,groups=linuxsuren.github.io,resources=users,verbs=create;update,versions=v1alpha1,name=vuser-v1alpha1.kb.io,admissionReviewVersions=v1
+
+// UserCustomValidator rejects the invalid Users.
+type UserCustomValidator struct{}
+
+var _ webhook.CustomValidator = &UserCustomValidator{}
+
+// ValidateCreate implements webhook.CustomValidator so a webhook will be registered for the type User.
+func (v *UserCustomValidator) ValidateCreate(_ context.Context, obj runtime.Object) (admission.Warnings, error) {
+	user, ok := obj.(*v1alpha1.User)
+	if !ok {
+		return nil, fmt.Errorf("expected a User object but got %T", obj)
+	}
+	return nil, validateUser(user)
+}
+
+// ValidateUpdate implements webhook.CustomValidator so a webhook will be registered for the type User.
+func (v *UserCustomValidator) ValidateUpdate(_ context.Context, _, newObj runtime.Object) (admission.Warnings, error) {
+	user, ok := newObj.(*v1alpha1.User)
+	if !ok {
+		return nil, fmt.Errorf("expected a User object for the newObj but got %T", newObj)
+	}
+	return nil, validateUser(user)
+}
+
+// ValidateDelete implements webhook.CustomValidator so a webhook will be registered for the type User.
+func (v *UserCustomValidator) ValidateDelete(_ context.Context, _ runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+func validateUser(user *v1alpha1.User) error {
+	var allErrs field.ErrorList
+	specPath := field.NewPath("spec")
+
+	groups := make(map[string]bool, len(user.Spec.Groups))
+	for i, group := range user.Spec.Groups {
+		groupPath := specPath.Child("groups").Index(i)
+		switch {
+		case group == "":
+			allErrs = append(allErrs, field.Required(groupPath, "group name cannot be empty"))
+		case groups[group]:
+			allErrs = append(allErrs, field.Duplicate(groupPath, group))
+		}
+		groups[group] = true
+	}
+
+	hardPath := specPath.Child("resourceQuota", "spec", "hard")
+	for name, quantity := range user.Spec.ResourceQuota.Spec.Hard {
+		if quantity.Sign() < 0 {
+			allErrs = append(allErrs, field.Invalid(hardPath.Key(string(name)), quantity.String(), "must not be negative"))
+		}
+	}
+
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(v1alpha1.GroupVersion.WithKind("User").GroupKind(), user.Name, allErrs)
+}
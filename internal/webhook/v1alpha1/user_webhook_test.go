@@ -0,0 +1,79 @@
+/*
+Copyright 2024 kde authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"testing"
+
+	"github.com/linuxsuren/kde/api/linuxsuren.github.io/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestUserValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		spec   v1alpha1.UserSpec
+		fields []string
+	}{{
+		name: "valid",
+		spec: v1alpha1.UserSpec{
+			Username: "rick",
+			Groups:   []string{"dev", "admin"},
+			ResourceQuota: corev1.ResourceQuota{Spec: corev1.ResourceQuotaSpec{
+				Hard: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("8")},
+			}},
+		},
+	}, {
+		name: "invalid groups",
+		spec: v1alpha1.UserSpec{
+			Groups: []string{"dev", "", "dev"},
+		},
+		fields: []string{"spec.groups[1]", "spec.groups[2]"},
+	}, {
+		name: "negative quota",
+		spec: v1alpha1.UserSpec{
+			ResourceQuota: corev1.ResourceQuota{Spec: corev1.ResourceQuotaSpec{
+				Hard: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("-1Gi")},
+			}},
+		},
+		fields: []string{"spec.resourceQuota.spec.hard[memory]"},
+	}}
+	validator := &UserCustomValidator{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := &v1alpha1.User{
+				ObjectMeta: metav1.ObjectMeta{Name: "rick"},
+				Spec:       tt.spec,
+			}
+
+			_, err := validator.ValidateCreate(context.Background(), user)
+			assertFieldErrors(t, err, tt.fields)
+
+			_, err = validator.ValidateUpdate(context.Background(), user, user)
+			assertFieldErrors(t, err, tt.fields)
+		})
+	}
+
+	t.Run("unexpected object", func(t *testing.T) {
+		_, err := validator.ValidateCreate(context.Background(), &v1alpha1.DevSpace{})
+		assert.Error(t, err)
+	})
+}
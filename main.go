@@ -18,6 +18,7 @@ package main
 
 import (
 	"os"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/linuxsuren/kde/internal/apiserver"
@@ -103,7 +104,11 @@ func (o *option) runE(cmd *cobra.Command, args []string) {
 		SystemNamespace: o.systemNamespace,
 	}
 
-	r := gin.Default()
+	r := gin.New()
+	// the mirrored requests are as many as the IDE requests, and they carry the activity token
+	r.Use(gin.LoggerWithConfig(gin.LoggerConfig{Skip: func(c *gin.Context) bool {
+		return c.Request.URL.Path == "/activity" || strings.HasPrefix(c.Request.URL.Path, "/wakeup/")
+	}}), gin.Recovery())
 	apiserver.RegisterStaticFilesHandle(r.Use(func(ctx *gin.Context) {
 		ctx.Set("reader", kdeui.NewembedReader())
 	}))
@@ -112,6 +117,10 @@ func (o *option) runE(cmd *cobra.Command, args []string) {
 	}
 	apiserver.RegisterHealthEndpoint(r)
 	r.POST("/webhook", server.IDEWebhook)
+	r.Any("/activity", server.DevSpaceActivity)
+	r.GET("/wakeup/:namespace/:devspace", server.WakeUpDevSpace)
+	r.GET("/auth/devspace", server.DevSpaceForwardAuth)
+	r.GET("/auth/devspace/signin", server.DevSpaceSignIn)
 
 	authorizedAPI := r.Group("/api", apiserver.OAuthHandler(o.providerName))
 	authorizedAPI.GET("/devspace", server.ListDevSpace)
@@ -120,8 +129,14 @@ func (o *option) runE(cmd *cobra.Command, args []string) {
 	authorizedAPI.PUT("/devspace/:devspace", server.UpdateDevSpace)
 	authorizedAPI.PUT("/devspace/:devspace/restart", server.RestartDevSpace)
 	authorizedAPI.PUT("/devspace/:devspace/replicas", server.SetDevSpaceReplicas)
+	authorizedAPI.POST("/devspace/:devspace/extend", server.ExtendDevSpace)
+	authorizedAPI.POST("/devspace/:devspace/suspend", server.SuspendDevSpace)
+	authorizedAPI.DELETE("/devspace/:devspace/scheduleOverride", server.CancelScheduleOverride)
+	authorizedAPI.POST("/devspace/:devspace/clone", server.CloneDevSpace)
 	authorizedAPI.GET("/devspace/:devspace", server.GetDevSpace)
 	authorizedAPI.GET("/languages", server.GetDevSpaceLanguages)
+	authorizedAPI.GET("/templates", server.ListTemplates)
+	authorizedAPI.POST("/templates/preview", server.PreviewTemplates)
 	authorizedAPI.GET("/serverImages", server.ServerImages)
 	authorizedAPI.POST("/install", server.Install)
 	authorizedAPI.DELETE("/uninstall", server.Uninstall)
@@ -23,6 +23,7 @@ import (
 	"net/http"
 
 	linuxsurenv1alpha1 "github.com/linuxsuren/kde/pkg/client/clientset/versioned/typed/linuxsuren.github.io/v1alpha1"
+	linuxsurenv1beta1 "github.com/linuxsuren/kde/pkg/client/clientset/versioned/typed/linuxsuren.github.io/v1beta1"
 	discovery "k8s.io/client-go/discovery"
 	rest "k8s.io/client-go/rest"
 	flowcontrol "k8s.io/client-go/util/flowcontrol"
@@ -31,12 +32,14 @@ import (
 type Interface interface {
 	Discovery() discovery.DiscoveryInterface
 	LinuxsurenV1alpha1() linuxsurenv1alpha1.LinuxsurenV1alpha1Interface
+	LinuxsurenV1beta1() linuxsurenv1beta1.LinuxsurenV1beta1Interface
 }
 
 // Clientset contains the clients for groups.
 type Clientset struct {
 	*discovery.DiscoveryClient
 	linuxsurenV1alpha1 *linuxsurenv1alpha1.LinuxsurenV1alpha1Client
+	linuxsurenV1beta1  *linuxsurenv1beta1.LinuxsurenV1beta1Client
 }
 
 // LinuxsurenV1alpha1 retrieves the LinuxsurenV1alpha1Client
@@ -44,6 +47,11 @@ func (c *Clientset) LinuxsurenV1alpha1() linuxsurenv1alpha1.LinuxsurenV1alpha1In
 	return c.linuxsurenV1alpha1
 }
 
+// LinuxsurenV1beta1 retrieves the LinuxsurenV1beta1Client
+func (c *Clientset) LinuxsurenV1beta1() linuxsurenv1beta1.LinuxsurenV1beta1Interface {
+	return c.linuxsurenV1beta1
+}
+
 // Discovery retrieves the DiscoveryClient
 func (c *Clientset) Discovery() discovery.DiscoveryInterface {
 	if c == nil {
@@ -92,6 +100,10 @@ func NewForConfigAndClient(c *rest.Config, httpClient *http.Client) (*Clientset,
 	if err != nil {
 		return nil, err
 	}
+	cs.linuxsurenV1beta1, err = linuxsurenv1beta1.NewForConfigAndClient(&configShallowCopy, httpClient)
+	if err != nil {
+		return nil, err
+	}
 
 	cs.DiscoveryClient, err = discovery.NewDiscoveryClientForConfigAndClient(&configShallowCopy, httpClient)
 	if err != nil {
@@ -114,6 +126,7 @@ func NewForConfigOrDie(c *rest.Config) *Clientset {
 func New(c rest.Interface) *Clientset {
 	var cs Clientset
 	cs.linuxsurenV1alpha1 = linuxsurenv1alpha1.New(c)
+	cs.linuxsurenV1beta1 = linuxsurenv1beta1.New(c)
 
 	cs.DiscoveryClient = discovery.NewDiscoveryClient(c)
 	return &cs
@@ -22,6 +22,8 @@ import (
 	clientset "github.com/linuxsuren/kde/pkg/client/clientset/versioned"
 	linuxsurenv1alpha1 "github.com/linuxsuren/kde/pkg/client/clientset/versioned/typed/linuxsuren.github.io/v1alpha1"
 	fakelinuxsurenv1alpha1 "github.com/linuxsuren/kde/pkg/client/clientset/versioned/typed/linuxsuren.github.io/v1alpha1/fake"
+	linuxsurenv1beta1 "github.com/linuxsuren/kde/pkg/client/clientset/versioned/typed/linuxsuren.github.io/v1beta1"
+	fakelinuxsurenv1beta1 "github.com/linuxsuren/kde/pkg/client/clientset/versioned/typed/linuxsuren.github.io/v1beta1/fake"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/discovery"
@@ -83,3 +85,8 @@ var (
 func (c *Clientset) LinuxsurenV1alpha1() linuxsurenv1alpha1.LinuxsurenV1alpha1Interface {
 	return &fakelinuxsurenv1alpha1.FakeLinuxsurenV1alpha1{Fake: &c.Fake}
 }
+
+// LinuxsurenV1beta1 retrieves the LinuxsurenV1beta1Client
+func (c *Clientset) LinuxsurenV1beta1() linuxsurenv1beta1.LinuxsurenV1beta1Interface {
+	return &fakelinuxsurenv1beta1.FakeLinuxsurenV1beta1{Fake: &c.Fake}
+}
@@ -0,0 +1,195 @@
+/*
+Copyright 2024 kde authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Code generated by client-gen. DO NOT EDIT.
+
+package v1beta1
+
+import (
+	"context"
+	"time"
+
+	v1beta1 "github.com/linuxsuren/kde/api/linuxsuren.github.io/v1beta1"
+	scheme "github.com/linuxsuren/kde/pkg/client/clientset/versioned/scheme"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	rest "k8s.io/client-go/rest"
+)
+
+// DevSpacesGetter has a method to return a DevSpaceInterface.
+// A group's client should implement this interface.
+type DevSpacesGetter interface {
+	DevSpaces(namespace string) DevSpaceInterface
+}
+
+// DevSpaceInterface has methods to work with DevSpace resources.
+type DevSpaceInterface interface {
+	Create(ctx context.Context, devSpace *v1beta1.DevSpace, opts v1.CreateOptions) (*v1beta1.DevSpace, error)
+	Update(ctx context.Context, devSpace *v1beta1.DevSpace, opts v1.UpdateOptions) (*v1beta1.DevSpace, error)
+	UpdateStatus(ctx context.Context, devSpace *v1beta1.DevSpace, opts v1.UpdateOptions) (*v1beta1.DevSpace, error)
+	Delete(ctx context.Context, name string, opts v1.DeleteOptions) error
+	DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error
+	Get(ctx context.Context, name string, opts v1.GetOptions) (*v1beta1.DevSpace, error)
+	List(ctx context.Context, opts v1.ListOptions) (*v1beta1.DevSpaceList, error)
+	Watch(ctx context.Context, opts v1.ListOptions) (watch.Interface, error)
+	Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts v1.PatchOptions, subresources ...string) (result *v1beta1.DevSpace, err error)
+	DevSpaceExpansion
+}
+
+// devSpaces implements DevSpaceInterface
+type devSpaces struct {
+	client rest.Interface
+	ns     string
+}
+
+// newDevSpaces returns a DevSpaces
+func newDevSpaces(c *LinuxsurenV1beta1Client, namespace string) *devSpaces {
+	return &devSpaces{
+		client: c.RESTClient(),
+		ns:     namespace,
+	}
+}
+
+// Get takes name of the devSpace, and returns the corresponding devSpace object, and an error if there is any.
+func (c *devSpaces) Get(ctx context.Context, name string, options v1.GetOptions) (result *v1beta1.DevSpace, err error) {
+	result = &v1beta1.DevSpace{}
+	err = c.client.Get().
+		Namespace(c.ns).
+		Resource("devspaces").
+		Name(name).
+		VersionedParams(&options, scheme.ParameterCodec).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// List takes label and field selectors, and returns the list of DevSpaces that match those selectors.
+func (c *devSpaces) List(ctx context.Context, opts v1.ListOptions) (result *v1beta1.DevSpaceList, err error) {
+	var timeout time.Duration
+	if opts.TimeoutSeconds != nil {
+		timeout = time.Duration(*opts.TimeoutSeconds) * time.Second
+	}
+	result = &v1beta1.DevSpaceList{}
+	err = c.client.Get().
+		Namespace(c.ns).
+		Resource("devspaces").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// Watch returns a watch.Interface that watches the requested devSpaces.
+func (c *devSpaces) Watch(ctx context.Context, opts v1.ListOptions) (watch.Interface, error) {
+	var timeout time.Duration
+	if opts.TimeoutSeconds != nil {
+		timeout = time.Duration(*opts.TimeoutSeconds) * time.Second
+	}
+	opts.Watch = true
+	return c.client.Get().
+		Namespace(c.ns).
+		Resource("devspaces").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Watch(ctx)
+}
+
+// Create takes the representation of a devSpace and creates it.  Returns the server's representation of the devSpace, and an error, if there is any.
+func (c *devSpaces) Create(ctx context.Context, devSpace *v1beta1.DevSpace, opts v1.CreateOptions) (result *v1beta1.DevSpace, err error) {
+	result = &v1beta1.DevSpace{}
+	err = c.client.Post().
+		Namespace(c.ns).
+		Resource("devspaces").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(devSpace).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// Update takes the representation of a devSpace and updates it. Returns the server's representation of the devSpace, and an error, if there is any.
+func (c *devSpaces) Update(ctx context.Context, devSpace *v1beta1.DevSpace, opts v1.UpdateOptions) (result *v1beta1.DevSpace, err error) {
+	result = &v1beta1.DevSpace{}
+	err = c.client.Put().
+		Namespace(c.ns).
+		Resource("devspaces").
+		Name(devSpace.Name).
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(devSpace).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// UpdateStatus was generated because the type contains a Status member.
+// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+func (c *devSpaces) UpdateStatus(ctx context.Context, devSpace *v1beta1.DevSpace, opts v1.UpdateOptions) (result *v1beta1.DevSpace, err error) {
+	result = &v1beta1.DevSpace{}
+	err = c.client.Put().
+		Namespace(c.ns).
+		Resource("devspaces").
+		Name(devSpace.Name).
+		SubResource("status").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(devSpace).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// Delete takes name of the devSpace and deletes it. Returns an error if one occurs.
+func (c *devSpaces) Delete(ctx context.Context, name string, opts v1.DeleteOptions) error {
+	return c.client.Delete().
+		Namespace(c.ns).
+		Resource("devspaces").
+		Name(name).
+		Body(&opts).
+		Do(ctx).
+		Error()
+}
+
+// DeleteCollection deletes a collection of objects.
+func (c *devSpaces) DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error {
+	var timeout time.Duration
+	if listOpts.TimeoutSeconds != nil {
+		timeout = time.Duration(*listOpts.TimeoutSeconds) * time.Second
+	}
+	return c.client.Delete().
+		Namespace(c.ns).
+		Resource("devspaces").
+		VersionedParams(&listOpts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Body(&opts).
+		Do(ctx).
+		Error()
+}
+
+// Patch applies the patch and returns the patched devSpace.
+func (c *devSpaces) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts v1.PatchOptions, subresources ...string) (result *v1beta1.DevSpace, err error) {
+	result = &v1beta1.DevSpace{}
+	err = c.client.Patch(pt).
+		Namespace(c.ns).
+		Resource("devspaces").
+		Name(name).
+		SubResource(subresources...).
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(data).
+		Do(ctx).
+		Into(result)
+	return
+}
@@ -0,0 +1,141 @@
+/*
+Copyright 2024 kde authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Code generated by client-gen. DO NOT EDIT.
+
+package fake
+
+import (
+	"context"
+
+	v1beta1 "github.com/linuxsuren/kde/api/linuxsuren.github.io/v1beta1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	labels "k8s.io/apimachinery/pkg/labels"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	testing "k8s.io/client-go/testing"
+)
+
+// FakeDevSpaces implements DevSpaceInterface
+type FakeDevSpaces struct {
+	Fake *FakeLinuxsurenV1beta1
+	ns   string
+}
+
+var devspacesResource = v1beta1.SchemeGroupVersion.WithResource("devspaces")
+
+var devspacesKind = v1beta1.SchemeGroupVersion.WithKind("DevSpace")
+
+// Get takes name of the devSpace, and returns the corresponding devSpace object, and an error if there is any.
+func (c *FakeDevSpaces) Get(ctx context.Context, name string, options v1.GetOptions) (result *v1beta1.DevSpace, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewGetAction(devspacesResource, c.ns, name), &v1beta1.DevSpace{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1beta1.DevSpace), err
+}
+
+// List takes label and field selectors, and returns the list of DevSpaces that match those selectors.
+func (c *FakeDevSpaces) List(ctx context.Context, opts v1.ListOptions) (result *v1beta1.DevSpaceList, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewListAction(devspacesResource, devspacesKind, c.ns, opts), &v1beta1.DevSpaceList{})
+
+	if obj == nil {
+		return nil, err
+	}
+
+	label, _, _ := testing.ExtractFromListOptions(opts)
+	if label == nil {
+		label = labels.Everything()
+	}
+	list := &v1beta1.DevSpaceList{ListMeta: obj.(*v1beta1.DevSpaceList).ListMeta}
+	for _, item := range obj.(*v1beta1.DevSpaceList).Items {
+		if label.Matches(labels.Set(item.Labels)) {
+			list.Items = append(list.Items, item)
+		}
+	}
+	return list, err
+}
+
+// Watch returns a watch.Interface that watches the requested devSpaces.
+func (c *FakeDevSpaces) Watch(ctx context.Context, opts v1.ListOptions) (watch.Interface, error) {
+	return c.Fake.
+		InvokesWatch(testing.NewWatchAction(devspacesResource, c.ns, opts))
+
+}
+
+// Create takes the representation of a devSpace and creates it.  Returns the server's representation of the devSpace, and an error, if there is any.
+func (c *FakeDevSpaces) Create(ctx context.Context, devSpace *v1beta1.DevSpace, opts v1.CreateOptions) (result *v1beta1.DevSpace, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewCreateAction(devspacesResource, c.ns, devSpace), &v1beta1.DevSpace{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1beta1.DevSpace), err
+}
+
+// Update takes the representation of a devSpace and updates it. Returns the server's representation of the devSpace, and an error, if there is any.
+func (c *FakeDevSpaces) Update(ctx context.Context, devSpace *v1beta1.DevSpace, opts v1.UpdateOptions) (result *v1beta1.DevSpace, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewUpdateAction(devspacesResource, c.ns, devSpace), &v1beta1.DevSpace{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1beta1.DevSpace), err
+}
+
+// UpdateStatus was generated because the type contains a Status member.
+// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+func (c *FakeDevSpaces) UpdateStatus(ctx context.Context, devSpace *v1beta1.DevSpace, opts v1.UpdateOptions) (*v1beta1.DevSpace, error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewUpdateSubresourceAction(devspacesResource, "status", c.ns, devSpace), &v1beta1.DevSpace{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1beta1.DevSpace), err
+}
+
+// Delete takes name of the devSpace and deletes it. Returns an error if one occurs.
+func (c *FakeDevSpaces) Delete(ctx context.Context, name string, opts v1.DeleteOptions) error {
+	_, err := c.Fake.
+		Invokes(testing.NewDeleteActionWithOptions(devspacesResource, c.ns, name, opts), &v1beta1.DevSpace{})
+
+	return err
+}
+
+// DeleteCollection deletes a collection of objects.
+func (c *FakeDevSpaces) DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error {
+	action := testing.NewDeleteCollectionAction(devspacesResource, c.ns, listOpts)
+
+	_, err := c.Fake.Invokes(action, &v1beta1.DevSpaceList{})
+	return err
+}
+
+// Patch applies the patch and returns the patched devSpace.
+func (c *FakeDevSpaces) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts v1.PatchOptions, subresources ...string) (result *v1beta1.DevSpace, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewPatchSubresourceAction(devspacesResource, c.ns, name, pt, data, subresources...), &v1beta1.DevSpace{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1beta1.DevSpace), err
+}
@@ -22,6 +22,7 @@ import (
 	"fmt"
 
 	v1alpha1 "github.com/linuxsuren/kde/api/linuxsuren.github.io/v1alpha1"
+	v1beta1 "github.com/linuxsuren/kde/api/linuxsuren.github.io/v1beta1"
 	schema "k8s.io/apimachinery/pkg/runtime/schema"
 	cache "k8s.io/client-go/tools/cache"
 )
@@ -56,6 +57,10 @@ func (f *sharedInformerFactory) ForResource(resource schema.GroupVersionResource
 	case v1alpha1.SchemeGroupVersion.WithResource("devspaces"):
 		return &genericInformer{resource: resource.GroupResource(), informer: f.Linuxsuren().V1alpha1().DevSpaces().Informer()}, nil
 
+		// Group=linuxsuren.github.io, Version=v1beta1
+	case v1beta1.SchemeGroupVersion.WithResource("devspaces"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Linuxsuren().V1beta1().DevSpaces().Informer()}, nil
+
 	}
 
 	return nil, fmt.Errorf("no informer found for %v", resource)
@@ -0,0 +1,90 @@
+/*
+Copyright 2024 kde authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Code generated by informer-gen. DO NOT EDIT.
+
+package v1beta1
+
+import (
+	"context"
+	time "time"
+
+	linuxsurengithubiov1beta1 "github.com/linuxsuren/kde/api/linuxsuren.github.io/v1beta1"
+	versioned "github.com/linuxsuren/kde/pkg/client/clientset/versioned"
+	internalinterfaces "github.com/linuxsuren/kde/pkg/client/informers/externalversions/internalinterfaces"
+	v1beta1 "github.com/linuxsuren/kde/pkg/client/listers/linuxsuren.github.io/v1beta1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtime "k8s.io/apimachinery/pkg/runtime"
+	watch "k8s.io/apimachinery/pkg/watch"
+	cache "k8s.io/client-go/tools/cache"
+)
+
+// DevSpaceInformer provides access to a shared informer and lister for
+// DevSpaces.
+type DevSpaceInformer interface {
+	Informer() cache.SharedIndexInformer
+	Lister() v1beta1.DevSpaceLister
+}
+
+type devSpaceInformer struct {
+	factory          internalinterfaces.SharedInformerFactory
+	tweakListOptions internalinterfaces.TweakListOptionsFunc
+	namespace        string
+}
+
+// NewDevSpaceInformer constructs a new informer for DevSpace type.
+// Always prefer using an informer factory to get a shared informer instead of getting an independent
+// one. This reduces memory footprint and number of connections to the server.
+func NewDevSpaceInformer(client versioned.Interface, namespace string, resyncPeriod time.Duration, indexers cache.Indexers) cache.SharedIndexInformer {
+	return NewFilteredDevSpaceInformer(client, namespace, resyncPeriod, indexers, nil)
+}
+
+// NewFilteredDevSpaceInformer constructs a new informer for DevSpace type.
+// Always prefer using an informer factory to get a shared informer instead of getting an independent
+// one. This reduces memory footprint and number of connections to the server.
+func NewFilteredDevSpaceInformer(client versioned.Interface, namespace string, resyncPeriod time.Duration, indexers cache.Indexers, tweakListOptions internalinterfaces.TweakListOptionsFunc) cache.SharedIndexInformer {
+	return cache.NewSharedIndexInformer(
+		&cache.ListWatch{
+			ListFunc: func(options v1.ListOptions) (runtime.Object, error) {
+				if tweakListOptions != nil {
+					tweakListOptions(&options)
+				}
+				return client.LinuxsurenV1beta1().DevSpaces(namespace).List(context.TODO(), options)
+			},
+			WatchFunc: func(options v1.ListOptions) (watch.Interface, error) {
+				if tweakListOptions != nil {
+					tweakListOptions(&options)
+				}
+				return client.LinuxsurenV1beta1().DevSpaces(namespace).Watch(context.TODO(), options)
+			},
+		},
+		&linuxsurengithubiov1beta1.DevSpace{},
+		resyncPeriod,
+		indexers,
+	)
+}
+
+func (f *devSpaceInformer) defaultInformer(client versioned.Interface, resyncPeriod time.Duration) cache.SharedIndexInformer {
+	return NewFilteredDevSpaceInformer(client, f.namespace, resyncPeriod, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc}, f.tweakListOptions)
+}
+
+func (f *devSpaceInformer) Informer() cache.SharedIndexInformer {
+	return f.factory.InformerFor(&linuxsurengithubiov1beta1.DevSpace{}, f.defaultInformer)
+}
+
+func (f *devSpaceInformer) Lister() v1beta1.DevSpaceLister {
+	return v1beta1.NewDevSpaceLister(f.Informer().GetIndexer())
+}
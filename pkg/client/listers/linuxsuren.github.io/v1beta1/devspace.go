@@ -0,0 +1,99 @@
+/*
+Copyright 2024 kde authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Code generated by lister-gen. DO NOT EDIT.
+
+package v1beta1
+
+import (
+	v1beta1 "github.com/linuxsuren/kde/api/linuxsuren.github.io/v1beta1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+)
+
+// DevSpaceLister helps list DevSpaces.
+// All objects returned here must be treated as read-only.
+type DevSpaceLister interface {
+	// List lists all DevSpaces in the indexer.
+	// Objects returned here must be treated as read-only.
+	List(selector labels.Selector) (ret []*v1beta1.DevSpace, err error)
+	// DevSpaces returns an object that can list and get DevSpaces.
+	DevSpaces(namespace string) DevSpaceNamespaceLister
+	DevSpaceListerExpansion
+}
+
+// devSpaceLister implements the DevSpaceLister interface.
+type devSpaceLister struct {
+	indexer cache.Indexer
+}
+
+// NewDevSpaceLister returns a new DevSpaceLister.
+func NewDevSpaceLister(indexer cache.Indexer) DevSpaceLister {
+	return &devSpaceLister{indexer: indexer}
+}
+
+// List lists all DevSpaces in the indexer.
+func (s *devSpaceLister) List(selector labels.Selector) (ret []*v1beta1.DevSpace, err error) {
+	err = cache.ListAll(s.indexer, selector, func(m interface{}) {
+		ret = append(ret, m.(*v1beta1.DevSpace))
+	})
+	return ret, err
+}
+
+// DevSpaces returns an object that can list and get DevSpaces.
+func (s *devSpaceLister) DevSpaces(namespace string) DevSpaceNamespaceLister {
+	return devSpaceNamespaceLister{indexer: s.indexer, namespace: namespace}
+}
+
+// DevSpaceNamespaceLister helps list and get DevSpaces.
+// All objects returned here must be treated as read-only.
+type DevSpaceNamespaceLister interface {
+	// List lists all DevSpaces in the indexer for a given namespace.
+	// Objects returned here must be treated as read-only.
+	List(selector labels.Selector) (ret []*v1beta1.DevSpace, err error)
+	// Get retrieves the DevSpace from the indexer for a given namespace and name.
+	// Objects returned here must be treated as read-only.
+	Get(name string) (*v1beta1.DevSpace, error)
+	DevSpaceNamespaceListerExpansion
+}
+
+// devSpaceNamespaceLister implements the DevSpaceNamespaceLister
+// interface.
+type devSpaceNamespaceLister struct {
+	indexer   cache.Indexer
+	namespace string
+}
+
+// List lists all DevSpaces in the indexer for a given namespace.
+func (s devSpaceNamespaceLister) List(selector labels.Selector) (ret []*v1beta1.DevSpace, err error) {
+	err = cache.ListAllByNamespace(s.indexer, s.namespace, selector, func(m interface{}) {
+		ret = append(ret, m.(*v1beta1.DevSpace))
+	})
+	return ret, err
+}
+
+// Get retrieves the DevSpace from the indexer for a given namespace and name.
+func (s devSpaceNamespaceLister) Get(name string) (*v1beta1.DevSpace, error) {
+	obj, exists, err := s.indexer.GetByKey(s.namespace + "/" + name)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errors.NewNotFound(v1beta1.Resource("devspace"), name)
+	}
+	return obj.(*v1beta1.DevSpace), nil
+}
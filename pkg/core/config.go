@@ -20,6 +20,9 @@ import (
 	"encoding/json"
 	"os"
 	"strings"
+	"time"
+
+	"github.com/linuxsuren/kde/api/linuxsuren.github.io/v1alpha1"
 )
 
 type Config struct {
@@ -30,11 +33,68 @@ type Config struct {
 	ImagePullPolicy  string     `json:"imagePullPolicy"`
 	Host             string     `json:"host"`
 	Languages        []Language `json:"languages"`
+	// IdleTimeout is a duration string (e.g. 2h), the DevSpaces will be suspended
+	// after being idle for this long. Empty or zero disables the idle detection.
+	IdleTimeout string `json:"idleTimeout,omitempty"`
+	// KnownHosts are the lines of known_hosts for all the DevSpaces, e.g. the host keys of the internal git server
+	KnownHosts []string `json:"knownHosts,omitempty"`
+	// OvercommitRatio derives the requests from the limits of the containers which do not give the requests,
+	// e.g. the CPU request is 500m for a limit of 2 when it is 4. The requests equal the limits if it is not greater than 1.
+	OvercommitRatio float64 `json:"overcommitRatio,omitempty"`
+	// Scheduling is the default scheduling of all the DevSpaces
+	Scheduling v1alpha1.Scheduling `json:"scheduling,omitempty"`
+	// SecurityProfile is the default security profile of the DevSpaces, it is also the least strict one
+	// which a DevSpace may choose. Empty means privileged.
+	SecurityProfile v1alpha1.SecurityProfile `json:"securityProfile,omitempty"`
+	// NetworkPolicy is the default network policy of the DevSpaces, a DevSpace overrides the given fields
+	NetworkPolicy v1alpha1.NetworkPolicy `json:"networkPolicy,omitempty"`
+	// IngressProvider routes the requests to the DevSpaces, it is one of nginx, traefik and gateway.
+	// It is nginx if it is empty, the ingress mode only tells whether the DevSpaces are routed by the path or the host.
+	IngressProvider string `json:"ingressProvider,omitempty"`
+	// Gateway is the parent of the HTTPRoutes of the gateway provider
+	Gateway GatewayReference `json:"gateway,omitempty"`
+	// IngressControllerNamespace is where the ingress controller runs if it is not the usual namespace of the provider,
+	// e.g. traefik. The NetworkPolicies of the DevSpaces let the requests from it in.
+	IngressControllerNamespace string `json:"ingressControllerNamespace,omitempty"`
+	// TLS serves the DevSpaces over HTTPS
+	TLS TLS `json:"tls,omitempty"`
+	// ForwardAuth lets only the owners and the collaborators open the DevSpaces, they log in through the
+	// OAuth provider of the kde apiserver which is served on the Host. It takes precedence over the basic auth,
+	// and it is not supported by the gateway ingress provider.
+	ForwardAuth bool `json:"forwardAuth,omitempty"`
+}
+
+// TLS gives the certificates of the DevSpace hosts, the gateway provider leaves them to the listeners of the Gateway
+type TLS struct {
+	// SecretName is a wildcard certificate Secret in the system namespace, it is copied into the namespaces of the DevSpaces.
+	// It should cover the exposed hosts as well, e.g. *.demo.kde.example.com, which are one level deeper than the IDE ones.
+	// The private key is in every namespace of the DevSpaces, prefer the default certificate or cert-manager.
+	SecretName string `json:"secretName,omitempty"`
+	// DefaultCertificate serves the default certificate of the ingress controller, e.g. --default-ssl-certificate
+	// of ingress-nginx or the default TLSStore of Traefik, nothing is copied. It takes precedence over the secret.
+	DefaultCertificate bool `json:"defaultCertificate,omitempty"`
+	// Issuer and ClusterIssuer let cert-manager issue a certificate per DevSpace, they take precedence over the others
+	Issuer        string `json:"issuer,omitempty"`
+	ClusterIssuer string `json:"clusterIssuer,omitempty"`
+}
+
+// Enabled returns true if the DevSpaces are served over HTTPS
+func (t TLS) Enabled() bool {
+	return t.SecretName != "" || t.DefaultCertificate || t.Issuer != "" || t.ClusterIssuer != ""
+}
+
+// GatewayReference points to a Gateway of the Gateway API
+type GatewayReference struct {
+	Name string `json:"name"`
+	// Namespace is the system namespace if it is empty
+	Namespace string `json:"namespace,omitempty"`
 }
 
 type Language struct {
 	Name  string `json:"name"`
 	Image string `json:"image"`
+	// Scheduling is the default scheduling of the DevSpaces with this image, it takes precedence over the global one
+	Scheduling *v1alpha1.Scheduling `json:"scheduling,omitempty"`
 }
 
 func ReadConfigFromJSONFile(file string) (config *Config, err error) {
@@ -56,6 +116,16 @@ func (c *Config) ToJSON() ([]byte, error) {
 	return json.Marshal(c)
 }
 
+// GetIdleTimeout returns the parsed idle timeout, zero means disabled.
+func (c *Config) GetIdleTimeout() (timeout time.Duration) {
+	if c.IdleTimeout != "" {
+		if duration, err := time.ParseDuration(c.IdleTimeout); err == nil && duration > 0 {
+			timeout = duration
+		}
+	}
+	return
+}
+
 const ConfigFileName = "config.json"
 
 func CleanInvalidLanguages(languages []Language) []Language {
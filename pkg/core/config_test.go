@@ -17,6 +17,7 @@ package core_test
 
 import (
 	"testing"
+	"time"
 
 	_ "embed"
 
@@ -108,3 +109,10 @@ var sampleConfig = core.Config{
 
 //go:embed testdata/config.json
 var sampleConfigData []byte
+
+func TestGetIdleTimeout(t *testing.T) {
+	assert.Equal(t, time.Duration(0), (&core.Config{}).GetIdleTimeout())
+	assert.Equal(t, time.Duration(0), (&core.Config{IdleTimeout: "invalid"}).GetIdleTimeout())
+	assert.Equal(t, time.Duration(0), (&core.Config{IdleTimeout: "-1h"}).GetIdleTimeout())
+	assert.Equal(t, 2*time.Hour, (&core.Config{IdleTimeout: "2h"}).GetIdleTimeout())
+}
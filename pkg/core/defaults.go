@@ -0,0 +1,111 @@
+/*
+Copyright 2024 kde authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"github.com/linuxsuren/kde/api/linuxsuren.github.io/v1alpha1"
+)
+
+// the default values of a DevSpace
+const (
+	DefaultImage   = "ghcr.io/linuxsuren/openvscode-server-full:v0.0.8"
+	DefaultCPU     = "2"
+	DefaultMemory  = "4Gi"
+	DefaultStorage = "50Gi"
+
+	ImagePullPolicyAlways       = "Always"
+	ImagePullPolicyIfNotPresent = "IfNotPresent"
+)
+
+// legacyAnnotations maps the legacy annotation keys to the current ones
+var legacyAnnotations = map[string]string{
+	"storageClassName": v1alpha1.AnnoKeyStorageClassName,
+	"volumeMode":       v1alpha1.AnnoKeyVolumeMode,
+	"ingressMode":      v1alpha1.AnnoKeyIngressMode,
+}
+
+// SetDefaultDevSpace sets the default values of a DevSpace which is about to be created or updated.
+// The spec is left as it is if a template is referenced, the controller fills the
+// missing fields after merging the template.
+func SetDefaultDevSpace(devSpace *v1alpha1.DevSpace, config *Config) {
+	SetDefaultAnnotations(devSpace, config)
+	if devSpace.Spec.TemplateRef == nil {
+		SetDefaultSpec(&devSpace.Spec, config)
+	}
+}
+
+// SetDefaultAnnotations sets the annotations which come from the config if they are missing
+func SetDefaultAnnotations(devSpace *v1alpha1.DevSpace, config *Config) {
+	if config == nil {
+		config = &Config{}
+	}
+	if devSpace.Annotations == nil {
+		devSpace.Annotations = make(map[string]string)
+	}
+
+	// the templates used to read these keys, keep them working
+	for legacy, key := range legacyAnnotations {
+		if value, ok := devSpace.Annotations[legacy]; ok {
+			if _, exist := devSpace.Annotations[key]; !exist {
+				devSpace.Annotations[key] = value
+			}
+		}
+	}
+
+	defaults := map[string]string{
+		v1alpha1.AnnoKeyImagePullPolicy:  config.ImagePullPolicy,
+		v1alpha1.AnnoKeyStorageClassName: config.StorageClassName,
+		v1alpha1.AnnoKeyVolumeAccessMode: config.VolumeAccessMode,
+		v1alpha1.AnnoKeyVolumeMode:       config.VolumeMode,
+		v1alpha1.AnnoKeyIngressMode:      config.IngressMode,
+	}
+	for key, value := range defaults {
+		if _, ok := devSpace.Annotations[key]; !ok && value != "" {
+			devSpace.Annotations[key] = value
+		}
+	}
+
+	// only Always and IfNotPresent are supported
+	if devSpace.Annotations[v1alpha1.AnnoKeyImagePullPolicy] != ImagePullPolicyAlways {
+		devSpace.Annotations[v1alpha1.AnnoKeyImagePullPolicy] = ImagePullPolicyIfNotPresent
+	}
+}
+
+// SetDefaultSpec sets the default values of the spec fields
+func SetDefaultSpec(spec *v1alpha1.DevSpaceSpec, config *Config) {
+	if config == nil {
+		config = &Config{}
+	}
+	if spec.Host == "" {
+		spec.Host = config.Host
+	}
+	if spec.Image == "" {
+		spec.Image = DefaultImage
+	}
+	if spec.CPU == "" {
+		spec.CPU = DefaultCPU
+	}
+	if spec.Memory == "" {
+		spec.Memory = DefaultMemory
+	}
+	if spec.Storage == "" {
+		spec.Storage = DefaultStorage
+	}
+	if spec.SecurityProfile == "" {
+		spec.SecurityProfile = config.SecurityProfile
+	}
+}
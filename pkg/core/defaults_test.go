@@ -14,7 +14,7 @@ See the License for the specific language governing permissions and
 limitations under the License.
 */
 
-package apiserver
+package core_test
 
 import (
 	"testing"
@@ -24,9 +24,9 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
-func TestSetDefaultConfig(t *testing.T) {
+func TestSetDefaultDevSpace(t *testing.T) {
 	verify := func(t *testing.T, devspace *v1alpha1.DevSpace, config *core.Config) {
-		setDefaultConfig(devspace, config)
+		core.SetDefaultDevSpace(devspace, config)
 
 		assert.Equal(t, "Always", devspace.Annotations[v1alpha1.AnnoKeyImagePullPolicy])
 		assert.Equal(t, "standard", devspace.Annotations[v1alpha1.AnnoKeyStorageClassName])
@@ -48,9 +48,15 @@ func TestSetDefaultConfig(t *testing.T) {
 			VolumeMode:       "Filesystem",
 			IngressMode:      "nginx",
 			Host:             "another",
+			SecurityProfile:  v1alpha1.SecurityProfileBaseline,
 		}
 		verify(t, devspace, config)
 		assert.Equal(t, "localhost", devspace.Spec.Host)
+		assert.Equal(t, v1alpha1.SecurityProfileBaseline, devspace.Spec.SecurityProfile)
+		assert.Equal(t, core.DefaultImage, devspace.Spec.Image)
+		assert.Equal(t, core.DefaultCPU, devspace.Spec.CPU)
+		assert.Equal(t, core.DefaultMemory, devspace.Spec.Memory)
+		assert.Equal(t, core.DefaultStorage, devspace.Spec.Storage)
 	})
 
 	t.Run("annotations are not empty", func(t *testing.T) {
@@ -73,4 +79,37 @@ func TestSetDefaultConfig(t *testing.T) {
 		verify(t, devspace, config)
 		assert.Equal(t, "another", devspace.Spec.Host)
 	})
+
+	t.Run("unsupported image pull policy", func(t *testing.T) {
+		devspace := &v1alpha1.DevSpace{}
+		core.SetDefaultDevSpace(devspace, &core.Config{ImagePullPolicy: "Never"})
+		assert.Equal(t, "IfNotPresent", devspace.Annotations[v1alpha1.AnnoKeyImagePullPolicy])
+		assert.NotContains(t, devspace.Annotations, v1alpha1.AnnoKeyStorageClassName)
+	})
+
+	t.Run("the spec is left to the template", func(t *testing.T) {
+		devspace := &v1alpha1.DevSpace{
+			Spec: v1alpha1.DevSpaceSpec{
+				TemplateRef: &v1alpha1.TemplateReference{Name: "java"},
+			},
+		}
+		core.SetDefaultDevSpace(devspace, nil)
+		assert.Empty(t, devspace.Spec.Image)
+		assert.Empty(t, devspace.Spec.CPU)
+		assert.Equal(t, "IfNotPresent", devspace.Annotations[v1alpha1.AnnoKeyImagePullPolicy])
+	})
+
+	t.Run("legacy annotations", func(t *testing.T) {
+		devspace := &v1alpha1.DevSpace{}
+		devspace.Annotations = map[string]string{
+			"storageClassName":         "legacy",
+			"volumeMode":               "Block",
+			"ingressMode":              "path",
+			v1alpha1.AnnoKeyVolumeMode: "Filesystem",
+		}
+		core.SetDefaultDevSpace(devspace, &core.Config{StorageClassName: "standard"})
+		assert.Equal(t, "legacy", devspace.Annotations[v1alpha1.AnnoKeyStorageClassName])
+		assert.Equal(t, "Filesystem", devspace.Annotations[v1alpha1.AnnoKeyVolumeMode])
+		assert.Equal(t, "path", devspace.Annotations[v1alpha1.AnnoKeyIngressMode])
+	})
 }
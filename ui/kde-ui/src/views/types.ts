@@ -42,6 +42,7 @@ export interface DevSpace {
         },
         auth: {
             sshPrivateKey: string;
+            collaborators?: string[];
         };
         services: {
             docker: {
@@ -80,9 +81,18 @@ export interface DevSpace {
         phase: string;
         deployStatus: string;
         link: string;
+        services?: ServiceStatus[];
     };
 }
 
+export interface ServiceStatus {
+    name: string;
+    ready: boolean;
+    restartCount: number;
+    endpoints?: string[];
+    credentialsSecret?: string;
+}
+
 export function NewEmptyDevSpace() {
     return {
         metadata: {
@@ -146,6 +156,18 @@ export interface Config {
     imagePullPolicy: string;
     host: string;
     languages: Language[];
+    idleTimeout: string;
+    overcommitRatio?: number;
+    securityProfile?: string;
+    ingressProvider?: string;
+    ingressControllerNamespace?: string;
+    tls?: {
+        secretName?: string;
+        defaultCertificate?: boolean;
+        issuer?: string;
+        clusterIssuer?: string;
+    };
+    forwardAuth?: boolean;
 }
 
 export interface Cluster {